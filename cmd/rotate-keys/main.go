@@ -0,0 +1,7 @@
+package main
+
+import "necutya/faker/internal/app"
+
+func main() {
+	app.RotateSigningKeys("configs/")
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"necutya/faker/internal/config"
+	"necutya/faker/internal/service"
+	aiGrpcClient "necutya/faker/pkg/ai-grpc-client"
+	"necutya/faker/pkg/ensemble"
+	heuristicDetector "necutya/faker/pkg/heuristic-detector"
+	"necutya/faker/pkg/logger"
+)
+
+const (
+	grpcDetectorType      = "grpc"
+	heuristicDetectorType = "heuristic"
+
+	primaryDetectorName = "primary"
+
+	defaultHealthCheckTimeout = 5 * time.Second
+)
+
+// initAIManager builds the ensemble of detectors declared in config. Without declared detectors
+// the ensemble consists of the single AI service from the top-level config.
+// The returned function closes connections of gRPC detectors.
+func initAIManager(ctx context.Context, cfg config.AiServiceConfig) (*ensemble.Ensemble, func()) {
+	detectors := cfg.Detectors
+	if len(detectors) == 0 {
+		detectors = []config.AiDetectorConfig{{
+			Name: primaryDetectorName,
+			Type: grpcDetectorType,
+			Addr: cfg.Addr,
+			TLS:  cfg.TLS,
+		}}
+	}
+
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = ensemble.WeightedAverageStrategy
+	}
+
+	var (
+		members     = make([]ensemble.Member, 0, len(detectors))
+		grpcClients []*aiGrpcClient.AiGrpc
+		healthy     int
+	)
+
+	for _, detectorCfg := range detectors {
+		member := ensemble.Member{
+			Name:   detectorCfg.Name,
+			Weight: detectorCfg.Weight,
+		}
+
+		switch detectorCfg.Type {
+		case grpcDetectorType:
+			client, err := newAIGrpcClient(cfg, detectorCfg)
+			if err != nil {
+				logger.Fatalf("can`t create ai detector %s: %s", detectorCfg.Name, err.Error())
+			}
+
+			if err = checkAIServiceHealth(ctx, client, cfg.HealthCheckTimeout); err != nil {
+				logger.Errorf("ai detector %s is not healthy: %s", detectorCfg.Name, err.Error())
+			} else {
+				healthy++
+			}
+
+			grpcClients = append(grpcClients, client)
+			member.Detector = client
+
+		case heuristicDetectorType:
+			member.Detector = heuristicDetector.New()
+			healthy++
+
+		default:
+			logger.Fatalf("unknown type %q of ai detector %s", detectorCfg.Type, detectorCfg.Name)
+		}
+
+		members = append(members, member)
+	}
+
+	if healthy == 0 {
+		logger.Fatal("no healthy ai detectors")
+	}
+
+	aiManager, err := ensemble.New(strategy, cfg.ModelVersion, cfg.GeneratedThreshold, members...)
+	if err != nil {
+		logger.Fatal("can`t create ai detectors ensemble:", err.Error())
+	}
+
+	return aiManager, func() {
+		for _, client := range grpcClients {
+			if err := client.Close(); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// initLanguageAIManagers builds an ensemble for every language with a dedicated AI endpoint.
+// Settings not declared for the language are taken from the top-level config.
+func initLanguageAIManagers(ctx context.Context, cfg config.AiServiceConfig) (map[string]service.AIManager, func()) {
+	var (
+		aiManagers = make(map[string]service.AIManager, len(cfg.Languages))
+		closers    = make([]func(), 0, len(cfg.Languages))
+	)
+
+	for language, languageCfg := range cfg.Languages {
+		languageAICfg := cfg
+		languageAICfg.Detectors = languageCfg.Detectors
+
+		if languageCfg.Addr != "" {
+			languageAICfg.Addr = languageCfg.Addr
+		}
+
+		if languageCfg.TLS.Enabled {
+			languageAICfg.TLS = languageCfg.TLS
+		}
+
+		if languageCfg.Strategy != "" {
+			languageAICfg.Strategy = languageCfg.Strategy
+		}
+
+		aiManager, closeAIManager := initAIManager(ctx, languageAICfg)
+
+		aiManagers[language] = aiManager
+		closers = append(closers, closeAIManager)
+	}
+
+	return aiManagers, func() {
+		for _, closeAIManager := range closers {
+			closeAIManager()
+		}
+	}
+}
+
+// newAIGrpcClient creates a client for the detector, connection settings not declared
+// for the detector are taken from the top-level config.
+func newAIGrpcClient(cfg config.AiServiceConfig, detectorCfg config.AiDetectorConfig) (*aiGrpcClient.AiGrpc, error) {
+	addr := detectorCfg.Addr
+	if addr == "" {
+		addr = cfg.Addr
+	}
+
+	tls := detectorCfg.TLS
+	if !tls.Enabled {
+		tls = cfg.TLS
+	}
+
+	return aiGrpcClient.New(aiGrpcClient.Options{
+		Addr:         addr,
+		ModelVersion: cfg.ModelVersion,
+		TLS: aiGrpcClient.TLSOptions{
+			Enabled:    tls.Enabled,
+			CAFile:     tls.CAFile,
+			CertFile:   tls.CertFile,
+			KeyFile:    tls.KeyFile,
+			ServerName: tls.ServerName,
+		},
+		Timeout:          cfg.Timeout,
+		KeepaliveTime:    cfg.KeepaliveTime,
+		KeepaliveTimeout: cfg.KeepaliveTimeout,
+		MaxRetries:       cfg.MaxRetries,
+		RetryBaseDelay:   cfg.RetryBaseDelay,
+		BreakerThreshold: cfg.BreakerThreshold,
+		BreakerTimeout:   cfg.BreakerTimeout,
+	})
+}
+
+func checkAIServiceHealth(ctx context.Context, client *aiGrpcClient.AiGrpc, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return client.HealthCheck(ctx)
+}
@@ -10,12 +10,15 @@ import (
 	"syscall"
 
 	"necutya/faker/internal/config"
+	"necutya/faker/internal/domain/domain"
 	"necutya/faker/internal/repositories/mongo"
 	"necutya/faker/internal/service"
-	aiGrpcClient "necutya/faker/pkg/ai-grpc-client"
+	documentParser "necutya/faker/pkg/document-parser"
 	"necutya/faker/pkg/generators"
+	languageDetector "necutya/faker/pkg/language-detector"
 	notificationGrpcClient "necutya/faker/pkg/notification-grpc-client"
-	"necutya/faker/pkg/payments/fondy"
+	pdfWriter "necutya/faker/pkg/pdf-writer"
+	"necutya/faker/pkg/webhook"
 
 	"necutya/faker/internal/repositories/redis"
 	"necutya/faker/pkg/database/mongodb"
@@ -52,16 +55,18 @@ func Run(configPath string) {
 
 	database := mongoClient.Database(cfg.Mongo.DatabaseName)
 
-	jwtTokenManager, err := tokenManager.NewJWT(cfg.Token.SignKey)
-
-	paymentsManager := fondy.NewPaymentManager(
-		int64(cfg.Payments.MerchantID),
-		cfg.Payments.MerchantPassword,
-		cfg.Payments.Currency,
-		cfg.Payments.Language,
-		cfg.Payments.ResponseURL,
-		cfg.Payments.CallbackURL,
+	jwtTokenManager, err := tokenManager.NewJWT(
+		cfg.Token.Algorithm, cfg.Token.SignKey, cfg.Token.Issuer, cfg.Token.Audience, cfg.Token.HS256AcceptedUntil,
 	)
+	if err != nil {
+		logger.Fatal("can`t create token manager:", err.Error())
+	}
+
+	aiManager, closeAIManager := initAIManager(ctx, cfg.AI)
+	defer closeAIManager()
+
+	languageAIManagers, closeLanguageAIManagers := initLanguageAIManagers(ctx, cfg.AI)
+	defer closeLanguageAIManagers()
 
 	services := service.New(
 		mongo.NewUsersRepo(database),
@@ -72,32 +77,131 @@ func Run(configPath string) {
 		redis.NewBlacklistRepo(redisClient),
 		redis.NewRequestCounterRepo(redisClient),
 		redis.NewVerificationRepo(redisClient),
+		redis.NewCheckJobRepo(redisClient),
+		redis.NewCheckCacheRepo(redisClient),
+		mongo.NewAPIKeysRepo(database),
+		redis.NewMFAChallengeRepo(redisClient),
+		mongo.NewSettingsRepo(database),
+		redis.NewAttemptRepo(redisClient),
+		redis.NewOAuthStateRepo(redisClient),
+		mongo.NewSigningKeysRepo(database),
+		redis.NewMagicLinkRepo(redisClient),
+		mongo.NewRolesRepo(database),
+		mongo.NewSubscriptionsRepo(database),
+		mongo.NewInvoicesRepo(database),
 		hasher.NewBcryptHasher(),
 		jwtTokenManager,
-		aiGrpcClient.New(cfg.AI.Addr),
+		jwtTokenManager,
+		initKeyCipher(cfg.Token),
+		aiManager,
+		languageAIManagers,
 		notificationGrpcClient.New(cfg.Notification.Addr, cfg.Notification.From),
 		generators.NewRandomGenerator(),
-		paymentsManager,
+		initPaymentsManager(cfg.Payments),
+		documentParser.New(),
+		languageDetector.New(),
+		webhook.New(cfg.CheckJobs.WebhookTimeout, cfg.CheckJobs.WebhookMaxAttempts, cfg.CheckJobs.WebhookBaseDelay),
+		initOAuthProviders(cfg.OAuth),
+		initBreachedPasswords(cfg.PasswordPolicy),
 		cfg.Token.AccessTokenTTL,
 		cfg.Token.RefreshTokenTTL,
 		cfg.VerificationCodeTTL,
+		cfg.CheckJobs.JobTTL,
+		cfg.TwoFactor.ChallengeTTL,
+		service.AttemptLimits{
+			MaxPerEmail:     cfg.BruteForce.MaxPerEmail,
+			MaxPerIP:        cfg.BruteForce.MaxPerIP,
+			MaxCodeAttempts: cfg.BruteForce.MaxCodeAttempts,
+			Window:          cfg.BruteForce.Window,
+			LockoutTTL:      cfg.BruteForce.LockoutTTL,
+			BaseDelay:       cfg.BruteForce.BaseDelay,
+			MaxDelay:        cfg.BruteForce.MaxDelay,
+		},
+		service.EmailChangeSettings{
+			RevertURL: cfg.EmailChange.RevertURL,
+			RevertTTL: cfg.EmailChange.RevertTTL,
+		},
+		service.MagicLinkSettings{
+			Enabled: cfg.MagicLink.Enabled,
+			URL:     cfg.MagicLink.URL,
+			Secret:  cfg.MagicLink.Secret,
+			TTL:     cfg.MagicLink.TTL,
+		},
+		service.PasswordPolicy{
+			MinLength:          cfg.PasswordPolicy.MinLength,
+			MaxLength:          cfg.PasswordPolicy.MaxLength,
+			RequireUpper:       cfg.PasswordPolicy.RequireUpper,
+			RequireLower:       cfg.PasswordPolicy.RequireLower,
+			RequireDigit:       cfg.PasswordPolicy.RequireDigit,
+			RequireSymbol:      cfg.PasswordPolicy.RequireSymbol,
+			ForbidPersonalInfo: cfg.PasswordPolicy.ForbidPersonalInfo,
+		},
+		service.SubscriptionSettings{
+			RenewBefore:        cfg.Subscriptions.RenewBefore,
+			RetryInterval:      cfg.Subscriptions.RetryInterval,
+			MaxRenewalAttempts: cfg.Subscriptions.MaxRenewalAttempts,
+		},
+		service.InvoiceSettings{
+			Seller: domain.InvoiceParty{
+				Name:    cfg.Invoices.Seller.Name,
+				Address: cfg.Invoices.Seller.Address,
+				Email:   cfg.Invoices.Seller.Email,
+				TaxID:   cfg.Invoices.Seller.TaxID,
+			},
+			URL: cfg.Invoices.URL,
+		},
+		cfg.OAuth.StateTTL,
+		cfg.Token.KeysReloadInterval,
 		cfg.Feedbacks.Receiver,
+		cfg.TwoFactor.Issuer,
+		cfg.AI.BatchConcurrency,
+		cfg.AI.CacheTTL,
+		cfg.AI.CacheCaseInsensitive,
 	)
 
+	if err = services.SigningKey.Load(ctx); err != nil {
+		logger.Fatal("can`t load signing keys:", err.Error())
+	}
+
+	go services.SigningKey.Run(ctx)
+
+	if migrated, err := services.APIKey.MigrateExternalTokens(ctx); err != nil {
+		logger.Error("can`t migrate external tokens to api keys:", err.Error())
+	} else if migrated > 0 {
+		logger.Infof("migrated %d external tokens to api keys", migrated)
+	}
+
+	if migrated, err := services.Auth.MigrateRefreshTokens(ctx); err != nil {
+		logger.Error("can`t hash refresh tokens:", err.Error())
+	} else if migrated > 0 {
+		logger.Infof("hashed refresh tokens of %d sessions", migrated)
+	}
+
 	initCronJobs(cfg.Cron, services)
 
-	handlers := httpHandler.New(services, initValidator())
+	checkJobsDone := make(chan struct{})
+
+	go func() {
+		defer close(checkJobsDone)
+		services.CheckJob.Run(ctx, cfg.CheckJobs.Workers)
+	}()
 
-	httpServer := httpTransport.NewHttp(
-		&cfg.HTTP,
-		handlers.Init(
-			cfg.HTTP.URLPrefix,
-			cfg.HTTP.ExternalURLPrefix,
-			cfg.HTTP.CORSAllowedHost,
-		),
+	handlers, err := httpHandler.New(services, initValidator()).Init(
+		cfg.HTTP.URLPrefix,
+		cfg.HTTP.ExternalURLPrefix,
+		cfg.HTTP.CORSAllowedHost,
+		cfg.HTTP.TrustedProxies,
 	)
+	if err != nil {
+		logger.Fatal("can`t init http handlers:", err.Error())
+	}
+
+	httpServer := httpTransport.NewHttp(&cfg.HTTP, handlers)
 
 	httpServer.Run(ctx)
+
+	// wait for the check jobs in progress to be handed back and the webhooks to be delivered
+	<-checkJobsDone
 }
 
 func gracefulShutdown(stop func()) {
@@ -120,6 +224,11 @@ func initValidator() *validator.Validate {
 		return name
 	})
 
+	// the names are printed on the invoices with the standard PDF fonts
+	_ = validate.RegisterValidation("invoice_name", func(fl validator.FieldLevel) bool {
+		return pdfWriter.Encodable(fl.Field().String())
+	})
+
 	return validate
 }
 
@@ -130,5 +239,9 @@ func initCronJobs(cfg config.CronConfigs, services *service.Service) {
 		logger.Fatal(err, "cron ValidatePlanSync init failed")
 	}
 
+	if _, err := c.AddFunc(cfg.RenewSubscriptions, services.Subscription.RenewSubscriptionsSync); err != nil {
+		logger.Fatal(err, "cron RenewSubscriptions init failed")
+	}
+
 	c.Start()
 }
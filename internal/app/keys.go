@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"necutya/faker/internal/config"
+	"necutya/faker/internal/repositories/mongo"
+	"necutya/faker/internal/service"
+	"necutya/faker/pkg/database/mongodb"
+	"necutya/faker/pkg/logger"
+	tokenManager "necutya/faker/pkg/token_managers"
+)
+
+// RotateSigningKeys creates a new access token signing key, running instances start signing
+// with it after their next reload.
+func RotateSigningKeys(configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatal("cannot load config: ", err)
+	}
+
+	mongoClient, err := mongodb.NewClient(cfg.Mongo.URI, cfg.Mongo.User, cfg.Mongo.Password)
+	if err != nil {
+		logger.Fatal("can`t connect to mongo:", err.Error())
+	}
+
+	jwtTokenManager, err := tokenManager.NewJWT(
+		cfg.Token.Algorithm, cfg.Token.SignKey, cfg.Token.Issuer, cfg.Token.Audience, cfg.Token.HS256AcceptedUntil,
+	)
+	if err != nil {
+		logger.Fatal("can`t create token manager:", err.Error())
+	}
+
+	signingKeys := service.NewSigningKeyService(
+		mongo.NewSigningKeysRepo(mongoClient.Database(cfg.Mongo.DatabaseName)),
+		jwtTokenManager,
+		initKeyCipher(cfg.Token),
+		cfg.Token.AccessTokenTTL,
+		cfg.Token.KeysReloadInterval,
+	)
+
+	key, err := signingKeys.Rotate(context.Background())
+	if err != nil {
+		logger.Fatal("can`t rotate signing keys:", err.Error())
+	}
+
+	logger.Infof("signing key %s is created, it signs tokens from %s", key.ID, key.ActivatesAt.Format(time.RFC3339))
+}
+
+// initKeyCipher returns the cipher of the stored private keys, it is required for an asymmetric algorithm only.
+func initKeyCipher(cfg config.TokenConfig) service.KeyCipher {
+	if !tokenManager.IsAsymmetric(cfg.Algorithm) {
+		return nil
+	}
+
+	keyCipher, err := tokenManager.NewKeyCipher(cfg.KeysEncryptionKey)
+	if err != nil {
+		logger.Fatal("can`t create signing keys cipher:", err.Error())
+	}
+
+	return keyCipher
+}
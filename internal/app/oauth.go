@@ -0,0 +1,55 @@
+package app
+
+import (
+	"time"
+
+	"necutya/faker/internal/config"
+	"necutya/faker/internal/service"
+	"necutya/faker/pkg/logger"
+	"necutya/faker/pkg/oauth"
+)
+
+const (
+	googleOAuthType = "google"
+	githubOAuthType = "github"
+	oidcOAuthType   = "oidc"
+
+	defaultOAuthTimeout = 10 * time.Second
+)
+
+// initOAuthProviders creates the sign-in providers declared in config, the key of a provider
+// is its name in the API paths.
+func initOAuthProviders(cfg config.OAuthConfig) map[string]service.OAuthProvider {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultOAuthTimeout
+	}
+
+	providers := make(map[string]service.OAuthProvider, len(cfg.Providers))
+
+	for name, providerCfg := range cfg.Providers {
+		clientCfg := oauth.Config{
+			ClientID:     providerCfg.ClientID,
+			ClientSecret: providerCfg.ClientSecret,
+			RedirectURL:  providerCfg.RedirectURL,
+			Scopes:       providerCfg.Scopes,
+		}
+
+		switch providerCfg.Type {
+		case googleOAuthType:
+			providers[name] = oauth.NewGoogle(clientCfg, timeout)
+		case githubOAuthType:
+			providers[name] = oauth.NewGitHub(clientCfg, timeout)
+		case oidcOAuthType:
+			if providerCfg.DiscoveryURL == "" {
+				logger.Fatalf("oauth provider %s: discovery url is required", name)
+			}
+
+			providers[name] = oauth.NewOIDC(providerCfg.DiscoveryURL, clientCfg, timeout)
+		default:
+			logger.Fatalf("oauth provider %s: unknown type %q", name, providerCfg.Type)
+		}
+	}
+
+	return providers
+}
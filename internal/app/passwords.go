@@ -0,0 +1,24 @@
+package app
+
+import (
+	"necutya/faker/internal/config"
+	"necutya/faker/internal/service"
+	breachedPasswords "necutya/faker/pkg/breached-passwords"
+	"necutya/faker/pkg/logger"
+)
+
+// initBreachedPasswords loads the breached passwords dump, nil turns the check off.
+func initBreachedPasswords(cfg config.PasswordPolicyConfig) service.BreachedPasswordChecker {
+	if cfg.BreachedFile == "" {
+		return nil
+	}
+
+	set, err := breachedPasswords.Load(cfg.BreachedFile, cfg.BreachedMinCount)
+	if err != nil {
+		logger.Fatalf("can`t load breached passwords: %s", err)
+	}
+
+	logger.Infof("loaded %d breached password hashes", set.Len())
+
+	return set
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"time"
+
+	"necutya/faker/internal/config"
+	"necutya/faker/internal/service"
+	"necutya/faker/pkg/logger"
+	"necutya/faker/pkg/payments/fake"
+	"necutya/faker/pkg/payments/fondy"
+	"necutya/faker/pkg/payments/stripe"
+)
+
+const (
+	fondyPaymentsProvider  = "fondy"
+	stripePaymentsProvider = "stripe"
+	fakePaymentsProvider   = "fake"
+
+	defaultStripeTimeout = 10 * time.Second
+)
+
+// initPaymentsManager creates the payment provider selected in config, Fondy is used if none is.
+func initPaymentsManager(cfg config.PaymentsConfig) service.PaymentsManager {
+	switch cfg.Provider {
+	case "", fondyPaymentsProvider:
+		return fondy.NewPaymentManager(
+			int64(cfg.MerchantID),
+			cfg.MerchantPassword,
+			cfg.Currency,
+			cfg.Language,
+			cfg.ResponseURL,
+			cfg.CallbackURL,
+		)
+	case stripePaymentsProvider:
+		timeout := cfg.Stripe.Timeout
+		if timeout <= 0 {
+			timeout = defaultStripeTimeout
+		}
+
+		return stripe.NewPaymentManager(stripe.Config{
+			SecretKey:     cfg.Stripe.SecretKey,
+			WebhookSecret: cfg.Stripe.WebhookSecret,
+			Currency:      cfg.Currency,
+			SuccessURL:    cfg.Stripe.SuccessURL,
+			CancelURL:     cfg.Stripe.CancelURL,
+		}, timeout)
+	case fakePaymentsProvider:
+		logger.Info("payments are simulated by the fake provider")
+
+		paymentsManager, err := fake.NewPaymentManager(fake.Config{
+			CheckoutURL:      cfg.Fake.CheckoutURL,
+			Currency:         cfg.Currency,
+			Secret:           cfg.Fake.Secret,
+			RecurringOutcome: cfg.Fake.RecurringOutcome,
+		})
+		if err != nil {
+			logger.Fatal("can`t create fake payments provider:", err.Error())
+		}
+
+		return paymentsManager
+	default:
+		logger.Fatalf("unknown payments provider %q", cfg.Provider)
+		return nil
+	}
+}
@@ -13,8 +13,17 @@ type Config struct {
 	AI                  AiServiceConfig           `json:"ai"`
 	Notification        NotificationServiceConfig `json:"notification"`
 	Payments            PaymentsConfig            `json:"payments"`
+	Subscriptions       SubscriptionsConfig       `json:"subscriptions"`
+	Invoices            InvoicesConfig            `json:"invoices"`
 	Feedbacks           FeedbacksConfig           `json:"feedbacks"`
 	Cron                CronConfigs               `json:"cron"`
+	CheckJobs           CheckJobsConfig           `json:"check_jobs"`
+	TwoFactor           TwoFactorConfig           `json:"two_factor"`
+	BruteForce          BruteForceConfig          `json:"brute_force"`
+	OAuth               OAuthConfig               `json:"oauth"`
+	EmailChange         EmailChangeConfig         `json:"email_change"`
+	MagicLink           MagicLinkConfig           `json:"magic_link"`
+	PasswordPolicy      PasswordPolicyConfig      `json:"password_policy"`
 }
 
 type Logger struct {
@@ -31,6 +40,9 @@ type HTTPConfig struct {
 	WriteTimeout       time.Duration `json:"write_timeout"`
 	MaxHeaderMegabytes int           `json:"max_header_bytes"`
 	CORSAllowedHost    []string      `json:"cors_allowed_host"`
+	// TrustedProxies are the addresses or CIDRs of the proxies whose X-Forwarded-For
+	// header is trusted, the header of the other requests is ignored.
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 type MongoConfig struct {
@@ -50,10 +62,59 @@ type TokenConfig struct {
 	AccessTokenTTL  int    `json:"access_token_ttl"`
 	RefreshTokenTTL int    `json:"refresh_token_ttl"`
 	SignKey         string `json:"sign_key" env:"TOKEN_CONFIG_SIGN_KEY"`
+	// Algorithm is HS256, RS256 or EdDSA. With an asymmetric one HS256 tokens signed with SignKey
+	// are accepted only until HS256AcceptedUntil, set it to the switch time plus the access token TTL.
+	Algorithm          string    `json:"algorithm"`
+	HS256AcceptedUntil time.Time `json:"hs256_accepted_until"`
+	Issuer             string    `json:"issuer"`
+	Audience           string    `json:"audience"`
+	KeysReloadInterval int       `json:"keys_reload_interval"`
+	// KeysEncryptionKey encrypts the private keys of an asymmetric algorithm in the database.
+	KeysEncryptionKey string `json:"keys_encryption_key" env:"TOKEN_CONFIG_KEYS_ENCRYPTION_KEY"`
 }
 
 type AiServiceConfig struct {
-	Addr string `json:"addr"`
+	Addr                 string                      `json:"addr"`
+	ModelVersion         string                      `json:"model_version"`
+	BatchConcurrency     int                         `json:"batch_concurrency"`
+	CacheTTL             int                         `json:"cache_ttl"`
+	CacheCaseInsensitive bool                        `json:"cache_case_insensitive"`
+	TLS                  AiServiceTLSConfig          `json:"tls"`
+	Timeout              time.Duration               `json:"timeout"`
+	KeepaliveTime        time.Duration               `json:"keepalive_time"`
+	KeepaliveTimeout     time.Duration               `json:"keepalive_timeout"`
+	MaxRetries           int                         `json:"max_retries"`
+	RetryBaseDelay       time.Duration               `json:"retry_base_delay"`
+	BreakerThreshold     int                         `json:"breaker_threshold"`
+	BreakerTimeout       time.Duration               `json:"breaker_timeout"`
+	HealthCheckTimeout   time.Duration               `json:"health_check_timeout"`
+	Strategy             string                      `json:"strategy"`
+	GeneratedThreshold   float64                     `json:"generated_threshold"`
+	Detectors            []AiDetectorConfig          `json:"detectors"`
+	Languages            map[string]AiLanguageConfig `json:"languages"`
+}
+
+type AiLanguageConfig struct {
+	Addr      string             `json:"addr"`
+	TLS       AiServiceTLSConfig `json:"tls"`
+	Strategy  string             `json:"strategy"`
+	Detectors []AiDetectorConfig `json:"detectors"`
+}
+
+type AiDetectorConfig struct {
+	Name   string             `json:"name"`
+	Type   string             `json:"type"`
+	Addr   string             `json:"addr"`
+	Weight float64            `json:"weight"`
+	TLS    AiServiceTLSConfig `json:"tls"`
+}
+
+type AiServiceTLSConfig struct {
+	Enabled    bool   `json:"enabled"`
+	CAFile     string `json:"ca_file"`
+	CertFile   string `json:"cert_file"`
+	KeyFile    string `json:"key_file"`
+	ServerName string `json:"server_name"`
 }
 
 type NotificationServiceConfig struct {
@@ -61,13 +122,54 @@ type NotificationServiceConfig struct {
 	From string `json:"from"`
 }
 
+// PaymentsConfig selects the payment provider: fondy (default), stripe or fake,
+// the merchant settings at the top level are the ones of Fondy.
 type PaymentsConfig struct {
-	MerchantID       int    `json:"merchant_id" env:"MERCHANT_ID"`
-	MerchantPassword string `json:"merchant_password" env:"MERCHANT_PASSWORD"`
-	Currency         string `json:"currency"`
-	Language         string `json:"language"`
-	ResponseURL      string `json:"response_url"`
-	CallbackURL      string `json:"callback_url"`
+	Provider         string               `json:"provider"`
+	MerchantID       int                  `json:"merchant_id" env:"MERCHANT_ID"`
+	MerchantPassword string               `json:"merchant_password" env:"MERCHANT_PASSWORD"`
+	Currency         string               `json:"currency"`
+	Language         string               `json:"language"`
+	ResponseURL      string               `json:"response_url"`
+	CallbackURL      string               `json:"callback_url"`
+	Stripe           StripePaymentsConfig `json:"stripe"`
+	Fake             FakePaymentsConfig   `json:"fake"`
+}
+
+type StripePaymentsConfig struct {
+	SecretKey     string        `json:"secret_key" env:"STRIPE_SECRET_KEY"`
+	WebhookSecret string        `json:"webhook_secret" env:"STRIPE_WEBHOOK_SECRET"`
+	SuccessURL    string        `json:"success_url"`
+	CancelURL     string        `json:"cancel_url"`
+	Timeout       time.Duration `json:"timeout"`
+}
+
+type FakePaymentsConfig struct {
+	// CheckoutURL is the page of the checkout, %s is replaced with the order id.
+	CheckoutURL      string `json:"checkout_url"`
+	Secret           string `json:"secret"`
+	RecurringOutcome string `json:"recurring_outcome"`
+}
+
+type SubscriptionsConfig struct {
+	// RenewBefore is how long before the end of the period the card is charged, in seconds.
+	RenewBefore        int `json:"renew_before"`
+	RetryInterval      int `json:"retry_interval"`
+	MaxRenewalAttempts int `json:"max_renewal_attempts"`
+}
+
+type InvoicesConfig struct {
+	Seller InvoiceSellerConfig `json:"seller"`
+	// URL is the page the invoice is downloaded from, %s is replaced with the order id.
+	URL string `json:"url"`
+}
+
+type InvoiceSellerConfig struct {
+	Name string `json:"name"`
+	// Address may have several lines separated with "\n".
+	Address string `json:"address"`
+	Email   string `json:"email"`
+	TaxID   string `json:"tax_id"`
 }
 
 type FeedbacksConfig struct {
@@ -75,5 +177,72 @@ type FeedbacksConfig struct {
 }
 
 type CronConfigs struct {
-	ValidatePlanSync string `json:"validate_plan_sync"`
+	ValidatePlanSync   string `json:"validate_plan_sync"`
+	RenewSubscriptions string `json:"renew_subscriptions"`
+}
+
+type CheckJobsConfig struct {
+	Workers            int           `json:"workers"`
+	JobTTL             int           `json:"job_ttl"`
+	WebhookTimeout     time.Duration `json:"webhook_timeout"`
+	WebhookMaxAttempts int           `json:"webhook_max_attempts"`
+	WebhookBaseDelay   time.Duration `json:"webhook_base_delay"`
+}
+
+type TwoFactorConfig struct {
+	Issuer       string `json:"issuer"`
+	ChallengeTTL int    `json:"challenge_ttl"`
+}
+
+type BruteForceConfig struct {
+	MaxPerEmail     int           `json:"max_per_email"`
+	MaxPerIP        int           `json:"max_per_ip"`
+	MaxCodeAttempts int           `json:"max_code_attempts"`
+	Window          int           `json:"window"`
+	LockoutTTL      int           `json:"lockout_ttl"`
+	BaseDelay       time.Duration `json:"base_delay"`
+	MaxDelay        time.Duration `json:"max_delay"`
+}
+
+type OAuthConfig struct {
+	StateTTL  int                            `json:"state_ttl"`
+	Timeout   time.Duration                  `json:"timeout"`
+	Providers map[string]OAuthProviderConfig `json:"providers"`
+}
+
+type OAuthProviderConfig struct {
+	// Type is one of google, github or oidc, the last one requires DiscoveryURL.
+	Type         string   `json:"type"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	DiscoveryURL string   `json:"discovery_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+type EmailChangeConfig struct {
+	// RevertURL is the page of the revert link, %s is replaced with the revert token.
+	RevertURL string `json:"revert_url"`
+	RevertTTL int    `json:"revert_ttl"`
+}
+
+type MagicLinkConfig struct {
+	Enabled bool `json:"enabled"`
+	// URL is the page of the sign-in link, %s is replaced with the link token.
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	TTL    int    `json:"ttl"`
+}
+
+type PasswordPolicyConfig struct {
+	MinLength          int  `json:"min_length"`
+	MaxLength          int  `json:"max_length"`
+	RequireUpper       bool `json:"require_upper"`
+	RequireLower       bool `json:"require_lower"`
+	RequireDigit       bool `json:"require_digit"`
+	RequireSymbol      bool `json:"require_symbol"`
+	ForbidPersonalInfo bool `json:"forbid_personal_info"`
+	// BreachedFile is a HIBP-format dump of SHA-1 hashes of breached passwords, the check is off when it is empty.
+	BreachedFile     string `json:"breached_file"`
+	BreachedMinCount int    `json:"breached_min_count"`
 }
@@ -7,11 +7,13 @@ import (
 )
 
 const (
-	userIDKey    = "user-id"
-	userRoleKey  = "user-role"
-	sessionIDKey = "session-id"
-	tokenIDKey   = "token-id"
-	planIDKey    = "plan-id"
+	userIDKey      = "user-id"
+	userRoleKey    = "user-role"
+	sessionIDKey   = "session-id"
+	tokenIDKey     = "token-id"
+	planIDKey      = "plan-id"
+	scopesKey      = "scopes"
+	permissionsKey = "permissions"
 )
 
 // GetUserID - returns user ID from context.
@@ -83,3 +85,27 @@ func GetPlanID(ctx context.Context) string {
 func WithPlanID(ctx context.Context, value string) context.Context {
 	return context.WithValue(ctx, planIDKey, value) // nolint
 }
+
+// GetScopes - returns API key scopes from context.
+func GetScopes(ctx context.Context) []string {
+	value, _ := ctx.Value(scopesKey).([]string)
+
+	return value
+}
+
+// WithScopes - add API key scopes value to context.
+func WithScopes(ctx context.Context, value []string) context.Context {
+	return context.WithValue(ctx, scopesKey, value) // nolint
+}
+
+// GetPermissions - returns permissions of the user's role from context.
+func GetPermissions(ctx context.Context) []string {
+	value, _ := ctx.Value(permissionsKey).([]string)
+
+	return value
+}
+
+// WithPermissions - add permissions of the user's role to context.
+func WithPermissions(ctx context.Context, value []string) context.Context {
+	return context.WithValue(ctx, permissionsKey, value) // nolint
+}
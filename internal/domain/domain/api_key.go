@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+type Scope string
+
+const (
+	CheckWriteScope  Scope = "check:write"
+	HistoryReadScope Scope = "history:read"
+)
+
+var Scopes = []Scope{CheckWriteScope, HistoryReadScope}
+
+// APIKey authenticates requests to the external API. The key itself is shown once on creation,
+// only its hash is stored and Prefix is used to look it up.
+type APIKey struct {
+	ID     string
+	UserID string
+	Name   string
+	Prefix string
+	Hash   string
+	Scopes []Scope
+
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedAt  time.Time
+}
+
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+func (k *APIKey) HasScope(scope Scope) bool {
+	for i := range k.Scopes {
+		if k.Scopes[i] == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+const (
+	QueuedCheckJobStatus     = "queued"
+	ProcessingCheckJobStatus = "processing"
+	CompletedCheckJobStatus  = "completed"
+	FailedCheckJobStatus     = "failed"
+
+	CheckJobFinishedEvent = "check_job.finished"
+)
+
+type CheckJob struct {
+	ID          string
+	UserID      string
+	RequestType RequestType
+	Status      string
+
+	SaveToDb     bool
+	Segmentation string
+	Items        []*CheckJobItem
+
+	Processed int
+	Failed    int
+
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+func (j *CheckJob) Finished() bool {
+	return j.Status == CompletedCheckJobStatus || j.Status == FailedCheckJobStatus
+}
+
+// Progress returns the share of processed items in percents.
+func (j *CheckJob) Progress() float64 {
+	if len(j.Items) == 0 {
+		return 0
+	}
+
+	return float64(j.Processed) * 100 / float64(len(j.Items))
+}
+
+type CheckJobItem struct {
+	ID      string
+	Message string
+
+	Result    *CheckResult
+	Cached    bool
+	ErrorCode string
+	Error     string
+}
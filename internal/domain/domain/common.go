@@ -3,7 +3,7 @@ package domain
 import "time"
 
 const (
-	DateLayout  = "2006-02-01"
+	DateLayout  = "2006-01-02"
 	HoursInDay  = 24
 	DaysInMonth = 30
 )
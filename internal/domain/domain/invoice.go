@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type Invoice struct {
+	ID string
+	// Number is unique and sequential within the year the invoice is issued in, e.g. 2026-000042.
+	Number   string
+	OrderID  string
+	UserID   string
+	Seller   InvoiceParty
+	Buyer    InvoiceParty
+	Items    []*InvoiceItem
+	Currency string
+	IssuedAt time.Time
+}
+
+type InvoiceParty struct {
+	Name    string
+	Address string
+	Email   string
+	TaxID   string
+}
+
+type InvoiceItem struct {
+	Description string
+	Quantity    int
+	// UnitPrice is in cents.
+	UnitPrice int64
+}
+
+func (i *InvoiceItem) Amount() int64 {
+	return int64(i.Quantity) * i.UnitPrice
+}
+
+// Total is the amount of the invoice in cents.
+func (i *Invoice) Total() int64 {
+	var total int64
+
+	for _, item := range i.Items {
+		total += item.Amount()
+	}
+
+	return total
+}
+
+func (i *Invoice) FileName() string {
+	return fmt.Sprintf("invoice-%s.pdf", i.Number)
+}
+
+func InvoiceNumber(year, sequence int) string {
+	return fmt.Sprintf("%d-%06d", year, sequence)
+}
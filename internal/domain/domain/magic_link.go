@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// MagicLink is a pending passwordless sign-in. Only the hash of the link token is kept and the link
+// works only for the client and address it was requested from, which Fingerprint is taken of.
+type MagicLink struct {
+	TokenHash   string
+	UserID      string
+	Fingerprint string
+	ExpiresAt   time.Time
+}
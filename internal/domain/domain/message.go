@@ -3,7 +3,47 @@ package domain
 import "time"
 
 type Message struct {
-	Text      string
+	ID     string
+	UserID string
+
+	Text        string
+	RequestType RequestType
+
+	IsGenerated      bool
+	GeneratedPercent float64
+	ModelVersion     string
+	Language         string
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// CheckResult is a verdict of the AI service for a message.
+type CheckResult struct {
+	Message          string
+	IsGenerated      bool
+	GeneratedPercent float64
+	Segments         []*MessageSegment
+	Detectors        []*DetectorScore
+
+	Language           string
+	LanguageConfidence float64
+}
+
+// DetectorScore is a verdict of a single detector of the ensemble, Error is set if the detector failed.
+type DetectorScore struct {
+	Detector         string
+	IsGenerated      bool
+	GeneratedPercent float64
+	Error            string
+}
+
+type MessageSegment struct {
+	// Start and End are UTF-16 code unit offsets of the segment in the original message, End is exclusive.
+	Start int
+	End   int
+	Text  string
+
+	IsGenerated      bool
+	GeneratedPercent float64
+}
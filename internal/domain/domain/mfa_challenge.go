@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// MFAChallenge is issued by the first step of the sign-in of a user with the second factor enabled,
+// its Token is exchanged for the session tokens together with a valid code.
+type MFAChallenge struct {
+	Token     string
+	UserID    string
+	Client    string
+	IPAddress string
+	Attempts  int
+	ExpiresAt time.Time
+}
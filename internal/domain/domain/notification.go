@@ -4,4 +4,7 @@ const (
 	ConfirmEmailSubject    = "Email confirmation for CheckIT"
 	SuccessEmailSubject    = "Success payment"
 	DeactivatedPlanSubject = "Plan is deactivated"
+	AccountLockoutSubject  = "Your CheckIT account is temporarily locked"
+	EmailChangedSubject    = "Your CheckIT email has been changed"
+	MagicLinkSubject       = "Your CheckIT sign-in link"
 )
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// UserIdentity links the user to an account at an external OpenID Connect or OAuth2 provider.
+type UserIdentity struct {
+	Provider string
+	Subject  string
+	Email    string
+	LinkedAt time.Time
+}
+
+// OAuthState is kept between the redirect to the provider and the callback. UserID is set
+// when an already signed in user links the provider instead of signing in with it.
+type OAuthState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	UserID       string
+	ExpiresAt    time.Time
+}
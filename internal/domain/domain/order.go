@@ -6,10 +6,13 @@ import (
 )
 
 const (
-	NewOrderStatus    = "new"
-	PaidOrderStatus   = "paid"
-	OtherOrderStatus  = "other"
-	FailedOrderStatus = "failed"
+	NewOrderStatus               = "new"
+	PaidOrderStatus              = "paid"
+	OtherOrderStatus             = "other" // the payment is created or being processed by the provider
+	FailedOrderStatus            = "failed"
+	PartiallyRefundedOrderStatus = "partially_refunded"
+	RefundedOrderStatus          = "refunded"
+	ReversedOrderStatus          = "reversed" // the payment is reversed by the provider, not refunded by us
 
 	orderDescriptionTemplate = `CheckIT order:
 Customer %s
@@ -19,27 +22,129 @@ Order time: %s`
 )
 
 type Order struct {
-	ID           string
-	PlanID       string
-	UserID       string
-	Status       string
-	Amount       int
+	ID     string
+	PlanID string
+	UserID string
+	// SubscriptionID is set for the orders renewing the subscription.
+	SubscriptionID string
+	Status         string
+	Amount         int
+	// Credit is the unused part of the previous plan deducted from the amount, in cents.
+	Credit       int64
 	Currency     string
 	Description  string
 	Transactions []*Transaction
-	CreatedAt    time.Time
+	// PlanActivationPending is set with the paid transaction until the plan of the order is given to the user,
+	// so a repeated callback activates the plan if the first one failed to.
+	PlanActivationPending bool
+	// PendingRefunds are requested from the provider but not recorded as the transactions yet.
+	PendingRefunds []*PendingRefund
+	CreatedAt      time.Time
+}
+
+// orderTransitions lists the statuses the order can move to from each status,
+// failed, refunded and reversed orders are final.
+var orderTransitions = map[string][]string{
+	NewOrderStatus:   {OtherOrderStatus, PaidOrderStatus, FailedOrderStatus},
+	OtherOrderStatus: {OtherOrderStatus, PaidOrderStatus, FailedOrderStatus},
+	PaidOrderStatus:  {PartiallyRefundedOrderStatus, RefundedOrderStatus, ReversedOrderStatus},
+	PartiallyRefundedOrderStatus: {
+		PartiallyRefundedOrderStatus, RefundedOrderStatus, ReversedOrderStatus,
+	},
 }
 
+// Paid reports whether the order is paid and the payment is not refunded in full.
 func (o *Order) Paid() bool {
-	return o.Status == PaidOrderStatus
+	return o.Status == PaidOrderStatus || o.Status == PartiallyRefundedOrderStatus
+}
+
+// Total is the amount charged for the order in cents.
+func (o *Order) Total() int64 {
+	return int64(o.Amount)*100 - o.Credit
+}
+
+// RefundedAmount is the sum of the refunds of the order in cents.
+func (o *Order) RefundedAmount() int64 {
+	var refunded int64
+
+	for _, transaction := range o.Transactions {
+		refunded += transaction.RefundAmount
+	}
+
+	return refunded
+}
+
+// PendingRefundAmount is the sum of the pending refunds of the order in cents.
+func (o *Order) PendingRefundAmount() int64 {
+	var pending int64
+
+	for _, refund := range o.PendingRefunds {
+		pending += refund.Amount
+	}
+
+	return pending
+}
+
+// RefundableAmount is what is left to refund in cents, the amount of the pending refunds is reserved.
+func (o *Order) RefundableAmount() int64 {
+	return o.Total() - o.RefundedAmount() - o.PendingRefundAmount()
+}
+
+// PaymentID returns the id of the payment the order is paid with, it is empty for the unpaid orders.
+func (o *Order) PaymentID() string {
+	for _, transaction := range o.Transactions {
+		if transaction.Status == PaidOrderStatus {
+			return transaction.PaymentID
+		}
+	}
+
+	return ""
+}
+
+func (o *Order) CanTransitionTo(status string) bool {
+	for _, next := range orderTransitions[o.Status] {
+		if next == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTransaction reports whether the transaction with the idempotency key was already added to the order.
+func (o *Order) HasTransaction(idempotencyKey string) bool {
+	for i := range o.Transactions {
+		if o.Transactions[i].IdempotencyKey == idempotencyKey {
+			return true
+		}
+	}
+
+	return false
 }
 
 type Transaction struct {
-	Status         string
+	// IdempotencyKey identifies the provider notification the transaction is created from,
+	// the same notification is never added twice.
+	IdempotencyKey string
+	// PaymentID is the id of the payment at the provider.
+	PaymentID string
+	Status    string
+	// RefundAmount is the amount returned to the customer by the transaction, in cents.
+	RefundAmount   int64
 	CreatedAt      time.Time
 	AdditionalInfo string
 }
 
+// PendingRefund reserves the amount of the refund while it is requested from the provider,
+// so the concurrent refunds can not return more than was paid.
+type PendingRefund struct {
+	ID string
+	// Amount is in cents.
+	Amount    int64
+	Comment   string
+	CreatedAt time.Time
+}
+
 func GenerateOrderDescription(name, orderItem string, amount int, orderTime time.Time) string {
 	return fmt.Sprintf(
 		orderDescriptionTemplate,
@@ -15,12 +15,35 @@ type Plan struct {
 	Duration              int
 	InternalRequestsCount int
 	ExternalRequestsCount int
+
+	// MaxFileSize is a maximum size of an uploaded document in bytes, 0 means unlimited.
+	MaxFileSize int
+	// MaxDocumentLength is a maximum number of characters extracted from a document, 0 means unlimited.
+	MaxDocumentLength int
+	// CountCachedRequests tells whether checks answered from the result cache count toward the daily limits.
+	CountCachedRequests bool
+	// SupportedLanguages are ISO 639-1 codes of languages which can be checked, empty means any language.
+	SupportedLanguages []string
 }
 
 func (p *Plan) IsBasic() bool {
 	return p.Name == BasicPlanName
 }
 
+func (p *Plan) SupportsLanguage(language string) bool {
+	if len(p.SupportedLanguages) == 0 {
+		return true
+	}
+
+	for _, supported := range p.SupportedLanguages {
+		if supported == language {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *Plan) EndDateFromNow() *time.Time {
 	if p.Duration == 0 {
 		return nil
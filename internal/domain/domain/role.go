@@ -0,0 +1,81 @@
+package domain
+
+import "time"
+
+const (
+	// AdminRole is the super-admin, it has every permission and edits the permissions of other roles.
+	AdminRole          Role = "admin"
+	SupportRole        Role = "support"
+	BillingManagerRole Role = "billing-manager"
+	AnalystRole        Role = "analyst"
+	BasicRole          Role = "basic"
+)
+
+type Role string
+
+var Roles = []Role{AdminRole, SupportRole, BillingManagerRole, AnalystRole, BasicRole}
+
+type Permission string
+
+const (
+	UsersReadPermission       Permission = "users:read"
+	FeedbackResolvePermission Permission = "feedback:resolve"
+	PlansWritePermission      Permission = "plans:write"
+	ReportsReadPermission     Permission = "reports:read"
+	SettingsWritePermission   Permission = "settings:write"
+	// RolesWritePermission allows editing roles and assigning them to users, only super-admins have it.
+	RolesWritePermission Permission = "roles:write"
+	// OrdersRefundPermission allows refunding the orders through the payment provider.
+	OrdersRefundPermission Permission = "orders:refund"
+)
+
+var Permissions = []Permission{
+	UsersReadPermission,
+	FeedbackResolvePermission,
+	PlansWritePermission,
+	ReportsReadPermission,
+	SettingsWritePermission,
+	RolesWritePermission,
+	OrdersRefundPermission,
+}
+
+// RolePermissions maps a role to its permissions, the mapping is stored once edited
+// and DefaultRolePermissions are used until then.
+type RolePermissions struct {
+	Role        Role
+	Permissions []Permission
+	UpdatedAt   time.Time
+}
+
+var DefaultRolePermissions = map[Role][]Permission{
+	AdminRole:          Permissions,
+	SupportRole:        {UsersReadPermission, FeedbackResolvePermission},
+	BillingManagerRole: {UsersReadPermission, PlansWritePermission, OrdersRefundPermission},
+	AnalystRole:        {ReportsReadPermission},
+	BasicRole:          {},
+}
+
+func (r Role) IsValid() bool {
+	for i := range Roles {
+		if Roles[i] == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsEditable tells whether the role's permissions can be changed, the super-admin always has all of them.
+func (r Role) IsEditable() bool {
+	return r != AdminRole && r.IsValid()
+}
+
+func (p *RolePermissions) Has(permission Permission) bool {
+	for i := range p.Permissions {
+		if p.Permissions[i] == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -16,14 +16,21 @@ type TokenInfo struct {
 	TokenID   string
 	SessionID string
 	PlanID    string
+
+	Role        string
+	Permissions []string
 }
 
+// Session is a family of refresh tokens, each refresh replaces RefreshTokenHash with a hash of
+// a new token and remembers the previous one in UsedRefreshTokenHashes to detect its reuse.
 type Session struct {
-	ID           uuid.UUID
-	RefreshToken string
-	Client       string
-	IpAddress    string
+	ID                     uuid.UUID
+	RefreshTokenHash       string
+	UsedRefreshTokenHashes []string
+	Client                 string
+	IpAddress              string
 
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	LastUsedAt time.Time
 }
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// SecuritySettings are edited by admins and applied to all users.
+type SecuritySettings struct {
+	TwoFactorRequiredRoles []Role
+	UpdatedAt              time.Time
+}
+
+func (s *SecuritySettings) TwoFactorRequired(role Role) bool {
+	for i := range s.TwoFactorRequiredRoles {
+		if s.TwoFactorRequiredRoles[i] == role {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// SigningKey is a key pair signing access tokens, its ID is the kid header of the tokens.
+// The newest key whose ActivatesAt has passed is active, a rotated key is kept to verify
+// the tokens it has signed until ExpiresAt.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	// PrivateKey is the encrypted PEM block of the private key.
+	PrivateKey  string
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	ExpiresAt   *time.Time
+}
+
+func (k *SigningKey) IsActive(now time.Time) bool {
+	return !k.ActivatesAt.After(now) && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
@@ -0,0 +1,68 @@
+package domain
+
+import "time"
+
+const (
+	ActiveSubscriptionStatus = "active"
+	// PastDueSubscriptionStatus is the subscription which renewal payment has failed, it is retried until the attempts run out.
+	PastDueSubscriptionStatus  = "past_due"
+	CanceledSubscriptionStatus = "canceled"
+	ExpiredSubscriptionStatus  = "expired"
+)
+
+// Subscription renews the paid plan of the user every period by charging the card saved at the checkout.
+type Subscription struct {
+	ID       string
+	UserID   string
+	PlanID   string
+	Status   string
+	Rectoken string
+
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	// CancelAtPeriodEnd stops the renewal, the plan stays active until the end of the current period.
+	CancelAtPeriodEnd bool
+	// PaidOrderID is the order which started the current period, a period is started once for an order.
+	PaidOrderID string
+
+	// RenewalOrderID is the order of the last renewal payment.
+	RenewalOrderID  string
+	RenewalAttempts int
+	NextRenewalAt   time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Renewable reports whether the plan of the subscription is still in use and is going to be renewed.
+func (s *Subscription) Renewable() bool {
+	return (s.Status == ActiveSubscriptionStatus || s.Status == PastDueSubscriptionStatus) && !s.CancelAtPeriodEnd
+}
+
+// Ongoing reports whether the subscription has not been ended yet.
+func (s *Subscription) Ongoing() bool {
+	return s.Status == ActiveSubscriptionStatus || s.Status == PastDueSubscriptionStatus
+}
+
+// UnusedShare is the part of the current period which is left at the time, from 0 to 1.
+func (s *Subscription) UnusedShare(now time.Time) float64 {
+	period := s.CurrentPeriodEnd.Sub(s.CurrentPeriodStart)
+
+	switch {
+	case period <= 0 || !now.Before(s.CurrentPeriodEnd):
+		return 0
+	case now.Before(s.CurrentPeriodStart):
+		return 1
+	}
+
+	return float64(s.CurrentPeriodEnd.Sub(now)) / float64(period)
+}
+
+// StartPeriod starts the next period lasting the duration of the plan in months.
+func (s *Subscription) StartPeriod(start time.Time, months int) {
+	s.Status = ActiveSubscriptionStatus
+	s.CurrentPeriodStart = start
+	s.CurrentPeriodEnd = start.AddDate(0, months, 0)
+	s.RenewalAttempts = 0
+	s.NextRenewalAt = time.Time{}
+}
@@ -6,15 +6,20 @@ import (
 	"github.com/google/uuid"
 )
 
-const (
-	AdminRole Role = "admin"
-	BasicRole Role = "basic"
-)
-
-type Role string
+// UserWebhook is a URL notified about finished check jobs, requests are signed with Secret.
+type UserWebhook struct {
+	URL    string
+	Secret string
+}
 
-type UserCredential struct {
-	ExternalToken string `bson:"external_token"`
+// UserTwoFactor is a TOTP second factor, it is Enabled only after the first code is confirmed.
+// RecoveryCodes are hashed and each of them is removed once used.
+type UserTwoFactor struct {
+	Secret        string
+	Enabled       bool
+	RecoveryCodes []string
+	LastUsedStep  int64
+	EnabledAt     *time.Time
 }
 
 type User struct {
@@ -32,7 +37,9 @@ type User struct {
 	LastVisitAt time.Time
 
 	Role       Role
-	Credential *UserCredential
+	Webhook    *UserWebhook
+	TwoFactor  *UserTwoFactor
+	Identities []*UserIdentity
 
 	PlanID string
 
@@ -44,6 +51,21 @@ func (u *User) IsAdmin() bool {
 	return u.Role == AdminRole
 }
 
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// Identity returns the user's account at the provider or nil if it is not linked.
+func (u *User) Identity(provider string) *UserIdentity {
+	for i := range u.Identities {
+		if u.Identities[i].Provider == provider {
+			return u.Identities[i]
+		}
+	}
+
+	return nil
+}
+
 type UserCredentials struct {
 	ID                uuid.UUID
 	ExternalSecretKey string
@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+type MessageFilter struct {
+	UserID      string
+	From        *time.Time
+	To          *time.Time
+	IsGenerated *bool
+	Search      string
+
+	Limit  int64
+	Offset int64
+}
@@ -1,6 +1,11 @@
 package core
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 var (
 	ErrAlreadyExist = errors.New("already exists")
@@ -9,23 +14,96 @@ var (
 	ErrInvalidLoginOrPassword = errors.New("invalid login or password")
 	ErrExpiredSession         = errors.New("session is expired")
 	ErrInvalidSession         = errors.New("session is invalid")
+	ErrRefreshTokenReused     = errors.New("refresh token was already used, session is revoked")
 	ErrUnconfirmedEmail       = errors.New("unconfirmed email")
-	ErrInvalidExternalToken   = errors.New("invalid external token")
+	ErrInvalidAPIKey          = errors.New("invalid api key")
+	ErrExpiredAPIKey          = errors.New("api key is expired")
+	ErrInsufficientScope      = errors.New("api key does not have the required scope")
+
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for your role")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFAChallenge     = errors.New("sign-in challenge is invalid or expired, sign in again")
+
+	ErrUnknownOAuthProvider  = errors.New("unknown sign-in provider")
+	ErrInvalidOAuthState     = errors.New("sign-in state is invalid or expired, try one more time")
+	ErrOAuthFailed           = errors.New("sign-in with the provider failed")
+	ErrOAuthEmailNotVerified = errors.New("email of the provider account is not verified")
+	ErrIdentityAlreadyLinked = errors.New("provider account is already linked to another user")
+	ErrLastSignInMethod      = errors.New("can not unlink the only sign-in method, set a password first")
+
+	ErrUnknownRole            = errors.New("unknown role")
+	ErrRoleNotEditable        = errors.New("permissions of the role can not be edited")
+	ErrPermissionNotGrantable = errors.New("roles:write permission is reserved for super-admins")
+	ErrInsufficientPermission = errors.New("not enough permissions")
+
+	ErrKeyRotationUnsupported = errors.New("keys can be rotated only for asymmetric signing algorithms")
 
 	ErrExpiredCode = errors.New("code is expired, try one more time")
 	ErrInvalidCode = errors.New("invalid verification code")
 
+	ErrInvalidRevertToken = errors.New("revert link is invalid or expired")
+
+	ErrInvalidMagicLink  = errors.New("sign-in link is invalid or expired, request a new one")
+	ErrMagicLinkDisabled = errors.New("sign-in by link is disabled")
+
+	ErrCodeAttemptsExceeded  = errors.New("too many wrong codes, request a new one")
+	ErrEmailAlreadyConfirmed = errors.New("email is already confirmed")
+
 	ErrUnverifiedPasswordReset = errors.New("unverified password reset")
 	ErrInvalidCurrentPassword  = errors.New("invalid current password")
 
 	ErrRequestLimit = errors.New("requests limit for today has been reached")
 
-	ErrUnknownCallbackType = errors.New("unknown callback type")
-	ErrTransactionInvalid  = errors.New("invalid transaction")
+	ErrTransactionInvalid        = errors.New("invalid transaction")
+	ErrIllegalOrderStatus        = errors.New("order can not move to this status")
+	ErrPaymentSimulationDisabled = errors.New("payments can be simulated only with the fake provider")
+	ErrInvalidRefundAmount       = errors.New("refund amount must be positive and not exceed the amount left to refund")
+	ErrRefundDeclined            = errors.New("refund is declined by the payment provider")
+	ErrInvoiceNameUnsupported    = errors.New("name can not be printed on the invoice, update it with latin letters")
 
 	ErrThisPlanAlreadySet = errors.New("this plan already set")
+
+	ErrSubscriptionNotActive = errors.New("subscription is not active")
+
+	ErrUnsupportedDocument = errors.New("unsupported document format")
+	ErrMalformedDocument   = errors.New("document is malformed or can not be read")
+	ErrEmptyDocument       = errors.New("document contains no extractable text")
+	ErrFileTooLarge        = errors.New("file size exceeds the plan limit")
+	ErrDocumentTooLong     = errors.New("document length exceeds the plan limit")
+
+	ErrAIServiceUnavailable = errors.New("ai service is temporarily unavailable, try again later")
+
+	ErrInvalidWebhookURL = errors.New("webhook url must be an https url of a public host")
+
+	ErrUnsupportedLanguage = errors.New("language of the text is not supported by your plan")
 )
 
+// LockoutError is returned when there were too many failed attempts,
+// RetryAfter tells when the next attempt is allowed.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return "too many failed attempts, try again later"
+}
+
+// ValidationError is returned when some fields of the input are invalid,
+// Errors describe them one by one.
+type ValidationError struct {
+	Errors []ApiError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		msgs[i] = fmt.Sprintf("%s: %s", e.Errors[i].Field, e.Errors[i].Msg)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
 type ApiError struct {
 	Field string `json:"field"`
 	Msg   string `json:"message"`
@@ -0,0 +1,191 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const apiKeysCollection = "api_keys"
+
+type APIKey struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	UserID primitive.ObjectID `bson:"user_id"`
+	Name   string             `bson:"name"`
+	Prefix string             `bson:"prefix"`
+	Hash   string             `bson:"hash"`
+	Scopes []string           `bson:"scopes"`
+
+	ExpiresAt  *time.Time `bson:"expires_at"`
+	LastUsedAt *time.Time `bson:"last_used_at"`
+	LastUsedIP string     `bson:"last_used_ip"`
+	CreatedAt  time.Time  `bson:"created_at"`
+}
+
+func apiKeyModelToRecord(model *domain.APIKey) (*APIKey, error) {
+	id, err := primitive.ObjectIDFromHex(model.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(model.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, len(model.Scopes))
+	for i := range model.Scopes {
+		scopes[i] = string(model.Scopes[i])
+	}
+
+	return &APIKey{
+		ID:         id,
+		UserID:     userID,
+		Name:       model.Name,
+		Prefix:     model.Prefix,
+		Hash:       model.Hash,
+		Scopes:     scopes,
+		ExpiresAt:  model.ExpiresAt,
+		LastUsedAt: model.LastUsedAt,
+		LastUsedIP: model.LastUsedIP,
+		CreatedAt:  model.CreatedAt,
+	}, nil
+}
+
+func apiKeyRecordToModel(rec *APIKey) *domain.APIKey {
+	scopes := make([]domain.Scope, len(rec.Scopes))
+	for i := range rec.Scopes {
+		scopes[i] = domain.Scope(rec.Scopes[i])
+	}
+
+	return &domain.APIKey{
+		ID:         rec.ID.Hex(),
+		UserID:     rec.UserID.Hex(),
+		Name:       rec.Name,
+		Prefix:     rec.Prefix,
+		Hash:       rec.Hash,
+		Scopes:     scopes,
+		ExpiresAt:  rec.ExpiresAt,
+		LastUsedAt: rec.LastUsedAt,
+		LastUsedIP: rec.LastUsedIP,
+		CreatedAt:  rec.CreatedAt,
+	}
+}
+
+func apiKeysRecordToModel(recs []*APIKey) []*domain.APIKey {
+	models := make([]*domain.APIKey, len(recs))
+
+	for i := range recs {
+		models[i] = apiKeyRecordToModel(recs[i])
+	}
+
+	return models
+}
+
+type APIKeysRepo struct {
+	db *mongo.Collection
+}
+
+func NewAPIKeysRepo(db *mongo.Database) *APIKeysRepo {
+	return &APIKeysRepo{
+		db: db.Collection(apiKeysCollection),
+	}
+}
+
+func (r *APIKeysRepo) Create(ctx context.Context, key *domain.APIKey) error {
+	key.ID = primitive.NewObjectID().Hex()
+
+	rec, err := apiKeyModelToRecord(key)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.InsertOne(ctx, rec)
+	return wrapError(err)
+}
+
+func (r *APIKeysRepo) GetMany(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	var keys []*APIKey
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	cursor, err := r.db.Find(ctx, bson.M{"user_id": userObjectID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return apiKeysRecordToModel(keys), nil
+}
+
+// db.api_keys.createIndex( { "prefix": 1 }, { unique: true } )
+func (r *APIKeysRepo) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	var key APIKey
+
+	err := r.db.FindOne(ctx, bson.M{"prefix": prefix}).Decode(&key)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return apiKeyRecordToModel(&key), nil
+}
+
+func (r *APIKeysRepo) Delete(ctx context.Context, userID, keyID string) error {
+	objectID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.DeleteOne(ctx, bson.M{"_id": objectID, "user_id": userObjectID})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+func (r *APIKeysRepo) DeleteByUserID(ctx context.Context, userID string) error {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.DeleteMany(ctx, bson.M{"user_id": userObjectID})
+	return wrapError(err)
+}
+
+func (r *APIKeysRepo) UpdateLastUsed(ctx context.Context, keyID string, usedAt time.Time, ip string) error {
+	objectID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{
+		"$set": bson.M{
+			"last_used_at": usedAt,
+			"last_used_ip": ip,
+		},
+	})
+	return wrapError(err)
+}
@@ -0,0 +1,212 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	invoicesCollection        = "invoices"
+	invoiceCountersCollection = "invoice_counters"
+)
+
+type Invoice struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Number   string             `bson:"number,omitempty"`
+	OrderID  primitive.ObjectID `bson:"order_id"`
+	UserID   primitive.ObjectID `bson:"user_id"`
+	Seller   InvoiceParty       `bson:"seller"`
+	Buyer    InvoiceParty       `bson:"buyer"`
+	Items    []*InvoiceItem     `bson:"items"`
+	Currency string             `bson:"currency"`
+	IssuedAt time.Time          `bson:"issued_at"`
+}
+
+type InvoiceParty struct {
+	Name    string `bson:"name"`
+	Address string `bson:"address"`
+	Email   string `bson:"email"`
+	TaxID   string `bson:"tax_id"`
+}
+
+type InvoiceItem struct {
+	Description string `bson:"description"`
+	Quantity    int    `bson:"quantity"`
+	UnitPrice   int64  `bson:"unit_price"`
+}
+
+// invoiceCounter is the last number issued in the year, the id is the year. It can fall behind
+// the numbers taken by the invoices, the unique index of the numbers is what keeps them unique.
+type invoiceCounter struct {
+	ID       string `bson:"_id"`
+	Sequence int    `bson:"sequence"`
+}
+
+func invoiceModelToRecord(model *domain.Invoice) (*Invoice, error) {
+	id, err := primitive.ObjectIDFromHex(model.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID, err := primitive.ObjectIDFromHex(model.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(model.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*InvoiceItem, len(model.Items))
+	for i, item := range model.Items {
+		items[i] = &InvoiceItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		}
+	}
+
+	return &Invoice{
+		ID:       id,
+		Number:   model.Number,
+		OrderID:  orderID,
+		UserID:   userID,
+		Seller:   InvoiceParty(model.Seller),
+		Buyer:    InvoiceParty(model.Buyer),
+		Items:    items,
+		Currency: model.Currency,
+		IssuedAt: model.IssuedAt,
+	}, nil
+}
+
+func invoiceRecordToModel(rec *Invoice) *domain.Invoice {
+	items := make([]*domain.InvoiceItem, len(rec.Items))
+	for i, item := range rec.Items {
+		items[i] = &domain.InvoiceItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		}
+	}
+
+	return &domain.Invoice{
+		ID:       rec.ID.Hex(),
+		Number:   rec.Number,
+		OrderID:  rec.OrderID.Hex(),
+		UserID:   rec.UserID.Hex(),
+		Seller:   domain.InvoiceParty(rec.Seller),
+		Buyer:    domain.InvoiceParty(rec.Buyer),
+		Items:    items,
+		Currency: rec.Currency,
+		IssuedAt: rec.IssuedAt,
+	}
+}
+
+type InvoicesRepo struct {
+	db       *mongo.Collection
+	counters *mongo.Collection
+}
+
+func NewInvoicesRepo(db *mongo.Database) *InvoicesRepo {
+	return &InvoicesRepo{
+		db:       db.Collection(invoicesCollection),
+		counters: db.Collection(invoiceCountersCollection),
+	}
+}
+
+// LastSequence returns the last sequence number issued in the year, it is 0 before the first invoice of the year.
+func (r *InvoicesRepo) LastSequence(ctx context.Context, year int) (int, error) {
+	var counter invoiceCounter
+
+	err := r.counters.FindOne(ctx, bson.M{"_id": strconv.Itoa(year)}).Decode(&counter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return counter.Sequence, nil
+}
+
+// db.invoices.createIndex( { "order_id": 1 }, { unique: true } )
+// db.invoices.createIndex( { "number": 1 }, { unique: true, sparse: true } )
+//
+// Create stores the invoice, the invoice is created without the number and numbered with SetNumber
+// so that no number is taken by an invoice which failed to be created.
+func (r *InvoicesRepo) Create(ctx context.Context, invoice *domain.Invoice) error {
+	invoice.ID = primitive.NewObjectID().Hex()
+
+	rec, err := invoiceModelToRecord(invoice)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.InsertOne(ctx, rec)
+
+	return wrapError(err)
+}
+
+// SetNumber numbers the invoice which has no number yet, it returns ErrAlreadyExist if the number
+// is taken by another invoice and ErrNotFound if the invoice is already numbered.
+func (r *InvoicesRepo) SetNumber(ctx context.Context, invoiceID string, year, sequence int, number string) error {
+	id, err := primitive.ObjectIDFromHex(invoiceID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{
+		"_id":    id,
+		"number": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{"number": number},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	// the invoice keeps the number even if the counter is not moved, the next invoice then
+	// finds the number taken and takes the one after it
+	_, err = r.counters.UpdateOne(
+		ctx,
+		bson.M{"_id": strconv.Itoa(year)},
+		bson.M{"$max": bson.M{"sequence": sequence}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		logger.Errorf("failed to move the invoice counter of %d to %d: %s", year, sequence, err.Error())
+	}
+
+	return nil
+}
+
+func (r *InvoicesRepo) GetByOrderID(ctx context.Context, orderID string) (*domain.Invoice, error) {
+	var rec Invoice
+
+	orderObjectID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	err = r.db.FindOne(ctx, bson.M{"order_id": orderObjectID}).Decode(&rec)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return invoiceRecordToModel(&rec), nil
+}
@@ -5,18 +5,30 @@ import (
 	"time"
 
 	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/domain/dto"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const messagesCollection = "messages"
 
 type Message struct {
-	ID        primitive.ObjectID `bson:"_id"`
-	Text      string             `bson:"text"`
-	CreatedAt time.Time          `bson:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at"`
+	ID     primitive.ObjectID `bson:"_id"`
+	UserID primitive.ObjectID `bson:"user_id"`
+
+	Text        string `bson:"text"`
+	RequestType string `bson:"request_type"`
+
+	IsGenerated      bool    `bson:"is_generated"`
+	GeneratedPercent float64 `bson:"generated_percent"`
+	ModelVersion     string  `bson:"model_version"`
+	Language         string  `bson:"language"`
+
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
 }
 
 type MessagesRepo struct {
@@ -30,16 +42,184 @@ func NewMessagesRepo(db *mongo.Database) *MessagesRepo {
 }
 
 func (r *MessagesRepo) Create(ctx context.Context, message *domain.Message) error {
+	message.ID = primitive.NewObjectID().Hex()
+
 	_, err := r.db.InsertOne(ctx, r.marshalMessage(message))
 	return wrapError(err)
 }
 
+// db.messages.createIndex( { "text": "text" } )
+func (r *MessagesRepo) GetMany(ctx context.Context, filter dto.MessageFilter) ([]*domain.Message, int64, error) {
+	var messages []*Message
+
+	queryFilter, err := r.buildFilter(filter)
+	if err != nil {
+		return nil, 0, wrapError(err)
+	}
+
+	total, err := r.db.CountDocuments(ctx, queryFilter)
+	if err != nil {
+		return nil, 0, wrapError(err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetSkip(filter.Offset)
+
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := r.db.Find(ctx, queryFilter, opts)
+	if err != nil {
+		return nil, 0, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, 0, wrapError(err)
+	}
+
+	return r.unmarshalMessages(messages), total, nil
+}
+
+func (r *MessagesRepo) GetOne(ctx context.Context, userID, id string) (*domain.Message, error) {
+	var message *Message
+
+	filter, err := r.ownerFilter(userID, id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	err = r.db.FindOne(ctx, filter).Decode(&message)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return r.unmarshalMessage(message), nil
+}
+
+func (r *MessagesRepo) Delete(ctx context.Context, userID, id string) error {
+	filter, err := r.ownerFilter(userID, id)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.DeleteOne(ctx, filter)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+func (r *MessagesRepo) DeleteByUserID(ctx context.Context, userID string) error {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.DeleteMany(ctx, bson.M{"user_id": userObjectID})
+	return wrapError(err)
+}
+
+func (r *MessagesRepo) ownerFilter(userID, id string) (bson.M, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{
+		"_id":     objectID,
+		"user_id": userObjectID,
+	}, nil
+}
+
+func (r *MessagesRepo) buildFilter(filter dto.MessageFilter) (bson.M, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	queryFilter := bson.M{"user_id": userObjectID}
+
+	createdAt := bson.M{}
+	if filter.From != nil {
+		createdAt["$gte"] = *filter.From
+	}
+
+	if filter.To != nil {
+		createdAt["$lt"] = *filter.To
+	}
+
+	if len(createdAt) > 0 {
+		queryFilter["created_at"] = createdAt
+	}
+
+	if filter.IsGenerated != nil {
+		queryFilter["is_generated"] = *filter.IsGenerated
+	}
+
+	if filter.Search != "" {
+		queryFilter["$text"] = bson.M{"$search": filter.Search}
+	}
+
+	return queryFilter, nil
+}
+
 func (r *MessagesRepo) marshalMessage(message *domain.Message) *Message {
+	objectID, _ := primitive.ObjectIDFromHex(message.ID)
+	userID, _ := primitive.ObjectIDFromHex(message.UserID)
+
 	return &Message{
-		ID: primitive.NewObjectID(),
+		ID:     objectID,
+		UserID: userID,
+
+		Text:        message.Text,
+		RequestType: string(message.RequestType),
+
+		IsGenerated:      message.IsGenerated,
+		GeneratedPercent: message.GeneratedPercent,
+		ModelVersion:     message.ModelVersion,
+		Language:         message.Language,
 
-		Text:      message.Text,
 		CreatedAt: message.CreatedAt,
 		UpdatedAt: message.UpdatedAt,
 	}
 }
+
+func (r *MessagesRepo) unmarshalMessage(rec *Message) *domain.Message {
+	return &domain.Message{
+		ID:     rec.ID.Hex(),
+		UserID: rec.UserID.Hex(),
+
+		Text:        rec.Text,
+		RequestType: domain.RequestType(rec.RequestType),
+
+		IsGenerated:      rec.IsGenerated,
+		GeneratedPercent: rec.GeneratedPercent,
+		ModelVersion:     rec.ModelVersion,
+		Language:         rec.Language,
+
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+	}
+}
+
+func (r *MessagesRepo) unmarshalMessages(recs []*Message) []*domain.Message {
+	models := make([]*domain.Message, len(recs))
+
+	for i := range recs {
+		models[i] = r.unmarshalMessage(recs[i])
+	}
+
+	return models
+}
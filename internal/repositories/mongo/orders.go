@@ -4,25 +4,33 @@ import (
 	"context"
 	"time"
 
+	core "necutya/faker/internal/domain"
 	"necutya/faker/internal/domain/domain"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const ordersCollection = "orders"
 
 type Order struct {
-	ID           primitive.ObjectID `bson:"_id"`
-	PlanID       primitive.ObjectID `bson:"plan_id"`
-	UserID       primitive.ObjectID `bson:"user_id"`
-	Description  string             `bson:"description"`
-	Status       string             `bson:"status"`
-	Amount       int                `bson:"amount"`
-	Currency     string             `bson:"currency"`
-	Transactions []*Transaction     `bson:"transactions"`
-	CreatedAt    time.Time          `bson:"created_at"`
+	ID             primitive.ObjectID `bson:"_id"`
+	PlanID         primitive.ObjectID `bson:"plan_id"`
+	UserID         primitive.ObjectID `bson:"user_id"`
+	SubscriptionID string             `bson:"subscription_id,omitempty"`
+	Description    string             `bson:"description"`
+	Status         string             `bson:"status"`
+	Amount         int                `bson:"amount"`
+	Credit         int64              `bson:"credit"`
+	Currency       string             `bson:"currency"`
+	Transactions   []*Transaction     `bson:"transactions"`
+	PendingRefunds []*PendingRefund   `bson:"pending_refunds,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"`
+
+	// PlanActivationPending is absent in the orders paid before it was added, their plans are activated.
+	PlanActivationPending bool `bson:"plan_activation_pending,omitempty"`
 }
 
 func orderModelToRecord(model *domain.Order) *Order {
@@ -31,29 +39,39 @@ func orderModelToRecord(model *domain.Order) *Order {
 	userID, _ := primitive.ObjectIDFromHex(model.UserID)
 
 	return &Order{
-		ID:           objectID,
-		PlanID:       planID,
-		UserID:       userID,
-		Description:  model.Description,
-		Status:       model.Status,
-		Amount:       model.Amount,
-		Currency:     model.Currency,
-		CreatedAt:    model.CreatedAt,
-		Transactions: transactionsModelToRecord(model.Transactions),
+		ID:             objectID,
+		PlanID:         planID,
+		UserID:         userID,
+		SubscriptionID: model.SubscriptionID,
+		Description:    model.Description,
+		Status:         model.Status,
+		Amount:         model.Amount,
+		Credit:         model.Credit,
+		Currency:       model.Currency,
+		CreatedAt:      model.CreatedAt,
+		Transactions:   transactionsModelToRecord(model.Transactions),
+		PendingRefunds: pendingRefundsModelToRecord(model.PendingRefunds),
+
+		PlanActivationPending: model.PlanActivationPending,
 	}
 }
 
 func orderRecordToModel(rec *Order) *domain.Order {
 	return &domain.Order{
-		ID:           rec.ID.Hex(),
-		PlanID:       rec.PlanID.Hex(),
-		UserID:       rec.UserID.Hex(),
-		Description:  rec.Description,
-		Status:       rec.Status,
-		Amount:       rec.Amount,
-		Currency:     rec.Currency,
-		CreatedAt:    rec.CreatedAt,
-		Transactions: transactionsRecordToModel(rec.Transactions),
+		ID:             rec.ID.Hex(),
+		PlanID:         rec.PlanID.Hex(),
+		UserID:         rec.UserID.Hex(),
+		SubscriptionID: rec.SubscriptionID,
+		Description:    rec.Description,
+		Status:         rec.Status,
+		Amount:         rec.Amount,
+		Credit:         rec.Credit,
+		Currency:       rec.Currency,
+		CreatedAt:      rec.CreatedAt,
+		Transactions:   transactionsRecordToModel(rec.Transactions),
+		PendingRefunds: pendingRefundsRecordToModel(rec.PendingRefunds),
+
+		PlanActivationPending: rec.PlanActivationPending,
 	}
 }
 
@@ -68,14 +86,20 @@ func ordersRecordToModel(recs []*Order) []*domain.Order {
 }
 
 type Transaction struct {
+	IdempotencyKey string    `bson:"idempotency_key"`
+	PaymentID      string    `bson:"payment_id"`
 	Status         string    `bson:"status"`
+	RefundAmount   int64     `bson:"refund_amount"`
 	CreatedAt      time.Time `bson:"created_at"`
 	AdditionalInfo string    `bson:"additional_info"`
 }
 
 func transactionModelToRecord(model *domain.Transaction) *Transaction {
 	return &Transaction{
+		IdempotencyKey: model.IdempotencyKey,
+		PaymentID:      model.PaymentID,
 		Status:         model.Status,
+		RefundAmount:   model.RefundAmount,
 		CreatedAt:      model.CreatedAt,
 		AdditionalInfo: model.AdditionalInfo,
 	}
@@ -93,7 +117,10 @@ func transactionsModelToRecord(models []*domain.Transaction) []*Transaction {
 
 func transactionRecordToModel(rec *Transaction) *domain.Transaction {
 	return &domain.Transaction{
+		IdempotencyKey: rec.IdempotencyKey,
+		PaymentID:      rec.PaymentID,
 		Status:         rec.Status,
+		RefundAmount:   rec.RefundAmount,
 		CreatedAt:      rec.CreatedAt,
 		AdditionalInfo: rec.AdditionalInfo,
 	}
@@ -109,6 +136,33 @@ func transactionsRecordToModel(recs []*Transaction) []*domain.Transaction {
 	return models
 }
 
+type PendingRefund struct {
+	ID        string    `bson:"id"`
+	Amount    int64     `bson:"amount"`
+	Comment   string    `bson:"comment"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func pendingRefundsModelToRecord(models []*domain.PendingRefund) []*PendingRefund {
+	recs := make([]*PendingRefund, len(models))
+
+	for i, model := range models {
+		recs[i] = (*PendingRefund)(model)
+	}
+
+	return recs
+}
+
+func pendingRefundsRecordToModel(recs []*PendingRefund) []*domain.PendingRefund {
+	models := make([]*domain.PendingRefund, len(recs))
+
+	for i, rec := range recs {
+		models[i] = (*domain.PendingRefund)(rec)
+	}
+
+	return models
+}
+
 type OrdersRepo struct {
 	db *mongo.Collection
 }
@@ -144,13 +198,14 @@ func (r *OrdersRepo) GetByID(ctx context.Context, id string) (*domain.Order, err
 	return orderRecordToModel(order), wrapError(err)
 }
 
+// GetByUserID returns the orders of the user, the latest first.
 func (r *OrdersRepo) GetByUserID(ctx context.Context, userID string) ([]*domain.Order, error) {
 	userObjectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, wrapError(err)
 	}
 
-	cur, err := r.db.Find(ctx, bson.M{"user_id": userObjectID})
+	cur, err := r.db.Find(ctx, bson.M{"user_id": userObjectID}, options.Find().SetSort(bson.M{"created_at": -1}))
 	if err != nil {
 		return nil, wrapError(err)
 	}
@@ -163,31 +218,175 @@ func (r *OrdersRepo) GetByUserID(ctx context.Context, userID string) ([]*domain.
 	return ordersRecordToModel(orders), wrapError(err)
 }
 
+// AddTransaction pushes the transaction and sets its status if the order is still in fromStatus,
+// the paid transaction also marks the plan activation as pending.
+// ErrAlreadyExist is returned if the transaction with the same idempotency key was already added,
+// ErrNotFound if there is no such order or its status has changed.
 func (r *OrdersRepo) AddTransaction(
 	ctx context.Context,
 	orderID string,
+	fromStatus string,
 	transaction *domain.Transaction,
-) (*domain.Order, error) {
-	var order domain.Order
+) error {
+	set := bson.M{}
+	if transaction.Status == domain.PaidOrderStatus {
+		set["plan_activation_pending"] = true
+	}
 
+	return r.pushTransaction(ctx, orderID, fromStatus, transaction, bson.M{}, bson.M{"$set": set})
+}
+
+// CompletePlanActivation clears the pending plan activation of the order.
+func (r *OrdersRepo) CompletePlanActivation(ctx context.Context, orderID string) error {
 	orderObjectID, err := primitive.ObjectIDFromHex(orderID)
 	if err != nil {
-		return nil, wrapError(err)
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{"_id": orderObjectID}, bson.M{
+		"$unset": bson.M{"plan_activation_pending": ""},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// ReserveRefund adds the pending refund to the paid order if the refunded and the pending amounts
+// with it do not exceed the total of the order in cents, ErrNotFound is returned otherwise.
+func (r *OrdersRepo) ReserveRefund(ctx context.Context, orderID string, refund *domain.PendingRefund, total int64) error {
+	orderObjectID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return wrapError(err)
 	}
 
-	res := r.db.FindOneAndUpdate(ctx, bson.M{"_id": orderObjectID}, bson.M{
-		"$set": bson.M{
-			"status": transaction.Status,
+	refund.ID = primitive.NewObjectID().Hex()
+
+	res, err := r.db.UpdateOne(ctx, bson.M{
+		"_id":    orderObjectID,
+		"status": bson.M{"$in": bson.A{domain.PaidOrderStatus, domain.PartiallyRefundedOrderStatus}},
+		"$expr": bson.M{
+			"$lte": bson.A{
+				bson.M{"$add": bson.A{
+					bson.M{"$sum": "$transactions.refund_amount"},
+					bson.M{"$sum": "$pending_refunds.amount"},
+					refund.Amount,
+				}},
+				total,
+			},
 		},
+	}, bson.M{
 		"$push": bson.M{
-			"transactions": transactionModelToRecord(transaction),
+			"pending_refunds": (*PendingRefund)(refund),
 		},
 	})
-	if res.Err() != nil {
-		return nil, wrapError(res.Err())
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
 	}
 
-	err = res.Decode(&order)
+	return nil
+}
+
+// CompleteRefund replaces the pending refund with its transaction, the same as AddTransaction does.
+// ErrNotFound is also returned if the pending refund was already completed or released.
+func (r *OrdersRepo) CompleteRefund(
+	ctx context.Context,
+	orderID string,
+	fromStatus string,
+	refundID string,
+	transaction *domain.Transaction,
+) error {
+	return r.pushTransaction(ctx, orderID, fromStatus, transaction, bson.M{
+		"pending_refunds.id": refundID,
+	}, bson.M{
+		"$pull": bson.M{"pending_refunds": bson.M{"id": refundID}},
+	})
+}
+
+// ReleaseRefund removes the pending refund which was not made, ErrNotFound is returned
+// if it was already completed or released.
+func (r *OrdersRepo) ReleaseRefund(ctx context.Context, orderID, refundID string) error {
+	orderObjectID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{
+		"_id":                orderObjectID,
+		"pending_refunds.id": refundID,
+	}, bson.M{
+		"$pull": bson.M{"pending_refunds": bson.M{"id": refundID}},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// pushTransaction adds the transaction as AddTransaction does, the filter and the update
+// are extended with the extra conditions and changes.
+func (r *OrdersRepo) pushTransaction(
+	ctx context.Context,
+	orderID string,
+	fromStatus string,
+	transaction *domain.Transaction,
+	filter bson.M,
+	update bson.M,
+) error {
+	orderObjectID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	filter["_id"] = orderObjectID
+	filter["status"] = fromStatus
+	filter["transactions.idempotency_key"] = bson.M{"$ne": transaction.IdempotencyKey}
+
+	set, _ := update["$set"].(bson.M)
+	if set == nil {
+		set = bson.M{}
+	}
+
+	set["status"] = transaction.Status
+	update["$set"] = set
+	update["$push"] = bson.M{
+		"transactions": transactionModelToRecord(transaction),
+	}
+
+	res, err := r.db.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount != 0 {
+		return nil
+	}
+
+	duplicates, err := r.db.CountDocuments(ctx, bson.M{
+		"_id":                          orderObjectID,
+		"transactions.idempotency_key": transaction.IdempotencyKey,
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if duplicates != 0 {
+		return core.ErrAlreadyExist
+	}
 
-	return &order, err
+	return wrapError(mongo.ErrNoDocuments)
 }
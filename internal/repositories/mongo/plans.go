@@ -24,6 +24,10 @@ type Plan struct {
 	Duration              int                `bson:"month_duration"`
 	InternalRequestsCount int                `bson:"internal_request_count"`
 	ExternalRequestsCount int                `bson:"external_request_count"`
+	MaxFileSize           int                `bson:"max_file_size"`
+	MaxDocumentLength     int                `bson:"max_document_length"`
+	CountCachedRequests   bool               `bson:"count_cached_requests"`
+	SupportedLanguages    []string           `bson:"supported_languages"`
 
 	CreatedAt time.Time `bson:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at"`
@@ -85,6 +89,38 @@ func (r *PlansRepo) GetMany(ctx context.Context) ([]*domain.Plan, error) {
 	return plansRecordToModel(plans), nil
 }
 
+func (r *PlansRepo) Update(ctx context.Context, plan *domain.Plan) error {
+	objectID, err := primitive.ObjectIDFromHex(plan.ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{
+		"$set": bson.M{
+			"descriptions":           plan.Description,
+			"options":                plan.Options,
+			"price":                  plan.Price,
+			"month_duration":         plan.Duration,
+			"internal_request_count": plan.InternalRequestsCount,
+			"external_request_count": plan.ExternalRequestsCount,
+			"max_file_size":          plan.MaxFileSize,
+			"max_document_length":    plan.MaxDocumentLength,
+			"count_cached_requests":  plan.CountCachedRequests,
+			"supported_languages":    plan.SupportedLanguages,
+			"updated_at":             time.Now(),
+		},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
 func planRecordToModel(rec *Plan) *domain.Plan {
 	return &domain.Plan{
 		ID:                    rec.ID.Hex(),
@@ -95,6 +131,10 @@ func planRecordToModel(rec *Plan) *domain.Plan {
 		Duration:              rec.Duration,
 		InternalRequestsCount: rec.InternalRequestsCount,
 		ExternalRequestsCount: rec.ExternalRequestsCount,
+		MaxFileSize:           rec.MaxFileSize,
+		MaxDocumentLength:     rec.MaxDocumentLength,
+		CountCachedRequests:   rec.CountCachedRequests,
+		SupportedLanguages:    rec.SupportedLanguages,
 	}
 }
 
@@ -0,0 +1,105 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const rolesCollection = "roles"
+
+type RolePermissions struct {
+	Role        string    `bson:"_id"`
+	Permissions []string  `bson:"permissions"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+func rolePermissionsModelToRecord(model *domain.RolePermissions) *RolePermissions {
+	permissions := make([]string, len(model.Permissions))
+	for i := range model.Permissions {
+		permissions[i] = string(model.Permissions[i])
+	}
+
+	return &RolePermissions{
+		Role:        string(model.Role),
+		Permissions: permissions,
+		UpdatedAt:   model.UpdatedAt,
+	}
+}
+
+func rolePermissionsRecordToModel(rec *RolePermissions) *domain.RolePermissions {
+	permissions := make([]domain.Permission, len(rec.Permissions))
+	for i := range rec.Permissions {
+		permissions[i] = domain.Permission(rec.Permissions[i])
+	}
+
+	return &domain.RolePermissions{
+		Role:        domain.Role(rec.Role),
+		Permissions: permissions,
+		UpdatedAt:   rec.UpdatedAt,
+	}
+}
+
+type RolesRepo struct {
+	db *mongo.Collection
+}
+
+func NewRolesRepo(db *mongo.Database) *RolesRepo {
+	return &RolesRepo{
+		db: db.Collection(rolesCollection),
+	}
+}
+
+// Get returns the default permissions of the role until they are edited for the first time.
+func (r *RolesRepo) Get(ctx context.Context, role domain.Role) (*domain.RolePermissions, error) {
+	var rec RolePermissions
+
+	err := r.db.FindOne(ctx, bson.M{"_id": string(role)}).Decode(&rec)
+	if err == mongo.ErrNoDocuments {
+		return &domain.RolePermissions{
+			Role:        role,
+			Permissions: domain.DefaultRolePermissions[role],
+		}, nil
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return rolePermissionsRecordToModel(&rec), nil
+}
+
+// GetMany returns only the edited roles.
+func (r *RolesRepo) GetMany(ctx context.Context) ([]*domain.RolePermissions, error) {
+	var recs []*RolePermissions
+
+	cursor, err := r.db.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &recs); err != nil {
+		return nil, wrapError(err)
+	}
+
+	models := make([]*domain.RolePermissions, len(recs))
+	for i := range recs {
+		models[i] = rolePermissionsRecordToModel(recs[i])
+	}
+
+	return models, nil
+}
+
+func (r *RolesRepo) Update(ctx context.Context, permissions *domain.RolePermissions) error {
+	_, err := r.db.ReplaceOne(
+		ctx,
+		bson.M{"_id": string(permissions.Role)},
+		rolePermissionsModelToRecord(permissions),
+		options.Replace().SetUpsert(true),
+	)
+	return wrapError(err)
+}
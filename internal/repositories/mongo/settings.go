@@ -0,0 +1,84 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	settingsCollection = "settings"
+
+	securitySettingsID = "security"
+)
+
+type SecuritySettings struct {
+	ID                     string    `bson:"_id"`
+	TwoFactorRequiredRoles []string  `bson:"two_factor_required_roles"`
+	UpdatedAt              time.Time `bson:"updated_at"`
+}
+
+func securitySettingsModelToRecord(model *domain.SecuritySettings) *SecuritySettings {
+	roles := make([]string, len(model.TwoFactorRequiredRoles))
+	for i := range model.TwoFactorRequiredRoles {
+		roles[i] = string(model.TwoFactorRequiredRoles[i])
+	}
+
+	return &SecuritySettings{
+		ID:                     securitySettingsID,
+		TwoFactorRequiredRoles: roles,
+		UpdatedAt:              model.UpdatedAt,
+	}
+}
+
+func securitySettingsRecordToModel(rec *SecuritySettings) *domain.SecuritySettings {
+	roles := make([]domain.Role, len(rec.TwoFactorRequiredRoles))
+	for i := range rec.TwoFactorRequiredRoles {
+		roles[i] = domain.Role(rec.TwoFactorRequiredRoles[i])
+	}
+
+	return &domain.SecuritySettings{
+		TwoFactorRequiredRoles: roles,
+		UpdatedAt:              rec.UpdatedAt,
+	}
+}
+
+type SettingsRepo struct {
+	db *mongo.Collection
+}
+
+func NewSettingsRepo(db *mongo.Database) *SettingsRepo {
+	return &SettingsRepo{
+		db: db.Collection(settingsCollection),
+	}
+}
+
+// GetSecuritySettings returns empty settings until they are saved for the first time.
+func (r *SettingsRepo) GetSecuritySettings(ctx context.Context) (*domain.SecuritySettings, error) {
+	var settings SecuritySettings
+
+	err := r.db.FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return &domain.SecuritySettings{}, nil
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return securitySettingsRecordToModel(&settings), nil
+}
+
+func (r *SettingsRepo) UpdateSecuritySettings(ctx context.Context, settings *domain.SecuritySettings) error {
+	_, err := r.db.ReplaceOne(
+		ctx,
+		bson.M{"_id": securitySettingsID},
+		securitySettingsModelToRecord(settings),
+		options.Replace().SetUpsert(true),
+	)
+	return wrapError(err)
+}
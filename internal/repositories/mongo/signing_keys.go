@@ -0,0 +1,128 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const signingKeysCollection = "signing_keys"
+
+type SigningKey struct {
+	ID          string     `bson:"_id"`
+	Algorithm   string     `bson:"algorithm"`
+	PrivateKey  string     `bson:"private_key"`
+	CreatedAt   time.Time  `bson:"created_at"`
+	ActivatesAt time.Time  `bson:"activates_at"`
+	ExpiresAt   *time.Time `bson:"expires_at"`
+}
+
+func signingKeyModelToRecord(model *domain.SigningKey) *SigningKey {
+	return &SigningKey{
+		ID:          model.ID,
+		Algorithm:   model.Algorithm,
+		PrivateKey:  model.PrivateKey,
+		CreatedAt:   model.CreatedAt,
+		ActivatesAt: model.ActivatesAt,
+		ExpiresAt:   model.ExpiresAt,
+	}
+}
+
+func signingKeyRecordToModel(rec *SigningKey) *domain.SigningKey {
+	return &domain.SigningKey{
+		ID:          rec.ID,
+		Algorithm:   rec.Algorithm,
+		PrivateKey:  rec.PrivateKey,
+		CreatedAt:   rec.CreatedAt,
+		ActivatesAt: rec.ActivatesAt,
+		ExpiresAt:   rec.ExpiresAt,
+	}
+}
+
+type SigningKeysRepo struct {
+	db *mongo.Collection
+}
+
+func NewSigningKeysRepo(db *mongo.Database) *SigningKeysRepo {
+	return &SigningKeysRepo{
+		db: db.Collection(signingKeysCollection),
+	}
+}
+
+func (r *SigningKeysRepo) Create(ctx context.Context, key *domain.SigningKey) error {
+	_, err := r.db.InsertOne(ctx, signingKeyModelToRecord(key))
+	return wrapError(err)
+}
+
+// CreateIfNotExists inserts the key unless a key with its ID exists and returns the stored key.
+func (r *SigningKeysRepo) CreateIfNotExists(ctx context.Context, key *domain.SigningKey) (*domain.SigningKey, error) {
+	rec := signingKeyModelToRecord(key)
+
+	var stored SigningKey
+
+	err := r.db.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": rec.ID},
+		bson.M{"$setOnInsert": bson.M{
+			"algorithm":    rec.Algorithm,
+			"private_key":  rec.PrivateKey,
+			"created_at":   rec.CreatedAt,
+			"activates_at": rec.ActivatesAt,
+			"expires_at":   rec.ExpiresAt,
+		}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&stored)
+	if mongo.IsDuplicateKeyError(err) {
+		// the key was inserted by a concurrent upsert
+		err = r.db.FindOne(ctx, bson.M{"_id": rec.ID}).Decode(&stored)
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return signingKeyRecordToModel(&stored), nil
+}
+
+// GetMany returns keys which have not expired yet, the oldest first.
+func (r *SigningKeysRepo) GetMany(ctx context.Context, now time.Time) ([]*domain.SigningKey, error) {
+	var results []*SigningKey
+
+	cursor, err := r.db.Find(
+		ctx,
+		bson.M{"$or": []bson.M{
+			{"expires_at": nil},
+			{"expires_at": bson.M{"$gt": now}},
+		}},
+		options.Find().SetSort(bson.D{{Key: "activates_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, wrapError(err)
+	}
+
+	keys := make([]*domain.SigningKey, len(results))
+	for i := range results {
+		keys[i] = signingKeyRecordToModel(results[i])
+	}
+
+	return keys, nil
+}
+
+// Retire sets the expiration of all keys which do not expire yet.
+func (r *SigningKeysRepo) Retire(ctx context.Context, expiresAt time.Time) error {
+	_, err := r.db.UpdateMany(ctx, bson.M{"expires_at": nil}, bson.M{"$set": bson.M{"expires_at": expiresAt}})
+	return wrapError(err)
+}
+
+func (r *SigningKeysRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.db.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+	return wrapError(err)
+}
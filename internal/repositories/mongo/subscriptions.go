@@ -0,0 +1,233 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const subscriptionsCollection = "subscriptions"
+
+type Subscription struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	UserID   primitive.ObjectID `bson:"user_id"`
+	PlanID   primitive.ObjectID `bson:"plan_id"`
+	Status   string             `bson:"status"`
+	Rectoken string             `bson:"rectoken"`
+
+	CurrentPeriodStart time.Time `bson:"current_period_start"`
+	CurrentPeriodEnd   time.Time `bson:"current_period_end"`
+	CancelAtPeriodEnd  bool      `bson:"cancel_at_period_end"`
+	PaidOrderID        string    `bson:"paid_order_id"`
+
+	RenewalOrderID  string    `bson:"renewal_order_id"`
+	RenewalAttempts int       `bson:"renewal_attempts"`
+	NextRenewalAt   time.Time `bson:"next_renewal_at"`
+
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func subscriptionModelToRecord(model *domain.Subscription) (*Subscription, error) {
+	id, err := primitive.ObjectIDFromHex(model.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(model.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	planID, err := primitive.ObjectIDFromHex(model.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscription{
+		ID:                 id,
+		UserID:             userID,
+		PlanID:             planID,
+		Status:             model.Status,
+		Rectoken:           model.Rectoken,
+		CurrentPeriodStart: model.CurrentPeriodStart,
+		CurrentPeriodEnd:   model.CurrentPeriodEnd,
+		CancelAtPeriodEnd:  model.CancelAtPeriodEnd,
+		PaidOrderID:        model.PaidOrderID,
+		RenewalOrderID:     model.RenewalOrderID,
+		RenewalAttempts:    model.RenewalAttempts,
+		NextRenewalAt:      model.NextRenewalAt,
+		CreatedAt:          model.CreatedAt,
+		UpdatedAt:          model.UpdatedAt,
+	}, nil
+}
+
+func subscriptionRecordToModel(rec *Subscription) *domain.Subscription {
+	return &domain.Subscription{
+		ID:                 rec.ID.Hex(),
+		UserID:             rec.UserID.Hex(),
+		PlanID:             rec.PlanID.Hex(),
+		Status:             rec.Status,
+		Rectoken:           rec.Rectoken,
+		CurrentPeriodStart: rec.CurrentPeriodStart,
+		CurrentPeriodEnd:   rec.CurrentPeriodEnd,
+		CancelAtPeriodEnd:  rec.CancelAtPeriodEnd,
+		PaidOrderID:        rec.PaidOrderID,
+		RenewalOrderID:     rec.RenewalOrderID,
+		RenewalAttempts:    rec.RenewalAttempts,
+		NextRenewalAt:      rec.NextRenewalAt,
+		CreatedAt:          rec.CreatedAt,
+		UpdatedAt:          rec.UpdatedAt,
+	}
+}
+
+func subscriptionsRecordToModel(recs []*Subscription) []*domain.Subscription {
+	models := make([]*domain.Subscription, len(recs))
+
+	for i := range recs {
+		models[i] = subscriptionRecordToModel(recs[i])
+	}
+
+	return models
+}
+
+type SubscriptionsRepo struct {
+	db *mongo.Collection
+}
+
+func NewSubscriptionsRepo(db *mongo.Database) *SubscriptionsRepo {
+	return &SubscriptionsRepo{
+		db: db.Collection(subscriptionsCollection),
+	}
+}
+
+// db.subscriptions.createIndex( { "user_id": 1 }, { unique: true } )
+func (r *SubscriptionsRepo) GetByUserID(ctx context.Context, userID string) (*domain.Subscription, error) {
+	var rec Subscription
+
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	err = r.db.FindOne(ctx, bson.M{"user_id": userObjectID}).Decode(&rec)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return subscriptionRecordToModel(&rec), nil
+}
+
+// GetDue returns the ongoing subscriptions which current period ends before the time.
+func (r *SubscriptionsRepo) GetDue(ctx context.Context, before time.Time) ([]*domain.Subscription, error) {
+	var recs []*Subscription
+
+	cursor, err := r.db.Find(ctx, bson.M{
+		"status": bson.M{"$in": []string{
+			domain.ActiveSubscriptionStatus,
+			domain.PastDueSubscriptionStatus,
+		}},
+		"current_period_end": bson.M{"$lte": before},
+	}, options.Find().SetSort(bson.M{"current_period_end": 1}))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &recs); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return subscriptionsRecordToModel(recs), nil
+}
+
+// ClaimRenewal moves the next renewal of the subscription to the time only if neither the next renewal
+// nor the renewal order have changed since the subscription was read, ErrNotFound means they have.
+func (r *SubscriptionsRepo) ClaimRenewal(ctx context.Context, subscription *domain.Subscription, until time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(subscription.ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{
+		"_id":              objectID,
+		"renewal_order_id": subscription.RenewalOrderID,
+		"next_renewal_at":  subscription.NextRenewalAt,
+	}, bson.M{
+		"$set": bson.M{
+			"next_renewal_at": until,
+			"updated_at":      time.Now(),
+		},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// SetRenewalOrder records the order the subscription is being renewed with,
+// the other fields are left as they are so a concurrent cancel is not overwritten.
+func (r *SubscriptionsRepo) SetRenewalOrder(ctx context.Context, id, orderID string, now time.Time) error {
+	return r.update(ctx, id, bson.M{
+		"$set": bson.M{
+			"renewal_order_id": orderID,
+			"updated_at":       now,
+		},
+	})
+}
+
+// FailRenewal marks the subscription past due and schedules the next renewal attempt.
+func (r *SubscriptionsRepo) FailRenewal(ctx context.Context, id string, nextRenewalAt, now time.Time) error {
+	return r.update(ctx, id, bson.M{
+		"$set": bson.M{
+			"status":          domain.PastDueSubscriptionStatus,
+			"next_renewal_at": nextRenewalAt,
+			"updated_at":      now,
+		},
+		"$inc": bson.M{"renewal_attempts": 1},
+	})
+}
+
+func (r *SubscriptionsRepo) update(ctx context.Context, id string, update bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// Save replaces the subscription of the user, there is only one per user.
+func (r *SubscriptionsRepo) Save(ctx context.Context, subscription *domain.Subscription) error {
+	if subscription.ID == "" {
+		subscription.ID = primitive.NewObjectID().Hex()
+	}
+
+	rec, err := subscriptionModelToRecord(subscription)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.ReplaceOne(ctx, bson.M{"user_id": rec.UserID}, rec, options.Replace().SetUpsert(true))
+
+	return wrapError(err)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	core "necutya/faker/internal/domain"
 	"necutya/faker/internal/domain/domain"
 	"necutya/faker/internal/domain/dto"
 
@@ -11,12 +12,29 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const usersCollection = "users"
 
-type UserCredential struct {
-	ExternalToken string `bson:"external_token"`
+type UserWebhook struct {
+	URL    string `bson:"url"`
+	Secret string `bson:"secret"`
+}
+
+type UserTwoFactor struct {
+	Secret        string     `bson:"secret"`
+	Enabled       bool       `bson:"enabled"`
+	RecoveryCodes []string   `bson:"recovery_codes"`
+	LastUsedStep  int64      `bson:"last_used_step"`
+	EnabledAt     *time.Time `bson:"enabled_at"`
+}
+
+type UserIdentity struct {
+	Provider string    `bson:"provider"`
+	Subject  string    `bson:"subject"`
+	Email    string    `bson:"email"`
+	LinkedAt time.Time `bson:"linked_at"`
 }
 
 type User struct {
@@ -38,7 +56,9 @@ type User struct {
 	PlanId primitive.ObjectID `bson:"plan_id"`
 
 	Sessions   []*Session      `bson:"sessions"`
-	Credential *UserCredential `bson:"credential"`
+	Webhook    *UserWebhook    `bson:"webhook"`
+	TwoFactor  *UserTwoFactor  `bson:"two_factor"`
+	Identities []*UserIdentity `bson:"identities"`
 	Feedbacks  []*Feedback     `bson:"feedbacks"`
 }
 
@@ -52,19 +72,80 @@ func NewUsersRepo(db *mongo.Database) *UsersRepo {
 	}
 }
 
-func userCredentialModelToRecord(model *domain.UserCredential) *UserCredential {
-	return &UserCredential{
-		ExternalToken: model.ExternalToken,
+func userWebhookModelToRecord(model *domain.UserWebhook) *UserWebhook {
+	if model == nil {
+		return nil
+	}
+
+	return &UserWebhook{
+		URL:    model.URL,
+		Secret: model.Secret,
+	}
+}
+
+func userWebhookRecordToModel(rec *UserWebhook) *domain.UserWebhook {
+	if rec == nil {
+		return nil
+	}
+
+	return &domain.UserWebhook{
+		URL:    rec.URL,
+		Secret: rec.Secret,
+	}
+}
+
+func userTwoFactorModelToRecord(model *domain.UserTwoFactor) *UserTwoFactor {
+	if model == nil {
+		return nil
+	}
+
+	return &UserTwoFactor{
+		Secret:        model.Secret,
+		Enabled:       model.Enabled,
+		RecoveryCodes: model.RecoveryCodes,
+		LastUsedStep:  model.LastUsedStep,
+		EnabledAt:     model.EnabledAt,
+	}
+}
+
+func userTwoFactorRecordToModel(rec *UserTwoFactor) *domain.UserTwoFactor {
+	if rec == nil {
+		return nil
+	}
+
+	return &domain.UserTwoFactor{
+		Secret:        rec.Secret,
+		Enabled:       rec.Enabled,
+		RecoveryCodes: rec.RecoveryCodes,
+		LastUsedStep:  rec.LastUsedStep,
+		EnabledAt:     rec.EnabledAt,
 	}
 }
 
-func userCredentialRecordToModel(rec *UserCredential) *domain.UserCredential {
-	return &domain.UserCredential{
-		ExternalToken: rec.ExternalToken,
+func userIdentityModelToRecord(model *domain.UserIdentity) *UserIdentity {
+	return &UserIdentity{
+		Provider: model.Provider,
+		Subject:  model.Subject,
+		Email:    model.Email,
+		LinkedAt: model.LinkedAt,
+	}
+}
+
+func userIdentityRecordToModel(rec *UserIdentity) *domain.UserIdentity {
+	return &domain.UserIdentity{
+		Provider: rec.Provider,
+		Subject:  rec.Subject,
+		Email:    rec.Email,
+		LinkedAt: rec.LinkedAt,
 	}
 }
 
 func userModelToRecord(model *domain.User) *User {
+	identities := make([]*UserIdentity, len(model.Identities))
+	for i := range model.Identities {
+		identities[i] = userIdentityModelToRecord(model.Identities[i])
+	}
+
 	objectID, _ := primitive.ObjectIDFromHex(model.ID)
 	planID, _ := primitive.ObjectIDFromHex(model.PlanID)
 
@@ -88,11 +169,18 @@ func userModelToRecord(model *domain.User) *User {
 		PlanId: planID,
 
 		Sessions:   []*Session{},
-		Credential: userCredentialModelToRecord(model.Credential),
+		Webhook:    userWebhookModelToRecord(model.Webhook),
+		TwoFactor:  userTwoFactorModelToRecord(model.TwoFactor),
+		Identities: identities,
 	}
 }
 
 func userRecordToModel(rec *User) *domain.User {
+	identities := make([]*domain.UserIdentity, len(rec.Identities))
+	for i := range rec.Identities {
+		identities[i] = userIdentityRecordToModel(rec.Identities[i])
+	}
+
 	return &domain.User{
 		ID:        rec.ID.Hex(),
 		FirstName: rec.FirstName,
@@ -111,7 +199,9 @@ func userRecordToModel(rec *User) *domain.User {
 
 		PlanID: rec.PlanId.Hex(),
 
-		Credential: userCredentialRecordToModel(rec.Credential),
+		Webhook:    userWebhookRecordToModel(rec.Webhook),
+		TwoFactor:  userTwoFactorRecordToModel(rec.TwoFactor),
+		Identities: identities,
 	}
 }
 
@@ -180,11 +270,154 @@ func (r *UsersRepo) GetUserByID(ctx context.Context, id string) (*domain.User, e
 	return userRecordToModel(&user), nil
 }
 
-func (r *UsersRepo) GetUserByExternalToken(ctx context.Context, externalToken string) (*domain.User, error) {
+// db.users.createIndex( { "email": 1 }, { unique: true } )
+func (r *UsersRepo) Create(ctx context.Context, user *domain.User) error {
+	user.ID = primitive.NewObjectID().Hex()
+
+	_, err := r.db.InsertOne(ctx, userModelToRecord(user))
+	return wrapError(err)
+}
+
+func (r *UsersRepo) Update(ctx context.Context, user *domain.User) error {
+	objectID, err := primitive.ObjectIDFromHex(user.ID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	fields, err := bson.Marshal(userModelToRecord(user))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	var set bson.M
+	if err = bson.Unmarshal(fields, &set); err != nil {
+		return wrapError(err)
+	}
+
+	// sessions, identities, feedbacks, the second factor and the webhook are managed by their own methods,
+	// a user read before they changed must not overwrite them
+	delete(set, "_id")
+	delete(set, "sessions")
+	delete(set, "identities")
+	delete(set, "feedbacks")
+	delete(set, "two_factor")
+	delete(set, "webhook")
+
+	_, err = r.db.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": set})
+	return wrapError(err)
+}
+
+// SetTwoFactor updates only the second factor, so the user's sessions are kept.
+func (r *UsersRepo) SetTwoFactor(ctx context.Context, userID string, twoFactor *domain.UserTwoFactor) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{
+		"$set": bson.M{
+			"two_factor": userTwoFactorModelToRecord(twoFactor),
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// SetWebhook updates only the webhook, it is removed when the webhook is nil.
+func (r *UsersRepo) SetWebhook(ctx context.Context, userID string, webhook *domain.UserWebhook) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{
+		"$set": bson.M{
+			"webhook":    userWebhookModelToRecord(webhook),
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// UseTwoFactorStep records the TOTP step as used only if it is later than the last used one,
+// so a code checked concurrently passes once. ErrNotFound means the step is already used.
+func (r *UsersRepo) UseTwoFactorStep(ctx context.Context, userID string, step int64) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{
+		"_id":                       objectID,
+		"two_factor.last_used_step": bson.M{"$lt": step},
+	}, bson.M{
+		"$set": bson.M{
+			"two_factor.last_used_step": step,
+			"updated_at":                time.Now(),
+		},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode removes the hash of the recovery code only if it is still there.
+// ErrNotFound means the code is already used.
+func (r *UsersRepo) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx, bson.M{
+		"_id":                       objectID,
+		"two_factor.recovery_codes": hash,
+	}, bson.M{
+		"$pull": bson.M{"two_factor.recovery_codes": hash},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// db.users.createIndex( { "identities.provider": 1, "identities.subject": 1 }, { unique: true, sparse: true } )
+func (r *UsersRepo) GetUserByIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
 	var user User
 
 	err := r.db.FindOne(ctx, bson.M{
-		"credential.external_token": externalToken,
+		"identities": bson.M{"$elemMatch": bson.M{
+			"provider": provider,
+			"subject":  subject,
+		}},
 	}).Decode(&user)
 	if err != nil {
 		return nil, wrapError(err)
@@ -193,21 +426,166 @@ func (r *UsersRepo) GetUserByExternalToken(ctx context.Context, externalToken st
 	return userRecordToModel(&user), nil
 }
 
-// db.users.createIndex( { "email": 1 }, { unique: true } )
-func (r *UsersRepo) Create(ctx context.Context, user *domain.User) error {
-	user.ID = primitive.NewObjectID().Hex()
+// AddIdentity links the provider's account, it returns ErrAlreadyExist if the user
+// already has an account of the provider linked.
+func (r *UsersRepo) AddIdentity(ctx context.Context, userID string, identity *domain.UserIdentity) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
 
-	_, err := r.db.InsertOne(ctx, userModelToRecord(user))
+	res, err := r.db.UpdateOne(ctx,
+		bson.M{
+			"_id":                 objectID,
+			"identities.provider": bson.M{"$ne": identity.Provider},
+		},
+		bson.M{
+			"$push": bson.M{"identities": userIdentityModelToRecord(identity)},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return core.ErrAlreadyExist
+	}
+
+	return nil
+}
+
+func (r *UsersRepo) RemoveIdentity(ctx context.Context, userID, provider string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(ctx,
+		bson.M{
+			"_id":                 objectID,
+			"identities.provider": provider,
+		},
+		bson.M{
+			"$pull": bson.M{"identities": bson.M{"provider": provider}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+// GetExternalTokens returns the external tokens the users had before the API keys, by user id.
+func (r *UsersRepo) GetExternalTokens(ctx context.Context) (map[string]string, error) {
+	var users []struct {
+		ID         primitive.ObjectID `bson:"_id"`
+		Credential struct {
+			ExternalToken string `bson:"external_token"`
+		} `bson:"credential"`
+	}
+
+	cursor, err := r.db.Find(
+		ctx,
+		bson.M{"credential.external_token": bson.M{"$exists": true, "$ne": ""}},
+		options.Find().SetProjection(bson.M{"credential": 1}),
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, wrapError(err)
+	}
+
+	tokens := make(map[string]string, len(users))
+	for _, user := range users {
+		tokens[user.ID.Hex()] = user.Credential.ExternalToken
+	}
+
+	return tokens, nil
+}
+
+func (r *UsersRepo) RemoveExternalToken(ctx context.Context, userID string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	_, err = r.db.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$unset": bson.M{"credential": ""}})
 	return wrapError(err)
 }
 
-func (r *UsersRepo) Update(ctx context.Context, user *domain.User) error {
-	objectID, err := primitive.ObjectIDFromHex(user.ID)
+// GetPlainRefreshTokens returns the refresh tokens of the sessions created before the tokens were hashed,
+// by the session IDs of every user.
+func (r *UsersRepo) GetPlainRefreshTokens(ctx context.Context) (map[string]map[uuid.UUID]string, error) {
+	var users []struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Sessions []struct {
+			SessionID    uuid.UUID `bson:"session_id"`
+			RefreshToken string    `bson:"refresh_token"`
+		} `bson:"sessions"`
+	}
+
+	cursor, err := r.db.Find(
+		ctx,
+		bson.M{"sessions.refresh_token": bson.M{"$exists": true, "$ne": ""}},
+		options.Find().SetProjection(bson.M{"sessions.session_id": 1, "sessions.refresh_token": 1}),
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, wrapError(err)
+	}
+
+	tokens := make(map[string]map[uuid.UUID]string, len(users))
+	for _, user := range users {
+		for _, session := range user.Sessions {
+			if session.RefreshToken == "" {
+				continue
+			}
+
+			if tokens[user.ID.Hex()] == nil {
+				tokens[user.ID.Hex()] = make(map[uuid.UUID]string)
+			}
+
+			tokens[user.ID.Hex()][session.SessionID] = session.RefreshToken
+		}
+	}
+
+	return tokens, nil
+}
+
+// HashRefreshToken replaces the plain refresh token of the session with its hash, unless the token
+// has been replaced already.
+func (r *UsersRepo) HashRefreshToken(ctx context.Context, userID string, sessionID uuid.UUID, refreshToken, hash string) error {
+	userMongoID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return wrapError(err)
 	}
 
-	_, err = r.db.ReplaceOne(ctx, bson.M{"_id": objectID}, userModelToRecord(user))
+	_, err = r.db.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": userMongoID,
+			"sessions": bson.M{"$elemMatch": bson.M{
+				"session_id":    sessionID,
+				"refresh_token": refreshToken,
+			}},
+		},
+		bson.M{
+			"$set":   bson.M{"sessions.$.refresh_token_hash": hash},
+			"$unset": bson.M{"sessions.$.refresh_token": ""},
+		})
+
 	return wrapError(err)
 }
 
@@ -224,32 +602,38 @@ func (r *UsersRepo) Delete(ctx context.Context, userID string) error {
 type Session struct {
 	SessionID uuid.UUID `bson:"session_id"`
 
-	RefreshToken string    `bson:"refresh_token"`
-	ExpiresAt    time.Time `bson:"expires_at"`
-	CreatedAt    time.Time `bson:"created_at"`
-	Client       string    `bson:"client"`
-	IpAddress    string    `bson:"ip_address"`
+	RefreshTokenHash       string    `bson:"refresh_token_hash"`
+	UsedRefreshTokenHashes []string  `bson:"used_refresh_token_hashes"`
+	ExpiresAt              time.Time `bson:"expires_at"`
+	CreatedAt              time.Time `bson:"created_at"`
+	LastUsedAt             time.Time `bson:"last_used_at"`
+	Client                 string    `bson:"client"`
+	IpAddress              string    `bson:"ip_address"`
 }
 
 func userSessionModelToRecord(model *domain.Session) *Session {
 	return &Session{
-		SessionID:    model.ID,
-		RefreshToken: model.RefreshToken,
-		ExpiresAt:    model.ExpiresAt,
-		CreatedAt:    model.CreatedAt,
-		Client:       model.Client,
-		IpAddress:    model.IpAddress,
+		SessionID:              model.ID,
+		RefreshTokenHash:       model.RefreshTokenHash,
+		UsedRefreshTokenHashes: model.UsedRefreshTokenHashes,
+		ExpiresAt:              model.ExpiresAt,
+		CreatedAt:              model.CreatedAt,
+		LastUsedAt:             model.LastUsedAt,
+		Client:                 model.Client,
+		IpAddress:              model.IpAddress,
 	}
 }
 
 func userSessionRecordToModel(rec *Session) *domain.Session {
 	return &domain.Session{
-		ID:           rec.SessionID,
-		RefreshToken: rec.RefreshToken,
-		ExpiresAt:    rec.ExpiresAt,
-		CreatedAt:    rec.CreatedAt,
-		Client:       rec.Client,
-		IpAddress:    rec.IpAddress,
+		ID:                     rec.SessionID,
+		RefreshTokenHash:       rec.RefreshTokenHash,
+		UsedRefreshTokenHashes: rec.UsedRefreshTokenHashes,
+		ExpiresAt:              rec.ExpiresAt,
+		CreatedAt:              rec.CreatedAt,
+		LastUsedAt:             rec.LastUsedAt,
+		Client:                 rec.Client,
+		IpAddress:              rec.IpAddress,
 	}
 }
 
@@ -304,10 +688,11 @@ func (r *UsersRepo) SetSession(ctx context.Context, userID string, session *doma
 	return wrapError(err)
 }
 
+// GetSessionByRefreshToken finds the session whose current or previously used refresh token has the hash.
 func (r *UsersRepo) GetSessionByRefreshToken(
 	ctx context.Context,
 	userID string,
-	refreshToken string,
+	refreshTokenHash string,
 ) (*domain.Session, error) {
 	var user *User
 
@@ -324,55 +709,102 @@ func (r *UsersRepo) GetSessionByRefreshToken(
 	}
 
 	for i := range user.Sessions {
-		if user.Sessions[i].RefreshToken == refreshToken {
+		if user.Sessions[i].RefreshTokenHash == refreshTokenHash {
 			return userSessionRecordToModel(user.Sessions[i]), nil
 		}
+
+		for _, usedHash := range user.Sessions[i].UsedRefreshTokenHashes {
+			if usedHash == refreshTokenHash {
+				return userSessionRecordToModel(user.Sessions[i]), nil
+			}
+		}
 	}
 
 	return nil, wrapError(mongo.ErrNoDocuments)
 }
 
-func (r *UsersRepo) RemoveSession(ctx context.Context, userID string, sessionID uuid.UUID) error {
+// RotateRefreshToken replaces the session's refresh token only if it is still oldHash, so of two
+// concurrent refreshes with the same token only one succeeds. The old hash is kept in the last
+// keepUsed used ones.
+func (r *UsersRepo) RotateRefreshToken(
+	ctx context.Context,
+	userID string,
+	sessionID uuid.UUID,
+	oldHash, newHash string,
+	keepUsed int,
+) error {
+	userMongoID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	res, err := r.db.UpdateOne(
+		ctx,
+		bson.M{
+			"_id": userMongoID,
+			"sessions": bson.M{"$elemMatch": bson.M{
+				"session_id":         sessionID,
+				"refresh_token_hash": oldHash,
+			}},
+		},
+		bson.M{
+			"$set": bson.M{
+				"sessions.$.refresh_token_hash": newHash,
+				"sessions.$.last_used_at":       time.Now(),
+				"last_visit_at":                 time.Now(),
+			},
+			"$push": bson.M{
+				"sessions.$.used_refresh_token_hashes": bson.M{
+					"$each":  []string{oldHash},
+					"$slice": -keepUsed,
+				},
+			},
+		})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return wrapError(mongo.ErrNoDocuments)
+	}
+
+	return nil
+}
+
+func (r *UsersRepo) GetSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
 	var user *User
 
 	userMongoID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return err
+		return nil, wrapError(err)
 	}
 
-	err = r.db.Database().Client().UseSession(ctx, func(sessionContext mongo.SessionContext) error {
-		err := sessionContext.StartTransaction()
-		if err != nil {
-			return err
-		}
-
-		err = r.db.FindOne(ctx,
-			bson.M{"_id": userMongoID},
-		).Decode(&user)
-		if err != nil {
-			return err
-		}
+	err = r.db.FindOne(ctx,
+		bson.M{"_id": userMongoID},
+	).Decode(&user)
+	if err != nil {
+		return nil, wrapError(err)
+	}
 
-		for i := range user.Sessions {
-			if user.Sessions[i].SessionID == sessionID {
-				user.Sessions = append(user.Sessions[:i], user.Sessions[i+1:]...)
-				break
-			}
-		}
+	sessions := make([]*domain.Session, len(user.Sessions))
+	for i := range user.Sessions {
+		sessions[i] = userSessionRecordToModel(user.Sessions[i])
+	}
 
-		_, err = r.db.UpdateOne(
-			ctx,
-			bson.M{"_id": userMongoID},
-			bson.M{"$set": bson.M{
-				"sessions":    user.Sessions,
-				"lastVisitAt": time.Now(),
-			}})
-		if err != nil {
-			return err
-		}
+	return sessions, nil
+}
 
-		return sessionContext.CommitTransaction(sessionContext)
-	})
+func (r *UsersRepo) RemoveSessions(ctx context.Context, userID string, sessionIDs []uuid.UUID) error {
+	userMongoID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return wrapError(err)
+	}
 
+	_, err = r.db.UpdateOne(
+		ctx,
+		bson.M{"_id": userMongoID},
+		bson.M{"$pull": bson.M{
+			"sessions": bson.M{"session_id": bson.M{"$in": sessionIDs}},
+		}})
 	return wrapError(err)
 }
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redisdb "necutya/faker/pkg/database/redis"
+)
+
+const (
+	attemptsPrefix = "api:attempts"
+	lockoutPrefix  = "api:lockout"
+)
+
+type AttemptRepo struct {
+	cli *redisdb.Client
+}
+
+func NewAttemptRepo(cli *redisdb.Client) *AttemptRepo {
+	return &AttemptRepo{
+		cli: cli,
+	}
+}
+
+// Incr counts a failed attempt, the counter is dropped window seconds after the first one.
+func (r *AttemptRepo) Incr(ctx context.Context, key string, window int) (int, error) {
+	res, err := r.cli.IncrWithTTL(ctx, fmt.Sprintf("%s:%s", attemptsPrefix, key), int64(window))
+	return int(res), wrapError(err)
+}
+
+func (r *AttemptRepo) Reset(ctx context.Context, key string) error {
+	return wrapError(r.cli.Del(ctx, fmt.Sprintf("%s:%s", attemptsPrefix, key)))
+}
+
+func (r *AttemptRepo) Lock(ctx context.Context, key string, ttl int) error {
+	return wrapError(r.cli.Set(ctx, fmt.Sprintf("%s:%s", lockoutPrefix, key), []byte{}, int64(ttl)))
+}
+
+// LockedFor returns the remaining lockout time, zero if the key is not locked.
+func (r *AttemptRepo) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.cli.TTL(ctx, fmt.Sprintf("%s:%s", lockoutPrefix, key))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	// negative values mean the key does not exist
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
@@ -8,8 +8,9 @@ import (
 )
 
 const (
-	separator       = ":"
-	blackListPrefix = "api:inv_token_id"
+	separator              = ":"
+	blackListPrefix        = "api:inv_token_id"
+	sessionBlackListPrefix = "api:inv_session_id"
 )
 
 type BlacklistRepo struct {
@@ -51,3 +52,26 @@ func (r *BlacklistRepo) CheckToken(ctx context.Context, tokenID string) error {
 
 	return wrapError(ErrInvalidToken)
 }
+
+// AddSession invalidates all access tokens issued for the session, ttl should cover their lifetime.
+func (r *BlacklistRepo) AddSession(ctx context.Context, sessionID string, ttl int) error {
+	if ttl <= 0 {
+		ttl = 0
+	}
+
+	return r.cli.Set(ctx, fmt.Sprintf("%s:%s", sessionBlackListPrefix, sessionID), []byte{}, int64(ttl))
+}
+
+func (r *BlacklistRepo) CheckSession(ctx context.Context, sessionID string) error {
+	res, err := r.cli.Get(ctx, fmt.Sprintf("%s:%s", sessionBlackListPrefix, sessionID))
+
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res == nil {
+		return nil
+	}
+
+	return wrapError(ErrInvalidToken)
+}
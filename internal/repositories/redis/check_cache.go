@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"necutya/faker/internal/domain/domain"
+	redisdb "necutya/faker/pkg/database/redis"
+)
+
+const checkCachePrefix = "api:check_cache"
+
+type CheckCacheRepo struct {
+	cli *redisdb.Client
+}
+
+func NewCheckCacheRepo(cli *redisdb.Client) *CheckCacheRepo {
+	return &CheckCacheRepo{
+		cli: cli,
+	}
+}
+
+func (r *CheckCacheRepo) generateCheckCacheKey(modelVersion, hash string) string {
+	return fmt.Sprintf("%s:%s:%s", checkCachePrefix, modelVersion, hash)
+}
+
+// Get returns nil if there is no cached result for the hash.
+func (r *CheckCacheRepo) Get(ctx context.Context, modelVersion, hash string) (*domain.CheckResult, error) {
+	var result CheckResult
+
+	value, err := r.cli.Get(ctx, r.generateCheckCacheKey(modelVersion, hash))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	if err = json.Unmarshal(value, &result); err != nil {
+		return nil, wrapError(ErrInvalidValue)
+	}
+
+	return checkResultRecordToModel(&result), nil
+}
+
+func (r *CheckCacheRepo) Set(ctx context.Context, modelVersion, hash string, result *domain.CheckResult, ttl int) error {
+	value, err := json.Marshal(checkResultModelToRecord(result))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return wrapError(r.cli.Set(ctx, r.generateCheckCacheKey(modelVersion, hash), value, int64(ttl)))
+}
+
+// Purge deletes cached results of the model version, or of all versions if modelVersion is empty.
+func (r *CheckCacheRepo) Purge(ctx context.Context, modelVersion string) (int64, error) {
+	if modelVersion == "" {
+		modelVersion = "*"
+	}
+
+	deleted, err := r.cli.DelByPattern(ctx, r.generateCheckCacheKey(modelVersion, "*"))
+
+	return deleted, wrapError(err)
+}
@@ -0,0 +1,307 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+	redisdb "necutya/faker/pkg/database/redis"
+)
+
+const (
+	checkJobPrefix        = "api:check_job"
+	checkJobLeasePrefix   = "api:check_job_lease"
+	checkJobQueueKey      = "api:check_jobs_queue"
+	checkJobProcessingKey = "api:check_jobs_processing"
+)
+
+type CheckJob struct {
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	RequestType string `json:"request_type"`
+	Status      string `json:"status"`
+
+	SaveToDb     bool            `json:"save_to_db"`
+	Segmentation string          `json:"segmentation"`
+	Items        []*CheckJobItem `json:"items"`
+
+	Processed int `json:"processed"`
+	Failed    int `json:"failed"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+type CheckJobItem struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+
+	Result    *CheckResult `json:"result"`
+	Cached    bool         `json:"cached"`
+	ErrorCode string       `json:"error_code"`
+	Error     string       `json:"error"`
+}
+
+type CheckResult struct {
+	Message          string            `json:"message"`
+	IsGenerated      bool              `json:"is_generated"`
+	GeneratedPercent float64           `json:"generated_percent"`
+	Segments         []*MessageSegment `json:"segments"`
+	Detectors        []*DetectorScore  `json:"detectors"`
+
+	Language           string  `json:"language"`
+	LanguageConfidence float64 `json:"language_confidence"`
+}
+
+type DetectorScore struct {
+	Detector         string  `json:"detector"`
+	IsGenerated      bool    `json:"is_generated"`
+	GeneratedPercent float64 `json:"generated_percent"`
+	Error            string  `json:"error"`
+}
+
+type MessageSegment struct {
+	Start            int     `json:"start"`
+	End              int     `json:"end"`
+	Text             string  `json:"text"`
+	IsGenerated      bool    `json:"is_generated"`
+	GeneratedPercent float64 `json:"generated_percent"`
+}
+
+func checkResultModelToRecord(model *domain.CheckResult) *CheckResult {
+	if model == nil {
+		return nil
+	}
+
+	segments := make([]*MessageSegment, len(model.Segments))
+	for i := range model.Segments {
+		segment := MessageSegment(*model.Segments[i])
+		segments[i] = &segment
+	}
+
+	detectors := make([]*DetectorScore, len(model.Detectors))
+	for i := range model.Detectors {
+		detector := DetectorScore(*model.Detectors[i])
+		detectors[i] = &detector
+	}
+
+	return &CheckResult{
+		Message:          model.Message,
+		IsGenerated:      model.IsGenerated,
+		GeneratedPercent: model.GeneratedPercent,
+		Segments:         segments,
+		Detectors:        detectors,
+
+		Language:           model.Language,
+		LanguageConfidence: model.LanguageConfidence,
+	}
+}
+
+func checkResultRecordToModel(rec *CheckResult) *domain.CheckResult {
+	if rec == nil {
+		return nil
+	}
+
+	segments := make([]*domain.MessageSegment, len(rec.Segments))
+	for i := range rec.Segments {
+		segment := domain.MessageSegment(*rec.Segments[i])
+		segments[i] = &segment
+	}
+
+	detectors := make([]*domain.DetectorScore, len(rec.Detectors))
+	for i := range rec.Detectors {
+		detector := domain.DetectorScore(*rec.Detectors[i])
+		detectors[i] = &detector
+	}
+
+	return &domain.CheckResult{
+		Message:          rec.Message,
+		IsGenerated:      rec.IsGenerated,
+		GeneratedPercent: rec.GeneratedPercent,
+		Segments:         segments,
+		Detectors:        detectors,
+
+		Language:           rec.Language,
+		LanguageConfidence: rec.LanguageConfidence,
+	}
+}
+
+func checkJobModelToRecord(model *domain.CheckJob) *CheckJob {
+	items := make([]*CheckJobItem, len(model.Items))
+
+	for i, item := range model.Items {
+		items[i] = &CheckJobItem{
+			ID:        item.ID,
+			Message:   item.Message,
+			Result:    checkResultModelToRecord(item.Result),
+			Cached:    item.Cached,
+			ErrorCode: item.ErrorCode,
+			Error:     item.Error,
+		}
+	}
+
+	return &CheckJob{
+		ID:           model.ID,
+		UserID:       model.UserID,
+		RequestType:  string(model.RequestType),
+		Status:       model.Status,
+		SaveToDb:     model.SaveToDb,
+		Segmentation: model.Segmentation,
+		Items:        items,
+		Processed:    model.Processed,
+		Failed:       model.Failed,
+		CreatedAt:    model.CreatedAt,
+		UpdatedAt:    model.UpdatedAt,
+		FinishedAt:   model.FinishedAt,
+	}
+}
+
+func checkJobRecordToModel(rec *CheckJob) *domain.CheckJob {
+	items := make([]*domain.CheckJobItem, len(rec.Items))
+
+	for i, item := range rec.Items {
+		items[i] = &domain.CheckJobItem{
+			ID:        item.ID,
+			Message:   item.Message,
+			Result:    checkResultRecordToModel(item.Result),
+			Cached:    item.Cached,
+			ErrorCode: item.ErrorCode,
+			Error:     item.Error,
+		}
+	}
+
+	return &domain.CheckJob{
+		ID:           rec.ID,
+		UserID:       rec.UserID,
+		RequestType:  domain.RequestType(rec.RequestType),
+		Status:       rec.Status,
+		SaveToDb:     rec.SaveToDb,
+		Segmentation: rec.Segmentation,
+		Items:        items,
+		Processed:    rec.Processed,
+		Failed:       rec.Failed,
+		CreatedAt:    rec.CreatedAt,
+		UpdatedAt:    rec.UpdatedAt,
+		FinishedAt:   rec.FinishedAt,
+	}
+}
+
+type CheckJobRepo struct {
+	cli *redisdb.Client
+}
+
+func NewCheckJobRepo(cli *redisdb.Client) *CheckJobRepo {
+	return &CheckJobRepo{
+		cli: cli,
+	}
+}
+
+func (r *CheckJobRepo) generateCheckJobKey(jobID string) string {
+	return fmt.Sprintf("%s:%s", checkJobPrefix, jobID)
+}
+
+func (r *CheckJobRepo) Save(ctx context.Context, job *domain.CheckJob, ttl int) error {
+	value, err := json.Marshal(checkJobModelToRecord(job))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return wrapError(r.cli.Set(ctx, r.generateCheckJobKey(job.ID), value, int64(ttl)))
+}
+
+func (r *CheckJobRepo) Get(ctx context.Context, jobID string) (*domain.CheckJob, error) {
+	var job CheckJob
+
+	value, err := r.cli.Get(ctx, r.generateCheckJobKey(jobID))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if value == nil {
+		return nil, wrapError(ErrNotFound)
+	}
+
+	if err = json.Unmarshal(value, &job); err != nil {
+		return nil, wrapError(ErrInvalidValue)
+	}
+
+	return checkJobRecordToModel(&job), nil
+}
+
+func (r *CheckJobRepo) generateCheckJobLeaseKey(jobID string) string {
+	return fmt.Sprintf("%s:%s", checkJobLeasePrefix, jobID)
+}
+
+func (r *CheckJobRepo) Enqueue(ctx context.Context, jobID string) error {
+	return wrapError(r.cli.LPush(ctx, checkJobQueueKey, jobID))
+}
+
+// Dequeue waits up to timeout for the next job ID and moves it to the processing list,
+// where it stays until Ack. Returns empty string if the queue stays empty.
+func (r *CheckJobRepo) Dequeue(ctx context.Context, timeout time.Duration) (string, error) {
+	jobID, err := r.cli.BRPopLPush(ctx, timeout, checkJobQueueKey, checkJobProcessingKey)
+	return jobID, wrapError(err)
+}
+
+// Ack removes the job from the processing list.
+func (r *CheckJobRepo) Ack(ctx context.Context, jobID string) error {
+	_, err := r.cli.LRem(ctx, checkJobProcessingKey, 1, jobID)
+	return wrapError(err)
+}
+
+// Lease claims the job for ttl seconds, reports false if another worker holds it.
+func (r *CheckJobRepo) Lease(ctx context.Context, jobID string, ttl int) (bool, error) {
+	ok, err := r.cli.SetNX(ctx, r.generateCheckJobLeaseKey(jobID), []byte("1"), int64(ttl))
+	return ok, wrapError(err)
+}
+
+func (r *CheckJobRepo) ExtendLease(ctx context.Context, jobID string, ttl int) error {
+	return wrapError(r.cli.Expire(ctx, r.generateCheckJobLeaseKey(jobID), time.Duration(ttl)*time.Second))
+}
+
+func (r *CheckJobRepo) ReleaseLease(ctx context.Context, jobID string) error {
+	return wrapError(r.cli.Del(ctx, r.generateCheckJobLeaseKey(jobID)))
+}
+
+// RequeueAbandoned puts the jobs of the processing list whose lease has expired back
+// at the head of the queue and returns their number.
+func (r *CheckJobRepo) RequeueAbandoned(ctx context.Context) (int, error) {
+	jobIDs, err := r.cli.LRange(ctx, checkJobProcessingKey, 0, -1)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	requeued := 0
+
+	for _, jobID := range jobIDs {
+		leased, err := r.cli.Exists(ctx, r.generateCheckJobLeaseKey(jobID))
+		if err != nil {
+			return requeued, wrapError(err)
+		}
+
+		if leased {
+			continue
+		}
+
+		// only the reaper that removed the job requeues it
+		removed, err := r.cli.LRem(ctx, checkJobProcessingKey, 1, jobID)
+		if err != nil {
+			return requeued, wrapError(err)
+		}
+
+		if removed == 0 {
+			continue
+		}
+
+		if err = r.cli.RPush(ctx, checkJobQueueKey, jobID); err != nil {
+			return requeued, wrapError(err)
+		}
+
+		requeued++
+	}
+
+	return requeued, nil
+}
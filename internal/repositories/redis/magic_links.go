@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+	redisdb "necutya/faker/pkg/database/redis"
+)
+
+const magicLinkPrefix = "api:magic_link"
+
+type MagicLink struct {
+	TokenHash   string    `json:"token_hash"`
+	UserID      string    `json:"user_id"`
+	Fingerprint string    `json:"fingerprint"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type MagicLinkRepo struct {
+	cli *redisdb.Client
+}
+
+func NewMagicLinkRepo(cli *redisdb.Client) *MagicLinkRepo {
+	return &MagicLinkRepo{
+		cli: cli,
+	}
+}
+
+func (r *MagicLinkRepo) generateMagicLinkKey(tokenHash string) string {
+	return fmt.Sprintf("%s:%s", magicLinkPrefix, tokenHash)
+}
+
+// Save stores the link until its ExpiresAt.
+func (r *MagicLinkRepo) Save(ctx context.Context, link *domain.MagicLink) error {
+	ttl := int64(time.Until(link.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return nil
+	}
+
+	value, err := json.Marshal(MagicLink(*link))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return wrapError(r.cli.Set(ctx, r.generateMagicLinkKey(link.TokenHash), value, ttl))
+}
+
+// Pop returns the link and deletes it, so each link is used only once.
+func (r *MagicLinkRepo) Pop(ctx context.Context, tokenHash string) (*domain.MagicLink, error) {
+	var magicLink MagicLink
+
+	value, err := r.cli.GetDel(ctx, r.generateMagicLinkKey(tokenHash))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if value == nil {
+		return nil, wrapError(ErrNotFound)
+	}
+
+	if err = json.Unmarshal(value, &magicLink); err != nil {
+		return nil, wrapError(ErrInvalidValue)
+	}
+
+	model := domain.MagicLink(magicLink)
+
+	return &model, nil
+}
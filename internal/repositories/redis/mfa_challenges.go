@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+	redisdb "necutya/faker/pkg/database/redis"
+)
+
+const mfaChallengePrefix = "api:mfa_challenge"
+
+type MFAChallenge struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	Client    string    `json:"client"`
+	IPAddress string    `json:"ip_address"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MFAChallengeRepo struct {
+	cli *redisdb.Client
+}
+
+func NewMFAChallengeRepo(cli *redisdb.Client) *MFAChallengeRepo {
+	return &MFAChallengeRepo{
+		cli: cli,
+	}
+}
+
+func (r *MFAChallengeRepo) generateMFAChallengeKey(token string) string {
+	return fmt.Sprintf("%s:%s", mfaChallengePrefix, token)
+}
+
+// Save stores the challenge until its ExpiresAt.
+func (r *MFAChallengeRepo) Save(ctx context.Context, challenge *domain.MFAChallenge) error {
+	ttl := int64(time.Until(challenge.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return r.Delete(ctx, challenge.Token)
+	}
+
+	value, err := json.Marshal(MFAChallenge(*challenge))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return wrapError(r.cli.Set(ctx, r.generateMFAChallengeKey(challenge.Token), value, ttl))
+}
+
+func (r *MFAChallengeRepo) Get(ctx context.Context, token string) (*domain.MFAChallenge, error) {
+	var challenge MFAChallenge
+
+	value, err := r.cli.Get(ctx, r.generateMFAChallengeKey(token))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if value == nil {
+		return nil, wrapError(ErrNotFound)
+	}
+
+	if err = json.Unmarshal(value, &challenge); err != nil {
+		return nil, wrapError(ErrInvalidValue)
+	}
+
+	model := domain.MFAChallenge(challenge)
+
+	return &model, nil
+}
+
+func (r *MFAChallengeRepo) Delete(ctx context.Context, token string) error {
+	return wrapError(r.cli.Del(ctx, r.generateMFAChallengeKey(token)))
+}
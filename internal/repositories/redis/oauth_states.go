@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+	redisdb "necutya/faker/pkg/database/redis"
+)
+
+const oauthStatePrefix = "api:oauth_state"
+
+type OAuthState struct {
+	State        string    `json:"state"`
+	Provider     string    `json:"provider"`
+	CodeVerifier string    `json:"code_verifier"`
+	Nonce        string    `json:"nonce"`
+	UserID       string    `json:"user_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type OAuthStateRepo struct {
+	cli *redisdb.Client
+}
+
+func NewOAuthStateRepo(cli *redisdb.Client) *OAuthStateRepo {
+	return &OAuthStateRepo{
+		cli: cli,
+	}
+}
+
+func (r *OAuthStateRepo) generateOAuthStateKey(state string) string {
+	return fmt.Sprintf("%s:%s", oauthStatePrefix, state)
+}
+
+// Save stores the state until its ExpiresAt.
+func (r *OAuthStateRepo) Save(ctx context.Context, state *domain.OAuthState) error {
+	ttl := int64(time.Until(state.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return nil
+	}
+
+	value, err := json.Marshal(OAuthState(*state))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return wrapError(r.cli.Set(ctx, r.generateOAuthStateKey(state.State), value, ttl))
+}
+
+// Pop returns the state and deletes it, so each state is used only once.
+func (r *OAuthStateRepo) Pop(ctx context.Context, state string) (*domain.OAuthState, error) {
+	var oauthState OAuthState
+
+	value, err := r.cli.GetDel(ctx, r.generateOAuthStateKey(state))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if value == nil {
+		return nil, wrapError(ErrNotFound)
+	}
+
+	if err = json.Unmarshal(value, &oauthState); err != nil {
+		return nil, wrapError(ErrInvalidValue)
+	}
+
+	model := domain.OAuthState(oauthState)
+
+	return &model, nil
+}
@@ -27,6 +27,15 @@ func (r *RequestCounterRepo) Incr(ctx context.Context, userID string, _type doma
 	return wrapError(r.cli.HIncrBy(ctx, userID, string(_type), 1))
 }
 
+func (r *RequestCounterRepo) IncrBy(ctx context.Context, userID string, _type domain.RequestType, value int) (int, error) {
+	res, err := r.cli.HIncrByAndGet(ctx, userID, string(_type), int64(value))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return int(res), nil
+}
+
 func (r *RequestCounterRepo) GetByUserID(ctx context.Context, userID string, _type domain.RequestType) (int, error) {
 	value := r.cli.HMGet(ctx, userID, string(_type))
 	if len(value) < 0 {
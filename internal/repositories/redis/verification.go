@@ -28,3 +28,7 @@ func (r *VerificationRepo) GetCode(ctx context.Context, email string) (string, e
 
 	return string(code), nil
 }
+
+func (r *VerificationRepo) DeleteCode(ctx context.Context, key string) error {
+	return wrapError(r.cli.Del(ctx, key))
+}
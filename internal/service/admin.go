@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"necutya/faker/internal/domain/domain"
 	"necutya/faker/internal/domain/dto"
@@ -12,20 +13,44 @@ type UserAdminRepository interface {
 }
 
 type AdminService struct {
-	userRepo UserAdminRepository
-	planRepo PlanRepository
+	userRepo     UserAdminRepository
+	planRepo     PlanRepository
+	settingsRepo SettingsRepository
 }
 
 func NewAdminService(
 	userRepo UserAdminRepository,
 	planRepo PlanRepository,
+	settingsRepo SettingsRepository,
 ) *AdminService {
 	return &AdminService{
-		userRepo: userRepo,
-		planRepo: planRepo,
+		userRepo:     userRepo,
+		planRepo:     planRepo,
+		settingsRepo: settingsRepo,
 	}
 }
 
+func (s *AdminService) GetSecuritySettings(ctx context.Context) (*domain.SecuritySettings, error) {
+	return s.settingsRepo.GetSecuritySettings(ctx)
+}
+
+type SecuritySettingsUpdateInput struct {
+	TwoFactorRequiredRoles []domain.Role
+}
+
+func (s *AdminService) UpdateSecuritySettings(ctx context.Context, input SecuritySettingsUpdateInput) (*domain.SecuritySettings, error) {
+	settings := &domain.SecuritySettings{
+		TwoFactorRequiredRoles: input.TwoFactorRequiredRoles,
+		UpdatedAt:              time.Now(),
+	}
+
+	if err := s.settingsRepo.UpdateSecuritySettings(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
 func (s *AdminService) GetUsersReport(ctx context.Context) (*domain.UsersReport, error) {
 	users, err := s.userRepo.GetMany(ctx, dto.UserFilter{
 		Role:        string(domain.BasicRole),
@@ -2,24 +2,43 @@ package service
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 
 	core "necutya/faker/internal/domain"
 	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/domain/dto"
+	aiGrpcClient "necutya/faker/pkg/ai-grpc-client"
+	"necutya/faker/pkg/ensemble"
+	heuristicDetector "necutya/faker/pkg/heuristic-detector"
+	"necutya/faker/pkg/logger"
 )
 
 type MessageRepository interface {
 	Create(context.Context, *domain.Message) error
+	GetMany(context.Context, dto.MessageFilter) ([]*domain.Message, int64, error)
+	GetOne(ctx context.Context, userID, id string) (*domain.Message, error)
+	Delete(ctx context.Context, userID, id string) error
+	DeleteByUserID(ctx context.Context, userID string) error
 }
 
 type RequestCounterRepository interface {
 	Incr(context.Context, string, domain.RequestType) error
+	IncrBy(context.Context, string, domain.RequestType, int) (int, error)
 	GetByUserID(context.Context, string, domain.RequestType) (int, error)
 	Expire(ctx context.Context, key string, ttl time.Duration) error
 }
 
+const defaultBatchConcurrency = 4
+
+// aiCallLimiter bounds the concurrent calls to the AI service made for a single request,
+// it is shared by the messages of a batch and by the segments of every message.
+type aiCallLimiter chan struct{}
+
 type AIManager interface {
-	CheckMessage(msg string) (string, bool, float64, error)
+	CheckMessage(ctx context.Context, msg string) (*ensemble.Result, error)
+	ModelVersion() string
 }
 
 type AIService struct {
@@ -28,6 +47,16 @@ type AIService struct {
 	aiManager          AIManager
 	planRepo           PlanRepository
 	userRepo           UserRepository
+	documentParser     DocumentParser
+	checkCacheRepo     CheckCacheRepository
+	languageDetector   LanguageDetector
+	// languageAIManagers route checks of a language to a dedicated AI endpoint, aiManager is used for the rest.
+	languageAIManagers map[string]AIManager
+
+	batchConcurrency int
+	// cacheTTL is a lifetime of cached results in seconds, 0 disables the cache.
+	cacheTTL             int
+	cacheCaseInsensitive bool
 }
 
 func NewAIService(
@@ -36,61 +65,357 @@ func NewAIService(
 	aiManager AIManager,
 	planRepo PlanRepository,
 	userRepo UserRepository,
+	documentParser DocumentParser,
+	checkCacheRepo CheckCacheRepository,
+	languageDetector LanguageDetector,
+	languageAIManagers map[string]AIManager,
+	batchConcurrency int,
+	cacheTTL int,
+	cacheCaseInsensitive bool,
 ) *AIService {
+	if batchConcurrency <= 0 {
+		batchConcurrency = defaultBatchConcurrency
+	}
+
 	return &AIService{
-		messageRepo:        messageRepo,
-		requestCounterRepo: requestCounterRepo,
-		aiManager:          aiManager,
-		planRepo:           planRepo,
-		userRepo:           userRepo,
+		messageRepo:          messageRepo,
+		requestCounterRepo:   requestCounterRepo,
+		aiManager:            aiManager,
+		planRepo:             planRepo,
+		userRepo:             userRepo,
+		documentParser:       documentParser,
+		checkCacheRepo:       checkCacheRepo,
+		languageDetector:     languageDetector,
+		languageAIManagers:   languageAIManagers,
+		batchConcurrency:     batchConcurrency,
+		cacheTTL:             cacheTTL,
+		cacheCaseInsensitive: cacheCaseInsensitive,
 	}
 }
 
+func (s *AIService) newAICallLimiter() aiCallLimiter {
+	return make(aiCallLimiter, s.batchConcurrency)
+}
+
 type CheckMessageInput struct {
 	Message  string
 	SaveToDb bool
+	// Segmentation is one of NoSegmentation, SentenceSegmentation or ParagraphSegmentation.
+	Segmentation string
 }
 
 type CheckMessageOutput struct {
 	Message          string
 	IsGenerated      bool
 	GeneratedPercent float64
+	Segments         []*domain.MessageSegment
+	// Detectors holds the verdict of every detector of the ensemble.
+	Detectors []*domain.DetectorScore
+	// Cached is true when the result is taken from the cache instead of the AI service.
+	Cached bool
+
+	Language           string
+	LanguageConfidence float64
 }
 
 func (s *AIService) CheckMessage(ctx context.Context, userID, planID string, input *CheckMessageInput, requestType domain.RequestType) (*CheckMessageOutput, error) {
-	var (
-		err    error
-		output = CheckMessageOutput{}
-	)
+	plan, err := s.getUserPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := s.prepareCheck(ctx, plan, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// cached hits which do not count toward the quota are served even when the limit is reached
+	if check.cached != nil && !plan.CountCachedRequests {
+		return s.runCheck(ctx, userID, check, requestType, nil)
+	}
 
 	if err = s.validateRequestCountPerDay(ctx, userID, planID, requestType); err != nil {
 		return nil, err
 	}
 
-	output.Message, output.IsGenerated, output.GeneratedPercent, err = s.aiManager.CheckMessage(input.Message)
+	output, err := s.runCheck(ctx, userID, check, requestType, s.newAICallLimiter())
 	if err != nil {
 		return nil, err
 	}
 
-	if input.SaveToDb {
-		err = s.messageRepo.Create(ctx, &domain.Message{
-			Text:      input.Message,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		})
-		if err != nil {
+	err = s.incrementRequestCounter(ctx, userID, requestType)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+type CheckMessagesItemInput struct {
+	ID      string
+	Message string
+}
+
+type CheckMessagesInput struct {
+	Messages     []CheckMessagesItemInput
+	SaveToDb     bool
+	Segmentation string
+}
+
+type CheckMessagesItemOutput struct {
+	ID     string
+	Result *CheckMessageOutput
+	Err    error
+}
+
+// InternalItemErrorCode is the code of the failed items whose errors are not known to the clients.
+const InternalItemErrorCode = "internal"
+
+// CheckItemError returns the stable code and the message a failed item is reported to the clients with.
+func CheckItemError(err error) (code, message string) {
+	switch {
+	case errors.Is(err, core.ErrUnsupportedLanguage):
+		return "unsupported_language", core.ErrUnsupportedLanguage.Error()
+	case errors.Is(err, core.ErrAIServiceUnavailable):
+		return "service_unavailable", core.ErrAIServiceUnavailable.Error()
+	case errors.Is(err, core.ErrRequestLimit):
+		return "requests limit", core.ErrRequestLimit.Error()
+	}
+
+	return InternalItemErrorCode, "internal error"
+}
+
+// CheckMessages checks a batch of messages with bounded concurrency.
+// The whole batch is reserved against the daily quota up front, failed items are given back afterwards
+// together with cached hits if the plan does not count them.
+func (s *AIService) CheckMessages(
+	ctx context.Context,
+	userID, planID string,
+	input *CheckMessagesInput,
+	requestType domain.RequestType,
+) ([]*CheckMessagesItemOutput, error) {
+	plan, err := s.getUserPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.reserveRequests(ctx, userID, requestType, len(input.Messages)); err != nil {
+		return nil, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		limiter = s.newAICallLimiter()
+		outputs = make([]*CheckMessagesItemOutput, len(input.Messages))
+	)
+
+	// the number of messages is bounded by the request validation, the calls to the AI service by the limiter
+	for i := range input.Messages {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			item := input.Messages[i]
+			result, err := s.checkMessage(ctx, userID, plan, &CheckMessageInput{
+				Message:      item.Message,
+				SaveToDb:     input.SaveToDb,
+				Segmentation: input.Segmentation,
+			}, requestType, limiter)
+
+			outputs[i] = &CheckMessagesItemOutput{
+				ID:     item.ID,
+				Result: result,
+				Err:    err,
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	refund := 0
+	for i := range outputs {
+		if outputs[i].Err != nil || (outputs[i].Result.Cached && !plan.CountCachedRequests) {
+			refund++
+		}
+	}
+
+	if refund > 0 {
+		if _, err = s.requestCounterRepo.IncrBy(ctx, userID, requestType, -refund); err != nil {
 			return nil, err
 		}
 	}
 
-	err = s.incrementRequestCounter(ctx, userID, requestType)
+	return outputs, nil
+}
+
+// messageCheck is a message prepared for the check: its language, the AI manager
+// the language is routed to and the result cached by that manager's model, nil on a miss.
+type messageCheck struct {
+	input      *CheckMessageInput
+	language   string
+	confidence float64
+	aiManager  AIManager
+	cached     *CheckMessageOutput
+}
+
+func (s *AIService) prepareCheck(ctx context.Context, plan *domain.Plan, input *CheckMessageInput) (*messageCheck, error) {
+	language, confidence, err := s.detectLanguage(plan, input.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &messageCheck{
+		input:      input,
+		language:   language,
+		confidence: confidence,
+		aiManager:  s.aiManagerFor(language),
+	}
+
+	if output := s.getCachedResult(ctx, check.aiManager, input); output != nil {
+		output.Language, output.LanguageConfidence = language, confidence
+		check.cached = output
+	}
+
+	return check, nil
+}
+
+func (s *AIService) checkMessage(
+	ctx context.Context,
+	userID string,
+	plan *domain.Plan,
+	input *CheckMessageInput,
+	requestType domain.RequestType,
+	limiter aiCallLimiter,
+) (*CheckMessageOutput, error) {
+	check, err := s.prepareCheck(ctx, plan, input)
 	if err != nil {
 		return nil, err
 	}
 
+	return s.runCheck(ctx, userID, check, requestType, limiter)
+}
+
+// runCheck returns the cached result of the prepared message or checks it through its AI manager,
+// the limiter is not used for the cached results.
+func (s *AIService) runCheck(
+	ctx context.Context,
+	userID string,
+	check *messageCheck,
+	requestType domain.RequestType,
+	limiter aiCallLimiter,
+) (*CheckMessageOutput, error) {
+	input := check.input
+
+	if check.cached != nil {
+		if err := s.saveMessage(ctx, userID, check.aiManager, input, check.cached, requestType); err != nil {
+			return nil, err
+		}
+
+		return check.cached, nil
+	}
+
+	var (
+		err       error
+		aiManager = check.aiManager
+		degraded  bool
+		output    = CheckMessageOutput{
+			Message:            input.Message,
+			Language:           check.language,
+			LanguageConfidence: check.confidence,
+		}
+	)
+
+	if input.Segmentation == NoSegmentation {
+		limiter <- struct{}{}
+		result, err := aiManager.CheckMessage(ctx, input.Message)
+		<-limiter
+
+		if err != nil {
+			return nil, convertAIManagerError(err)
+		}
+
+		output.IsGenerated, output.GeneratedPercent = result.IsGenerated, result.GeneratedPercent
+		output.Detectors = convertDetectorScores(result.Scores)
+		degraded = isDegraded(result)
+	} else {
+		output.Segments = splitMessage(input.Message, input.Segmentation)
+
+		output.Detectors, degraded, err = s.scoreSegments(ctx, aiManager, limiter, output.Segments)
+		if err != nil {
+			return nil, convertAIManagerError(err)
+		}
+
+		output.IsGenerated, output.GeneratedPercent = aggregateSegments(output.Segments)
+	}
+
+	// the verdict of a partial ensemble is not cached, the next check may get all the detectors
+	if !degraded {
+		s.setCachedResult(ctx, aiManager, input, &output)
+	}
+
+	if err = s.saveMessage(ctx, userID, aiManager, input, &output, requestType); err != nil {
+		return nil, err
+	}
+
 	return &output, nil
 }
 
+// saveMessage stores the check with the model version of the AI manager that made it.
+func (s *AIService) saveMessage(
+	ctx context.Context,
+	userID string,
+	aiManager AIManager,
+	input *CheckMessageInput,
+	output *CheckMessageOutput,
+	requestType domain.RequestType,
+) error {
+	if !input.SaveToDb {
+		return nil
+	}
+
+	return s.messageRepo.Create(ctx, &domain.Message{
+		UserID:           userID,
+		Text:             input.Message,
+		RequestType:      requestType,
+		IsGenerated:      output.IsGenerated,
+		GeneratedPercent: output.GeneratedPercent,
+		ModelVersion:     aiManager.ModelVersion(),
+		Language:         output.Language,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	})
+}
+
+// reserveRequests atomically adds amount to today's counter and rolls it back when the plan limit would be exceeded.
+func (s *AIService) reserveRequests(ctx context.Context, userID string, requestType domain.RequestType, amount int) error {
+	limit, err := s.getRequestsLimit(ctx, userID, requestType)
+	if err != nil {
+		return err
+	}
+
+	reqCount, err := s.requestCounterRepo.IncrBy(ctx, userID, requestType, amount)
+	if err != nil {
+		return err
+	}
+
+	if reqCount == amount {
+		if err = s.requestCounterRepo.Expire(ctx, userID, getDurationToMidnight()); err != nil {
+			return err
+		}
+	}
+
+	if limit != 0 && reqCount > limit {
+		if _, err = s.requestCounterRepo.IncrBy(ctx, userID, requestType, -amount); err != nil {
+			return err
+		}
+
+		return core.ErrRequestLimit
+	}
+
+	return nil
+}
+
 func (s *AIService) incrementRequestCounter(ctx context.Context, userID string, requestType domain.RequestType) error {
 	reqCount, err := s.requestCounterRepo.GetByUserID(ctx, userID, requestType)
 	if err != nil {
@@ -115,26 +440,97 @@ func (s *AIService) validateRequestCountPerDay(ctx context.Context, userID, plan
 		return err
 	}
 
-	user, err := s.userRepo.GetUserByID(ctx, userID)
+	limit, err := s.getRequestsLimit(ctx, userID, requestType)
 	if err != nil {
 		return err
 	}
 
-	plan, err := s.planRepo.GetOne(ctx, user.PlanID)
+	if limit != 0 && reqCount >= limit {
+		return core.ErrRequestLimit
+	}
+
+	return nil
+}
+
+// getRequestsLimit returns the daily limit of the user's plan for the request type, 0 means unlimited.
+func (s *AIService) getRequestsLimit(ctx context.Context, userID string, requestType domain.RequestType) (int, error) {
+	plan, err := s.getUserPlan(ctx, userID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	switch requestType {
 	case domain.External:
-		if plan.ExternalRequestsCount != 0 && reqCount >= plan.ExternalRequestsCount {
-			return core.ErrRequestLimit
-		}
+		return plan.ExternalRequestsCount, nil
 	case domain.Internal:
-		if plan.InternalRequestsCount != 0 && reqCount >= plan.InternalRequestsCount {
-			return core.ErrRequestLimit
+		return plan.InternalRequestsCount, nil
+	}
+
+	return 0, nil
+}
+
+func (s *AIService) getUserPlan(ctx context.Context, userID string) (*domain.Plan, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.planRepo.GetOne(ctx, user.PlanID)
+}
+
+func convertAIManagerError(err error) error {
+	if errors.Is(err, aiGrpcClient.ErrCircuitOpen) || errors.Is(err, aiGrpcClient.ErrUnavailable) {
+		return core.ErrAIServiceUnavailable
+	}
+
+	return err
+}
+
+// isDegraded reports whether some detector of the ensemble failed on any of the results.
+// A text too short for the detector is not a failure, the detector can not score it at all.
+func isDegraded(results ...*ensemble.Result) bool {
+	for _, result := range results {
+		for _, score := range result.Scores {
+			if score.Err != nil && !errors.Is(score.Err, heuristicDetector.ErrNotEnoughText) {
+				return true
+			}
 		}
 	}
 
-	return nil
+	return false
+}
+
+// detectorErrorCode returns the stable code a failed detector is reported with,
+// the errors of an unknown kind are logged.
+func detectorErrorCode(score *ensemble.Score) string {
+	switch {
+	case errors.Is(score.Err, heuristicDetector.ErrNotEnoughText):
+		return "not_enough_text"
+	case errors.Is(score.Err, aiGrpcClient.ErrCircuitOpen), errors.Is(score.Err, aiGrpcClient.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(score.Err, context.DeadlineExceeded):
+		return "timeout"
+	}
+
+	logger.Errorf("detector %s: %s", score.Detector, score.Err)
+
+	return "failed"
+}
+
+func convertDetectorScores(scores []*ensemble.Score) []*domain.DetectorScore {
+	detectorScores := make([]*domain.DetectorScore, len(scores))
+
+	for i, score := range scores {
+		detectorScores[i] = &domain.DetectorScore{
+			Detector:         score.Detector,
+			IsGenerated:      score.IsGenerated,
+			GeneratedPercent: score.GeneratedPercent,
+		}
+
+		if score.Err != nil {
+			detectorScores[i].Error = detectorErrorCode(score)
+		}
+	}
+
+	return detectorScores
 }
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyTag          = "ck"
+	apiKeyPrefixLength = 12
+	apiKeySecretLength = 32
+
+	// the external tokens issued before the API keys are kept as keys with all the scopes,
+	// their prefix is taken from the hash as the tokens themselves have none
+	legacyAPIKeyName   = "External token"
+	legacyAPIKeyPrefix = "legacy"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetMany(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	Delete(ctx context.Context, userID, keyID string) error
+	DeleteByUserID(ctx context.Context, userID string) error
+	UpdateLastUsed(ctx context.Context, keyID string, usedAt time.Time, ip string) error
+}
+
+type APIKeyService struct {
+	apiKeyRepo  APIKeyRepository
+	userRepo    UserRepository
+	codeManager CodeManager
+}
+
+func NewAPIKeyService(apiKeyRepo APIKeyRepository, userRepo UserRepository, codeManager CodeManager) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:  apiKeyRepo,
+		userRepo:    userRepo,
+		codeManager: codeManager,
+	}
+}
+
+type APIKeyCreateInput struct {
+	Name      string
+	Scopes    []domain.Scope
+	ExpiresAt *time.Time
+}
+
+// Create generates a new key in the `ck_<prefix>_<secret>` format. The key is returned only here,
+// the repository keeps its prefix for the lookup and a hash of the whole key.
+func (s *APIKeyService) Create(ctx context.Context, userID string, input APIKeyCreateInput) (*domain.APIKey, string, error) {
+	secret, err := s.codeManager.GenerateString(apiKeySecretLength)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefix := strings.ReplaceAll(uuid.New().String(), "-", "")[:apiKeyPrefixLength]
+	rawKey := strings.Join([]string{apiKeyTag, prefix, strings.TrimRight(secret, "=")}, "_")
+
+	key := &domain.APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    prefix,
+		Hash:      hashAPIKey(rawKey),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	if err = s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+func (s *APIKeyService) GetMany(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	return s.apiKeyRepo.GetMany(ctx, userID)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, userID, keyID string) error {
+	return s.apiKeyRepo.Delete(ctx, userID, keyID)
+}
+
+// MigrateExternalTokens turns the external tokens of the users into API keys with all the scopes,
+// so the integrations that use them keep working. It is safe to run on every start and on several instances.
+func (s *APIKeyService) MigrateExternalTokens(ctx context.Context) (int, error) {
+	tokens, err := s.userRepo.GetExternalTokens(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+
+	for userID, token := range tokens {
+		hash := hashAPIKey(token)
+
+		err = s.apiKeyRepo.Create(ctx, &domain.APIKey{
+			UserID:    userID,
+			Name:      legacyAPIKeyName,
+			Prefix:    legacyPrefix(hash),
+			Hash:      hash,
+			Scopes:    domain.Scopes,
+			CreatedAt: time.Now(),
+		})
+		if err != nil && !errors.Is(err, core.ErrAlreadyExist) {
+			return migrated, err
+		}
+
+		if err = s.userRepo.RemoveExternalToken(ctx, userID); err != nil {
+			return migrated, err
+		}
+
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// Authenticate finds the key by its prefix, compares hashes and records the usage.
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey, ip string) (*domain.APIKey, error) {
+	if rawKey == "" {
+		return nil, core.ErrInvalidAPIKey
+	}
+
+	prefix, ok := parseAPIKeyPrefix(rawKey)
+	if !ok {
+		prefix = legacyPrefix(hashAPIKey(rawKey))
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidAPIKey
+		}
+
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(rawKey))) != 1 {
+		return nil, core.ErrInvalidAPIKey
+	}
+
+	if key.IsExpired() {
+		return nil, core.ErrExpiredAPIKey
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidAPIKey
+		}
+
+		return nil, err
+	}
+
+	if !user.IsConfirmed {
+		return nil, core.ErrUnconfirmedEmail
+	}
+
+	if err = s.apiKeyRepo.UpdateLastUsed(ctx, key.ID, time.Now(), ip); err != nil {
+		logger.Error(err)
+	}
+
+	return key, nil
+}
+
+func parseAPIKeyPrefix(rawKey string) (string, bool) {
+	parts := strings.SplitN(rawKey, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag || len(parts[1]) != apiKeyPrefixLength || parts[2] == "" {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+// legacyPrefix never matches the prefix of a generated key, which is hex.
+func legacyPrefix(hash string) string {
+	return legacyAPIKeyPrefix + hash[:apiKeyPrefixLength]
+}
+
+func hashAPIKey(rawKey string) string {
+	hash := sha256.Sum256([]byte(rawKey))
+
+	return hex.EncodeToString(hash[:])
+}
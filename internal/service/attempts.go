@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+)
+
+const (
+	signInAction        = "sign-in"
+	confirmEmailAction  = "confirm"
+	passwordResetAction = "password-reset"
+	emailChangeAction   = "email-change"
+	magicLinkAction     = "magic-link"
+	twoFactorAction     = "two-factor"
+)
+
+const (
+	defaultMaxPerEmail      = 5
+	defaultMaxPerIP         = 20
+	defaultMaxCodeAttempts  = 5
+	defaultAttemptsWindow   = 15 * 60
+	defaultLockoutTTL       = 15 * 60
+	defaultAttemptBaseDelay = 500 * time.Millisecond
+	defaultAttemptMaxDelay  = 5 * time.Second
+)
+
+type AttemptRepository interface {
+	Incr(ctx context.Context, key string, window int) (int, error)
+	Reset(ctx context.Context, key string) error
+	Lock(ctx context.Context, key string, ttl int) error
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+}
+
+// AttemptLimits configures the brute-force protection, windows and ttl are in seconds.
+type AttemptLimits struct {
+	MaxPerEmail     int
+	MaxPerIP        int
+	MaxCodeAttempts int
+	Window          int
+	LockoutTTL      int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+}
+
+func (l AttemptLimits) withDefaults() AttemptLimits {
+	if l.MaxPerEmail <= 0 {
+		l.MaxPerEmail = defaultMaxPerEmail
+	}
+
+	if l.MaxPerIP <= 0 {
+		l.MaxPerIP = defaultMaxPerIP
+	}
+
+	if l.MaxCodeAttempts <= 0 {
+		l.MaxCodeAttempts = defaultMaxCodeAttempts
+	}
+
+	if l.Window <= 0 {
+		l.Window = defaultAttemptsWindow
+	}
+
+	if l.LockoutTTL <= 0 {
+		l.LockoutTTL = defaultLockoutTTL
+	}
+
+	if l.BaseDelay <= 0 {
+		l.BaseDelay = defaultAttemptBaseDelay
+	}
+
+	if l.MaxDelay <= 0 {
+		l.MaxDelay = defaultAttemptMaxDelay
+	}
+
+	return l
+}
+
+// attemptGuard counts failed attempts of an action per email and per IP address. Every failure after
+// the first one is answered with a growing delay and reaching a limit locks the email or the address.
+type attemptGuard struct {
+	repo   AttemptRepository
+	limits AttemptLimits
+}
+
+func newAttemptGuard(repo AttemptRepository, limits AttemptLimits) *attemptGuard {
+	return &attemptGuard{
+		repo:   repo,
+		limits: limits.withDefaults(),
+	}
+}
+
+// check returns a lockout error if the email or the address is locked for the action.
+func (g *attemptGuard) check(ctx context.Context, action, email, ip string) error {
+	for _, key := range g.keys(action, email, ip) {
+		lockedFor, err := g.repo.LockedFor(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if lockedFor > 0 {
+			return &core.LockoutError{RetryAfter: lockedFor}
+		}
+	}
+
+	return nil
+}
+
+// fail registers a failed attempt. It returns a lockout error and emailLocked set
+// once the email has just been locked, so the owner can be notified.
+func (g *attemptGuard) fail(ctx context.Context, action, email, ip string) (emailLocked bool, err error) {
+	emailKey, ipKey := g.emailKey(action, email), g.ipKey(action, ip)
+
+	emailAttempts, err := g.repo.Incr(ctx, emailKey, g.limits.Window)
+	if err != nil {
+		return false, err
+	}
+
+	ipAttempts := 0
+	if ip != "" {
+		if ipAttempts, err = g.repo.Incr(ctx, ipKey, g.limits.Window); err != nil {
+			return false, err
+		}
+	}
+
+	if emailAttempts >= g.limits.MaxPerEmail {
+		if err = g.lock(ctx, emailKey); err != nil {
+			return false, err
+		}
+
+		return true, &core.LockoutError{RetryAfter: time.Duration(g.limits.LockoutTTL) * time.Second}
+	}
+
+	if ipAttempts >= g.limits.MaxPerIP {
+		if err = g.lock(ctx, ipKey); err != nil {
+			return false, err
+		}
+
+		return false, &core.LockoutError{RetryAfter: time.Duration(g.limits.LockoutTTL) * time.Second}
+	}
+
+	return false, g.delay(ctx, emailAttempts)
+}
+
+// succeed resets the email counter, the address keeps its failures until the window ends.
+func (g *attemptGuard) succeed(ctx context.Context, action, email string) error {
+	return g.repo.Reset(ctx, g.emailKey(action, email))
+}
+
+// failCode registers a wrong guess of the verification code stored under codeKey
+// and reports whether the code has run out of attempts.
+func (g *attemptGuard) failCode(ctx context.Context, codeKey string, codeTTL int) (bool, error) {
+	attempts, err := g.repo.Incr(ctx, g.codeKey(codeKey), codeTTL)
+	if err != nil {
+		return false, err
+	}
+
+	if attempts < g.limits.MaxCodeAttempts {
+		return false, nil
+	}
+
+	return true, g.repo.Reset(ctx, g.codeKey(codeKey))
+}
+
+// resetCode drops the wrong guesses of the code, it is called whenever a new code is issued.
+func (g *attemptGuard) resetCode(ctx context.Context, codeKey string) error {
+	return g.repo.Reset(ctx, g.codeKey(codeKey))
+}
+
+func (g *attemptGuard) lock(ctx context.Context, key string) error {
+	if err := g.repo.Lock(ctx, key, g.limits.LockoutTTL); err != nil {
+		return err
+	}
+
+	return g.repo.Reset(ctx, key)
+}
+
+// delay slows down the answer on repeated failures: no delay for the first one,
+// then the base delay doubled on every next failure up to the max delay.
+func (g *attemptGuard) delay(ctx context.Context, attempts int) error {
+	if attempts < 2 {
+		return nil
+	}
+
+	d := g.limits.MaxDelay
+	if shift := attempts - 2; shift < 16 && g.limits.BaseDelay<<shift < g.limits.MaxDelay {
+		d = g.limits.BaseDelay << shift
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (g *attemptGuard) keys(action, email, ip string) []string {
+	if ip == "" {
+		return []string{g.emailKey(action, email)}
+	}
+
+	return []string{g.emailKey(action, email), g.ipKey(action, ip)}
+}
+
+func (g *attemptGuard) emailKey(action, email string) string {
+	return fmt.Sprintf("%s:email:%s", action, strings.ToLower(email))
+}
+
+func (g *attemptGuard) ipKey(action, ip string) string {
+	return fmt.Sprintf("%s:ip:%s", action, ip)
+}
+
+func (g *attemptGuard) codeKey(codeKey string) string {
+	return fmt.Sprintf("code:%s", codeKey)
+}
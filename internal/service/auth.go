@@ -2,22 +2,30 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 	"time"
 
 	core "necutya/faker/internal/domain"
 	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
 
 	"github.com/google/uuid"
 )
 
 const (
-	verifiedPasswordCheck = "verified"
-	codeLength            = 8
-	externalTokenLength   = 16
+	verifiedPasswordCheck  = "verified"
+	codeLength             = 8
+	mfaTokenLength         = 32
+	mfaMaxAttempts         = 5
+	defaultMFAChallengeTTL = 5 * 60
+	usedRefreshTokensLimit = 100
+	webhookSecretLength    = 32
 )
 
 type Hasher interface {
@@ -26,7 +34,7 @@ type Hasher interface {
 }
 
 type TokenManager interface {
-	GenerateAccessToken(userID, planID, sessionID, tokenID string, ttl int64) (string, error)
+	GenerateAccessToken(userID, planID, sessionID, tokenID, role string, permissions []string, ttl int64) (string, error)
 	GenerateRefreshToken() (string, error)
 	Parse(accessToken string) (map[string]interface{}, error)
 }
@@ -34,15 +42,24 @@ type TokenManager interface {
 type BlacklistRepository interface {
 	AddToken(ctx context.Context, tokenID string, ttl int) error
 	CheckToken(ctx context.Context, tokenID string) error
+	AddSession(ctx context.Context, sessionID string, ttl int) error
+	CheckSession(ctx context.Context, sessionID string) error
 }
 
 type VerificationRepository interface {
 	SetCode(context.Context, string, string, int) error
 	GetCode(context.Context, string) (string, error)
+	DeleteCode(context.Context, string) error
+}
+
+type MFAChallengeRepository interface {
+	Save(ctx context.Context, challenge *domain.MFAChallenge) error
+	Get(ctx context.Context, token string) (*domain.MFAChallenge, error)
+	Delete(ctx context.Context, token string) error
 }
 
 type CodeManager interface {
-	GenerateNumericCode(length int) string
+	GenerateNumericCode(length int) (string, error)
 	GenerateString(s int) (string, error)
 }
 
@@ -52,6 +69,13 @@ type AuthService struct {
 	reqCounterRepo   RequestCounterRepository
 	verificationRepo VerificationRepository
 	planRepo         PlanRepository
+	mfaChallengeRepo MFAChallengeRepository
+	settingsRepo     SettingsRepository
+	magicLinkRepo    MagicLinkRepository
+	roleRepo         RoleRepository
+	attemptGuard     *attemptGuard
+
+	passwordValidator *passwordValidator
 
 	hasher              Hasher
 	tokenManager        TokenManager
@@ -61,6 +85,9 @@ type AuthService struct {
 	accessTokenTTL      int
 	refreshTokenTTL     int
 	verificationCodeTTL int
+	mfaChallengeTTL     int
+	emailChange         EmailChangeSettings
+	magicLink           MagicLinkSettings
 }
 
 func NewAuthService(
@@ -69,22 +96,43 @@ func NewAuthService(
 	reqCounterRepo RequestCounterRepository,
 	verificationRepo VerificationRepository,
 	planRepo PlanRepository,
+	mfaChallengeRepo MFAChallengeRepository,
+	settingsRepo SettingsRepository,
+	attemptRepo AttemptRepository,
+	magicLinkRepo MagicLinkRepository,
+	roleRepo RoleRepository,
 
 	hasher Hasher,
 	tokenManager TokenManager,
 	notificationManager NotificationManager,
 	codeManager CodeManager,
+	breachedPasswords BreachedPasswordChecker,
 
 	accessTokenTTL int,
 	refreshTokenTTL int,
 	verificationCodeTTL int,
+	mfaChallengeTTL int,
+	attemptLimits AttemptLimits,
+	emailChange EmailChangeSettings,
+	magicLink MagicLinkSettings,
+	passwordPolicy PasswordPolicy,
 ) *AuthService {
+	if mfaChallengeTTL <= 0 {
+		mfaChallengeTTL = defaultMFAChallengeTTL
+	}
+
 	return &AuthService{
 		userRepo:            userRepo,
 		blackListRepo:       blackListRepo,
 		reqCounterRepo:      reqCounterRepo,
 		verificationRepo:    verificationRepo,
 		planRepo:            planRepo,
+		mfaChallengeRepo:    mfaChallengeRepo,
+		settingsRepo:        settingsRepo,
+		magicLinkRepo:       magicLinkRepo,
+		roleRepo:            roleRepo,
+		attemptGuard:        newAttemptGuard(attemptRepo, attemptLimits),
+		passwordValidator:   newPasswordValidator(passwordPolicy, breachedPasswords),
 		hasher:              hasher,
 		tokenManager:        tokenManager,
 		notificationManager: notificationManager,
@@ -92,6 +140,9 @@ func NewAuthService(
 		accessTokenTTL:      accessTokenTTL,
 		refreshTokenTTL:     refreshTokenTTL,
 		verificationCodeTTL: verificationCodeTTL,
+		mfaChallengeTTL:     mfaChallengeTTL,
+		emailChange:         emailChange.withDefaults(),
+		magicLink:           magicLink.withDefaults(),
 	}
 }
 
@@ -104,12 +155,12 @@ type UserSignUpInput struct {
 }
 
 func (s *AuthService) SignUp(ctx context.Context, input UserSignUpInput) (*domain.User, error) {
-	passwordHash, err := s.hasher.Hash(input.Password)
+	err := s.passwordValidator.validate("Password", input.Password, input.Email, input.FirstName, input.LastName)
 	if err != nil {
 		return nil, err
 	}
 
-	externalToken, err := s.codeManager.GenerateString(externalTokenLength)
+	passwordHash, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +170,7 @@ func (s *AuthService) SignUp(ctx context.Context, input UserSignUpInput) (*domai
 		return nil, err
 	}
 
-	err = s.userRepo.Create(ctx, &domain.User{
+	user := &domain.User{
 		FirstName: input.FirstName,
 		LastName:  input.LastName,
 		Email:     input.Email,
@@ -136,34 +187,58 @@ func (s *AuthService) SignUp(ctx context.Context, input UserSignUpInput) (*domai
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		LastVisitAt: time.Now(),
+	}
 
-		Credential: &domain.UserCredential{
-			ExternalToken: externalToken,
-		},
-	})
+	if err = s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err = s.sendEmailConfirmation(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetUserByEmail(ctx, input.Email)
+}
 
-	verificationCode := s.codeManager.GenerateNumericCode(codeLength)
+// ResendEmailConfirmation sends a new confirmation code, the previous one stops working.
+func (s *AuthService) ResendEmailConfirmation(ctx context.Context, userEmail string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, userEmail)
+	if err != nil {
+		return err
+	}
 
-	err = s.verificationRepo.SetCode(ctx, emailConfirmationKey(input.Email), verificationCode, s.verificationCodeTTL)
+	if user.IsConfirmed {
+		return core.ErrEmailAlreadyConfirmed
+	}
+
+	return s.sendEmailConfirmation(ctx, user)
+}
+
+func (s *AuthService) sendEmailConfirmation(ctx context.Context, user *domain.User) error {
+	verificationCode, err := s.codeManager.GenerateNumericCode(codeLength)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	err = s.verificationRepo.SetCode(ctx, emailConfirmationKey(user.Email), verificationCode, s.verificationCodeTTL)
+	if err != nil {
+		return err
+	}
+
+	if err = s.attemptGuard.resetCode(ctx, emailConfirmationKey(user.Email)); err != nil {
+		return err
 	}
 
 	emailConfirmTemplate, err := getEmailConfirmationTemplate(
-		strings.Title(fmt.Sprintf("%s %s", input.FirstName, input.LastName)),
+		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
 		verificationCode,
 		int64(s.verificationCodeTTL),
 	)
 	if err != nil {
-		return nil, err
-	}
-
-	err = s.notificationManager.SendEmail([]string{input.Email}, domain.ConfirmEmailSubject, emailConfirmTemplate)
-	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return s.userRepo.GetUserByEmail(ctx, input.Email)
+	return s.notificationManager.SendEmail([]string{user.Email}, domain.ConfirmEmailSubject, emailConfirmTemplate)
 }
 
 type UserSignInInput struct {
@@ -173,43 +248,169 @@ type UserSignInInput struct {
 	IPAddress string
 }
 
-func (s *AuthService) SignIn(ctx context.Context, input UserSignInInput) (*domain.Token, *domain.User, error) {
+// SignInOutput contains either the session tokens or, for users with the second factor enabled,
+// the MFA challenge to be passed to SignInTwoFactor.
+type SignInOutput struct {
+	Tokens *domain.Token
+	User   *domain.User
+
+	MFAChallenge *domain.MFAChallenge
+
+	// TwoFactorSetupRequired is set when the user's role requires the second factor which is not enabled yet.
+	TwoFactorSetupRequired bool
+}
+
+func (s *AuthService) SignIn(ctx context.Context, input UserSignInInput) (*SignInOutput, error) {
+	if err := s.attemptGuard.check(ctx, signInAction, input.Email, input.IPAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetUserByEmail(ctx, input.Email)
 	if err != nil {
 		if errors.Is(err, core.ErrNotFound) {
-			return nil, nil, core.ErrInvalidLoginOrPassword
+			return nil, s.registerFailure(ctx, signInAction, input.Email, input.IPAddress, nil, core.ErrInvalidLoginOrPassword)
 		}
 
-		return nil, nil, err
+		return nil, err
 	}
 
 	if !s.hasher.CheckPasswordHash(input.Password, user.Password) {
-		return nil, nil, core.ErrInvalidLoginOrPassword
+		return nil, s.registerFailure(ctx, signInAction, input.Email, input.IPAddress, user, core.ErrInvalidLoginOrPassword)
+	}
+
+	if err = s.attemptGuard.succeed(ctx, signInAction, input.Email); err != nil {
+		return nil, err
 	}
 
+	return s.signInUser(ctx, user, input.Client, input.IPAddress)
+}
+
+// signInUser finishes the sign-in of the authenticated user, it either starts the second factor
+// challenge or creates the session.
+func (s *AuthService) signInUser(ctx context.Context, user *domain.User, client, IPAddress string) (*SignInOutput, error) {
 	if !user.IsConfirmed {
-		return nil, nil, core.ErrUnconfirmedEmail
+		return nil, core.ErrUnconfirmedEmail
+	}
+
+	if user.TwoFactorEnabled() {
+		challenge, err := s.createMFAChallenge(ctx, user.ID, client, IPAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SignInOutput{MFAChallenge: challenge}, nil
 	}
 
-	tokens, err := s.createSession(ctx, user.ID, user.PlanID, input.Client, input.IPAddress)
+	settings, err := s.settingsRepo.GetSecuritySettings(ctx)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	tokens, err := s.createSession(ctx, user, client, IPAddress)
+	if err != nil {
+		return nil, err
 	}
 
-	return tokens, user, nil
+	return &SignInOutput{
+		Tokens:                 tokens,
+		User:                   user,
+		TwoFactorSetupRequired: settings.TwoFactorRequired(user.Role),
+	}, nil
 }
 
-func (s *AuthService) createSession(ctx context.Context, userID, planID string, client, IPAddress string) (*domain.Token, error) {
-	tokenID := uuid.New()
+func (s *AuthService) createMFAChallenge(ctx context.Context, userID, client, IPAddress string) (*domain.MFAChallenge, error) {
+	token, err := s.codeManager.GenerateString(mfaTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &domain.MFAChallenge{
+		Token:     token,
+		UserID:    userID,
+		Client:    client,
+		IPAddress: IPAddress,
+		ExpiresAt: time.Now().Add(time.Duration(s.mfaChallengeTTL) * time.Second),
+	}
+
+	if err = s.mfaChallengeRepo.Save(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+type UserSignInTwoFactorInput struct {
+	MFAToken string
+	Code     string
+}
+
+// SignInTwoFactor finishes the sign-in started by SignIn with a TOTP or recovery code.
+// The challenge is dropped after too many wrong codes. Wrong codes are also counted per user
+// across the challenges and lock the second factor out, the password sign-in does not reset them.
+func (s *AuthService) SignInTwoFactor(ctx context.Context, input UserSignInTwoFactorInput) (*SignInOutput, error) {
+	challenge, err := s.mfaChallengeRepo.Get(ctx, input.MFAToken)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidMFAChallenge
+		}
+
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return nil, core.ErrInvalidMFAChallenge
+	}
+
+	if err = s.attemptGuard.check(ctx, twoFactorAction, user.Email, challenge.IPAddress); err != nil {
+		return nil, err
+	}
+
+	if err = verifySecondFactor(ctx, s.userRepo, s.hasher, user, input.Code); err != nil {
+		if !errors.Is(err, core.ErrInvalidCode) {
+			return nil, err
+		}
+
+		challenge.Attempts++
+		if challenge.Attempts >= mfaMaxAttempts {
+			err = s.mfaChallengeRepo.Delete(ctx, challenge.Token)
+		} else {
+			err = s.mfaChallengeRepo.Save(ctx, challenge)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, s.registerFailure(ctx, twoFactorAction, user.Email, challenge.IPAddress, user, core.ErrInvalidCode)
+	}
+
+	if err = s.attemptGuard.succeed(ctx, twoFactorAction, user.Email); err != nil {
+		return nil, err
+	}
+
+	if err = s.mfaChallengeRepo.Delete(ctx, challenge.Token); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.createSession(ctx, user, challenge.Client, challenge.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignInOutput{
+		Tokens: tokens,
+		User:   user,
+	}, nil
+}
+
+func (s *AuthService) createSession(ctx context.Context, user *domain.User, client, IPAddress string) (*domain.Token, error) {
 	sessionID := uuid.New()
 
-	accessToken, err := s.tokenManager.GenerateAccessToken(
-		userID,
-		planID,
-		sessionID.String(),
-		tokenID.String(),
-		int64(s.accessTokenTTL),
-	)
+	accessToken, err := s.generateAccessToken(ctx, user, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -219,13 +420,14 @@ func (s *AuthService) createSession(ctx context.Context, userID, planID string,
 		return nil, err
 	}
 
-	err = s.userRepo.SetSession(ctx, userID, &domain.Session{
-		ID:           sessionID,
-		RefreshToken: refreshToken,
-		Client:       client,
-		IpAddress:    IPAddress,
-		CreatedAt:    time.Now(),
-		ExpiresAt:    time.Now().Add(time.Duration(s.refreshTokenTTL) * time.Second),
+	err = s.userRepo.SetSession(ctx, user.ID, &domain.Session{
+		ID:               sessionID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		Client:           client,
+		IpAddress:        IPAddress,
+		CreatedAt:        time.Now(),
+		LastUsedAt:       time.Now(),
+		ExpiresAt:        time.Now().Add(time.Duration(s.refreshTokenTTL) * time.Second),
 	})
 	if err != nil {
 		return nil, err
@@ -237,19 +439,73 @@ func (s *AuthService) createSession(ctx context.Context, userID, planID string,
 	}, err
 }
 
-func (s *AuthService) SignOut(ctx context.Context, userID string, tokenID, sessionID uuid.UUID) error {
-	return s.revokeSession(ctx, userID, tokenID, sessionID)
+func (s *AuthService) SignOut(ctx context.Context, userID string, sessionID uuid.UUID) error {
+	return s.revokeSessions(ctx, userID, sessionID)
 }
 
-func (s *AuthService) revokeSession(ctx context.Context, userID string, sessionID, tokenID uuid.UUID) error {
-	err := s.userRepo.RemoveSession(ctx, userID, sessionID)
+func (s *AuthService) GetSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	sessions, err := s.userRepo.GetSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, userID string, sessionID uuid.UUID) error {
+	sessions, err := s.userRepo.GetSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if sessions[i].ID == sessionID {
+			return s.revokeSessions(ctx, userID, sessionID)
+		}
+	}
+
+	return core.ErrNotFound
+}
 
-	err = s.blackListRepo.AddToken(ctx, tokenID.String(), s.accessTokenTTL)
+// RevokeOtherSessions signs the user out everywhere except the current session.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID string, currentSessionID uuid.UUID) error {
+	sessions, err := s.userRepo.GetSessions(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	return err
+	sessionIDs := make([]uuid.UUID, 0, len(sessions))
+	for i := range sessions {
+		if sessions[i].ID != currentSessionID {
+			sessionIDs = append(sessionIDs, sessions[i].ID)
+		}
+	}
+
+	return s.revokeSessions(ctx, userID, sessionIDs...)
+}
+
+// revokeSessions removes the sessions and blacklists them, so access tokens issued for them
+// stop working before they expire.
+func (s *AuthService) revokeSessions(ctx context.Context, userID string, sessionIDs ...uuid.UUID) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	if err := s.userRepo.RemoveSessions(ctx, userID, sessionIDs); err != nil {
+		return err
+	}
+
+	for i := range sessionIDs {
+		if err := s.blackListRepo.AddSession(ctx, sessionIDs[i].String(), s.accessTokenTTL); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *AuthService) parseToken(accessToken string) (*domain.TokenInfo, error) {
@@ -258,12 +514,18 @@ func (s *AuthService) parseToken(accessToken string) (*domain.TokenInfo, error)
 		return nil, err
 	}
 
-	return &domain.TokenInfo{
+	tokenInfo := &domain.TokenInfo{
 		UserID:    claims["user_id"].(string),
 		SessionID: claims["session_id"].(string),
 		TokenID:   claims["token_id"].(string),
 		PlanID:    claims["plan_id"].(string),
-	}, nil
+	}
+
+	// tokens issued before roles were embedded have no role and permissions
+	tokenInfo.Role, _ = claims["role"].(string)
+	tokenInfo.Permissions, _ = claims["permissions"].([]string)
+
+	return tokenInfo, nil
 }
 
 func (s *AuthService) ValidateToken(ctx context.Context, accessToken string) (*domain.TokenInfo, error) {
@@ -285,73 +547,159 @@ func (s *AuthService) ValidateToken(ctx context.Context, accessToken string) (*d
 		return nil, err
 	}
 
+	if err = s.blackListRepo.CheckSession(ctx, tokenInfo.SessionID); err != nil {
+		return nil, err
+	}
+
+	// tokens issued before roles were embedded carry no permissions, the current ones of the user's role apply
+	if tokenInfo.Permissions == nil {
+		permissions, err := getRolePermissions(ctx, s.roleRepo, user.Role)
+		if err != nil {
+			return nil, err
+		}
+
+		if tokenInfo.Role == "" {
+			tokenInfo.Role = string(user.Role)
+		}
+
+		tokenInfo.Permissions = make([]string, len(permissions))
+		for i := range permissions {
+			tokenInfo.Permissions[i] = string(permissions[i])
+		}
+	}
+
 	return tokenInfo, nil
 }
 
+// RefreshToken rotates the refresh token of the session. Presenting a token which was already
+// rotated means it was stolen, so the whole session is revoked together with its access tokens.
 func (s *AuthService) RefreshToken(ctx context.Context, userID, refreshToken string) (*domain.Token, error) {
-	session, err := s.userRepo.GetSessionByRefreshToken(ctx, userID, refreshToken)
+	tokenHash := hashRefreshToken(refreshToken)
+
+	session, err := s.userRepo.GetSessionByRefreshToken(ctx, userID, tokenHash)
 	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidSession
+		}
+
 		return nil, err
 	}
 
+	if session.RefreshTokenHash != tokenHash {
+		return nil, s.revokeReusedSession(ctx, userID, session.ID)
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		if err = s.revokeSessions(ctx, userID, session.ID); err != nil {
+			return nil, err
+		}
+
+		return nil, core.ErrExpiredSession
+	}
+
 	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.userRepo.RemoveSession(ctx, userID, session.ID)
+	newRefreshToken, err := s.tokenManager.GenerateRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
-	if time.Now().After(session.ExpiresAt) {
-		return nil, core.ErrExpiredSession
-	}
+	err = s.userRepo.RotateRefreshToken(ctx, userID, session.ID, tokenHash, hashRefreshToken(newRefreshToken), usedRefreshTokensLimit)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			// the token was rotated by a concurrent request
+			return nil, s.revokeReusedSession(ctx, userID, session.ID)
+		}
 
-	tokenID := uuid.New()
+		return nil, err
+	}
 
-	accessToken, err := s.tokenManager.GenerateAccessToken(
-		user.ID,
-		user.PlanID,
-		session.ID.String(),
-		tokenID.String(),
-		int64(s.accessTokenTTL),
-	)
+	accessToken, err := s.generateAccessToken(ctx, user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &domain.Token{
 		AccessToken:  accessToken,
-		RefreshToken: session.RefreshToken,
+		RefreshToken: newRefreshToken,
 	}, nil
 }
 
-func (s *AuthService) ValidateExternalToken(ctx context.Context, externalToken string) (string, error) {
-	user, err := s.userRepo.GetUserByExternalToken(ctx, externalToken)
+// generateAccessToken embeds the permissions of the user's role, so they are checked without
+// loading the user. Changes of the role's permissions apply once the token is refreshed.
+func (s *AuthService) generateAccessToken(ctx context.Context, user *domain.User, sessionID uuid.UUID) (string, error) {
+	permissions, err := getRolePermissions(ctx, s.roleRepo, user.Role)
 	if err != nil {
-		return "", core.ErrInvalidExternalToken
+		return "", err
 	}
 
-	if !user.IsConfirmed {
-		return "", core.ErrUnconfirmedEmail
+	permissionNames := make([]string, len(permissions))
+	for i := range permissions {
+		permissionNames[i] = string(permissions[i])
 	}
 
-	return user.ID, nil
+	return s.tokenManager.GenerateAccessToken(
+		user.ID,
+		user.PlanID,
+		sessionID.String(),
+		uuid.New().String(),
+		string(user.Role),
+		permissionNames,
+		int64(s.accessTokenTTL),
+	)
 }
 
-func (s *AuthService) ConfirmUserEmail(ctx context.Context, userEmail, codeToCheck string) error {
-	code, err := s.verificationRepo.GetCode(ctx, emailConfirmationKey(userEmail))
+func (s *AuthService) revokeReusedSession(ctx context.Context, userID string, sessionID uuid.UUID) error {
+	logger.Infof("refresh token reuse detected, revoking session %s of user %s", sessionID, userID)
+
+	if err := s.revokeSessions(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	return core.ErrRefreshTokenReused
+}
+
+// MigrateRefreshTokens hashes the refresh tokens of the sessions created before the tokens were hashed,
+// so they keep working. It is safe to run on every start and on several instances.
+func (s *AuthService) MigrateRefreshTokens(ctx context.Context) (int, error) {
+	tokens, err := s.userRepo.GetPlainRefreshTokens(ctx)
 	if err != nil {
-		if errors.Is(err, core.ErrNotFound) {
-			return core.ErrExpiredCode
+		return 0, err
+	}
+
+	migrated := 0
+
+	for userID, sessions := range tokens {
+		for sessionID, refreshToken := range sessions {
+			err = s.userRepo.HashRefreshToken(ctx, userID, sessionID, refreshToken, hashRefreshToken(refreshToken))
+			if err != nil {
+				return migrated, err
+			}
+
+			migrated++
 		}
+	}
+
+	return migrated, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+
+	return hex.EncodeToString(hash[:])
+}
 
+func (s *AuthService) ConfirmUserEmail(ctx context.Context, userEmail, codeToCheck, ipAddress string) error {
+	err := s.checkCode(ctx, confirmEmailAction, emailConfirmationKey(userEmail), userEmail, codeToCheck, ipAddress)
+	if err != nil {
 		return err
 	}
 
-	if code != codeToCheck {
-		return core.ErrInvalidCode
+	if err = s.verificationRepo.DeleteCode(ctx, emailConfirmationKey(userEmail)); err != nil {
+		return err
 	}
 
 	user, err := s.userRepo.GetUserByEmail(ctx, userEmail)
@@ -375,13 +723,20 @@ func (s *AuthService) PasswordResetRequest(ctx context.Context, userEmail string
 		return err
 	}
 
-	verificationCode := s.codeManager.GenerateNumericCode(codeLength)
+	verificationCode, err := s.codeManager.GenerateNumericCode(codeLength)
+	if err != nil {
+		return err
+	}
 
 	err = s.verificationRepo.SetCode(ctx, passwordResetKey(userEmail), verificationCode, s.verificationCodeTTL)
 	if err != nil {
 		return err
 	}
 
+	if err = s.attemptGuard.resetCode(ctx, passwordResetKey(userEmail)); err != nil {
+		return err
+	}
+
 	passwordResetTemplate, err := getPasswordResetTemplate(
 		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
 		verificationCode,
@@ -399,8 +754,23 @@ func (s *AuthService) PasswordResetRequest(ctx context.Context, userEmail string
 	return nil
 }
 
-func (s *AuthService) VerifyPasswordReset(ctx context.Context, userEmail, codeToCheck string) error {
-	code, err := s.verificationRepo.GetCode(ctx, passwordResetKey(userEmail))
+func (s *AuthService) VerifyPasswordReset(ctx context.Context, userEmail, codeToCheck, ipAddress string) error {
+	err := s.checkCode(ctx, passwordResetAction, passwordResetKey(userEmail), userEmail, codeToCheck, ipAddress)
+	if err != nil {
+		return err
+	}
+
+	return s.verificationRepo.SetCode(ctx, passwordResetKey(userEmail), verifiedPasswordCheck, s.verificationCodeTTL)
+}
+
+// checkCode compares the code with the one stored under codeKey. Wrong guesses are counted per email
+// and per address like sign-in failures, and the code is dropped after too many of them.
+func (s *AuthService) checkCode(ctx context.Context, action, codeKey, userEmail, codeToCheck, ipAddress string) error {
+	if err := s.attemptGuard.check(ctx, action, userEmail, ipAddress); err != nil {
+		return err
+	}
+
+	code, err := s.verificationRepo.GetCode(ctx, codeKey)
 	if err != nil {
 		if errors.Is(err, core.ErrNotFound) {
 			return core.ErrExpiredCode
@@ -408,13 +778,83 @@ func (s *AuthService) VerifyPasswordReset(ctx context.Context, userEmail, codeTo
 
 		return err
 	}
-	log.Println(code)
 
-	if code != codeToCheck {
-		return core.ErrInvalidCode
+	// the verified password reset is not a code to guess
+	if code == verifiedPasswordCheck || subtle.ConstantTimeCompare([]byte(code), []byte(codeToCheck)) != 1 {
+		exhausted, err := s.attemptGuard.failCode(ctx, codeKey, s.verificationCodeTTL)
+		if err != nil {
+			return err
+		}
+
+		if exhausted {
+			if err = s.verificationRepo.DeleteCode(ctx, codeKey); err != nil {
+				return err
+			}
+		}
+
+		user, err := s.userRepo.GetUserByEmail(ctx, userEmail)
+		if err != nil && !errors.Is(err, core.ErrNotFound) {
+			return err
+		}
+
+		if exhausted {
+			return s.registerFailure(ctx, action, userEmail, ipAddress, user, core.ErrCodeAttemptsExceeded)
+		}
+
+		return s.registerFailure(ctx, action, userEmail, ipAddress, user, core.ErrInvalidCode)
 	}
 
-	return s.verificationRepo.SetCode(ctx, passwordResetKey(userEmail), verifiedPasswordCheck, s.verificationCodeTTL)
+	return s.attemptGuard.succeed(ctx, action, userEmail)
+}
+
+// registerFailure counts the failed attempt and returns either the lockout error or failErr.
+// The owner of the account is notified when the email gets locked.
+// checkSecondFactor verifies the code of a signed-in user. Wrong codes count towards the same per-user
+// limit as SignInTwoFactor, so the second factor can not be guessed through the account settings either.
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *domain.User, code string) error {
+	if err := s.attemptGuard.check(ctx, twoFactorAction, user.Email, ""); err != nil {
+		return err
+	}
+
+	if err := verifySecondFactor(ctx, s.userRepo, s.hasher, user, code); err != nil {
+		if !errors.Is(err, core.ErrInvalidCode) {
+			return err
+		}
+
+		return s.registerFailure(ctx, twoFactorAction, user.Email, "", user, core.ErrInvalidCode)
+	}
+
+	return s.attemptGuard.succeed(ctx, twoFactorAction, user.Email)
+}
+
+func (s *AuthService) registerFailure(ctx context.Context, action, email, ipAddress string, user *domain.User, failErr error) error {
+	emailLocked, err := s.attemptGuard.fail(ctx, action, email, ipAddress)
+	if emailLocked && user != nil {
+		go s.notifyLockout(user)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return failErr
+}
+
+func (s *AuthService) notifyLockout(user *domain.User) {
+	lockoutTemplate, err := getAccountLockoutTemplate(
+		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
+		s.attemptGuard.limits.MaxPerEmail,
+		time.Now().Add(time.Duration(s.attemptGuard.limits.LockoutTTL)*time.Second),
+	)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	err = s.notificationManager.SendEmail([]string{user.Email}, domain.AccountLockoutSubject, lockoutTemplate)
+	if err != nil {
+		logger.Error(err)
+	}
 }
 
 type UserPasswordResetInput struct {
@@ -441,6 +881,11 @@ func (s *AuthService) PasswordReset(ctx context.Context, userEmail string, input
 		return err
 	}
 
+	err = s.passwordValidator.validate("Password", input.Password, user.Email, user.FirstName, user.LastName)
+	if err != nil {
+		return err
+	}
+
 	user.Password, err = s.hasher.Hash(input.Password)
 	if err != nil {
 		return err
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type CheckCacheRepository interface {
+	Get(ctx context.Context, modelVersion, hash string) (*domain.CheckResult, error)
+	Set(ctx context.Context, modelVersion, hash string, result *domain.CheckResult, ttl int) error
+	Purge(ctx context.Context, modelVersion string) (int64, error)
+}
+
+// normalizeMessage brings the text to Unicode NFC and collapses whitespace runs into single spaces,
+// so that the same text pasted from different editors gets the same cache key.
+func normalizeMessage(msg string, caseInsensitive bool) string {
+	msg = strings.Join(strings.Fields(norm.NFC.String(msg)), " ")
+
+	if caseInsensitive {
+		msg = strings.ToLower(msg)
+	}
+
+	return msg
+}
+
+func (s *AIService) checkCacheHash(input *CheckMessageInput) string {
+	sum := sha256.Sum256([]byte(input.Segmentation + ":" + normalizeMessage(input.Message, s.cacheCaseInsensitive)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *AIService) cacheEnabled() bool {
+	return s.cacheTTL > 0
+}
+
+// getCachedResult returns nil on a cache miss. The results are cached under the model version
+// of the AI manager the message is routed to. Cache failures are logged and treated as misses.
+func (s *AIService) getCachedResult(ctx context.Context, aiManager AIManager, input *CheckMessageInput) *CheckMessageOutput {
+	if !s.cacheEnabled() {
+		return nil
+	}
+
+	result, err := s.checkCacheRepo.Get(ctx, aiManager.ModelVersion(), s.checkCacheHash(input))
+	if err != nil {
+		logger.Error(err)
+		return nil
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	output := &CheckMessageOutput{
+		Message:          input.Message,
+		IsGenerated:      result.IsGenerated,
+		GeneratedPercent: result.GeneratedPercent,
+		Detectors:        result.Detectors,
+		Cached:           true,
+	}
+
+	if input.Segmentation != NoSegmentation {
+		// offsets of the cached segments belong to the text which was checked first,
+		// so segments are rebuilt for this text and only the scores are taken from the cache.
+		output.Segments = splitMessage(input.Message, input.Segmentation)
+		if len(output.Segments) != len(result.Segments) {
+			return nil
+		}
+
+		for i := range output.Segments {
+			output.Segments[i].IsGenerated = result.Segments[i].IsGenerated
+			output.Segments[i].GeneratedPercent = result.Segments[i].GeneratedPercent
+		}
+	}
+
+	return output
+}
+
+func (s *AIService) setCachedResult(ctx context.Context, aiManager AIManager, input *CheckMessageInput, output *CheckMessageOutput) {
+	if !s.cacheEnabled() {
+		return
+	}
+
+	err := s.checkCacheRepo.Set(ctx, aiManager.ModelVersion(), s.checkCacheHash(input), &domain.CheckResult{
+		Message:          output.Message,
+		IsGenerated:      output.IsGenerated,
+		GeneratedPercent: output.GeneratedPercent,
+		Segments:         output.Segments,
+		Detectors:        output.Detectors,
+	}, s.cacheTTL)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+// PurgeCache deletes cached results of the model version, or of all versions if modelVersion is empty.
+func (s *AIService) PurgeCache(ctx context.Context, modelVersion string) (int64, error) {
+	return s.checkCacheRepo.Purge(ctx, modelVersion)
+}
@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultCheckJobWorkers = 2
+	defaultCheckJobTTL     = 24 * 60 * 60
+	// checkJobLeaseTTL is how long a job stays claimed by a worker that makes no progress, in seconds.
+	checkJobLeaseTTL = 5 * 60
+
+	checkJobDequeueTimeout = 5 * time.Second
+	checkJobReapInterval   = time.Minute
+	webhookDeliveryTimeout = 5 * time.Minute
+)
+
+type CheckJobRepository interface {
+	Save(ctx context.Context, job *domain.CheckJob, ttl int) error
+	Get(ctx context.Context, jobID string) (*domain.CheckJob, error)
+	Enqueue(ctx context.Context, jobID string) error
+	// Dequeue moves the next job to the processing list, it stays there until Ack
+	// so the jobs of crashed workers are put back into the queue by RequeueAbandoned.
+	Dequeue(ctx context.Context, timeout time.Duration) (string, error)
+	Ack(ctx context.Context, jobID string) error
+	Lease(ctx context.Context, jobID string, ttl int) (bool, error)
+	ExtendLease(ctx context.Context, jobID string, ttl int) error
+	ReleaseLease(ctx context.Context, jobID string) error
+	RequeueAbandoned(ctx context.Context) (int, error)
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, url, secret, event string, payload []byte) error
+}
+
+type CheckJobService struct {
+	checkJobRepo  CheckJobRepository
+	userRepo      UserRepository
+	aiService     *AIService
+	webhookSender WebhookSender
+
+	jobTTL int
+	// notifications tracks the webhook deliveries so Run waits for them on shutdown.
+	notifications sync.WaitGroup
+}
+
+func NewCheckJobService(
+	checkJobRepo CheckJobRepository,
+	userRepo UserRepository,
+	aiService *AIService,
+	webhookSender WebhookSender,
+	jobTTL int,
+) *CheckJobService {
+	if jobTTL <= 0 {
+		jobTTL = defaultCheckJobTTL
+	}
+
+	return &CheckJobService{
+		checkJobRepo:  checkJobRepo,
+		userRepo:      userRepo,
+		aiService:     aiService,
+		webhookSender: webhookSender,
+		jobTTL:        jobTTL,
+	}
+}
+
+// Create reserves the whole batch against the daily quota and puts the job into the queue.
+// Failed items and cached hits not counted by the plan are given back when the job is finished.
+func (s *CheckJobService) Create(
+	ctx context.Context,
+	userID string,
+	input *CheckMessagesInput,
+	requestType domain.RequestType,
+) (*domain.CheckJob, error) {
+	if err := s.aiService.reserveRequests(ctx, userID, requestType, len(input.Messages)); err != nil {
+		return nil, err
+	}
+
+	items := make([]*domain.CheckJobItem, len(input.Messages))
+	for i := range input.Messages {
+		items[i] = &domain.CheckJobItem{
+			ID:      input.Messages[i].ID,
+			Message: input.Messages[i].Message,
+		}
+	}
+
+	job := &domain.CheckJob{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		RequestType:  requestType,
+		Status:       domain.QueuedCheckJobStatus,
+		SaveToDb:     input.SaveToDb,
+		Segmentation: input.Segmentation,
+		Items:        items,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	err := s.checkJobRepo.Save(ctx, job, s.jobTTL)
+	if err == nil {
+		err = s.checkJobRepo.Enqueue(ctx, job.ID)
+	}
+
+	if err != nil {
+		s.refund(ctx, job, len(items))
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *CheckJobService) Get(ctx context.Context, userID, jobID string) (*domain.CheckJob, error) {
+	job, err := s.checkJobRepo.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.UserID != userID {
+		return nil, core.ErrNotFound
+	}
+
+	return job, nil
+}
+
+// Run starts workers that process queued jobs and the reaper of the jobs abandoned by crashed workers.
+// It blocks until ctx is canceled and the webhooks of the finished jobs are delivered.
+func (s *CheckJobService) Run(ctx context.Context, workers int) {
+	if workers <= 0 {
+		workers = defaultCheckJobWorkers
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		s.reap(ctx)
+	}()
+
+	wg.Wait()
+	s.notifications.Wait()
+}
+
+func (s *CheckJobService) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobID, err := s.checkJobRepo.Dequeue(ctx, checkJobDequeueTimeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error(err)
+				time.Sleep(checkJobDequeueTimeout)
+			}
+
+			continue
+		}
+
+		if jobID == "" {
+			continue
+		}
+
+		if err = s.process(ctx, jobID); err != nil {
+			logger.Errorf("check job %s: %v", jobID, err)
+		}
+	}
+}
+
+// reap puts the jobs whose lease has expired back into the queue.
+func (s *CheckJobService) reap(ctx context.Context) {
+	ticker := time.NewTicker(checkJobReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			requeued, err := s.checkJobRepo.RequeueAbandoned(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error(err)
+			}
+
+			if requeued > 0 {
+				logger.Infof("requeued %d abandoned check jobs", requeued)
+			}
+		}
+	}
+}
+
+// process claims the job and checks its items. A job that can not be finished is marked failed
+// and the items left unchecked are given back to the quota. The jobs interrupted by the shutdown or
+// whose state can not be saved keep their place in the processing list and are resumed after requeue.
+func (s *CheckJobService) process(ctx context.Context, jobID string) error {
+	leased, err := s.checkJobRepo.Lease(ctx, jobID, checkJobLeaseTTL)
+	if err != nil {
+		return err
+	}
+
+	if !leased {
+		// the job was requeued while its worker is still alive
+		return s.checkJobRepo.Ack(ctx, jobID)
+	}
+
+	job, err := s.checkJobRepo.Get(ctx, jobID)
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return err
+	}
+
+	if err == nil && !job.Finished() {
+		err = s.check(ctx, job)
+
+		if ctx.Err() != nil {
+			s.releaseLease(jobID)
+			return nil
+		}
+
+		if err != nil {
+			logger.Errorf("check job %s failed: %v", jobID, err)
+
+			if err = s.fail(ctx, job); err != nil {
+				return err
+			}
+		}
+
+		s.notifications.Add(1)
+
+		go func() {
+			defer s.notifications.Done()
+			s.notify(job)
+		}()
+	}
+
+	s.releaseLease(jobID)
+
+	return s.checkJobRepo.Ack(ctx, jobID)
+}
+
+// check checks the items left unchecked, so a requeued job is resumed where it stopped.
+// Failed items and cached hits not counted by the plan are given back as soon as they are saved.
+func (s *CheckJobService) check(ctx context.Context, job *domain.CheckJob) error {
+	job.Status = domain.ProcessingCheckJobStatus
+	job.UpdatedAt = time.Now()
+
+	if err := s.checkJobRepo.Save(ctx, job, s.jobTTL); err != nil {
+		return err
+	}
+
+	plan, err := s.aiService.getUserPlan(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	limiter := s.aiService.newAICallLimiter()
+
+	for _, item := range job.Items[job.Processed:] {
+		result, err := s.aiService.checkMessage(ctx, job.UserID, plan, &CheckMessageInput{
+			Message:      item.Message,
+			SaveToDb:     job.SaveToDb,
+			Segmentation: job.Segmentation,
+		}, job.RequestType, limiter)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var refund bool
+
+		if err != nil {
+			logger.Errorf("check job %s item %s: %s", job.ID, item.ID, err)
+
+			item.ErrorCode, item.Error = CheckItemError(err)
+			job.Failed++
+			refund = true
+		} else {
+			item.Result = &domain.CheckResult{
+				Message:          result.Message,
+				IsGenerated:      result.IsGenerated,
+				GeneratedPercent: result.GeneratedPercent,
+				Segments:         result.Segments,
+				Detectors:        result.Detectors,
+
+				Language:           result.Language,
+				LanguageConfidence: result.LanguageConfidence,
+			}
+			item.Cached = result.Cached
+			refund = result.Cached && !plan.CountCachedRequests
+		}
+
+		job.Processed++
+		job.UpdatedAt = time.Now()
+
+		if err = s.checkJobRepo.Save(ctx, job, s.jobTTL); err != nil {
+			return err
+		}
+
+		if refund {
+			s.refund(ctx, job, 1)
+		}
+
+		if err = s.checkJobRepo.ExtendLease(ctx, job.ID, checkJobLeaseTTL); err != nil {
+			return err
+		}
+	}
+
+	return s.finish(ctx, job, domain.CompletedCheckJobStatus)
+}
+
+// fail finishes the job as failed and gives back the items left unchecked.
+func (s *CheckJobService) fail(ctx context.Context, job *domain.CheckJob) error {
+	unchecked := len(job.Items) - job.Processed
+
+	if err := s.finish(ctx, job, domain.FailedCheckJobStatus); err != nil {
+		return err
+	}
+
+	if unchecked > 0 {
+		s.refund(ctx, job, unchecked)
+	}
+
+	return nil
+}
+
+func (s *CheckJobService) finish(ctx context.Context, job *domain.CheckJob, status string) error {
+	finishedAt := time.Now()
+
+	job.Status = status
+	if job.Failed == len(job.Items) {
+		job.Status = domain.FailedCheckJobStatus
+	}
+
+	job.FinishedAt = &finishedAt
+	job.UpdatedAt = finishedAt
+
+	return s.checkJobRepo.Save(ctx, job, s.jobTTL)
+}
+
+func (s *CheckJobService) refund(ctx context.Context, job *domain.CheckJob, requests int) {
+	if _, err := s.aiService.requestCounterRepo.IncrBy(ctx, job.UserID, job.RequestType, -requests); err != nil {
+		logger.Error(err)
+	}
+}
+
+// releaseLease lets the reaper requeue the job without waiting for the lease to expire,
+// it runs on a fresh context as it is called on the shutdown too.
+func (s *CheckJobService) releaseLease(jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkJobDequeueTimeout)
+	defer cancel()
+
+	if err := s.checkJobRepo.ReleaseLease(ctx, jobID); err != nil {
+		logger.Error(err)
+	}
+}
+
+type checkJobWebhookPayload struct {
+	Event      string                       `json:"event"`
+	JobID      string                       `json:"job_id"`
+	Status     string                       `json:"status"`
+	Processed  int                          `json:"processed"`
+	Failed     int                          `json:"failed"`
+	FinishedAt *time.Time                   `json:"finished_at"`
+	Results    []*checkJobWebhookItemResult `json:"results"`
+}
+
+type checkJobWebhookItemResult struct {
+	ID               string  `json:"id"`
+	IsGenerated      bool    `json:"is_generated"`
+	GeneratedPercent float64 `json:"generated_percent"`
+	ErrorCode        string  `json:"error_code,omitempty"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// notify delivers the finished job to the user's webhook, if one is configured.
+func (s *CheckJobService) notify(job *domain.CheckJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookDeliveryTimeout)
+	defer cancel()
+
+	user, err := s.userRepo.GetUserByID(ctx, job.UserID)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	if user.Webhook == nil {
+		return
+	}
+
+	results := make([]*checkJobWebhookItemResult, len(job.Items))
+	for i, item := range job.Items {
+		results[i] = &checkJobWebhookItemResult{
+			ID:        item.ID,
+			ErrorCode: item.ErrorCode,
+			Error:     item.Error,
+		}
+
+		if item.Result != nil {
+			results[i].IsGenerated = item.Result.IsGenerated
+			results[i].GeneratedPercent = item.Result.GeneratedPercent
+		}
+	}
+
+	payload, err := json.Marshal(checkJobWebhookPayload{
+		Event:      domain.CheckJobFinishedEvent,
+		JobID:      job.ID,
+		Status:     job.Status,
+		Processed:  job.Processed,
+		Failed:     job.Failed,
+		FinishedAt: job.FinishedAt,
+		Results:    results,
+	})
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	err = s.webhookSender.Send(ctx, user.Webhook.URL, user.Webhook.Secret, domain.CheckJobFinishedEvent, payload)
+	if err != nil {
+		logger.Errorf("webhook for check job %s: %v", job.ID, err)
+	}
+}
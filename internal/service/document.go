@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	documentParser "necutya/faker/pkg/document-parser"
+)
+
+type DocumentParser interface {
+	Extract(filename string, data []byte) (string, int, string, error)
+}
+
+type CheckDocumentInput struct {
+	Filename     string
+	Content      []byte
+	SaveToDb     bool
+	Segmentation string
+}
+
+type DocumentMetadata struct {
+	Filename       string
+	PageCount      int
+	WordCount      int
+	CharacterCount int
+	Encoding       string
+}
+
+type CheckDocumentOutput struct {
+	CheckMessageOutput
+	Document DocumentMetadata
+}
+
+// CheckDocument extracts text from the uploaded document and checks it as a single message.
+func (s *AIService) CheckDocument(
+	ctx context.Context,
+	userID, planID string,
+	input *CheckDocumentInput,
+	requestType domain.RequestType,
+) (*CheckDocumentOutput, error) {
+	plan, err := s.getUserPlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.MaxFileSize != 0 && len(input.Content) > plan.MaxFileSize {
+		return nil, core.ErrFileTooLarge
+	}
+
+	text, pages, encoding, err := s.documentParser.Extract(input.Filename, input.Content)
+	if err != nil {
+		return nil, convertDocumentParserError(err)
+	}
+
+	characters := utf8.RuneCountInString(text)
+	if plan.MaxDocumentLength != 0 && characters > plan.MaxDocumentLength {
+		return nil, core.ErrDocumentTooLong
+	}
+
+	result, err := s.CheckMessage(ctx, userID, planID, &CheckMessageInput{
+		Message:      text,
+		SaveToDb:     input.SaveToDb,
+		Segmentation: input.Segmentation,
+	}, requestType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CheckDocumentOutput{
+		CheckMessageOutput: *result,
+		Document: DocumentMetadata{
+			Filename:       input.Filename,
+			PageCount:      pages,
+			WordCount:      len(strings.Fields(text)),
+			CharacterCount: characters,
+			Encoding:       encoding,
+		},
+	}, nil
+}
+
+func convertDocumentParserError(err error) error {
+	switch {
+	case errors.Is(err, documentParser.ErrUnsupportedFormat):
+		return core.ErrUnsupportedDocument
+	case errors.Is(err, documentParser.ErrEmptyDocument):
+		return core.ErrEmptyDocument
+	case errors.Is(err, documentParser.ErrMalformedDocument):
+		return core.ErrMalformedDocument
+	case errors.Is(err, documentParser.ErrDocumentTooLarge):
+		return core.ErrDocumentTooLong
+	}
+
+	return err
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	emailRevertTokenLength = 32
+	defaultEmailRevertTTL  = 7 * 24 * 60 * 60
+)
+
+// EmailChangeSettings configures the revert link sent to the previous address, RevertTTL is in seconds.
+type EmailChangeSettings struct {
+	RevertURL string
+	RevertTTL int
+}
+
+func (s EmailChangeSettings) withDefaults() EmailChangeSettings {
+	if s.RevertTTL <= 0 {
+		s.RevertTTL = defaultEmailRevertTTL
+	}
+
+	return s
+}
+
+type EmailChangeInput struct {
+	NewEmail string
+	Password string
+}
+
+// RequestEmailChange sends a code to the new address, the email is changed once the code is confirmed.
+func (s *AuthService) RequestEmailChange(ctx context.Context, userID string, input EmailChangeInput) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !s.hasher.CheckPasswordHash(input.Password, user.Password) {
+		return core.ErrInvalidCurrentPassword
+	}
+
+	if err = s.checkEmailIsFree(ctx, input.NewEmail); err != nil {
+		return err
+	}
+
+	verificationCode, err := s.codeManager.GenerateNumericCode(codeLength)
+	if err != nil {
+		return err
+	}
+
+	err = s.verificationRepo.SetCode(ctx, emailChangeAddressKey(userID), input.NewEmail, s.verificationCodeTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.verificationRepo.SetCode(ctx, emailChangeKey(userID), verificationCode, s.verificationCodeTTL)
+	if err != nil {
+		return err
+	}
+
+	if err = s.attemptGuard.resetCode(ctx, emailChangeKey(userID)); err != nil {
+		return err
+	}
+
+	emailConfirmTemplate, err := getEmailConfirmationTemplate(
+		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
+		verificationCode,
+		int64(s.verificationCodeTTL),
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.notificationManager.SendEmail([]string{input.NewEmail}, domain.ConfirmEmailSubject, emailConfirmTemplate)
+}
+
+// ConfirmEmailChange changes the email and signs the user out everywhere. The previous address
+// gets a link to revert the change in case the account was taken over.
+func (s *AuthService) ConfirmEmailChange(ctx context.Context, userID, codeToCheck, ipAddress string) (*domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	newEmail, err := s.verificationRepo.GetCode(ctx, emailChangeAddressKey(userID))
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrExpiredCode
+		}
+
+		return nil, err
+	}
+
+	err = s.checkCode(ctx, emailChangeAction, emailChangeKey(userID), user.Email, codeToCheck, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.checkEmailIsFree(ctx, newEmail); err != nil {
+		return nil, err
+	}
+
+	revertToken, err := s.codeManager.GenerateString(emailRevertTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	oldEmail := user.Email
+
+	user.Email = newEmail
+	user.UpdatedAt = time.Now()
+
+	if err = s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err = s.deleteEmailChange(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err = s.verificationRepo.SetCode(ctx, emailRevertKey(revertToken), emailRevertValue(userID, oldEmail), s.emailChange.RevertTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.revokeAllSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	s.notifyEmailChanged(user, oldEmail, revertToken)
+
+	return user, nil
+}
+
+// RevertEmailChange restores the previous email by the token from the revert link
+// and signs the user out everywhere.
+func (s *AuthService) RevertEmailChange(ctx context.Context, revertToken string) error {
+	revertKey := emailRevertKey(revertToken)
+
+	value, err := s.verificationRepo.GetCode(ctx, revertKey)
+	if errors.Is(err, core.ErrNotFound) {
+		// the links sent before the tokens were hashed work until they expire
+		revertKey = legacyEmailRevertKey(revertToken)
+		value, err = s.verificationRepo.GetCode(ctx, revertKey)
+	}
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return core.ErrInvalidRevertToken
+		}
+
+		return err
+	}
+
+	userID, oldEmail, ok := parseEmailRevertValue(value)
+	if !ok {
+		return core.ErrInvalidRevertToken
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return core.ErrInvalidRevertToken
+		}
+
+		return err
+	}
+
+	if user.Email != oldEmail {
+		if err = s.checkEmailIsFree(ctx, oldEmail); err != nil {
+			return err
+		}
+
+		user.Email = oldEmail
+		user.UpdatedAt = time.Now()
+
+		if err = s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if err = s.verificationRepo.DeleteCode(ctx, revertKey); err != nil {
+		return err
+	}
+
+	if err = s.deleteEmailChange(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.revokeAllSessions(ctx, userID)
+}
+
+func (s *AuthService) checkEmailIsFree(ctx context.Context, email string) error {
+	_, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err == nil {
+		return core.ErrAlreadyExist
+	}
+
+	if !errors.Is(err, core.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *AuthService) deleteEmailChange(ctx context.Context, userID string) error {
+	if err := s.verificationRepo.DeleteCode(ctx, emailChangeKey(userID)); err != nil {
+		return err
+	}
+
+	return s.verificationRepo.DeleteCode(ctx, emailChangeAddressKey(userID))
+}
+
+func (s *AuthService) revokeAllSessions(ctx context.Context, userID string) error {
+	sessions, err := s.userRepo.GetSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	sessionIDs := make([]uuid.UUID, len(sessions))
+	for i := range sessions {
+		sessionIDs[i] = sessions[i].ID
+	}
+
+	return s.revokeSessions(ctx, userID, sessionIDs...)
+}
+
+// notifyEmailChanged sends the revert link to the previous address, a failure is only logged
+// as the email is already changed.
+func (s *AuthService) notifyEmailChanged(user *domain.User, oldEmail, revertToken string) {
+	emailChangedTemplate, err := getEmailChangedTemplate(
+		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
+		user.Email,
+		fmt.Sprintf(s.emailChange.RevertURL, url.QueryEscape(revertToken)),
+		int64(s.emailChange.RevertTTL),
+	)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	err = s.notificationManager.SendEmail([]string{oldEmail}, domain.EmailChangedSubject, emailChangedTemplate)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func emailChangeKey(userID string) string {
+	return fmt.Sprintf("email-change:%s", userID)
+}
+
+func emailChangeAddressKey(userID string) string {
+	return fmt.Sprintf("email-change-address:%s", userID)
+}
+
+// emailRevertKey stores the revert token hashed, so the stored keys can not be used as links.
+func emailRevertKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return fmt.Sprintf("email-revert:%s", hex.EncodeToString(hash[:]))
+}
+
+// legacyEmailRevertKey is the key of the revert tokens stored before they were hashed,
+// it can be removed once RevertTTL has passed since then.
+func legacyEmailRevertKey(token string) string {
+	return fmt.Sprintf("email-revert:%s", token)
+}
+
+func emailRevertValue(userID, oldEmail string) string {
+	return fmt.Sprintf("%s:%s", userID, oldEmail)
+}
+
+func parseEmailRevertValue(value string) (string, string, bool) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
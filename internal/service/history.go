@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/domain/dto"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+type HistoryService struct {
+	messageRepo MessageRepository
+}
+
+func NewHistoryService(messageRepo MessageRepository) *HistoryService {
+	return &HistoryService{
+		messageRepo: messageRepo,
+	}
+}
+
+func (s *HistoryService) GetMany(ctx context.Context, filter dto.MessageFilter) ([]*domain.Message, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+
+	if filter.Limit > maxHistoryLimit {
+		filter.Limit = maxHistoryLimit
+	}
+
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return s.messageRepo.GetMany(ctx, filter)
+}
+
+func (s *HistoryService) GetOne(ctx context.Context, userID, checkID string) (*domain.Message, error) {
+	return s.messageRepo.GetOne(ctx, userID, checkID)
+}
+
+func (s *HistoryService) Delete(ctx context.Context, userID, checkID string) error {
+	return s.messageRepo.Delete(ctx, userID, checkID)
+}
+
+func (s *HistoryService) DeleteAll(ctx context.Context, userID string) error {
+	return s.messageRepo.DeleteByUserID(ctx, userID)
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	pdfWriter "necutya/faker/pkg/pdf-writer"
+)
+
+const (
+	invoiceContentType = "application/pdf"
+	// invoiceNumberAttempts is how many numbers are tried when they are taken by the concurrently issued invoices.
+	invoiceNumberAttempts = 20
+)
+
+type InvoiceRepository interface {
+	LastSequence(ctx context.Context, year int) (int, error)
+	Create(ctx context.Context, invoice *domain.Invoice) error
+	SetNumber(ctx context.Context, invoiceID string, year, sequence int, number string) error
+	GetByOrderID(ctx context.Context, orderID string) (*domain.Invoice, error)
+}
+
+type InvoiceSettings struct {
+	Seller domain.InvoiceParty
+	// URL is the page the invoice is downloaded from, %s is replaced with the order id.
+	// The link is not added to the emails when it is empty.
+	URL string
+}
+
+func (s InvoiceSettings) invoiceURL(orderID string) string {
+	if s.URL == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(s.URL, orderID)
+}
+
+type InvoiceService struct {
+	invoiceRepo     InvoiceRepository
+	settings        InvoiceSettings
+	defaultCurrency string
+}
+
+func NewInvoiceService(invoiceRepo InvoiceRepository, settings InvoiceSettings, defaultCurrency string) *InvoiceService {
+	return &InvoiceService{
+		invoiceRepo:     invoiceRepo,
+		settings:        settings,
+		defaultCurrency: defaultCurrency,
+	}
+}
+
+// Issue creates the invoice of the paid order and numbers it, the order has only one invoice
+// so the existing one is returned if it was already issued.
+func (s *InvoiceService) Issue(ctx context.Context, order *domain.Order, user *domain.User, plan *domain.Plan) (*domain.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByOrderID(ctx, order.ID)
+	if errors.Is(err, core.ErrNotFound) {
+		invoice, err = s.create(ctx, order, user, plan)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invoice.Number != "" {
+		return invoice, nil
+	}
+
+	// the invoice is created but not numbered yet, or it failed to be numbered before
+	return s.number(ctx, invoice)
+}
+
+func (s *InvoiceService) create(ctx context.Context, order *domain.Order, user *domain.User, plan *domain.Plan) (*domain.Invoice, error) {
+	buyerName := strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName))
+	if !pdfWriter.Encodable(buyerName) {
+		return nil, core.ErrInvoiceNameUnsupported
+	}
+
+	currency := order.Currency
+	if currency == "" {
+		currency = s.defaultCurrency
+	}
+
+	invoice := &domain.Invoice{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		Seller:  s.settings.Seller,
+		Buyer: domain.InvoiceParty{
+			Name:  buyerName,
+			Email: user.Email,
+		},
+		Items: []*domain.InvoiceItem{
+			{
+				Description: invoiceItemDescription(plan),
+				Quantity:    1,
+				UnitPrice:   int64(convertDollarsToCents(order.Amount)),
+			},
+		},
+		Currency: currency,
+		IssuedAt: time.Now().UTC(),
+	}
+
+	if order.Credit > 0 {
+		invoice.Items = append(invoice.Items, &domain.InvoiceItem{
+			Description: "Credit for the unused time of the previous plan",
+			Quantity:    1,
+			UnitPrice:   -order.Credit,
+		})
+	}
+
+	err := s.invoiceRepo.Create(ctx, invoice)
+	if errors.Is(err, core.ErrAlreadyExist) {
+		// created concurrently
+		return s.invoiceRepo.GetByOrderID(ctx, order.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// number gives the invoice the next free number of the year it is issued in. A number is taken
+// only by the stored invoice, so the failed and the concurrent issues leave no gaps in the numbers.
+func (s *InvoiceService) number(ctx context.Context, invoice *domain.Invoice) (*domain.Invoice, error) {
+	year := invoice.IssuedAt.Year()
+
+	var sequence int
+
+	for attempt := 0; attempt < invoiceNumberAttempts; attempt++ {
+		last, err := s.invoiceRepo.LastSequence(ctx, year)
+		if err != nil {
+			return nil, err
+		}
+
+		// the counter is behind the numbers taken when it failed to be moved
+		if last < sequence {
+			last = sequence
+		}
+
+		sequence = last + 1
+		number := domain.InvoiceNumber(year, sequence)
+
+		err = s.invoiceRepo.SetNumber(ctx, invoice.ID, year, sequence, number)
+		switch {
+		case err == nil:
+			invoice.Number = number
+			return invoice, nil
+		case errors.Is(err, core.ErrNotFound):
+			// numbered concurrently
+			return s.invoiceRepo.GetByOrderID(ctx, invoice.OrderID)
+		case !errors.Is(err, core.ErrAlreadyExist):
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("no free number for invoice of order %s in %d attempts", invoice.OrderID, invoiceNumberAttempts)
+}
+
+// GetByOrderID returns ErrNotFound until the invoice of the order is numbered.
+func (s *InvoiceService) GetByOrderID(ctx context.Context, orderID string) (*domain.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if invoice.Number == "" {
+		return nil, core.ErrNotFound
+	}
+
+	return invoice, nil
+}
+
+// Render lays the invoice of the order out on an A4 page, the invoice stays as issued
+// and the note under it tells whether the order was refunded since.
+func (s *InvoiceService) Render(invoice *domain.Invoice, order *domain.Order) ([]byte, error) {
+	const (
+		left  = 50.0
+		right = pdfWriter.PageWidth - 50
+		// the columns of the items table are aligned by their right edge except the description
+		quantityColumn  = 360.0
+		unitPriceColumn = 450.0
+		rowHeight       = 20.0
+	)
+
+	doc := pdfWriter.New("Invoice "+invoice.Number, invoice.IssuedAt)
+	page := doc.AddPage()
+
+	page.Text(left, 70, pdfWriter.HelveticaBold, 24, "INVOICE")
+	page.TextRight(right, 60, pdfWriter.Helvetica, 10, "No. "+invoice.Number)
+	page.TextRight(right, 75, pdfWriter.Helvetica, 10, "Date: "+invoice.IssuedAt.Format(domain.DateLayout))
+
+	sellerEnd := drawInvoiceParty(page, left, 120, "Seller", invoice.Seller)
+	buyerEnd := drawInvoiceParty(page, 320, 120, "Buyer", invoice.Buyer)
+
+	y := sellerEnd
+	if buyerEnd > y {
+		y = buyerEnd
+	}
+
+	y += 30
+
+	page.FillRect(left, y-14, right-left, rowHeight, 0.92)
+	page.Text(left+5, y, pdfWriter.HelveticaBold, 10, "Description")
+	page.TextRight(quantityColumn, y, pdfWriter.HelveticaBold, 10, "Qty")
+	page.TextRight(unitPriceColumn, y, pdfWriter.HelveticaBold, 10, "Unit price")
+	page.TextRight(right-5, y, pdfWriter.HelveticaBold, 10, "Amount")
+
+	for _, item := range invoice.Items {
+		y += rowHeight
+
+		page.Text(left+5, y, pdfWriter.Helvetica, 10, item.Description)
+		page.TextRight(quantityColumn, y, pdfWriter.Helvetica, 10, fmt.Sprint(item.Quantity))
+		page.TextRight(unitPriceColumn, y, pdfWriter.Helvetica, 10, formatCents(item.UnitPrice, invoice.Currency))
+		page.TextRight(right-5, y, pdfWriter.Helvetica, 10, formatCents(item.Amount(), invoice.Currency))
+	}
+
+	y += 10
+	page.Line(left, y, right, y, 0.5)
+
+	y += 20
+	page.TextRight(unitPriceColumn, y, pdfWriter.HelveticaBold, 11, "Total")
+	page.TextRight(right-5, y, pdfWriter.HelveticaBold, 11, formatCents(invoice.Total(), invoice.Currency))
+
+	y += 40
+	page.Text(left, y, pdfWriter.Helvetica, 9, "Order "+invoice.OrderID)
+	page.Text(left, y+14, pdfWriter.Helvetica, 9, invoicePaymentNote(order, invoice.Currency))
+
+	return doc.Bytes()
+}
+
+// drawInvoiceParty draws the party as a column starting at y and returns the y of its last line.
+func drawInvoiceParty(page *pdfWriter.Page, x, y float64, title string, party domain.InvoiceParty) float64 {
+	const lineHeight = 14.0
+
+	page.Text(x, y, pdfWriter.HelveticaBold, 11, title)
+
+	lines := []string{party.Name}
+	lines = append(lines, strings.Split(party.Address, "\n")...)
+	lines = append(lines, party.Email)
+
+	if party.TaxID != "" {
+		lines = append(lines, "Tax ID: "+party.TaxID)
+	}
+
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+
+		y += lineHeight
+		page.Text(x, y, pdfWriter.Helvetica, 10, line)
+	}
+
+	return y
+}
+
+func invoicePaymentNote(order *domain.Order, currency string) string {
+	switch order.Status {
+	case domain.PartiallyRefundedOrderStatus:
+		return fmt.Sprintf("Paid, %s of it refunded.", formatCents(order.RefundedAmount(), currency))
+	case domain.RefundedOrderStatus:
+		return "Refunded in full."
+	case domain.ReversedOrderStatus:
+		return "The payment was reversed."
+	default:
+		return "Paid in full. Thank you for choosing CheckIT."
+	}
+}
+
+func invoiceItemDescription(plan *domain.Plan) string {
+	switch plan.Duration {
+	case 0:
+		return fmt.Sprintf("CheckIT plan %s", plan.Name)
+	case 1:
+		return fmt.Sprintf("CheckIT plan %s, 1 month", plan.Name)
+	default:
+		return fmt.Sprintf("CheckIT plan %s, %d months", plan.Name, plan.Duration)
+	}
+}
+
+func formatCents(cents int64, currency string) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d %s", sign, cents/100, cents%100, currency)
+}
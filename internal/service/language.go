@@ -0,0 +1,30 @@
+package service
+
+import (
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+)
+
+type LanguageDetector interface {
+	Detect(text string) (language string, confidence float64)
+}
+
+// detectLanguage returns the language of the message and the detector's confidence,
+// core.ErrUnsupportedLanguage is returned if the plan does not support the language.
+func (s *AIService) detectLanguage(plan *domain.Plan, message string) (string, float64, error) {
+	language, confidence := s.languageDetector.Detect(message)
+
+	if !plan.SupportsLanguage(language) {
+		return "", 0, core.ErrUnsupportedLanguage
+	}
+
+	return language, confidence, nil
+}
+
+func (s *AIService) aiManagerFor(language string) AIManager {
+	if aiManager, ok := s.languageAIManagers[language]; ok {
+		return aiManager
+	}
+
+	return s.aiManager
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+)
+
+const (
+	magicLinkTokenLength = 32
+	defaultMagicLinkTTL  = 15 * 60
+)
+
+type MagicLinkRepository interface {
+	Save(ctx context.Context, link *domain.MagicLink) error
+	Pop(ctx context.Context, tokenHash string) (*domain.MagicLink, error)
+}
+
+// MagicLinkSettings configures the passwordless sign-in, URL contains %s for the token
+// which is signed with Secret. TTL is in seconds.
+type MagicLinkSettings struct {
+	Enabled bool
+	URL     string
+	Secret  string
+	TTL     int
+}
+
+func (s MagicLinkSettings) withDefaults() MagicLinkSettings {
+	if s.TTL <= 0 {
+		s.TTL = defaultMagicLinkTTL
+	}
+
+	return s
+}
+
+type MagicLinkInput struct {
+	Email     string
+	Client    string
+	IPAddress string
+}
+
+// RequestMagicLink emails a single-use sign-in link. Every request counts against the attempt limits,
+// so the links can not be used to flood the mailbox, and unknown or unconfirmed emails get no link
+// without telling the caller about it.
+func (s *AuthService) RequestMagicLink(ctx context.Context, input MagicLinkInput) error {
+	if !s.magicLink.Enabled {
+		return core.ErrMagicLinkDisabled
+	}
+
+	if err := s.attemptGuard.check(ctx, magicLinkAction, input.Email, input.IPAddress); err != nil {
+		return err
+	}
+
+	if _, err := s.attemptGuard.fail(ctx, magicLinkAction, input.Email, input.IPAddress); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, input.Email)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !user.IsConfirmed {
+		return nil
+	}
+
+	token, err := s.generateMagicLinkToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.magicLinkRepo.Save(ctx, &domain.MagicLink{
+		TokenHash:   hashMagicLinkToken(token),
+		UserID:      user.ID,
+		Fingerprint: magicLinkFingerprint(input.Client, input.IPAddress),
+		ExpiresAt:   time.Now().Add(time.Duration(s.magicLink.TTL) * time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	magicLinkTemplate, err := getMagicLinkTemplate(
+		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
+		fmt.Sprintf(s.magicLink.URL, url.QueryEscape(token)),
+		int64(s.magicLink.TTL),
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.notificationManager.SendEmail([]string{user.Email}, domain.MagicLinkSubject, magicLinkTemplate)
+}
+
+type MagicLinkSignInInput struct {
+	Token     string
+	Client    string
+	IPAddress string
+}
+
+// SignInMagicLink consumes the link, it is dropped on the first use even if it was opened
+// from another client or address than the one it was requested from.
+func (s *AuthService) SignInMagicLink(ctx context.Context, input MagicLinkSignInInput) (*SignInOutput, error) {
+	if !s.magicLink.Enabled {
+		return nil, core.ErrMagicLinkDisabled
+	}
+
+	if !s.verifyMagicLinkToken(input.Token) {
+		return nil, core.ErrInvalidMagicLink
+	}
+
+	link, err := s.magicLinkRepo.Pop(ctx, hashMagicLinkToken(input.Token))
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidMagicLink
+		}
+
+		return nil, err
+	}
+
+	fingerprint := magicLinkFingerprint(input.Client, input.IPAddress)
+	if subtle.ConstantTimeCompare([]byte(link.Fingerprint), []byte(fingerprint)) != 1 {
+		return nil, core.ErrInvalidMagicLink
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, link.UserID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidMagicLink
+		}
+
+		return nil, err
+	}
+
+	if err = s.attemptGuard.succeed(ctx, magicLinkAction, user.Email); err != nil {
+		return nil, err
+	}
+
+	return s.signInUser(ctx, user, input.Client, input.IPAddress)
+}
+
+// generateMagicLinkToken returns a random value followed by its signature, so forged tokens
+// are rejected before the storage is queried.
+func (s *AuthService) generateMagicLinkToken() (string, error) {
+	value, err := s.codeManager.GenerateString(magicLinkTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	value = strings.TrimRight(value, "=")
+
+	return value + "." + s.signMagicLinkValue(value), nil
+}
+
+func (s *AuthService) verifyMagicLinkToken(token string) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(parts[1]), []byte(s.signMagicLinkValue(parts[0])))
+}
+
+func (s *AuthService) signMagicLinkValue(value string) string {
+	mac := hmac.New(sha256.New, []byte(s.magicLink.Secret))
+	mac.Write([]byte(value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashMagicLinkToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func magicLinkFingerprint(client, ipAddress string) string {
+	hash := sha256.Sum256([]byte(client + "\n" + ipAddress))
+
+	return hex.EncodeToString(hash[:])
+}
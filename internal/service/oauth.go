@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+	"necutya/faker/pkg/oauth"
+)
+
+const (
+	oauthStateLength     = 32
+	oauthNonceLength     = 32
+	defaultOAuthStateTTL = 10 * 60
+)
+
+type OAuthProvider interface {
+	AuthCodeURL(ctx context.Context, state, codeChallenge, nonce string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*oauth.Identity, error)
+}
+
+type OAuthStateRepository interface {
+	Save(ctx context.Context, state *domain.OAuthState) error
+	Pop(ctx context.Context, state string) (*domain.OAuthState, error)
+}
+
+type OAuthService struct {
+	userRepo    UserRepository
+	planRepo    PlanRepository
+	stateRepo   OAuthStateRepository
+	authService *AuthService
+	codeManager CodeManager
+
+	providers map[string]OAuthProvider
+	stateTTL  int
+}
+
+func NewOAuthService(
+	userRepo UserRepository,
+	planRepo PlanRepository,
+	stateRepo OAuthStateRepository,
+	authService *AuthService,
+	codeManager CodeManager,
+	providers map[string]OAuthProvider,
+	stateTTL int,
+) *OAuthService {
+	if stateTTL <= 0 {
+		stateTTL = defaultOAuthStateTTL
+	}
+
+	return &OAuthService{
+		userRepo:    userRepo,
+		planRepo:    planRepo,
+		stateRepo:   stateRepo,
+		authService: authService,
+		codeManager: codeManager,
+		providers:   providers,
+		stateTTL:    stateTTL,
+	}
+}
+
+// Providers returns names of the configured providers.
+func (s *OAuthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// AuthorizationURL starts the authorization code flow with PKCE. userID is set to link the provider
+// to the signed in user and is empty to sign in with it.
+func (s *OAuthService) AuthorizationURL(ctx context.Context, providerName, userID string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", core.ErrUnknownOAuthProvider
+	}
+
+	state, err := s.codeManager.GenerateString(oauthStateLength)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := s.codeManager.GenerateString(oauthNonceLength)
+	if err != nil {
+		return "", err
+	}
+
+	codeVerifier, err := oauth.GenerateCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.stateRepo.Save(ctx, &domain.OAuthState{
+		State:        state,
+		Provider:     providerName,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(time.Duration(s.stateTTL) * time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(ctx, state, oauth.CodeChallenge(codeVerifier), nonce)
+}
+
+type OAuthCallbackInput struct {
+	Provider  string
+	State     string
+	Code      string
+	Client    string
+	IPAddress string
+}
+
+// SignIn finishes the flow started by AuthorizationURL. A user without a linked account of the provider
+// is linked by the verified email or created as a confirmed user on the basic plan.
+func (s *OAuthService) SignIn(ctx context.Context, input OAuthCallbackInput) (*SignInOutput, error) {
+	identity, err := s.exchange(ctx, input, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByIdentity(ctx, input.Provider, identity.Subject)
+	if err != nil {
+		if !errors.Is(err, core.ErrNotFound) {
+			return nil, err
+		}
+
+		user, err = s.linkOrCreateUser(ctx, input.Provider, identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.authService.signInUser(ctx, user, input.Client, input.IPAddress)
+}
+
+func (s *OAuthService) linkOrCreateUser(ctx context.Context, providerName string, identity *oauth.Identity) (*domain.User, error) {
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, core.ErrOAuthEmailNotVerified
+	}
+
+	userIdentity := &domain.UserIdentity{
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now(),
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, identity.Email)
+	if err == nil {
+		if !user.IsConfirmed {
+			// the provider has proven the ownership of the email, the password of an unconfirmed
+			// account was set by someone who has not, so it is dropped
+			user.IsConfirmed = true
+			user.Password = ""
+			user.UpdatedAt = time.Now()
+
+			if err = s.userRepo.Update(ctx, user); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = s.userRepo.AddIdentity(ctx, user.ID, userIdentity); err != nil {
+			if errors.Is(err, core.ErrAlreadyExist) {
+				// another account of the provider with the same email is linked
+				return nil, core.ErrIdentityAlreadyLinked
+			}
+
+			return nil, err
+		}
+
+		user.Identities = append(user.Identities, userIdentity)
+
+		return user, nil
+	}
+
+	if !errors.Is(err, core.ErrNotFound) {
+		return nil, err
+	}
+
+	plan, err := s.planRepo.GetOneByName(ctx, domain.BasicPlanName)
+	if err != nil {
+		return nil, err
+	}
+
+	user = &domain.User{
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		Email:     identity.Email,
+
+		Role: domain.BasicRole,
+
+		IsConfirmed: true,
+
+		PlanID: plan.ID,
+
+		Identities: []*domain.UserIdentity{userIdentity},
+
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		LastVisitAt: time.Now(),
+	}
+
+	if err = s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Link finishes the flow started by AuthorizationURL for the signed in user.
+func (s *OAuthService) Link(ctx context.Context, userID string, input OAuthCallbackInput) (*domain.User, error) {
+	identity, err := s.exchange(ctx, input, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	linkedUser, err := s.userRepo.GetUserByIdentity(ctx, input.Provider, identity.Subject)
+	if err == nil {
+		if linkedUser.ID != userID {
+			return nil, core.ErrIdentityAlreadyLinked
+		}
+
+		return nil, core.ErrAlreadyExist
+	}
+
+	if !errors.Is(err, core.ErrNotFound) {
+		return nil, err
+	}
+
+	err = s.userRepo.AddIdentity(ctx, userID, &domain.UserIdentity{
+		Provider: input.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetUserByID(ctx, userID)
+}
+
+// Unlink removes the provider's account, the user must keep a password or another provider to sign in with.
+func (s *OAuthService) Unlink(ctx context.Context, userID, providerName string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Identity(providerName) == nil {
+		return core.ErrNotFound
+	}
+
+	if user.Password == "" && len(user.Identities) == 1 {
+		return core.ErrLastSignInMethod
+	}
+
+	return s.userRepo.RemoveIdentity(ctx, userID, providerName)
+}
+
+// exchange checks the state, which is usable only once, and redeems the code at the provider.
+func (s *OAuthService) exchange(ctx context.Context, input OAuthCallbackInput, userID string) (*oauth.Identity, error) {
+	provider, ok := s.providers[input.Provider]
+	if !ok {
+		return nil, core.ErrUnknownOAuthProvider
+	}
+
+	state, err := s.stateRepo.Pop(ctx, input.State)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidOAuthState
+		}
+
+		return nil, err
+	}
+
+	if state.Provider != input.Provider || state.UserID != userID {
+		return nil, core.ErrInvalidOAuthState
+	}
+
+	identity, err := provider.Exchange(ctx, input.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		logger.Errorf("oauth %s: %s", input.Provider, err)
+
+		return nil, core.ErrOAuthFailed
+	}
+
+	return identity, nil
+}
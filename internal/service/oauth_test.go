@@ -0,0 +1,543 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/oauth"
+	"necutya/faker/pkg/oauth/oauthtest"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testOAuthProvider = "stub"
+
+// fakeUserRepo keeps the users in memory, the methods the OAuth flow does not use panic.
+type fakeUserRepo struct {
+	UserRepository
+
+	users map[string]*domain.User
+}
+
+func (r *fakeUserRepo) GetUserByID(_ context.Context, id string) (*domain.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+
+	return nil, core.ErrNotFound
+}
+
+func (r *fakeUserRepo) GetUserByEmail(_ context.Context, email string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, core.ErrNotFound
+}
+
+func (r *fakeUserRepo) GetUserByIdentity(_ context.Context, provider, subject string) (*domain.User, error) {
+	for _, user := range r.users {
+		if identity := user.Identity(provider); identity != nil && identity.Subject == subject {
+			return user, nil
+		}
+	}
+
+	return nil, core.ErrNotFound
+}
+
+func (r *fakeUserRepo) Create(_ context.Context, user *domain.User) error {
+	user.ID = fmt.Sprintf("user-%d", len(r.users)+1)
+	r.users[user.ID] = user
+
+	return nil
+}
+
+func (r *fakeUserRepo) Update(_ context.Context, user *domain.User) error {
+	r.users[user.ID] = user
+
+	return nil
+}
+
+// AddIdentity rejects the account linked to any user, and any account of the provider linked to the user.
+func (r *fakeUserRepo) AddIdentity(ctx context.Context, userID string, identity *domain.UserIdentity) error {
+	if _, err := r.GetUserByIdentity(ctx, identity.Provider, identity.Subject); err == nil {
+		return core.ErrAlreadyExist
+	}
+
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Identity(identity.Provider) != nil {
+		return core.ErrAlreadyExist
+	}
+
+	user.Identities = append(user.Identities, identity)
+
+	return nil
+}
+
+func (r *fakeUserRepo) RemoveIdentity(ctx context.Context, userID, provider string) error {
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range user.Identities {
+		if user.Identities[i].Provider == provider {
+			user.Identities = append(user.Identities[:i], user.Identities[i+1:]...)
+			return nil
+		}
+	}
+
+	return core.ErrNotFound
+}
+
+type fakePlanRepo struct {
+	PlanRepository
+}
+
+func (r *fakePlanRepo) GetOneByName(_ context.Context, name string) (*domain.Plan, error) {
+	return &domain.Plan{ID: "plan-" + name, Name: name}, nil
+}
+
+type fakeOAuthStateRepo struct {
+	states map[string]*domain.OAuthState
+}
+
+func (r *fakeOAuthStateRepo) Save(_ context.Context, state *domain.OAuthState) error {
+	r.states[state.State] = state
+
+	return nil
+}
+
+func (r *fakeOAuthStateRepo) Pop(_ context.Context, state string) (*domain.OAuthState, error) {
+	s, ok := r.states[state]
+	if !ok {
+		return nil, core.ErrNotFound
+	}
+
+	delete(r.states, state)
+
+	return s, nil
+}
+
+type fakeMFAChallengeRepo struct {
+	MFAChallengeRepository
+
+	challenges map[string]*domain.MFAChallenge
+}
+
+func (r *fakeMFAChallengeRepo) Save(_ context.Context, challenge *domain.MFAChallenge) error {
+	r.challenges[challenge.Token] = challenge
+
+	return nil
+}
+
+type fakeCodeManager struct {
+	generated int
+}
+
+func (m *fakeCodeManager) GenerateNumericCode(length int) (string, error) {
+	m.generated++
+
+	return fmt.Sprintf("%0*d", length, m.generated), nil
+}
+
+func (m *fakeCodeManager) GenerateString(length int) (string, error) {
+	m.generated++
+
+	return fmt.Sprintf("%0*d", length, m.generated), nil
+}
+
+type oauthTest struct {
+	stub      *oauthtest.Provider
+	users     *fakeUserRepo
+	states    *fakeOAuthStateRepo
+	service   *OAuthService
+	idClaims  jwt.MapClaims
+	lastState *domain.OAuthState
+}
+
+// newOAuthTest returns the service with the stub provider. Only the users with the second factor can sign in,
+// their sign-in stops at the MFA challenge, so the sessions are not needed.
+func newOAuthTest(t *testing.T, users ...*domain.User) *oauthTest {
+	t.Helper()
+
+	stub := oauthtest.NewProvider("client-id")
+	t.Cleanup(stub.Close)
+
+	userRepo := &fakeUserRepo{users: make(map[string]*domain.User)}
+	for _, user := range users {
+		userRepo.users[user.ID] = user
+	}
+
+	stateRepo := &fakeOAuthStateRepo{states: make(map[string]*domain.OAuthState)}
+	codeManager := &fakeCodeManager{}
+
+	authService := &AuthService{
+		userRepo:         userRepo,
+		mfaChallengeRepo: &fakeMFAChallengeRepo{challenges: make(map[string]*domain.MFAChallenge)},
+		codeManager:      codeManager,
+		mfaChallengeTTL:  60,
+	}
+
+	provider := oauth.NewOIDC(stub.Issuer(), oauth.Config{
+		ClientID:     stub.ClientID,
+		ClientSecret: oauthtest.ClientSecret,
+		RedirectURL:  "https://app.example/oauth/callback",
+	}, 5*time.Second)
+
+	return &oauthTest{
+		stub:   stub,
+		users:  userRepo,
+		states: stateRepo,
+		service: NewOAuthService(userRepo, &fakePlanRepo{}, stateRepo, authService, codeManager,
+			map[string]OAuthProvider{testOAuthProvider: provider}, 0),
+		idClaims: jwt.MapClaims{"sub": "subject", "email": "jane@example.com", "email_verified": true},
+	}
+}
+
+// authorize starts the flow and returns the callback input the provider redirects back with.
+func (tt *oauthTest) authorize(t *testing.T, userID string) OAuthCallbackInput {
+	t.Helper()
+
+	authURL, err := tt.service.AuthorizationURL(context.Background(), testOAuthProvider, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, err := tt.stub.Authorize(authURL, tt.idClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt.lastState = tt.states.states[state]
+
+	return OAuthCallbackInput{Provider: testOAuthProvider, State: state, Code: code}
+}
+
+func TestOAuthAuthorizationURL(t *testing.T) {
+	tt := newOAuthTest(t)
+
+	authURL, err := tt.service.AuthorizationURL(context.Background(), testOAuthProvider, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+
+	state, ok := tt.states.states[query.Get("state")]
+	if !ok {
+		t.Fatalf("state %q is not saved", query.Get("state"))
+	}
+
+	if state.Provider != testOAuthProvider || state.UserID != "user-1" {
+		t.Errorf("state = %+v, want the provider and the user", state)
+	}
+
+	if got := oauth.CodeChallenge(state.CodeVerifier); got != query.Get("code_challenge") {
+		t.Errorf("code_challenge = %q, want the challenge of the saved verifier %q", query.Get("code_challenge"), got)
+	}
+
+	if query.Get("nonce") == "" || query.Get("nonce") != state.Nonce {
+		t.Errorf("nonce = %q, want the saved nonce %q", query.Get("nonce"), state.Nonce)
+	}
+
+	if _, err = tt.service.AuthorizationURL(context.Background(), "unknown", ""); err != core.ErrUnknownOAuthProvider {
+		t.Errorf("err = %v for an unknown provider, want %v", err, core.ErrUnknownOAuthProvider)
+	}
+}
+
+func TestOAuthState(t *testing.T) {
+	tests := []struct {
+		name  string
+		input func(t *testing.T, tt *oauthTest, input OAuthCallbackInput) OAuthCallbackInput
+	}{
+		{
+			name: "unknown state",
+			input: func(_ *testing.T, _ *oauthTest, input OAuthCallbackInput) OAuthCallbackInput {
+				input.State = "unknown"
+				return input
+			},
+		},
+		{
+			name: "reused state",
+			input: func(t *testing.T, tt *oauthTest, input OAuthCallbackInput) OAuthCallbackInput {
+				if _, err := tt.service.Link(context.Background(), "user-1", input); err != nil {
+					t.Fatal(err)
+				}
+
+				return input
+			},
+		},
+		{
+			name: "state of another provider",
+			input: func(_ *testing.T, tt *oauthTest, input OAuthCallbackInput) OAuthCallbackInput {
+				tt.lastState.Provider = "another"
+				return input
+			},
+		},
+		{
+			name: "state of another user",
+			input: func(_ *testing.T, tt *oauthTest, input OAuthCallbackInput) OAuthCallbackInput {
+				tt.lastState.UserID = "user-2"
+				return input
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			tt := newOAuthTest(t, &domain.User{ID: "user-1", Password: "hash"})
+
+			input := test.input(t, tt, tt.authorize(t, "user-1"))
+
+			if _, err := tt.service.Link(context.Background(), "user-1", input); err != core.ErrInvalidOAuthState {
+				t.Fatalf("err = %v, want %v", err, core.ErrInvalidOAuthState)
+			}
+		})
+	}
+}
+
+func TestOAuthSignIn(t *testing.T) {
+	twoFactor := &domain.UserTwoFactor{Enabled: true}
+
+	tests := []struct {
+		name     string
+		users    []*domain.User
+		idClaims jwt.MapClaims
+		wantErr  error
+		// wantUser signs in, the password is dropped when the account was not confirmed
+		wantUser     string
+		wantPassword string
+	}{
+		{
+			name: "linked account",
+			users: []*domain.User{{
+				ID: "user-1", Email: "jane@work.example", IsConfirmed: true, Password: "hash", TwoFactor: twoFactor,
+				Identities: []*domain.UserIdentity{{Provider: testOAuthProvider, Subject: "subject"}},
+			}},
+			wantUser:     "user-1",
+			wantPassword: "hash",
+		},
+		{
+			name: "linked by the verified email",
+			users: []*domain.User{{
+				ID: "user-1", Email: "jane@example.com", IsConfirmed: true, Password: "hash", TwoFactor: twoFactor,
+			}},
+			wantUser:     "user-1",
+			wantPassword: "hash",
+		},
+		{
+			name: "unconfirmed account linked by the verified email",
+			users: []*domain.User{{
+				ID: "user-1", Email: "jane@example.com", Password: "hash", TwoFactor: twoFactor,
+			}},
+			wantUser: "user-1",
+		},
+		{
+			name:     "unverified email",
+			users:    []*domain.User{{ID: "user-1", Email: "jane@example.com", IsConfirmed: true, TwoFactor: twoFactor}},
+			idClaims: jwt.MapClaims{"email_verified": false},
+			wantErr:  core.ErrOAuthEmailNotVerified,
+		},
+		{
+			name: "email linked to another account of the provider",
+			users: []*domain.User{{
+				ID: "user-1", Email: "jane@example.com", IsConfirmed: true, TwoFactor: twoFactor,
+				Identities: []*domain.UserIdentity{{Provider: testOAuthProvider, Subject: "another subject"}},
+			}},
+			wantErr: core.ErrIdentityAlreadyLinked,
+		},
+		{
+			name:     "invalid id token",
+			idClaims: jwt.MapClaims{"aud": "another-client"},
+			wantErr:  core.ErrOAuthFailed,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			tt := newOAuthTest(t, test.users...)
+
+			for name, value := range test.idClaims {
+				tt.idClaims[name] = value
+			}
+
+			output, err := tt.service.SignIn(context.Background(), tt.authorize(t, ""))
+			if test.wantErr != nil {
+				if err != test.wantErr {
+					t.Fatalf("err = %v, want %v", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if output.MFAChallenge == nil || output.MFAChallenge.UserID != test.wantUser {
+				t.Fatalf("output = %+v, want the MFA challenge of %s", output, test.wantUser)
+			}
+
+			user := tt.users.users[test.wantUser]
+
+			if identity := user.Identity(testOAuthProvider); identity == nil || identity.Subject != "subject" {
+				t.Errorf("identities = %+v, want the provider's account linked", user.Identities)
+			}
+
+			if !user.IsConfirmed || user.Password != test.wantPassword {
+				t.Errorf("confirmed = %t, password = %q, want confirmed with %q", user.IsConfirmed, user.Password, test.wantPassword)
+			}
+		})
+	}
+}
+
+func TestOAuthLinkOrCreateUser(t *testing.T) {
+	tt := newOAuthTest(t)
+
+	user, err := tt.service.linkOrCreateUser(context.Background(), testOAuthProvider, &oauth.Identity{
+		Subject:       "subject",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		FirstName:     "Jane",
+		LastName:      "Doe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tt.users.users[user.ID] != user {
+		t.Fatal("user is not created")
+	}
+
+	if !user.IsConfirmed || user.Password != "" || user.Role != domain.BasicRole || user.PlanID != "plan-"+domain.BasicPlanName {
+		t.Errorf("user = %+v, want a confirmed user without a password on the basic plan", user)
+	}
+
+	if identity := user.Identity(testOAuthProvider); identity == nil || identity.Subject != "subject" {
+		t.Errorf("identities = %+v, want the provider's account linked", user.Identities)
+	}
+}
+
+func TestOAuthLink(t *testing.T) {
+	linked := []*domain.UserIdentity{{Provider: testOAuthProvider, Subject: "subject"}}
+
+	tests := []struct {
+		name    string
+		users   []*domain.User
+		wantErr error
+	}{
+		{
+			name:  "not linked",
+			users: []*domain.User{{ID: "user-1", Password: "hash"}},
+		},
+		{
+			name:    "linked to the user",
+			users:   []*domain.User{{ID: "user-1", Password: "hash", Identities: linked}},
+			wantErr: core.ErrAlreadyExist,
+		},
+		{
+			name: "linked to another user",
+			users: []*domain.User{
+				{ID: "user-1", Password: "hash"},
+				{ID: "user-2", Password: "hash", Identities: linked},
+			},
+			wantErr: core.ErrIdentityAlreadyLinked,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			tt := newOAuthTest(t, test.users...)
+
+			user, err := tt.service.Link(context.Background(), "user-1", tt.authorize(t, "user-1"))
+			if err != test.wantErr {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+
+			if test.wantErr != nil {
+				return
+			}
+
+			identity := user.Identity(testOAuthProvider)
+			if identity == nil || identity.Subject != "subject" || identity.Email != "jane@example.com" {
+				t.Errorf("identity = %+v, want the provider's account", identity)
+			}
+		})
+	}
+}
+
+func TestOAuthUnlink(t *testing.T) {
+	identity := func(provider string) *domain.UserIdentity {
+		return &domain.UserIdentity{Provider: provider, Subject: "subject"}
+	}
+
+	tests := []struct {
+		name       string
+		user       *domain.User
+		wantErr    error
+		wantLinked bool
+	}{
+		{
+			name: "with password",
+			user: &domain.User{ID: "user-1", Password: "hash", Identities: []*domain.UserIdentity{identity(testOAuthProvider)}},
+		},
+		{
+			name: "with another provider",
+			user: &domain.User{ID: "user-1", Identities: []*domain.UserIdentity{
+				identity(testOAuthProvider), identity("another"),
+			}},
+		},
+		{
+			name:       "last sign-in method",
+			user:       &domain.User{ID: "user-1", Identities: []*domain.UserIdentity{identity(testOAuthProvider)}},
+			wantErr:    core.ErrLastSignInMethod,
+			wantLinked: true,
+		},
+		{
+			name:    "not linked",
+			user:    &domain.User{ID: "user-1", Password: "hash", Identities: []*domain.UserIdentity{identity("another")}},
+			wantErr: core.ErrNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			tt := newOAuthTest(t, test.user)
+
+			err := tt.service.Unlink(context.Background(), "user-1", testOAuthProvider)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+
+			if linked := test.user.Identity(testOAuthProvider) != nil; linked != test.wantLinked {
+				t.Errorf("linked = %t, want %t", linked, test.wantLinked)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+)
+
+type OrderService struct {
+	orderRepo      OrderRepository
+	userRepo       UserRepository
+	planRepo       PlanRepository
+	invoiceService *InvoiceService
+}
+
+func NewOrderService(
+	orderRepo OrderRepository,
+	userRepo UserRepository,
+	planRepo PlanRepository,
+	invoiceService *InvoiceService,
+) *OrderService {
+	return &OrderService{
+		orderRepo:      orderRepo,
+		userRepo:       userRepo,
+		planRepo:       planRepo,
+		invoiceService: invoiceService,
+	}
+}
+
+// GetMany returns the orders of the user, the latest first.
+func (s *OrderService) GetMany(ctx context.Context, userID string) ([]*domain.Order, error) {
+	return s.orderRepo.GetByUserID(ctx, userID)
+}
+
+// GetOne returns the order of the user with its invoice, the invoice is nil until the order is paid.
+func (s *OrderService) GetOne(ctx context.Context, userID, orderID string) (*domain.Order, *domain.Invoice, error) {
+	order, err := s.getUserOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invoice, err := s.invoiceService.GetByOrderID(ctx, orderID)
+	if errors.Is(err, core.ErrNotFound) {
+		return order, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, invoice, nil
+}
+
+// GetInvoice returns the invoice of the paid order rendered as PDF, the invoice
+// is issued now if it failed to be issued when the payment was processed.
+func (s *OrderService) GetInvoice(ctx context.Context, userID, orderID string) (*domain.Invoice, []byte, error) {
+	order, err := s.getUserOrder(ctx, userID, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invoice, err := s.invoiceService.GetByOrderID(ctx, orderID)
+	if errors.Is(err, core.ErrNotFound) && order.Paid() {
+		invoice, err = s.issueInvoice(ctx, order)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := s.invoiceService.Render(invoice, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return invoice, data, nil
+}
+
+func (s *OrderService) issueInvoice(ctx context.Context, order *domain.Order) (*domain.Invoice, error) {
+	user, err := s.userRepo.GetUserByID(ctx, order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.planRepo.GetOne(ctx, order.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.invoiceService.Issue(ctx, order, user, plan)
+}
+
+// getUserOrder returns ErrNotFound for the orders of the other users.
+func (s *OrderService) getUserOrder(ctx context.Context, userID, orderID string) (*domain.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.UserID != userID {
+		return nil, core.ErrNotFound
+	}
+
+	return order, nil
+}
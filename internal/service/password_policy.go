@@ -0,0 +1,157 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	core "necutya/faker/internal/domain"
+)
+
+const (
+	defaultPasswordMinLength = 8
+	// bcrypt uses only the first 72 bytes of the password
+	bcryptMaxPasswordLength = 72
+	// parts of the email or the name shorter than this are not looked for in the password
+	minPersonalInfoLength = 3
+)
+
+type BreachedPasswordChecker interface {
+	Contains(password string) bool
+}
+
+// PasswordPolicy configures the requirements for new passwords. MinLength is in characters,
+// MaxLength is in bytes as it is bound by bcrypt.
+type PasswordPolicy struct {
+	MinLength          int
+	MaxLength          int
+	RequireUpper       bool
+	RequireLower       bool
+	RequireDigit       bool
+	RequireSymbol      bool
+	ForbidPersonalInfo bool
+}
+
+func (p PasswordPolicy) withDefaults() PasswordPolicy {
+	if p.MinLength <= 0 {
+		p.MinLength = defaultPasswordMinLength
+	}
+
+	if p.MaxLength <= 0 || p.MaxLength > bcryptMaxPasswordLength {
+		p.MaxLength = bcryptMaxPasswordLength
+	}
+
+	return p
+}
+
+// passwordValidator checks new passwords against the policy and the breached passwords,
+// which are not checked if breached is nil.
+type passwordValidator struct {
+	policy   PasswordPolicy
+	breached BreachedPasswordChecker
+}
+
+func newPasswordValidator(policy PasswordPolicy, breached BreachedPasswordChecker) *passwordValidator {
+	return &passwordValidator{
+		policy:   policy.withDefaults(),
+		breached: breached,
+	}
+}
+
+// validate returns a validation error listing every broken rule under the field name,
+// personalInfo are the email and the name of the user.
+func (v *passwordValidator) validate(field, password string, personalInfo ...string) error {
+	var msgs []string
+
+	if utf8.RuneCountInString(password) < v.policy.MinLength {
+		msgs = append(msgs, fmt.Sprintf("must be at least %d characters long", v.policy.MinLength))
+	}
+
+	if len(password) > v.policy.MaxLength {
+		msgs = append(msgs, fmt.Sprintf("must be at most %d bytes long", v.policy.MaxLength))
+	}
+
+	msgs = append(msgs, v.checkCharacterClasses(password)...)
+
+	if v.policy.ForbidPersonalInfo && containsPersonalInfo(password, personalInfo) {
+		msgs = append(msgs, "must not contain your email or name")
+	}
+
+	if v.breached != nil && v.breached.Contains(password) {
+		msgs = append(msgs, "has appeared in a data breach, choose another one")
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	apiErrors := make([]core.ApiError, len(msgs))
+	for i := range msgs {
+		apiErrors[i] = core.ApiError{
+			Field: field,
+			Msg:   msgs[i],
+		}
+	}
+
+	return &core.ValidationError{Errors: apiErrors}
+}
+
+func (v *passwordValidator) checkCharacterClasses(password string) []string {
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	var msgs []string
+
+	if v.policy.RequireUpper && !hasUpper {
+		msgs = append(msgs, "must contain an uppercase letter")
+	}
+
+	if v.policy.RequireLower && !hasLower {
+		msgs = append(msgs, "must contain a lowercase letter")
+	}
+
+	if v.policy.RequireDigit && !hasDigit {
+		msgs = append(msgs, "must contain a digit")
+	}
+
+	if v.policy.RequireSymbol && !hasSymbol {
+		msgs = append(msgs, "must contain a special character")
+	}
+
+	return msgs
+}
+
+// containsPersonalInfo looks for the email, its local part and the names in the password ignoring the case.
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	password = strings.ToLower(password)
+
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+
+		parts := []string{info}
+		if i := strings.IndexByte(info, '@'); i > 0 {
+			parts = append(parts, info[:i])
+		}
+
+		for _, part := range parts {
+			if len(part) >= minPersonalInfoLength && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
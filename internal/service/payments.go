@@ -2,32 +2,49 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"strings"
 	"time"
 
 	core "necutya/faker/internal/domain"
 	"necutya/faker/internal/domain/domain"
 	"necutya/faker/pkg/logger"
-	"necutya/faker/pkg/payments/fondy"
+	"necutya/faker/pkg/payments"
+)
 
-	"github.com/google/uuid"
+const (
+	// refundRecordAttempts is how many times the refund is added to the order changed concurrently.
+	refundRecordAttempts = 3
+	// pendingRefundTimeout is how long the refund is left to the request which asked the provider for it,
+	// in seconds, after that it is reconciled with the refunded amount the provider reports.
+	pendingRefundTimeout = 10 * 60
 )
 
+// PaymentsManager is the payment provider, the amounts are in cents.
 type PaymentsManager interface {
-	GenerateSubscriptionCheckout(
-		orderID, orderDesc, senderEmail, productID string,
-		amount int64,
-	) (string, error)
-	ValidateCallback(input interface{}) error
+	Name() string
+	Currency() string
+	CreateCheckout(ctx context.Context, req payments.CheckoutRequest) (string, error)
+	ChargeRecurring(ctx context.Context, req payments.RecurringRequest) (*payments.Payment, error)
+	VerifyWebhook(ctx context.Context, header http.Header, body []byte) (*payments.Payment, error)
+	Refund(ctx context.Context, req payments.RefundRequest) (*payments.Refund, error)
+	GetPaymentStatus(ctx context.Context, orderID string) (*payments.Payment, error)
+}
+
+// PaymentSimulator is implemented by the fake provider, it returns the signed webhook
+// reporting the outcome of the checkout.
+type PaymentSimulator interface {
+	Simulate(orderID, outcome string) (http.Header, []byte, error)
 }
 
 type PaymentsService struct {
-	orderRepo OrderRepository
-	userRepo  UserRepository
-	planRepo  PlanRepository
+	orderRepo        OrderRepository
+	userRepo         UserRepository
+	planRepo         PlanRepository
+	subscriptionRepo SubscriptionRepository
+	invoiceService   *InvoiceService
 
 	paymentsManager     PaymentsManager
 	notificationManager NotificationManager
@@ -37,6 +54,8 @@ func NewPaymentsService(
 	orderRepo OrderRepository,
 	userRepo UserRepository,
 	planRepo PlanRepository,
+	subscriptionRepo SubscriptionRepository,
+	invoiceService *InvoiceService,
 	paymentsManager PaymentsManager,
 	notificationManager NotificationManager,
 ) *PaymentsService {
@@ -45,110 +64,601 @@ func NewPaymentsService(
 		orderRepo:           orderRepo,
 		userRepo:            userRepo,
 		planRepo:            planRepo,
+		subscriptionRepo:    subscriptionRepo,
+		invoiceService:      invoiceService,
 		notificationManager: notificationManager,
 	}
 }
 
-func (s *PaymentsService) GetPaymentsCheckout(ctx context.Context) (string, error) {
-	return s.paymentsManager.GenerateSubscriptionCheckout(
-		uuid.New().String(),
-		"test",
-		"art.lebedev2020@gmail.com",
-		uuid.New().String(),
-		1000,
-	)
+// ProcessWebhook verifies the webhook of the provider and processes the payment it reports.
+// A stale event the order can not move to, e.g. a decline delivered after the success, is logged
+// and acknowledged, the provider would only repeat it.
+func (s *PaymentsService) ProcessWebhook(ctx context.Context, header http.Header, body []byte) error {
+	payment, err := s.paymentsManager.VerifyWebhook(ctx, header, body)
+	if errors.Is(err, payments.ErrIgnoredEvent) {
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("%s webhook is rejected: %s", s.paymentsManager.Name(), err.Error())
+		return core.ErrTransactionInvalid
+	}
+
+	err = s.processPayment(ctx, payment)
+	if errors.Is(err, core.ErrIllegalOrderStatus) {
+		logger.Warnf("%s payment %s of order %s is ignored, the order can not move to %s",
+			s.paymentsManager.Name(), payment.PaymentID, payment.OrderID, payment.Status)
+		return nil
+	}
+
+	return err
 }
 
-func (s *PaymentsService) ProcessCallback(ctx context.Context, callback interface{}) error {
-	switch callbackData := callback.(type) {
-	case fondy.Callback:
-		return s.processFondyCallback(ctx, callbackData)
-	default:
-		return core.ErrUnknownCallbackType
+// SimulatePayment finishes the checkout of the order with the outcome, it is available only with the fake provider.
+func (s *PaymentsService) SimulatePayment(ctx context.Context, orderID, outcome string) error {
+	simulator, ok := s.paymentsManager.(PaymentSimulator)
+	if !ok {
+		return core.ErrPaymentSimulationDisabled
 	}
+
+	header, body, err := simulator.Simulate(orderID, outcome)
+	if errors.Is(err, payments.ErrPaymentUnknown) {
+		return core.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.ProcessWebhook(ctx, header, body)
 }
 
-func (s *PaymentsService) processFondyCallback(ctx context.Context, callback fondy.Callback) error {
-	orderID := callback.OrderId
+// processPayment adds the transaction to the order and activates the plan once the order is paid.
+// Providers repeat the webhooks until they are acknowledged, so the repeated ones are acknowledged without processing
+// unless the plan of the paid order failed to be activated, then the activation is repeated.
+func (s *PaymentsService) processPayment(ctx context.Context, payment *payments.Payment) error {
+	order, err := s.orderRepo.GetByID(ctx, payment.OrderID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return core.ErrTransactionInvalid
+		}
+
+		return err
+	}
 
-	log.Println(callback)
+	if err = s.checkPaymentMatchesOrder(order, payment); err != nil {
+		return err
+	}
 
-	if err := s.paymentsManager.ValidateCallback(callback); err != nil {
-		return core.ErrTransactionInvalid
+	if payment.Status == payments.ReversedStatus || payment.RefundedAmount > 0 && order.Paid() {
+		return s.processReversal(ctx, order, payment)
+	}
+
+	transaction := createTransactionFromPayment(payment)
+
+	added, err := s.addTransaction(ctx, order, transaction)
+	if err != nil || transaction.Status != domain.PaidOrderStatus {
+		return err
+	}
+
+	if !added {
+		if order, err = s.orderRepo.GetByID(ctx, order.ID); err != nil {
+			return err
+		}
+
+		if !order.PlanActivationPending || !order.Paid() {
+			return nil
+		}
 	}
 
-	transaction, err := createTransactionFromFondyCallback(&callback)
+	user, err := s.userRepo.GetUserByID(ctx, order.UserID)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.orderRepo.AddTransaction(ctx, orderID, transaction)
+	user.PlanID = order.PlanID
+
+	err = s.userRepo.Update(ctx, user)
 	if err != nil {
 		return err
 	}
 
-	if transaction.Status != domain.PaidOrderStatus {
-		return nil
+	plan, err := s.planRepo.GetOne(ctx, order.PlanID)
+	if err != nil {
+		return err
 	}
 
-	user, err := s.userRepo.GetUserByEmail(ctx, callback.SenderEmail)
+	if err = s.startSubscriptionPeriod(ctx, order, plan, payment.RecurringToken); err != nil {
+		return err
+	}
+
+	if err = s.orderRepo.CompletePlanActivation(ctx, order.ID); err != nil {
+		return err
+	}
+
+	// the invoice can be issued later on download, so the paid order is acknowledged without it
+	invoice, err := s.invoiceService.Issue(ctx, order, user, plan)
+	if err != nil {
+		logger.Errorf("failed to issue invoice of order %s: %s", order.ID, err.Error())
+	}
+
+	if err = s.sendSuccessNotification(order, user, plan, invoice); err != nil {
+		logger.Errorf("failed to send email after purchase: %s", err.Error())
+	}
+
+	return nil
+}
+
+// processReversal records the amount reversed by the provider, the provider reports the total
+// reversed amount so the part already refunded with Refund is not recorded again.
+func (s *PaymentsService) processReversal(ctx context.Context, order *domain.Order, payment *payments.Payment) error {
+	reversed := payment.RefundedAmount
+	if reversed == 0 {
+		// the amount is not reported with every reversal, then it is the whole payment
+		reversed = order.Total()
+	}
+
+	order, err := s.reconcileRefunds(ctx, order, reversed)
 	if err != nil {
 		return err
 	}
 
-	user.PlanID = callback.ProductId
+	// the pending refunds are recorded by their requests
+	refunded := order.RefundedAmount() + order.PendingRefundAmount()
+	if reversed <= refunded {
+		return nil
+	}
+
+	status := domain.PartiallyRefundedOrderStatus
+	if reversed >= order.Total() {
+		status = domain.ReversedOrderStatus
+	}
+
+	transaction := &domain.Transaction{
+		IdempotencyKey: fmt.Sprintf("%s:%s:%s:%d", payment.Provider, payment.PaymentID, payments.ReversedStatus, reversed),
+		PaymentID:      payment.PaymentID,
+		Status:         status,
+		RefundAmount:   reversed - refunded,
+		CreatedAt:      time.Now(),
+		AdditionalInfo: payment.Raw,
+	}
+
+	added, err := s.addTransaction(ctx, order, transaction)
+	if err != nil || !added || status != domain.ReversedOrderStatus {
+		return err
+	}
+
+	return s.revokePlan(ctx, order)
+}
 
-	err = s.userRepo.Update(ctx, user)
+// Refund returns the amount in cents of the paid order to the customer, zero amount refunds
+// all that is left. The plan of the order is revoked once the order is refunded in full.
+//
+// The amount is reserved as a pending refund before the provider is asked, so the concurrent
+// refunds can not return more than was paid. The pending refund is replaced with the transaction
+// once the refund is made, and it is reconciled later if its result failed to be recorded.
+func (s *PaymentsService) Refund(ctx context.Context, orderID string, amount int64, comment string) (*domain.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if order, err = s.reconcileStaleRefunds(ctx, order); err != nil {
+		return nil, err
+	}
+
+	if !order.Paid() {
+		return nil, core.ErrIllegalOrderStatus
+	}
+
+	left := order.RefundableAmount()
+
+	if amount == 0 {
+		amount = left
+	}
+
+	if amount <= 0 || amount > left {
+		return nil, core.ErrInvalidRefundAmount
+	}
+
+	pending := &domain.PendingRefund{
+		Amount:    amount,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	}
+
+	err = s.orderRepo.ReserveRefund(ctx, order.ID, pending, order.Total())
+	if errors.Is(err, core.ErrNotFound) {
+		// refunded concurrently
+		return nil, core.ErrInvalidRefundAmount
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := s.paymentsManager.Refund(ctx, payments.RefundRequest{
+		OrderID:   order.ID,
+		PaymentID: order.PaymentID(),
+		RefundID:  pending.ID,
+		Amount:    amount,
+		Comment:   comment,
+	})
+	if err != nil {
+		// the refund can be made even if its response is lost, so the amount stays reserved until it is reconciled
+		return nil, err
+	}
+
+	// a pending refund is accepted by the provider, it is recorded as it is rarely declined after that
+	if refund.Status == payments.DeclinedStatus {
+		if err = s.orderRepo.ReleaseRefund(ctx, order.ID, pending.ID); err != nil {
+			logger.Errorf("declined refund %s of order %s is not released: %s", pending.ID, orderID, err.Error())
+		}
+
+		return nil, core.ErrRefundDeclined
+	}
+
+	order, err = s.recordRefund(ctx, order, pending, refund.ID)
+	if err != nil {
+		logger.Errorf("refund %s of order %s is made but left pending: %s", refund.ID, orderID, err.Error())
+		return nil, err
+	}
+
+	if order.Status == domain.RefundedOrderStatus {
+		if err = s.revokePlan(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// recordRefund replaces the pending refund made by the provider with its transaction, the order
+// is reloaded and the refund is recorded again if the order was changed concurrently.
+func (s *PaymentsService) recordRefund(
+	ctx context.Context,
+	order *domain.Order,
+	pending *domain.PendingRefund,
+	refundID string,
+) (*domain.Order, error) {
+	key := fmt.Sprintf("%s:%s:refund:%s", s.paymentsManager.Name(), order.PaymentID(), refundID)
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		err = s.completeRefund(ctx, order, pending, key)
+		if !errors.Is(err, core.ErrIllegalOrderStatus) || attempt == refundRecordAttempts {
+			break
+		}
+
+		if order, err = s.orderRepo.GetByID(ctx, order.ID); err != nil {
+			return nil, err
+		}
+
+		if !hasPendingRefund(order, pending.ID) {
+			// reconciled concurrently
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orderRepo.GetByID(ctx, order.ID)
+}
+
+// completeRefund moves the order to the status of the refund and adds its transaction instead
+// of the pending refund, ErrIllegalOrderStatus is returned if the order was changed concurrently.
+func (s *PaymentsService) completeRefund(ctx context.Context, order *domain.Order, pending *domain.PendingRefund, key string) error {
+	status := domain.PartiallyRefundedOrderStatus
+	if order.RefundedAmount()+pending.Amount >= order.Total() {
+		status = domain.RefundedOrderStatus
+	}
+
+	if !order.CanTransitionTo(status) {
+		return core.ErrIllegalOrderStatus
+	}
+
+	err := s.orderRepo.CompleteRefund(ctx, order.ID, order.Status, pending.ID, &domain.Transaction{
+		IdempotencyKey: key,
+		PaymentID:      order.PaymentID(),
+		Status:         status,
+		RefundAmount:   pending.Amount,
+		CreatedAt:      time.Now(),
+		AdditionalInfo: pending.Comment,
+	})
+	switch {
+	case errors.Is(err, core.ErrAlreadyExist):
+		return nil
+	case errors.Is(err, core.ErrNotFound):
+		return core.ErrIllegalOrderStatus
+	}
+
+	return err
+}
+
+// reconcileStaleRefunds asks the provider for the refunded amount if the order has the refunds
+// pending longer than pendingRefundTimeout, their requests failed to get or to record the result.
+func (s *PaymentsService) reconcileStaleRefunds(ctx context.Context, order *domain.Order) (*domain.Order, error) {
+	staleBefore := time.Now().Add(-pendingRefundTimeout * time.Second)
+
+	stale := false
+	for _, pending := range order.PendingRefunds {
+		stale = stale || pending.CreatedAt.Before(staleBefore)
+	}
+
+	if !stale {
+		return order, nil
+	}
+
+	payment, err := s.paymentsManager.GetPaymentStatus(ctx, order.ID)
 	if err != nil {
+		return nil, err
+	}
+
+	return s.reconcileRefunds(ctx, order, payment.RefundedAmount)
+}
+
+// reconcileRefunds settles the stale pending refunds with the amount refunded by the provider,
+// the oldest refunds are taken as made while the amount covers them and the rest are released.
+// The refunds pending within pendingRefundTimeout are left to their requests.
+func (s *PaymentsService) reconcileRefunds(ctx context.Context, order *domain.Order, providerRefunded int64) (*domain.Order, error) {
+	if len(order.PendingRefunds) == 0 {
+		return order, nil
+	}
+
+	staleBefore := time.Now().Add(-pendingRefundTimeout * time.Second)
+	covered := providerRefunded - order.RefundedAmount()
+	reconciled := false
+
+	for _, pending := range order.PendingRefunds {
+		made := covered >= pending.Amount
+		if made {
+			covered -= pending.Amount
+		}
+
+		if !pending.CreatedAt.Before(staleBefore) {
+			continue
+		}
+
+		var err error
+
+		if made {
+			key := fmt.Sprintf("%s:%s:refund:%s", s.paymentsManager.Name(), order.PaymentID(), pending.ID)
+			err = s.completeRefund(ctx, order, pending, key)
+		} else {
+			err = s.orderRepo.ReleaseRefund(ctx, order.ID, pending.ID)
+		}
+
+		if errors.Is(err, core.ErrIllegalOrderStatus) || errors.Is(err, core.ErrNotFound) {
+			// reconciled concurrently, the rest is left to the next time
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		reconciled = true
+
+		if order, err = s.orderRepo.GetByID(ctx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if !reconciled {
+		return order, nil
+	}
+
+	logger.Warnf("pending refunds of order %s are reconciled with %d refunded by the provider", order.ID, providerRefunded)
+
+	if order.Status == domain.RefundedOrderStatus {
+		if err := s.revokePlan(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func hasPendingRefund(order *domain.Order, refundID string) bool {
+	for _, pending := range order.PendingRefunds {
+		if pending.ID == refundID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addTransaction moves the order to the status of the transaction, false is returned
+// if the transaction was already added.
+func (s *PaymentsService) addTransaction(ctx context.Context, order *domain.Order, transaction *domain.Transaction) (bool, error) {
+	if order.HasTransaction(transaction.IdempotencyKey) {
+		return false, nil
+	}
+
+	if !order.CanTransitionTo(transaction.Status) {
+		return false, core.ErrIllegalOrderStatus
+	}
+
+	err := s.orderRepo.AddTransaction(ctx, order.ID, order.Status, transaction)
+	switch {
+	case errors.Is(err, core.ErrAlreadyExist):
+		return false, nil
+	case errors.Is(err, core.ErrNotFound):
+		// the status was changed by a concurrent webhook, the repeated one is checked against the new status
+		return false, core.ErrIllegalOrderStatus
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// revokePlan moves the user back to the basic plan and ends the subscription once the order is refunded in full,
+// nothing is changed if the user has switched to another plan since.
+func (s *PaymentsService) revokePlan(ctx context.Context, order *domain.Order) error {
+	subscription, err := s.subscriptionRepo.GetByUserID(ctx, order.UserID)
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+	case err != nil:
 		return err
+	case subscription.Ongoing() && subscription.PlanID == order.PlanID:
+		subscription.Status = domain.CanceledSubscriptionStatus
+		subscription.UpdatedAt = time.Now()
+
+		if err = s.subscriptionRepo.Save(ctx, subscription); err != nil {
+			return err
+		}
 	}
 
-	plan, err := s.planRepo.GetOne(ctx, callback.ProductId)
+	user, err := s.userRepo.GetUserByID(ctx, order.UserID)
 	if err != nil {
-		log.Println("get plan")
 		return err
 	}
 
-	if err = s.sendSuccessNotification(user, plan); err != nil {
-		logger.Errorf("failed to send email after purchase: %s", err.Error())
+	if user.PlanID != order.PlanID {
+		return nil
+	}
+
+	basicPlan, err := s.planRepo.GetOneByName(ctx, domain.BasicPlanName)
+	if err != nil {
+		return err
+	}
+
+	user.PlanID = basicPlan.ID
+
+	if err = s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if err = sendPlanDeactivatedNotification(s.notificationManager, user); err != nil {
+		logger.Errorf("failed to send email after refund: %s", err.Error())
 	}
 
 	return nil
 }
 
-func (s *PaymentsService) sendSuccessNotification(user *domain.User, plan *domain.Plan) error {
+// checkPaymentMatchesOrder checks that the payment is the one requested at the checkout of the order.
+func (s *PaymentsService) checkPaymentMatchesOrder(order *domain.Order, payment *payments.Payment) error {
+	currency := order.Currency
+	if currency == "" {
+		currency = s.paymentsManager.Currency()
+	}
+
+	if payment.Amount != order.Total() ||
+		!strings.EqualFold(payment.Currency, currency) ||
+		payment.ProductID != order.PlanID {
+		logger.Errorf(
+			"payment of order %s does not match the order: amount %d, currency %s, product %s",
+			order.ID, payment.Amount, payment.Currency, payment.ProductID,
+		)
+
+		return core.ErrTransactionInvalid
+	}
+
+	return nil
+}
+
+// startSubscriptionPeriod subscribes the user to the plan of the paid order,
+// the period of the renewal order starts when the current one ends.
+func (s *PaymentsService) startSubscriptionPeriod(ctx context.Context, order *domain.Order, plan *domain.Plan, rectoken string) error {
+	if plan.Duration == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	subscription, err := s.subscriptionRepo.GetByUserID(ctx, order.UserID)
+	if errors.Is(err, core.ErrNotFound) {
+		subscription, err = &domain.Subscription{UserID: order.UserID, CreatedAt: now}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if subscription.PaidOrderID == order.ID {
+		// started by the activation which failed after that
+		return nil
+	}
+
+	start := now
+
+	if order.SubscriptionID != "" && order.SubscriptionID == subscription.ID {
+		if subscription.Ongoing() && subscription.CurrentPeriodEnd.After(now) {
+			start = subscription.CurrentPeriodEnd
+		}
+	} else {
+		subscription.CancelAtPeriodEnd = false
+		subscription.Rectoken = ""
+	}
+
+	if rectoken != "" {
+		subscription.Rectoken = rectoken
+	}
+
+	subscription.PlanID = order.PlanID
+	subscription.PaidOrderID = order.ID
+	subscription.StartPeriod(start, plan.Duration)
+	subscription.UpdatedAt = now
+
+	return s.subscriptionRepo.Save(ctx, subscription)
+}
+
+// sendSuccessNotification attaches the invoice if the notification manager can send files,
+// otherwise the email has only the link to download it. The invoice is nil if it failed to be issued.
+func (s *PaymentsService) sendSuccessNotification(order *domain.Order, user *domain.User, plan *domain.Plan, invoice *domain.Invoice) error {
+	var invoiceNumber, invoiceURL string
+	if invoice != nil {
+		invoiceNumber, invoiceURL = invoice.Number, s.invoiceService.settings.invoiceURL(invoice.OrderID)
+	}
+
 	orderSuccessTemplate, err := getOrderSuccessTemplate(
 		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
 		plan.Name,
 		plan.EndDateFromNow(),
+		invoiceNumber,
+		invoiceURL,
 	)
 	if err != nil {
 		return err
 	}
 
-	return s.notificationManager.SendEmail([]string{user.Email}, domain.SuccessEmailSubject, orderSuccessTemplate)
-}
+	to := []string{user.Email}
 
-func createTransactionFromFondyCallback(callback *fondy.Callback) (*domain.Transaction, error) {
-	var status string
-	if callback.PaymentApproved() {
-		status = domain.PaidOrderStatus
-	} else {
-		status = domain.OtherOrderStatus
+	attachmentSender, ok := s.notificationManager.(AttachmentSender)
+	if !ok || invoice == nil {
+		return s.notificationManager.SendEmail(to, domain.SuccessEmailSubject, orderSuccessTemplate)
 	}
 
-	if !callback.Success() {
-		status = domain.FailedOrderStatus
+	data, err := s.invoiceService.Render(invoice, order)
+	if err != nil {
+		return err
 	}
 
-	additionalInfo, err := json.Marshal(callback)
-	if err != nil {
-		return nil, err
+	return attachmentSender.SendEmailWithAttachments(to, domain.SuccessEmailSubject, orderSuccessTemplate, []*EmailAttachment{
+		{
+			Name:        invoice.FileName(),
+			ContentType: invoiceContentType,
+			Data:        data,
+		},
+	})
+}
+
+func createTransactionFromPayment(payment *payments.Payment) *domain.Transaction {
+	var status string
+	switch payment.Status {
+	case payments.ApprovedStatus:
+		status = domain.PaidOrderStatus
+	case payments.DeclinedStatus, payments.ExpiredStatus:
+		status = domain.FailedOrderStatus
+	default:
+		status = domain.OtherOrderStatus
 	}
 
 	return &domain.Transaction{
+		IdempotencyKey: fmt.Sprintf("%s:%s:%s", payment.Provider, payment.PaymentID, payment.Status),
+		PaymentID:      payment.PaymentID,
 		Status:         status,
 		CreatedAt:      time.Now(),
-		AdditionalInfo: string(additionalInfo),
-	}, nil
+		AdditionalInfo: payment.Raw,
+	}
 }
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/payments"
+)
+
+// fakeOrderRepo keeps the orders in memory and adds the transactions the way the mongo repository does.
+type fakeOrderRepo struct {
+	OrderRepository
+
+	orders map[string]*domain.Order
+}
+
+func (r *fakeOrderRepo) GetByID(_ context.Context, id string) (*domain.Order, error) {
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, core.ErrNotFound
+	}
+
+	copied := *order
+	copied.Transactions = append([]*domain.Transaction(nil), order.Transactions...)
+
+	return &copied, nil
+}
+
+func (r *fakeOrderRepo) AddTransaction(_ context.Context, id, fromStatus string, transaction *domain.Transaction) error {
+	order, ok := r.orders[id]
+	switch {
+	case !ok:
+		return core.ErrNotFound
+	case order.HasTransaction(transaction.IdempotencyKey):
+		return core.ErrAlreadyExist
+	case order.Status != fromStatus:
+		return core.ErrNotFound
+	}
+
+	order.Status = transaction.Status
+	order.Transactions = append(order.Transactions, transaction)
+
+	if transaction.Status == domain.PaidOrderStatus {
+		order.PlanActivationPending = true
+	}
+
+	return nil
+}
+
+func (r *fakeOrderRepo) CompletePlanActivation(_ context.Context, id string) error {
+	order, ok := r.orders[id]
+	if !ok {
+		return core.ErrNotFound
+	}
+
+	order.PlanActivationPending = false
+
+	return nil
+}
+
+type fakeSubscriptionRepo struct {
+	SubscriptionRepository
+
+	subscriptions map[string]*domain.Subscription
+	saves         int
+}
+
+func (r *fakeSubscriptionRepo) GetByUserID(_ context.Context, userID string) (*domain.Subscription, error) {
+	subscription, ok := r.subscriptions[userID]
+	if !ok {
+		return nil, core.ErrNotFound
+	}
+
+	copied := *subscription
+
+	return &copied, nil
+}
+
+func (r *fakeSubscriptionRepo) Save(_ context.Context, subscription *domain.Subscription) error {
+	if subscription.ID == "" {
+		subscription.ID = "subscription-" + subscription.UserID
+	}
+
+	copied := *subscription
+	r.subscriptions[subscription.UserID] = &copied
+	r.saves++
+
+	return nil
+}
+
+// flakyUserRepo fails the first updates.
+type flakyUserRepo struct {
+	*fakeUserRepo
+
+	failUpdates int
+}
+
+func (r *flakyUserRepo) Update(ctx context.Context, user *domain.User) error {
+	if r.failUpdates > 0 {
+		r.failUpdates--
+		return errors.New("connection reset")
+	}
+
+	updated := *user
+
+	return r.fakeUserRepo.Update(ctx, &updated)
+}
+
+func (r *fakePlanRepo) GetOne(_ context.Context, id string) (*domain.Plan, error) {
+	return &domain.Plan{ID: id, Name: "Pro", Price: 10, Duration: 1}, nil
+}
+
+// unavailableInvoiceRepo fails every call, the paid order is processed without the invoice.
+type unavailableInvoiceRepo struct {
+	InvoiceRepository
+}
+
+func (unavailableInvoiceRepo) GetByOrderID(context.Context, string) (*domain.Invoice, error) {
+	return nil, errors.New("invoices are unavailable")
+}
+
+type fakeNotificationManager struct{}
+
+func (fakeNotificationManager) SendEmail([]string, string, string) error {
+	return nil
+}
+
+// webhookPaymentsManager delivers the same payment with every webhook.
+type webhookPaymentsManager struct {
+	PaymentsManager
+
+	payment payments.Payment
+}
+
+func (m *webhookPaymentsManager) Name() string {
+	return "stub"
+}
+
+func (m *webhookPaymentsManager) Currency() string {
+	return "USD"
+}
+
+func (m *webhookPaymentsManager) VerifyWebhook(context.Context, http.Header, []byte) (*payments.Payment, error) {
+	payment := m.payment
+
+	return &payment, nil
+}
+
+func TestProcessWebhookRepeatsFailedActivation(t *testing.T) {
+	const (
+		userID  = "user-1"
+		orderID = "order-1"
+		planID  = "plan-pro"
+	)
+
+	users := &flakyUserRepo{
+		fakeUserRepo: &fakeUserRepo{users: map[string]*domain.User{
+			userID: {ID: userID, Email: "jane@example.com", PlanID: "plan-basic"},
+		}},
+		failUpdates: 1,
+	}
+	orders := &fakeOrderRepo{orders: map[string]*domain.Order{
+		orderID: {ID: orderID, UserID: userID, PlanID: planID, Amount: 10, Currency: "USD", Status: domain.NewOrderStatus},
+	}}
+	subscriptions := &fakeSubscriptionRepo{subscriptions: map[string]*domain.Subscription{}}
+
+	s := NewPaymentsService(
+		orders,
+		users,
+		&fakePlanRepo{},
+		subscriptions,
+		NewInvoiceService(unavailableInvoiceRepo{}, InvoiceSettings{}, "USD"),
+		&webhookPaymentsManager{payment: payments.Payment{
+			Provider:  "stub",
+			PaymentID: "payment-1",
+			OrderID:   orderID,
+			ProductID: planID,
+			Status:    payments.ApprovedStatus,
+			Amount:    1000,
+			Currency:  "USD",
+		}},
+		fakeNotificationManager{},
+	)
+
+	ctx := context.Background()
+
+	if err := s.ProcessWebhook(ctx, nil, nil); err == nil {
+		t.Fatal("the webhook is acknowledged though the plan failed to be activated")
+	}
+
+	if !orders.orders[orderID].PlanActivationPending {
+		t.Fatal("the paid order has no pending plan activation")
+	}
+
+	// the provider repeats the webhook which was not acknowledged
+	if err := s.ProcessWebhook(ctx, nil, nil); err != nil {
+		t.Fatalf("repeated webhook: err = %v, want nil", err)
+	}
+
+	if got := users.users[userID].PlanID; got != planID {
+		t.Errorf("plan = %s, want %s", got, planID)
+	}
+
+	if orders.orders[orderID].PlanActivationPending {
+		t.Error("the plan activation is still pending")
+	}
+
+	subscription := subscriptions.subscriptions[userID]
+	if subscription == nil || subscription.Status != domain.ActiveSubscriptionStatus {
+		t.Fatalf("subscription = %+v, want an active one", subscription)
+	}
+
+	periodEnd := subscription.CurrentPeriodEnd
+
+	// the activated order is acknowledged as is
+	if err := s.ProcessWebhook(ctx, nil, nil); err != nil {
+		t.Fatalf("webhook after the activation: err = %v, want nil", err)
+	}
+
+	if got := subscriptions.subscriptions[userID].CurrentPeriodEnd; !got.Equal(periodEnd) {
+		t.Errorf("period end = %s after a repeated webhook, want %s", got, periodEnd)
+	}
+
+	if subscriptions.saves != 1 {
+		t.Errorf("subscription saved %d times, want 1", subscriptions.saves)
+	}
+}
@@ -10,6 +10,7 @@ type PlanRepository interface {
 	GetMany(ctx context.Context) ([]*domain.Plan, error)
 	GetOneByName(ctx context.Context, name string) (*domain.Plan, error)
 	GetOne(ctx context.Context, id string) (*domain.Plan, error)
+	Update(ctx context.Context, plan *domain.Plan) error
 }
 
 type PlanService struct {
@@ -32,6 +33,44 @@ func (s *PlanService) GetOne(ctx context.Context, planID string) (*domain.Plan,
 	return s.planRepo.GetOne(ctx, planID)
 }
 
+type PlanUpdateInput struct {
+	Description           string
+	Options               []string
+	Price                 int
+	Duration              int
+	InternalRequestsCount int
+	ExternalRequestsCount int
+	MaxFileSize           int
+	MaxDocumentLength     int
+	CountCachedRequests   bool
+	SupportedLanguages    []string
+}
+
+// Update changes the terms of the plan, the name is kept as the basic plan is found by it.
+func (s *PlanService) Update(ctx context.Context, planID string, input PlanUpdateInput) (*domain.Plan, error) {
+	plan, err := s.planRepo.GetOne(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Description = input.Description
+	plan.Options = input.Options
+	plan.Price = input.Price
+	plan.Duration = input.Duration
+	plan.InternalRequestsCount = input.InternalRequestsCount
+	plan.ExternalRequestsCount = input.ExternalRequestsCount
+	plan.MaxFileSize = input.MaxFileSize
+	plan.MaxDocumentLength = input.MaxDocumentLength
+	plan.CountCachedRequests = input.CountCachedRequests
+	plan.SupportedLanguages = input.SupportedLanguages
+
+	if err = s.planRepo.Update(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
 func createPlanMap(plans []*domain.Plan) map[string]*domain.Plan {
 	plansMap := make(map[string]*domain.Plan, len(plans))
 
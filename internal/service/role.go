@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+)
+
+type RoleRepository interface {
+	Get(ctx context.Context, role domain.Role) (*domain.RolePermissions, error)
+	GetMany(ctx context.Context) ([]*domain.RolePermissions, error)
+	Update(ctx context.Context, permissions *domain.RolePermissions) error
+}
+
+type RoleService struct {
+	roleRepo    RoleRepository
+	userRepo    UserRepository
+	authService *AuthService
+}
+
+func NewRoleService(roleRepo RoleRepository, userRepo UserRepository, authService *AuthService) *RoleService {
+	return &RoleService{
+		roleRepo:    roleRepo,
+		userRepo:    userRepo,
+		authService: authService,
+	}
+}
+
+// GetMany returns the permissions of every role, the default ones for roles which have not been edited.
+func (s *RoleService) GetMany(ctx context.Context) ([]*domain.RolePermissions, error) {
+	edited, err := s.roleRepo.GetMany(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	editedMap := make(map[domain.Role]*domain.RolePermissions, len(edited))
+	for i := range edited {
+		editedMap[edited[i].Role] = edited[i]
+	}
+
+	roles := make([]*domain.RolePermissions, len(domain.Roles))
+
+	for i, role := range domain.Roles {
+		if rolePermissions, ok := editedMap[role]; ok && role.IsEditable() {
+			roles[i] = rolePermissions
+			continue
+		}
+
+		roles[i] = &domain.RolePermissions{
+			Role:        role,
+			Permissions: domain.DefaultRolePermissions[role],
+		}
+	}
+
+	return roles, nil
+}
+
+// Update replaces the permissions of the role, signed in users get them once their access tokens are refreshed.
+func (s *RoleService) Update(ctx context.Context, role domain.Role, permissions []domain.Permission) (*domain.RolePermissions, error) {
+	if !role.IsEditable() {
+		return nil, core.ErrRoleNotEditable
+	}
+
+	for i := range permissions {
+		if permissions[i] == domain.RolesWritePermission {
+			return nil, core.ErrPermissionNotGrantable
+		}
+	}
+
+	rolePermissions := &domain.RolePermissions{
+		Role:        role,
+		Permissions: permissions,
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := s.roleRepo.Update(ctx, rolePermissions); err != nil {
+		return nil, err
+	}
+
+	return rolePermissions, nil
+}
+
+// SetUserRole changes the role of the user and signs the user out everywhere,
+// so the permissions of the previous role stop working at once.
+func (s *RoleService) SetUserRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error) {
+	if !role.IsValid() {
+		return nil, core.ErrUnknownRole
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role == role {
+		return user, nil
+	}
+
+	user.Role = role
+	user.UpdatedAt = time.Now()
+
+	if err = s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err = s.authService.revokeAllSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// getRolePermissions returns the stored permissions of the role, the super-admin has all of them.
+func getRolePermissions(ctx context.Context, roleRepo RoleRepository, role domain.Role) ([]domain.Permission, error) {
+	if role == domain.AdminRole {
+		return domain.Permissions, nil
+	}
+
+	if !role.IsValid() {
+		return nil, nil
+	}
+
+	rolePermissions, err := roleRepo.Get(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return rolePermissions.Permissions, nil
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sync"
+	"unicode"
+
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/ensemble"
+)
+
+const (
+	NoSegmentation        = ""
+	SentenceSegmentation  = "sentence"
+	ParagraphSegmentation = "paragraph"
+
+	// minSegmentLength is a minimal number of characters in a segment, shorter ones are merged with neighbours.
+	minSegmentLength = 20
+)
+
+// splitMessage splits text into sentences or paragraphs with UTF-16 offsets,
+// the unit the JavaScript strings of the clients are indexed in.
+func splitMessage(text, mode string) []*domain.MessageSegment {
+	runes := []rune(text)
+
+	var bounds [][2]int
+
+	switch mode {
+	case SentenceSegmentation:
+		bounds = splitSentences(runes)
+	case ParagraphSegmentation:
+		bounds = splitParagraphs(runes)
+	default:
+		bounds = [][2]int{{0, len(runes)}}
+	}
+
+	bounds = mergeShortSegments(trimSegments(runes, bounds))
+	offsets := utf16Offsets(runes)
+
+	segments := make([]*domain.MessageSegment, len(bounds))
+	for i := range bounds {
+		segments[i] = &domain.MessageSegment{
+			Start: offsets[bounds[i][0]],
+			End:   offsets[bounds[i][1]],
+			Text:  string(runes[bounds[i][0]:bounds[i][1]]),
+		}
+	}
+
+	return segments
+}
+
+// utf16Offsets maps rune indices to UTF-16 code unit offsets, the runes outside
+// the Basic Multilingual Plane take two code units.
+func utf16Offsets(runes []rune) []int {
+	offsets := make([]int, len(runes)+1)
+
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + 1
+		if r >= 0x10000 {
+			offsets[i+1]++
+		}
+	}
+
+	return offsets
+}
+
+func splitSentences(runes []rune) [][2]int {
+	var (
+		bounds [][2]int
+		start  int
+	)
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\n' && i+1 < len(runes) && runes[i+1] == '\n' {
+			bounds = append(bounds, [2]int{start, i})
+			start = i + 1
+			continue
+		}
+
+		if !isSentenceTerminator(runes[i]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && (isSentenceTerminator(runes[end]) || isClosingPunctuation(runes[end])) {
+			end++
+		}
+
+		if end < len(runes) && !unicode.IsSpace(runes[end]) {
+			i = end - 1
+			continue
+		}
+
+		bounds = append(bounds, [2]int{start, end})
+		start = end
+		i = end - 1
+	}
+
+	return append(bounds, [2]int{start, len(runes)})
+}
+
+func splitParagraphs(runes []rune) [][2]int {
+	var (
+		bounds  [][2]int
+		start   int
+		newline = -1
+	)
+
+	for i, r := range runes {
+		switch {
+		case r == '\n' && newline >= 0:
+			bounds = append(bounds, [2]int{start, newline})
+			start = i + 1
+			newline = -1
+		case r == '\n':
+			newline = i
+		case !unicode.IsSpace(r):
+			newline = -1
+		}
+	}
+
+	return append(bounds, [2]int{start, len(runes)})
+}
+
+func trimSegments(runes []rune, bounds [][2]int) [][2]int {
+	trimmed := make([][2]int, 0, len(bounds))
+
+	for _, b := range bounds {
+		start, end := b[0], b[1]
+
+		for start < end && unicode.IsSpace(runes[start]) {
+			start++
+		}
+
+		for end > start && unicode.IsSpace(runes[end-1]) {
+			end--
+		}
+
+		if start < end {
+			trimmed = append(trimmed, [2]int{start, end})
+		}
+	}
+
+	return trimmed
+}
+
+// mergeShortSegments joins segments shorter than minSegmentLength with the following one, the last one with the previous.
+func mergeShortSegments(bounds [][2]int) [][2]int {
+	merged := make([][2]int, 0, len(bounds))
+
+	for i := 0; i < len(bounds); i++ {
+		current := bounds[i]
+
+		for current[1]-current[0] < minSegmentLength && i+1 < len(bounds) {
+			i++
+			current[1] = bounds[i][1]
+		}
+
+		if current[1]-current[0] < minSegmentLength && len(merged) > 0 {
+			merged[len(merged)-1][1] = current[1]
+			continue
+		}
+
+		merged = append(merged, current)
+	}
+
+	return merged
+}
+
+func isSentenceTerminator(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isClosingPunctuation(r rune) bool {
+	return r == '"' || r == '\'' || r == ')' || r == ']' || r == '»' || r == '”' || r == '’'
+}
+
+// scoreSegments checks every segment through the AI manager within the limiter of the request
+// and returns scores of every detector aggregated over the segments, and whether some detector failed.
+func (s *AIService) scoreSegments(
+	ctx context.Context,
+	aiManager AIManager,
+	limiter aiCallLimiter,
+	segments []*domain.MessageSegment,
+) ([]*domain.DetectorScore, bool, error) {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		results  = make([]*ensemble.Result, len(segments))
+	)
+
+	for i := range segments {
+		wg.Add(1)
+		limiter <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+
+			result, err := aiManager.CheckMessage(ctx, segments[i].Text)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+				})
+
+				return
+			}
+
+			segments[i].IsGenerated, segments[i].GeneratedPercent = result.IsGenerated, result.GeneratedPercent
+			results[i] = result
+		}(i)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+
+	return aggregateDetectorScores(segments, results), isDegraded(results...), nil
+}
+
+// aggregateDetectorScores aggregates the verdicts of every detector over the segments the same way
+// as the segments themselves are aggregated. A detector is reported as failed only if it failed on all segments.
+func aggregateDetectorScores(segments []*domain.MessageSegment, results []*ensemble.Result) []*domain.DetectorScore {
+	var (
+		names    []string
+		scored   = make(map[string][]*domain.MessageSegment)
+		failures = make(map[string]string)
+	)
+
+	for i, result := range results {
+		for _, score := range result.Scores {
+			if _, ok := scored[score.Detector]; !ok {
+				names = append(names, score.Detector)
+				scored[score.Detector] = nil
+			}
+
+			if score.Err != nil {
+				if _, ok := failures[score.Detector]; !ok {
+					failures[score.Detector] = detectorErrorCode(score)
+				}
+
+				continue
+			}
+
+			scored[score.Detector] = append(scored[score.Detector], &domain.MessageSegment{
+				Start:            segments[i].Start,
+				End:              segments[i].End,
+				IsGenerated:      score.IsGenerated,
+				GeneratedPercent: score.GeneratedPercent,
+			})
+		}
+	}
+
+	scores := make([]*domain.DetectorScore, len(names))
+	for i, name := range names {
+		scores[i] = &domain.DetectorScore{
+			Detector: name,
+		}
+
+		if len(scored[name]) == 0 {
+			scores[i].Error = failures[name]
+			continue
+		}
+
+		scores[i].IsGenerated, scores[i].GeneratedPercent = aggregateSegments(scored[name])
+	}
+
+	return scores
+}
+
+// aggregateSegments returns the overall percent as a mean of segment percents weighted by their length,
+// the text is treated as generated when generated segments cover at least half of it.
+func aggregateSegments(segments []*domain.MessageSegment) (bool, float64) {
+	var (
+		weighted  float64
+		total     int
+		generated int
+	)
+
+	for _, segment := range segments {
+		length := segment.End - segment.Start
+		weighted += segment.GeneratedPercent * float64(length)
+		total += length
+
+		if segment.IsGenerated {
+			generated += length
+		}
+	}
+
+	if total == 0 {
+		return false, 0
+	}
+
+	return generated*2 >= total, math.Round(weighted/float64(total)*100) / 100
+}
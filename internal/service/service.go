@@ -1,13 +1,22 @@
 package service
 
 type Service struct {
-	User     *UserService
-	AI       *AIService
-	Auth     *AuthService
-	Admin    *AdminService
-	Payments *PaymentsService
-	Plan     *PlanService
-	Feedback *FeedbackService
+	User         *UserService
+	AI           *AIService
+	Auth         *AuthService
+	Admin        *AdminService
+	Payments     *PaymentsService
+	Plan         *PlanService
+	Feedback     *FeedbackService
+	History      *HistoryService
+	CheckJob     *CheckJobService
+	APIKey       *APIKeyService
+	TwoFactor    *TwoFactorService
+	OAuth        *OAuthService
+	SigningKey   *SigningKeyService
+	Role         *RoleService
+	Subscription *SubscriptionService
+	Order        *OrderService
 }
 
 func New(
@@ -19,31 +28,96 @@ func New(
 	blackListRepo BlacklistRepository,
 	requestCounterRepo RequestCounterRepository,
 	verificationRepo VerificationRepository,
+	checkJobRepo CheckJobRepository,
+	checkCacheRepo CheckCacheRepository,
+	apiKeyRepo APIKeyRepository,
+	mfaChallengeRepo MFAChallengeRepository,
+	settingsRepo SettingsRepository,
+	attemptRepo AttemptRepository,
+	oauthStateRepo OAuthStateRepository,
+	signingKeyRepo SigningKeyRepository,
+	magicLinkRepo MagicLinkRepository,
+	roleRepo RoleRepository,
+	subscriptionRepo SubscriptionRepository,
+	invoiceRepo InvoiceRepository,
 
 	hasher Hasher,
 	tokenManager TokenManager,
+	keyRing KeyRing,
+	keyCipher KeyCipher,
 	aiManager AIManager,
+	languageAIManagers map[string]AIManager,
 	notificationManager NotificationManager,
 	codeManager CodeManager,
 	paymentsManager PaymentsManager,
+	documentParser DocumentParser,
+	languageDetector LanguageDetector,
+	webhookSender WebhookSender,
+	oauthProviders map[string]OAuthProvider,
+	breachedPasswords BreachedPasswordChecker,
 
 	accessTokenTTL int,
 	refreshTokenTTL int,
 	verificationCodeTTL int,
+	checkJobTTL int,
+	mfaChallengeTTL int,
+	attemptLimits AttemptLimits,
+	emailChange EmailChangeSettings,
+	magicLink MagicLinkSettings,
+	passwordPolicy PasswordPolicy,
+	subscription SubscriptionSettings,
+	invoice InvoiceSettings,
+	oauthStateTTL int,
+	keysReloadInterval int,
 
 	feedbackReceiver string,
+	twoFactorIssuer string,
+	aiBatchConcurrency int,
+	aiCacheTTL int,
+	aiCacheCaseInsensitive bool,
 ) *Service {
+	aiService := NewAIService(
+		messageRepo, requestCounterRepo, aiManager, planRepo, userRepo, documentParser, checkCacheRepo,
+		languageDetector, languageAIManagers, aiBatchConcurrency, aiCacheTTL, aiCacheCaseInsensitive,
+	)
+
+	authService := NewAuthService(
+		userRepo, blackListRepo, requestCounterRepo, verificationRepo, planRepo, mfaChallengeRepo, settingsRepo, attemptRepo, magicLinkRepo, roleRepo,
+		hasher, tokenManager, notificationManager, codeManager, breachedPasswords,
+		accessTokenTTL, refreshTokenTTL, verificationCodeTTL, mfaChallengeTTL, attemptLimits, emailChange, magicLink, passwordPolicy,
+	)
+
+	userService := NewUserService(
+		userRepo, planRepo, orderRepo, subscriptionRepo, requestCounterRepo, apiKeyRepo, hasher, notificationManager, paymentsManager, codeManager,
+		breachedPasswords, passwordPolicy,
+	)
+
+	invoiceService := NewInvoiceService(invoiceRepo, invoice, paymentsManager.Currency())
+
+	paymentsService := NewPaymentsService(
+		orderRepo, userRepo, planRepo, subscriptionRepo, invoiceService, paymentsManager, notificationManager,
+	)
+
+	subscriptionService := NewSubscriptionService(
+		subscriptionRepo, orderRepo, userRepo, planRepo, paymentsManager, paymentsService, notificationManager, subscription,
+	)
+
 	return &Service{
-		Auth: NewAuthService(
-			userRepo, blackListRepo, requestCounterRepo, verificationRepo, planRepo,
-			hasher, tokenManager, notificationManager, codeManager,
-			accessTokenTTL, refreshTokenTTL, verificationCodeTTL,
-		),
-		User:     NewUserService(userRepo, planRepo, orderRepo, requestCounterRepo, hasher, notificationManager, paymentsManager, codeManager),
-		AI:       NewAIService(messageRepo, requestCounterRepo, aiManager, planRepo, userRepo),
-		Admin:    NewAdminService(userRepo, planRepo),
-		Payments: NewPaymentsService(orderRepo, userRepo, planRepo, paymentsManager, notificationManager),
-		Plan:     NewPlanService(planRepo),
-		Feedback: NewFeedbackService(feedbackRepo, notificationManager, feedbackReceiver),
+		Auth:         authService,
+		User:         userService,
+		AI:           aiService,
+		Admin:        NewAdminService(userRepo, planRepo, settingsRepo),
+		Payments:     paymentsService,
+		Plan:         NewPlanService(planRepo),
+		Feedback:     NewFeedbackService(feedbackRepo, notificationManager, feedbackReceiver),
+		History:      NewHistoryService(messageRepo),
+		CheckJob:     NewCheckJobService(checkJobRepo, userRepo, aiService, webhookSender, checkJobTTL),
+		APIKey:       NewAPIKeyService(apiKeyRepo, userRepo, codeManager),
+		TwoFactor:    NewTwoFactorService(userRepo, settingsRepo, hasher, codeManager, authService, twoFactorIssuer),
+		OAuth:        NewOAuthService(userRepo, planRepo, oauthStateRepo, authService, codeManager, oauthProviders, oauthStateTTL),
+		SigningKey:   NewSigningKeyService(signingKeyRepo, keyRing, keyCipher, accessTokenTTL, keysReloadInterval),
+		Role:         NewRoleService(roleRepo, userRepo, authService),
+		Subscription: subscriptionService,
+		Order:        NewOrderService(orderRepo, userRepo, planRepo, invoiceService),
 	}
 }
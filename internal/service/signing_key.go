@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+	tokenManager "necutya/faker/pkg/token_managers"
+
+	"github.com/google/uuid"
+)
+
+const defaultKeysReloadInterval = 60
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *domain.SigningKey) error
+	CreateIfNotExists(ctx context.Context, key *domain.SigningKey) (*domain.SigningKey, error)
+	GetMany(ctx context.Context, now time.Time) ([]*domain.SigningKey, error)
+	Retire(ctx context.Context, expiresAt time.Time) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type KeyRing interface {
+	Algorithm() string
+	SetKeys(active *tokenManager.Key, keys []*tokenManager.Key)
+	JWKS() ([]tokenManager.JSONWebKey, error)
+}
+
+// KeyCipher encrypts the private keys, they are not stored in plaintext.
+type KeyCipher interface {
+	Encrypt(privateKeyPEM string) (string, error)
+	Decrypt(encrypted string) (string, error)
+}
+
+// SigningKeyService keeps the key ring of the token manager in sync with the stored keys.
+// Every instance reloads the keys each reloadInterval seconds, so a rotated key is published
+// for a whole interval before it signs tokens and all instances can verify them by then.
+type SigningKeyService struct {
+	signingKeyRepo SigningKeyRepository
+	keyRing        KeyRing
+	keyCipher      KeyCipher
+
+	accessTokenTTL int
+	reloadInterval int
+}
+
+func NewSigningKeyService(
+	signingKeyRepo SigningKeyRepository,
+	keyRing KeyRing,
+	keyCipher KeyCipher,
+	accessTokenTTL int,
+	reloadInterval int,
+) *SigningKeyService {
+	if reloadInterval <= 0 {
+		reloadInterval = defaultKeysReloadInterval
+	}
+
+	return &SigningKeyService{
+		signingKeyRepo: signingKeyRepo,
+		keyRing:        keyRing,
+		keyCipher:      keyCipher,
+		accessTokenTTL: accessTokenTTL,
+		reloadInterval: reloadInterval,
+	}
+}
+
+// Run reloads the keys until ctx is done.
+func (s *SigningKeyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.reloadInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Load(ctx); err != nil {
+				logger.Errorf("reload signing keys: %s", err)
+			}
+		}
+	}
+}
+
+// Load drops expired keys and passes the rest to the token manager. The first key is created
+// and activated right away when there is no key of the configured algorithm, its ID is fixed
+// so the instances starting at once create the same key.
+func (s *SigningKeyService) Load(ctx context.Context) error {
+	algorithm := s.keyRing.Algorithm()
+	if !tokenManager.IsAsymmetric(algorithm) {
+		return nil
+	}
+
+	now := time.Now()
+
+	if err := s.signingKeyRepo.DeleteExpired(ctx, now); err != nil {
+		return err
+	}
+
+	keys, err := s.signingKeyRepo.GetMany(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var active *domain.SigningKey
+
+	// keys are sorted by the activation, so the newest active one wins
+	for i := range keys {
+		if keys[i].Algorithm == algorithm && keys[i].IsActive(now) {
+			active = keys[i]
+		}
+	}
+
+	if active == nil {
+		if active, err = s.createFirstKey(ctx, algorithm, now); err != nil {
+			return err
+		}
+
+		keys = append(keys, active)
+	}
+
+	var (
+		activeKey *tokenManager.Key
+		keyRing   = make([]*tokenManager.Key, 0, len(keys))
+	)
+
+	for i := range keys {
+		key, err := s.parseKey(keys[i])
+		if err != nil {
+			logger.Errorf("signing key %s: %s", keys[i].ID, err)
+			continue
+		}
+
+		if keys[i] == active {
+			activeKey = key
+		}
+
+		keyRing = append(keyRing, key)
+	}
+
+	if activeKey == nil {
+		return tokenManager.ErrNoSigningKey
+	}
+
+	s.keyRing.SetKeys(activeKey, keyRing)
+
+	return nil
+}
+
+// Rotate creates a new key which starts signing after the reload interval. An instance picks the new key
+// up to a reload interval later and signs with the current keys until then, so they expire once
+// the tokens signed by that time expire and no session is interrupted.
+func (s *SigningKeyService) Rotate(ctx context.Context) (*domain.SigningKey, error) {
+	algorithm := s.keyRing.Algorithm()
+	if !tokenManager.IsAsymmetric(algorithm) {
+		return nil, core.ErrKeyRotationUnsupported
+	}
+
+	activatesAt := time.Now().Add(time.Duration(s.reloadInterval) * time.Second)
+
+	retiresAt := activatesAt.Add(time.Duration(s.reloadInterval+s.accessTokenTTL) * time.Second)
+
+	err := s.signingKeyRepo.Retire(ctx, retiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.newKey(uuid.New().String(), algorithm, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.signingKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	if err = s.Load(ctx); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *SigningKeyService) GetMany(ctx context.Context) ([]*domain.SigningKey, error) {
+	return s.signingKeyRepo.GetMany(ctx, time.Now())
+}
+
+// JWKS returns the public keys the tokens can be verified with.
+func (s *SigningKeyService) JWKS() ([]tokenManager.JSONWebKey, error) {
+	return s.keyRing.JWKS()
+}
+
+// createFirstKey stores the first key of the algorithm unless another instance has stored it already,
+// the stored key is returned either way. The key can not be left from an earlier use of the algorithm,
+// such a key is either still active or expired and deleted.
+func (s *SigningKeyService) createFirstKey(ctx context.Context, algorithm string, activatesAt time.Time) (*domain.SigningKey, error) {
+	key, err := s.newKey("first-"+strings.ToLower(algorithm), algorithm, activatesAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signingKeyRepo.CreateIfNotExists(ctx, key)
+}
+
+// newKey generates the key with its private key encrypted.
+func (s *SigningKeyService) newKey(id, algorithm string, activatesAt time.Time) (*domain.SigningKey, error) {
+	key, err := tokenManager.GenerateKey(id, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := tokenManager.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.keyCipher.Encrypt(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SigningKey{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  encrypted,
+		CreatedAt:   time.Now(),
+		ActivatesAt: activatesAt,
+	}, nil
+}
+
+// parseKey decrypts the private key.
+func (s *SigningKeyService) parseKey(signingKey *domain.SigningKey) (*tokenManager.Key, error) {
+	privateKey, err := s.keyCipher.Decrypt(signingKey.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenManager.ParseKey(signingKey.ID, signingKey.Algorithm, privateKey)
+}
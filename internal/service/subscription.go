@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/logger"
+	"necutya/faker/pkg/payments"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRenewBefore        = 24 * 60 * 60
+	defaultRenewRetryInterval = 24 * 60 * 60
+	defaultMaxRenewalAttempts = 3
+
+	// renewalClaimTTL is how long the claimed subscription is not renewed by the other instances, in seconds.
+	renewalClaimTTL = 10 * 60
+)
+
+type SubscriptionRepository interface {
+	GetByUserID(ctx context.Context, userID string) (*domain.Subscription, error)
+	GetDue(ctx context.Context, before time.Time) ([]*domain.Subscription, error)
+	Save(ctx context.Context, subscription *domain.Subscription) error
+	ClaimRenewal(ctx context.Context, subscription *domain.Subscription, until time.Time) error
+	SetRenewalOrder(ctx context.Context, id, orderID string, now time.Time) error
+	FailRenewal(ctx context.Context, id string, nextRenewalAt, now time.Time) error
+}
+
+// SubscriptionSettings configures the renewal, RenewBefore is how long before the end of the period
+// the card is charged and RetryInterval is the pause between failed charges, both are in seconds.
+type SubscriptionSettings struct {
+	RenewBefore        int
+	RetryInterval      int
+	MaxRenewalAttempts int
+}
+
+func (s SubscriptionSettings) withDefaults() SubscriptionSettings {
+	if s.RenewBefore <= 0 {
+		s.RenewBefore = defaultRenewBefore
+	}
+
+	if s.RetryInterval <= 0 {
+		s.RetryInterval = defaultRenewRetryInterval
+	}
+
+	if s.MaxRenewalAttempts <= 0 {
+		s.MaxRenewalAttempts = defaultMaxRenewalAttempts
+	}
+
+	return s
+}
+
+type SubscriptionService struct {
+	subscriptionRepo SubscriptionRepository
+	orderRepo        OrderRepository
+	userRepo         UserRepository
+	planRepo         PlanRepository
+
+	paymentsManager     PaymentsManager
+	paymentsService     *PaymentsService
+	notificationManager NotificationManager
+
+	settings SubscriptionSettings
+}
+
+func NewSubscriptionService(
+	subscriptionRepo SubscriptionRepository,
+	orderRepo OrderRepository,
+	userRepo UserRepository,
+	planRepo PlanRepository,
+	paymentsManager PaymentsManager,
+	paymentsService *PaymentsService,
+	notificationManager NotificationManager,
+	settings SubscriptionSettings,
+) *SubscriptionService {
+	return &SubscriptionService{
+		subscriptionRepo:    subscriptionRepo,
+		orderRepo:           orderRepo,
+		userRepo:            userRepo,
+		planRepo:            planRepo,
+		paymentsManager:     paymentsManager,
+		paymentsService:     paymentsService,
+		notificationManager: notificationManager,
+		settings:            settings.withDefaults(),
+	}
+}
+
+func (s *SubscriptionService) Get(ctx context.Context, userID string) (*domain.Subscription, error) {
+	return s.subscriptionRepo.GetByUserID(ctx, userID)
+}
+
+// Cancel stops the renewal, the plan stays active until the end of the current period.
+func (s *SubscriptionService) Cancel(ctx context.Context, userID string) (*domain.Subscription, error) {
+	return s.setCancelAtPeriodEnd(ctx, userID, true)
+}
+
+// Resume renews the canceled subscription again if its period has not ended yet.
+func (s *SubscriptionService) Resume(ctx context.Context, userID string) (*domain.Subscription, error) {
+	return s.setCancelAtPeriodEnd(ctx, userID, false)
+}
+
+func (s *SubscriptionService) setCancelAtPeriodEnd(ctx context.Context, userID string, cancel bool) (*domain.Subscription, error) {
+	subscription, err := s.subscriptionRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !subscription.Ongoing() || !time.Now().Before(subscription.CurrentPeriodEnd) {
+		return nil, core.ErrSubscriptionNotActive
+	}
+
+	if subscription.CancelAtPeriodEnd == cancel {
+		return subscription, nil
+	}
+
+	subscription.CancelAtPeriodEnd = cancel
+	subscription.UpdatedAt = time.Now()
+
+	if err = s.subscriptionRepo.Save(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// RenewSubscriptions charges the subscriptions which period is about to end
+// and ends the canceled ones and the ones which renewal has failed once their period is over.
+func (s *SubscriptionService) RenewSubscriptions(ctx context.Context) error {
+	now := time.Now()
+
+	subscriptions, err := s.subscriptionRepo.GetDue(ctx, now.Add(time.Duration(s.settings.RenewBefore)*time.Second))
+	if err != nil {
+		return err
+	}
+
+	for i := range subscriptions {
+		if err = s.processDueSubscription(ctx, subscriptions[i], now); err != nil {
+			logger.Errorf("failed to process subscription %s: %s", subscriptions[i].ID, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) RenewSubscriptionsSync() {
+	ctx := context.Background()
+	traceID := "RenewSubscriptions"
+	logger.Infof(fmt.Sprintf("%s starts", traceID))
+
+	if err := s.RenewSubscriptions(ctx); err != nil {
+		err = errors.Wrap(errors.WithStack(err), fmt.Sprintf("TraceID: %+v", traceID))
+		logger.Error(err)
+	}
+
+	logger.Infof(fmt.Sprintf("%s ends", traceID))
+}
+
+func (s *SubscriptionService) processDueSubscription(ctx context.Context, subscription *domain.Subscription, now time.Time) error {
+	switch {
+	case !subscription.Renewable(), subscription.Rectoken == "":
+		if now.Before(subscription.CurrentPeriodEnd) {
+			return nil
+		}
+
+		if subscription.CancelAtPeriodEnd {
+			return s.endSubscription(ctx, subscription, domain.CanceledSubscriptionStatus, now)
+		}
+
+		return s.endSubscription(ctx, subscription, domain.ExpiredSubscriptionStatus, now)
+
+	case subscription.RenewalAttempts >= s.settings.MaxRenewalAttempts:
+		if now.Before(subscription.CurrentPeriodEnd) {
+			return nil
+		}
+
+		return s.endSubscription(ctx, subscription, domain.ExpiredSubscriptionStatus, now)
+
+	case now.Before(subscription.NextRenewalAt):
+		return nil
+	}
+
+	// the renewal is claimed by moving the next one forward, so the instances running the job at once
+	// do not charge the card twice, it is checked again once the claim expires unless it is settled by then
+	claimedUntil := now.Add(renewalClaimTTL * time.Second)
+
+	if err := s.subscriptionRepo.ClaimRenewal(ctx, subscription, claimedUntil); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	subscription.NextRenewalAt = claimedUntil
+
+	done, err := s.checkLastRenewal(ctx, subscription, now)
+	if err != nil || done {
+		return err
+	}
+
+	return s.renew(ctx, subscription, now)
+}
+
+// checkLastRenewal reports whether the last renewal payment is still processed by the provider
+// or has just been settled, the card is not charged again then. The provider is asked for the payment
+// in case its webhook has been lost. The pending payment blocks the renewal however long it takes,
+// and so does the payment the provider can not be asked about, a new charge could charge the card twice.
+func (s *SubscriptionService) checkLastRenewal(ctx context.Context, subscription *domain.Subscription, now time.Time) (bool, error) {
+	if subscription.RenewalOrderID == "" {
+		return false, nil
+	}
+
+	order, err := s.orderRepo.GetByID(ctx, subscription.RenewalOrderID)
+	if err != nil {
+		return false, err
+	}
+
+	// the paid order whose plan activation has failed is processed again, a new charge would charge the card twice
+	if order.Status != domain.NewOrderStatus && order.Status != domain.OtherOrderStatus && !order.PlanActivationPending {
+		return false, nil
+	}
+
+	payment, err := s.paymentsManager.GetPaymentStatus(ctx, order.ID)
+	if errors.Is(err, payments.ErrPaymentUnknown) {
+		// the charge has not reached the provider
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if payment.Status == payments.PendingStatus {
+		if now.Sub(order.CreatedAt) >= time.Duration(s.settings.RetryInterval)*time.Second {
+			logger.Warnf("renewal order %s of subscription %s is pending since %s",
+				order.ID, subscription.ID, order.CreatedAt.Format(time.RFC3339))
+		}
+
+		return true, nil
+	}
+
+	if err = s.paymentsService.processPayment(ctx, payment); err != nil {
+		return false, err
+	}
+
+	if payment.Status != payments.ApprovedStatus {
+		return true, s.failRenewal(ctx, subscription, now)
+	}
+
+	return true, nil
+}
+
+// renew charges the saved card for the next period, the period is started
+// once the payment is processed as any other callback.
+func (s *SubscriptionService) renew(ctx context.Context, subscription *domain.Subscription, now time.Time) error {
+	user, err := s.userRepo.GetUserByID(ctx, subscription.UserID)
+	if err != nil {
+		return err
+	}
+
+	plan, err := s.planRepo.GetOne(ctx, subscription.PlanID)
+	if err != nil {
+		return err
+	}
+
+	order := &domain.Order{
+		UserID:         user.ID,
+		PlanID:         plan.ID,
+		SubscriptionID: subscription.ID,
+		Amount:         plan.Price,
+		Currency:       s.paymentsManager.Currency(),
+		CreatedAt:      now,
+		Description: domain.GenerateOrderDescription(
+			strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
+			fmt.Sprintf("Plan \"%s\" renewal", plan.Name),
+			plan.Price,
+			now,
+		),
+		Status: domain.NewOrderStatus,
+	}
+
+	order, err = s.orderRepo.Create(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	if err = s.subscriptionRepo.SetRenewalOrder(ctx, subscription.ID, order.ID, now); err != nil {
+		return err
+	}
+
+	subscription.RenewalOrderID = order.ID
+
+	payment, err := s.paymentsManager.ChargeRecurring(ctx, payments.RecurringRequest{
+		OrderID:        order.ID,
+		Description:    order.Description,
+		CustomerEmail:  user.Email,
+		ProductID:      plan.ID,
+		RecurringToken: subscription.Rectoken,
+		Amount:         order.Total(),
+	})
+	if err != nil {
+		// the charge may still have reached the provider, the renewal order is checked
+		// by checkLastRenewal once the claim expires instead of failing the renewal
+		return errors.Wrap(err, "charge renewal")
+	}
+
+	if err = s.paymentsService.processPayment(ctx, payment); err != nil {
+		return errors.Wrap(err, "process renewal payment")
+	}
+
+	order, err = s.orderRepo.GetByID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	if order.Status == domain.FailedOrderStatus {
+		return s.failRenewal(ctx, subscription, now)
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) failRenewal(ctx context.Context, subscription *domain.Subscription, now time.Time) error {
+	return s.subscriptionRepo.FailRenewal(
+		ctx, subscription.ID, now.Add(time.Duration(s.settings.RetryInterval)*time.Second), now,
+	)
+}
+
+// endSubscription moves the user back to the basic plan unless the user has already changed the plan.
+func (s *SubscriptionService) endSubscription(ctx context.Context, subscription *domain.Subscription, status string, now time.Time) error {
+	subscription.Status = status
+	subscription.UpdatedAt = now
+
+	if err := s.subscriptionRepo.Save(ctx, subscription); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, subscription.UserID)
+	if err != nil {
+		return err
+	}
+
+	if user.PlanID != subscription.PlanID {
+		return nil
+	}
+
+	basicPlan, err := s.planRepo.GetOneByName(ctx, domain.BasicPlanName)
+	if err != nil {
+		return err
+	}
+
+	user.PlanID = basicPlan.ID
+
+	if err = s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if err = sendPlanDeactivatedNotification(s.notificationManager, user); err != nil {
+		logger.Errorf("failed to send email after subscription end: %s", err.Error())
+	}
+
+	return nil
+}
@@ -13,10 +13,13 @@ import (
 )
 
 const (
-	emailConfirmTemplate  = "confirm-email.tmpl"
-	passwordResetTemplate = "password-reset.tmpl"
-	orderSuccessTemplate  = "order-success.tmpl"
-	planUpdateTemplate    = "plan-update.tmpl"
+	emailConfirmTemplate   = "confirm-email.tmpl"
+	passwordResetTemplate  = "password-reset.tmpl"
+	orderSuccessTemplate   = "order-success.tmpl"
+	planUpdateTemplate     = "plan-update.tmpl"
+	accountLockoutTemplate = "account-lockout.tmpl"
+	emailChangedTemplate   = "email-changed.tmpl"
+	magicLinkTemplate      = "magic-link.tmpl"
 )
 
 var (
@@ -105,25 +108,26 @@ func getPasswordResetTemplate(name, code string, codeTTL int64) (string, error)
 	return sw.String(), nil
 }
 
-func getOrderSuccessTemplate(name, planName string, planEndDate *time.Time) (string, error) {
+func getOrderSuccessTemplate(name, planName string, planEndDate *time.Time, invoiceNumber, invoiceURL string) (string, error) {
 	tmpls, err := parseNotificationTemplates()
 	if err != nil {
 		return "", err
 	}
 
 	orderSuccessInfo := struct {
-		Name        string
-		PlanName    string
-		PlanEndDate string
+		Name          string
+		PlanName      string
+		PlanEndDate   string
+		InvoiceNumber string
+		InvoiceURL    string
 	}{
-		Name:        name,
-		PlanName:    planName,
-		PlanEndDate: planEndDate.Format(domain.DateLayout),
+		Name:          name,
+		PlanName:      planName,
+		InvoiceNumber: invoiceNumber,
+		InvoiceURL:    invoiceURL,
 	}
 
-	if planEndDate == nil {
-		orderSuccessInfo.PlanEndDate = ""
-	} else {
+	if planEndDate != nil {
 		orderSuccessInfo.PlanEndDate = planEndDate.Format(domain.DateLayout)
 	}
 
@@ -160,3 +164,86 @@ func getPlanUpdateTemplate(name string) (string, error) {
 
 	return sw.String(), nil
 }
+
+func getAccountLockoutTemplate(name string, attempts int, lockedUntil time.Time) (string, error) {
+	tmpls, err := parseNotificationTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	accountLockoutInfo := struct {
+		Name        string
+		Attempts    int
+		LockedUntil string
+	}{
+		Name:        name,
+		Attempts:    attempts,
+		LockedUntil: lockedUntil.UTC().Format("2006-01-02 15:04 MST"),
+	}
+
+	t := tmpls.Lookup(accountLockoutTemplate)
+
+	sw := bytes.NewBufferString("")
+
+	if err := t.Execute(sw, accountLockoutInfo); err != nil {
+		return "", err
+	}
+
+	return sw.String(), nil
+}
+
+func getEmailChangedTemplate(name, newEmail, revertURL string, revertTTL int64) (string, error) {
+	tmpls, err := parseNotificationTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	emailChangedInfo := struct {
+		Name      string
+		NewEmail  string
+		RevertURL string
+		RevertTTL int
+	}{
+		Name:      name,
+		NewEmail:  newEmail,
+		RevertURL: revertURL,
+		RevertTTL: int((time.Duration(revertTTL) * time.Second).Hours() / 24),
+	}
+
+	t := tmpls.Lookup(emailChangedTemplate)
+
+	sw := bytes.NewBufferString("")
+
+	if err := t.Execute(sw, emailChangedInfo); err != nil {
+		return "", err
+	}
+
+	return sw.String(), nil
+}
+
+func getMagicLinkTemplate(name, url string, ttl int64) (string, error) {
+	tmpls, err := parseNotificationTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	magicLinkInfo := struct {
+		Name string
+		URL  string
+		TTL  int
+	}{
+		Name: name,
+		URL:  url,
+		TTL:  int((time.Duration(ttl) * time.Second).Minutes()),
+	}
+
+	t := tmpls.Lookup(magicLinkTemplate)
+
+	sw := bytes.NewBufferString("")
+
+	if err := t.Execute(sw, magicLinkInfo); err != nil {
+		return "", err
+	}
+
+	return sw.String(), nil
+}
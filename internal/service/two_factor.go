@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/pkg/totp"
+)
+
+const (
+	twoFactorSkew               = 1
+	twoFactorRecoveryCodesCount = 10
+	twoFactorRecoveryCodeLength = 10
+)
+
+type SettingsRepository interface {
+	GetSecuritySettings(ctx context.Context) (*domain.SecuritySettings, error)
+	UpdateSecuritySettings(ctx context.Context, settings *domain.SecuritySettings) error
+}
+
+type TwoFactorService struct {
+	userRepo     UserRepository
+	settingsRepo SettingsRepository
+	hasher       Hasher
+	codeManager  CodeManager
+	authService  *AuthService
+
+	issuer string
+}
+
+func NewTwoFactorService(
+	userRepo UserRepository,
+	settingsRepo SettingsRepository,
+	hasher Hasher,
+	codeManager CodeManager,
+	authService *AuthService,
+	issuer string,
+) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+		hasher:       hasher,
+		codeManager:  codeManager,
+		authService:  authService,
+		issuer:       issuer,
+	}
+}
+
+type TwoFactorSetupOutput struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+// Setup generates a new secret, the second factor stays disabled until it is confirmed with a code.
+func (s *TwoFactorService) Setup(ctx context.Context, userID string) (*TwoFactorSetupOutput, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, core.ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepo.SetTwoFactor(ctx, userID, &domain.UserTwoFactor{
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetupOutput{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables the second factor and returns recovery codes, they are not shown again.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, core.ErrTwoFactorAlreadyEnabled
+	}
+
+	if user.TwoFactor == nil {
+		return nil, core.ErrTwoFactorNotEnabled
+	}
+
+	step, ok := totp.Validate(code, user.TwoFactor.Secret, time.Now(), twoFactorSkew)
+	if !ok {
+		return nil, core.ErrInvalidCode
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	enabledAt := time.Now()
+
+	user.TwoFactor.Enabled = true
+	user.TwoFactor.RecoveryCodes = hashes
+	user.TwoFactor.LastUsedStep = step
+	user.TwoFactor.EnabledAt = &enabledAt
+
+	if err = s.userRepo.SetTwoFactor(ctx, userID, user.TwoFactor); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+type TwoFactorDisableInput struct {
+	Password string
+	Code     string
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, userID string, input TwoFactorDisableInput) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return core.ErrTwoFactorNotEnabled
+	}
+
+	if !s.hasher.CheckPasswordHash(input.Password, user.Password) {
+		return core.ErrInvalidCurrentPassword
+	}
+
+	settings, err := s.settingsRepo.GetSecuritySettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	if settings.TwoFactorRequired(user.Role) {
+		return core.ErrTwoFactorRequired
+	}
+
+	if err = s.authService.checkSecondFactor(ctx, user, input.Code); err != nil {
+		return err
+	}
+
+	return s.userRepo.SetTwoFactor(ctx, userID, nil)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, the old ones stop working.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return nil, core.ErrTwoFactorNotEnabled
+	}
+
+	if err = s.authService.checkSecondFactor(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TwoFactor.RecoveryCodes = hashes
+
+	if err = s.userRepo.SetTwoFactor(ctx, userID, user.TwoFactor); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CheckRequirement returns ErrTwoFactorRequired if the user's role requires the second factor
+// and the user has not enabled it yet.
+func (s *TwoFactorService) CheckRequirement(ctx context.Context, user *domain.User) error {
+	if user.TwoFactorEnabled() {
+		return nil
+	}
+
+	settings, err := s.settingsRepo.GetSecuritySettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	if settings.TwoFactorRequired(user.Role) {
+		return core.ErrTwoFactorRequired
+	}
+
+	return nil
+}
+
+func (s *TwoFactorService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, twoFactorRecoveryCodesCount)
+	hashes := make([]string, twoFactorRecoveryCodesCount)
+
+	for i := range codes {
+		code, err := s.codeManager.GenerateNumericCode(twoFactorRecoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash, err := s.hasher.Hash(code)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes[i] = fmt.Sprintf("%s-%s", code[:twoFactorRecoveryCodeLength/2], code[twoFactorRecoveryCodeLength/2:])
+		hashes[i] = hash
+	}
+
+	return codes, hashes, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or one of the recovery codes. A TOTP code can not be
+// used twice and a recovery code is removed once used, both are claimed by a conditional update
+// so the same code checked concurrently passes once.
+func verifySecondFactor(ctx context.Context, userRepo UserRepository, hasher Hasher, user *domain.User, code string) error {
+	twoFactor := user.TwoFactor
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(code, twoFactor.Secret, time.Now(), twoFactorSkew)
+		if !ok || step <= twoFactor.LastUsedStep {
+			return core.ErrInvalidCode
+		}
+
+		if err := userRepo.UseTwoFactorStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, core.ErrNotFound) {
+				return core.ErrInvalidCode
+			}
+
+			return err
+		}
+
+		twoFactor.LastUsedStep = step
+
+		return nil
+	}
+
+	recoveryCode := strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	for i := range twoFactor.RecoveryCodes {
+		if !hasher.CheckPasswordHash(recoveryCode, twoFactor.RecoveryCodes[i]) {
+			continue
+		}
+
+		if err := userRepo.UseRecoveryCode(ctx, user.ID, twoFactor.RecoveryCodes[i]); err != nil {
+			if errors.Is(err, core.ErrNotFound) {
+				return core.ErrInvalidCode
+			}
+
+			return err
+		}
+
+		twoFactor.RecoveryCodes = append(twoFactor.RecoveryCodes[:i], twoFactor.RecoveryCodes[i+1:]...)
+
+		return nil
+	}
+
+	return core.ErrInvalidCode
+}
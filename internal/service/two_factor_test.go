@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+)
+
+type fakeAttemptRepo struct {
+	AttemptRepository
+
+	attempts map[string]int
+	locked   map[string]time.Duration
+}
+
+func (r *fakeAttemptRepo) Incr(_ context.Context, key string, _ int) (int, error) {
+	r.attempts[key]++
+
+	return r.attempts[key], nil
+}
+
+func (r *fakeAttemptRepo) Reset(_ context.Context, key string) error {
+	delete(r.attempts, key)
+
+	return nil
+}
+
+func (r *fakeAttemptRepo) Lock(_ context.Context, key string, ttl int) error {
+	r.locked[key] = time.Duration(ttl) * time.Second
+
+	return nil
+}
+
+func (r *fakeAttemptRepo) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	return r.locked[key], nil
+}
+
+func TestRegenerateRecoveryCodesLockout(t *testing.T) {
+	const (
+		userID      = "user-1"
+		maxAttempts = 3
+	)
+
+	userRepo := &fakeUserRepo{users: map[string]*domain.User{
+		userID: {
+			ID:        userID,
+			Email:     "jane@example.com",
+			TwoFactor: &domain.UserTwoFactor{Enabled: true, Secret: "JBSWY3DPEHPK3PXP"},
+		},
+	}}
+
+	authService := &AuthService{
+		userRepo:            userRepo,
+		notificationManager: fakeNotificationManager{},
+		attemptGuard: newAttemptGuard(
+			&fakeAttemptRepo{attempts: make(map[string]int), locked: make(map[string]time.Duration)},
+			AttemptLimits{MaxPerEmail: maxAttempts, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond},
+		),
+	}
+
+	s := NewTwoFactorService(userRepo, nil, nil, &fakeCodeManager{}, authService, "faker")
+
+	ctx := context.Background()
+
+	for i := 1; i < maxAttempts; i++ {
+		if _, err := s.RegenerateRecoveryCodes(ctx, userID, "12345-67890"); !errors.Is(err, core.ErrInvalidCode) {
+			t.Fatalf("attempt %d: err = %v, want %v", i, err, core.ErrInvalidCode)
+		}
+	}
+
+	var lockoutErr *core.LockoutError
+
+	if _, err := s.RegenerateRecoveryCodes(ctx, userID, "12345-67890"); !errors.As(err, &lockoutErr) {
+		t.Fatalf("attempt %d: err = %v, want a lockout error", maxAttempts, err)
+	}
+
+	if _, err := s.RegenerateRecoveryCodes(ctx, userID, "12345-67890"); !errors.As(err, &lockoutErr) {
+		t.Fatalf("attempt after the lockout: err = %v, want a lockout error", err)
+	}
+}
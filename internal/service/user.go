@@ -11,19 +11,35 @@ import (
 	"necutya/faker/internal/domain/domain"
 	"necutya/faker/internal/domain/dto"
 	"necutya/faker/pkg/logger"
+	"necutya/faker/pkg/payments"
+	"necutya/faker/pkg/webhook"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
+// minimumCharge is the least amount in cents left to pay after the credit, the providers decline smaller payments.
+const minimumCharge = 100
+
 type OrderRepository interface {
 	Create(ctx context.Context, order *domain.Order) (*domain.Order, error)
 	GetByID(ctx context.Context, userID string) (*domain.Order, error)
 	AddTransaction(
 		ctx context.Context,
 		id string,
+		fromStatus string,
+		transaction *domain.Transaction,
+	) error
+	ReserveRefund(ctx context.Context, orderID string, refund *domain.PendingRefund, total int64) error
+	CompleteRefund(
+		ctx context.Context,
+		orderID string,
+		fromStatus string,
+		refundID string,
 		transaction *domain.Transaction,
-	) (*domain.Order, error)
+	) error
+	ReleaseRefund(ctx context.Context, orderID, refundID string) error
+	CompletePlanActivation(ctx context.Context, orderID string) error
 	GetByUserID(ctx context.Context, userID string) ([]*domain.Order, error)
 }
 
@@ -31,18 +47,46 @@ type NotificationManager interface {
 	SendEmail(to []string, subject, body string) error
 }
 
+// AttachmentSender is implemented by the notification managers which can send files with the email,
+// the others get only the links to download them.
+type AttachmentSender interface {
+	SendEmailWithAttachments(to []string, subject, body string, attachments []*EmailAttachment) error
+}
+
+type EmailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
 type UserRepository interface {
 	GetUserByID(ctx context.Context, id string) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
-	GetUserByExternalToken(ctx context.Context, externalToken string) (*domain.User, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (*domain.User, error)
 
 	Create(ctx context.Context, user *domain.User) error
 	Update(ctx context.Context, user *domain.User) error
+	SetTwoFactor(ctx context.Context, userID string, twoFactor *domain.UserTwoFactor) error
+	SetWebhook(ctx context.Context, userID string, webhook *domain.UserWebhook) error
+	UseTwoFactorStep(ctx context.Context, userID string, step int64) error
+	UseRecoveryCode(ctx context.Context, userID, hash string) error
+	AddIdentity(ctx context.Context, userID string, identity *domain.UserIdentity) error
+	RemoveIdentity(ctx context.Context, userID, provider string) error
 	Delete(ctx context.Context, userID string) error
 
+	// GetExternalTokens and RemoveExternalToken serve the migration of the tokens issued before the API keys.
+	GetExternalTokens(ctx context.Context) (map[string]string, error)
+	RemoveExternalToken(ctx context.Context, userID string) error
+
+	// GetPlainRefreshTokens and HashRefreshToken serve the migration of the refresh tokens stored before they were hashed.
+	GetPlainRefreshTokens(ctx context.Context) (map[string]map[uuid.UUID]string, error)
+	HashRefreshToken(ctx context.Context, userID string, sessionID uuid.UUID, refreshToken, hash string) error
+
 	SetSession(ctx context.Context, userID string, session *domain.Session) error
-	GetSessionByRefreshToken(ctx context.Context, userID string, refreshToken string) (*domain.Session, error)
-	RemoveSession(ctx context.Context, userID string, sessionID uuid.UUID) error
+	GetSessionByRefreshToken(ctx context.Context, userID string, refreshTokenHash string) (*domain.Session, error)
+	RotateRefreshToken(ctx context.Context, userID string, sessionID uuid.UUID, oldHash, newHash string, keepUsed int) error
+	GetSessions(ctx context.Context, userID string) ([]*domain.Session, error)
+	RemoveSessions(ctx context.Context, userID string, sessionIDs []uuid.UUID) error
 
 	GetMany(context.Context, dto.UserFilter) ([]*domain.User, error)
 }
@@ -51,32 +95,42 @@ type UserService struct {
 	userRepo            UserRepository
 	planRepo            PlanRepository
 	orderRepo           OrderRepository
+	subscriptionRepo    SubscriptionRepository
 	requestCounterRepo  RequestCounterRepository
+	apiKeyRepo          APIKeyRepository
 	hasher              Hasher
 	notificationManager NotificationManager
 	paymentsManager     PaymentsManager
 	codeManager         CodeManager
+	passwordValidator   *passwordValidator
 }
 
 func NewUserService(
 	userRepo UserRepository,
 	planRepo PlanRepository,
 	orderRepo OrderRepository,
+	subscriptionRepo SubscriptionRepository,
 	requestCounterRepo RequestCounterRepository,
+	apiKeyRepo APIKeyRepository,
 	hasher Hasher,
 	notificationManager NotificationManager,
 	paymentsManager PaymentsManager,
 	codeManager CodeManager,
+	breachedPasswords BreachedPasswordChecker,
+	passwordPolicy PasswordPolicy,
 ) *UserService {
 	return &UserService{
 		userRepo:            userRepo,
 		planRepo:            planRepo,
 		orderRepo:           orderRepo,
+		subscriptionRepo:    subscriptionRepo,
 		requestCounterRepo:  requestCounterRepo,
+		apiKeyRepo:          apiKeyRepo,
 		hasher:              hasher,
 		notificationManager: notificationManager,
 		paymentsManager:     paymentsManager,
 		codeManager:         codeManager,
+		passwordValidator:   newPasswordValidator(passwordPolicy, breachedPasswords),
 	}
 }
 
@@ -122,6 +176,10 @@ func (s *UserService) Update(ctx context.Context, userID string, input UserUpdat
 }
 
 func (s *UserService) Delete(ctx context.Context, userID string) error {
+	if err := s.apiKeyRepo.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+
 	return s.userRepo.Delete(ctx, userID)
 }
 
@@ -153,6 +211,11 @@ func (s *UserService) UpdateUserPassword(ctx context.Context, userID string, inp
 		return core.ErrInvalidCurrentPassword
 	}
 
+	err = s.passwordValidator.validate("NewPassword", input.NewPassword, user.Email, user.FirstName, user.LastName)
+	if err != nil {
+		return err
+	}
+
 	user.Password, err = s.hasher.Hash(input.NewPassword)
 	if err != nil {
 		return err
@@ -177,12 +240,21 @@ func (s *UserService) UpdateUserPlan(ctx context.Context, userID, planID string)
 	}
 
 	if plan.IsBasic() {
+		if err = s.endSubscription(ctx, userID); err != nil {
+			return "", err
+		}
+
 		user.PlanID = plan.ID
 		return "", s.userRepo.Update(ctx, user)
 	}
 
 	now := time.Now()
 
+	credit, err := s.prorateCredit(ctx, user, plan, now)
+	if err != nil {
+		return "", err
+	}
+
 	orderDesc := domain.GenerateOrderDescription(
 		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
 		fmt.Sprintf("Plan \"%s\"", plan.Name),
@@ -194,6 +266,8 @@ func (s *UserService) UpdateUserPlan(ctx context.Context, userID, planID string)
 		UserID:      userID,
 		PlanID:      planID,
 		Amount:      plan.Price,
+		Credit:      credit,
+		Currency:    s.paymentsManager.Currency(),
 		CreatedAt:   now,
 		Description: orderDesc,
 		Status:      domain.NewOrderStatus,
@@ -204,13 +278,14 @@ func (s *UserService) UpdateUserPlan(ctx context.Context, userID, planID string)
 		return "", err
 	}
 
-	checkoutLink, err := s.paymentsManager.GenerateSubscriptionCheckout(
-		order.ID,
-		order.Description,
-		user.Email,
-		plan.ID,
-		int64(convertDollarsToCents(plan.Price)),
-	)
+	checkoutLink, err := s.paymentsManager.CreateCheckout(ctx, payments.CheckoutRequest{
+		OrderID:       order.ID,
+		Description:   order.Description,
+		CustomerEmail: user.Email,
+		ProductID:     plan.ID,
+		Amount:        order.Total(),
+		Recurring:     plan.Duration > 0,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -218,23 +293,99 @@ func (s *UserService) UpdateUserPlan(ctx context.Context, userID, planID string)
 	return checkoutLink, nil
 }
 
-func (s *UserService) RegenerateExternalToken(ctx context.Context, userID string) (*domain.User, error) {
-	user, err := s.userRepo.GetUserByID(ctx, userID)
+// prorateCredit returns the part of the current subscription period paid but left unused in cents,
+// it is deducted from the price of the new plan the user switches to. The credit leaves at least
+// the minimum charge to pay, the rest of the unused part is not returned.
+func (s *UserService) prorateCredit(ctx context.Context, user *domain.User, plan *domain.Plan, now time.Time) (int64, error) {
+	subscription, err := s.subscriptionRepo.GetByUserID(ctx, user.ID)
+	if errors.Is(err, core.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !subscription.Ongoing() || subscription.PlanID != user.PlanID {
+		return 0, nil
+	}
+
+	orders, err := s.orderRepo.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var paid int64
+
+	// the latest order paid for the plan before the period started is the one paying for it
+	for _, order := range orders {
+		if order.Paid() && order.PlanID == subscription.PlanID && !order.CreatedAt.After(subscription.CurrentPeriodStart) {
+			paid = order.Total() - order.RefundedAmount()
+			break
+		}
+	}
+
+	credit := int64(float64(paid) * subscription.UnusedShare(now))
+
+	if maxCredit := int64(convertDollarsToCents(plan.Price)) - minimumCharge; credit > maxCredit {
+		credit = maxCredit
+	}
+
+	if credit < 0 {
+		return 0, nil
+	}
+
+	return credit, nil
+}
+
+// endSubscription cancels the subscription at once when the user moves to the basic plan.
+func (s *UserService) endSubscription(ctx context.Context, userID string) error {
+	subscription, err := s.subscriptionRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, core.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !subscription.Ongoing() {
+		return nil
+	}
+
+	subscription.Status = domain.CanceledSubscriptionStatus
+	subscription.UpdatedAt = time.Now()
+
+	return s.subscriptionRepo.Save(ctx, subscription)
+}
+
+// SetWebhook sets the URL notified about finished check jobs and generates a new signing secret.
+func (s *UserService) SetWebhook(ctx context.Context, userID, url string) (*domain.User, error) {
+	if err := webhook.ValidateURL(url); err != nil {
+		return nil, core.ErrInvalidWebhookURL
+	}
+
+	secret, err := s.codeManager.GenerateString(webhookSecretLength)
 	if err != nil {
 		return nil, err
 	}
 
-	user.Credential.ExternalToken, err = s.codeManager.GenerateString(externalTokenLength)
+	err = s.userRepo.SetWebhook(ctx, userID, &domain.UserWebhook{
+		URL:    url,
+		Secret: secret,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.userRepo.Update(ctx, user)
+	return s.GetOne(ctx, userID)
+}
+
+func (s *UserService) RemoveWebhook(ctx context.Context, userID string) (*domain.User, error) {
+	err := s.userRepo.SetWebhook(ctx, userID, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.GetOne(ctx, user.ID)
+	return s.GetOne(ctx, userID)
 }
 
 func (s *UserService) ValidateUsersPlan(ctx context.Context) error {
@@ -260,6 +411,16 @@ func (s *UserService) ValidateUsersPlan(ctx context.Context) error {
 			continue
 		}
 
+		// the plans of the subscribers are ended by the renewal of their subscriptions
+		subscribed, err := s.hasOngoingSubscription(ctx, users[i].ID)
+		if err != nil {
+			return err
+		}
+
+		if subscribed {
+			continue
+		}
+
 		orders, err := s.orderRepo.GetByUserID(ctx, users[i].ID)
 		if err != nil {
 			return err
@@ -305,7 +466,23 @@ func (s *UserService) ValidateUsersPlanSync() {
 	logger.Infof(fmt.Sprintf("%s ends", traceID))
 }
 
+func (s *UserService) hasOngoingSubscription(ctx context.Context, userID string) (bool, error) {
+	subscription, err := s.subscriptionRepo.GetByUserID(ctx, userID)
+	if errors.Is(err, core.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return subscription.Ongoing(), nil
+}
+
 func (s *UserService) sendUpdatePlan(user *domain.User) error {
+	return sendPlanDeactivatedNotification(s.notificationManager, user)
+}
+
+func sendPlanDeactivatedNotification(notificationManager NotificationManager, user *domain.User) error {
 	planUpdateTmpl, err := getPlanUpdateTemplate(
 		strings.Title(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
 	)
@@ -313,5 +490,5 @@ func (s *UserService) sendUpdatePlan(user *domain.User) error {
 		return err
 	}
 
-	return s.notificationManager.SendEmail([]string{user.Email}, domain.DeactivatedPlanSubject, planUpdateTmpl)
+	return notificationManager.SendEmail([]string{user.Email}, domain.DeactivatedPlanSubject, planUpdateTmpl)
 }
@@ -27,12 +27,17 @@ func New(services *service.Service, validate *validator.Validate) *Handler {
 	return handler
 }
 
-func (h *Handler) Init(URLPrefix, externalURLPrefix string, CORSAllowedHost []string) http.Handler {
-	v1Router := v1.NewHandler(h.services, h.router, h.validate)
+func (h *Handler) Init(URLPrefix, externalURLPrefix string, CORSAllowedHost, trustedProxies []string) (http.Handler, error) {
+	proxies, err := v1.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	v1Router := v1.NewHandler(h.services, h.router, h.validate, proxies)
 
 	v1Router.Init(URLPrefix, externalURLPrefix, CORSAllowedHost)
 
-	return getCors().Handler(h.router)
+	return getCors().Handler(h.router), nil
 }
 
 func getCors() *cors.Cors {
@@ -5,17 +5,43 @@ import (
 	"time"
 
 	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/service"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 )
 
-func (h *Handler) initAdminRoutes(router *mux.Router, adminChain alice.Chain) {
+func (h *Handler) initAdminRoutes(router *mux.Router, authChain alice.Chain) {
 	usersRouter := router.PathPrefix("/admin/").Subrouter()
 
-	usersRouter.Handle("/users-report", adminChain.ThenFunc(h.adminGetUsersReport)).Methods(http.MethodGet)
-	usersRouter.Handle("/users/{user_id}/feedbacks", adminChain.ThenFunc(h.feedbacksGetByUser)).Methods(http.MethodGet)
-	usersRouter.Handle("/users/{user_id}/feedbacks/{feedback_id}/resolve", adminChain.ThenFunc(h.resolveUserFeedback)).Methods(http.MethodPut, http.MethodPatch, http.MethodOptions)
+	// the permission goes first, so users without it are rejected without loading them
+	permissionChain := func(permission domain.Permission) alice.Chain {
+		return authChain.Append(h.RequirePermission(permission), h.TwoFactorRequirementMiddleware)
+	}
+
+	var (
+		usersReadChain       = permissionChain(domain.UsersReadPermission)
+		feedbackResolveChain = permissionChain(domain.FeedbackResolvePermission)
+		plansWriteChain      = permissionChain(domain.PlansWritePermission)
+		reportsReadChain     = permissionChain(domain.ReportsReadPermission)
+		settingsWriteChain   = permissionChain(domain.SettingsWritePermission)
+		rolesWriteChain      = permissionChain(domain.RolesWritePermission)
+		ordersRefundChain    = permissionChain(domain.OrdersRefundPermission)
+	)
+
+	usersRouter.Handle("/users-report", reportsReadChain.ThenFunc(h.adminGetUsersReport)).Methods(http.MethodGet)
+	usersRouter.Handle("/users/{user_id}/feedbacks", usersReadChain.ThenFunc(h.feedbacksGetByUser)).Methods(http.MethodGet)
+	usersRouter.Handle("/users/{user_id}/feedbacks/{feedback_id}/resolve", feedbackResolveChain.ThenFunc(h.resolveUserFeedback)).Methods(http.MethodPut, http.MethodPatch, http.MethodOptions)
+	usersRouter.Handle("/users/{user_id}/role", rolesWriteChain.ThenFunc(h.adminSetUserRole)).Methods(http.MethodPut, http.MethodOptions)
+	usersRouter.Handle("/plans/{plan_id}", plansWriteChain.ThenFunc(h.adminUpdatePlan)).Methods(http.MethodPut, http.MethodOptions)
+	usersRouter.Handle("/orders/{order_id}/refund", ordersRefundChain.ThenFunc(h.adminOrderRefund)).Methods(http.MethodPost, http.MethodOptions)
+	usersRouter.Handle("/roles", rolesWriteChain.ThenFunc(h.adminGetRoles)).Methods(http.MethodGet)
+	usersRouter.Handle("/roles/{role}", rolesWriteChain.ThenFunc(h.adminUpdateRole)).Methods(http.MethodPut, http.MethodOptions)
+	usersRouter.Handle("/check-cache", settingsWriteChain.ThenFunc(h.adminPurgeCheckCache)).Methods(http.MethodDelete, http.MethodOptions)
+	usersRouter.Handle("/security-settings", settingsWriteChain.ThenFunc(h.adminGetSecuritySettings)).Methods(http.MethodGet)
+	usersRouter.Handle("/security-settings", settingsWriteChain.ThenFunc(h.adminUpdateSecuritySettings)).Methods(http.MethodPut, http.MethodOptions)
+	usersRouter.Handle("/signing-keys", settingsWriteChain.ThenFunc(h.adminGetSigningKeys)).Methods(http.MethodGet)
+	usersRouter.Handle("/signing-keys/rotate", settingsWriteChain.ThenFunc(h.adminRotateSigningKeys)).Methods(http.MethodPost, http.MethodOptions)
 }
 
 type adminUsersReportResponse struct {
@@ -133,3 +159,142 @@ func (h *Handler) resolveUserFeedback(w http.ResponseWriter, r *http.Request) {
 
 	SendEmptyResponse(w, http.StatusCreated)
 }
+
+type adminPurgeCheckCacheResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+func (h *Handler) adminPurgeCheckCache(w http.ResponseWriter, r *http.Request) {
+	var modelVersion string
+
+	param, err := GetQueryParam(r, "model_version", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+	if param != nil {
+		modelVersion = param.(string)
+	}
+
+	deleted, err := h.services.AI.PurgeCache(r.Context(), modelVersion)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, adminPurgeCheckCacheResponse{Deleted: deleted})
+}
+
+type adminSecuritySettingsRequest struct {
+	TwoFactorRequiredRoles []string `json:"two_factor_required_roles" validate:"unique,dive,oneof=admin support billing-manager analyst basic"`
+}
+
+type adminSecuritySettingsResponse struct {
+	TwoFactorRequiredRoles []string `json:"two_factor_required_roles"`
+	UpdatedAt              *string  `json:"updated_at"`
+}
+
+func convertSecuritySettingsToResponse(settings *domain.SecuritySettings) *adminSecuritySettingsResponse {
+	roles := make([]string, len(settings.TwoFactorRequiredRoles))
+	for i := range settings.TwoFactorRequiredRoles {
+		roles[i] = string(settings.TwoFactorRequiredRoles[i])
+	}
+
+	var updatedAt *string
+	if !settings.UpdatedAt.IsZero() {
+		updatedAt = formatOptionalTime(&settings.UpdatedAt)
+	}
+
+	return &adminSecuritySettingsResponse{
+		TwoFactorRequiredRoles: roles,
+		UpdatedAt:              updatedAt,
+	}
+}
+
+func (h *Handler) adminGetSecuritySettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.services.Admin.GetSecuritySettings(r.Context())
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertSecuritySettingsToResponse(settings))
+}
+
+func (h *Handler) adminUpdateSecuritySettings(w http.ResponseWriter, r *http.Request) {
+	var input adminSecuritySettingsRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	roles := make([]domain.Role, len(input.TwoFactorRequiredRoles))
+	for i := range input.TwoFactorRequiredRoles {
+		roles[i] = domain.Role(input.TwoFactorRequiredRoles[i])
+	}
+
+	settings, err := h.services.Admin.UpdateSecuritySettings(r.Context(), service.SecuritySettingsUpdateInput{
+		TwoFactorRequiredRoles: roles,
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertSecuritySettingsToResponse(settings))
+}
+
+type adminSigningKeyResponse struct {
+	ID          string  `json:"id"`
+	Algorithm   string  `json:"algorithm"`
+	CreatedAt   string  `json:"created_at"`
+	ActivatesAt string  `json:"activates_at"`
+	ExpiresAt   *string `json:"expires_at"`
+}
+
+type adminSigningKeysResponse struct {
+	Items []*adminSigningKeyResponse `json:"items"`
+}
+
+func convertSigningKeyToResponse(key *domain.SigningKey) *adminSigningKeyResponse {
+	return &adminSigningKeyResponse{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		CreatedAt:   key.CreatedAt.Format(time.RFC3339),
+		ActivatesAt: key.ActivatesAt.Format(time.RFC3339),
+		ExpiresAt:   formatOptionalTime(key.ExpiresAt),
+	}
+}
+
+func (h *Handler) adminGetSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.services.SigningKey.GetMany(r.Context())
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	items := make([]*adminSigningKeyResponse, len(keys))
+	for i := range keys {
+		items[i] = convertSigningKeyToResponse(keys[i])
+	}
+
+	SendResponse(w, http.StatusOK, adminSigningKeysResponse{Items: items})
+}
+
+func (h *Handler) adminRotateSigningKeys(w http.ResponseWriter, r *http.Request) {
+	key, err := h.services.SigningKey.Rotate(r.Context())
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusCreated, convertSigningKeyToResponse(key))
+}
@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initAPIKeysRoutes(router *mux.Router, privateChain alice.Chain) {
+	apiKeysRouter := router.PathPrefix("/users/{user_id}/api-keys").Subrouter()
+
+	apiKeysRouter.Handle("", privateChain.ThenFunc(h.apiKeyCreate)).Methods(http.MethodPost, http.MethodOptions)
+	apiKeysRouter.Handle("", privateChain.ThenFunc(h.apiKeyGetMany)).Methods(http.MethodGet)
+	apiKeysRouter.Handle("/{key_id}", privateChain.ThenFunc(h.apiKeyRevoke)).Methods(http.MethodDelete, http.MethodOptions)
+}
+
+type apiKeyCreateRequest struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,unique,dive,oneof=check:write history:read"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,gt"`
+}
+
+type apiKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	LastUsedIP string   `json:"last_used_ip,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// apiKeyCreateResponse is the only response containing the key itself.
+type apiKeyCreateResponse struct {
+	*apiKeyResponse
+	Key string `json:"key"`
+}
+
+type apiKeysResponse struct {
+	Items []*apiKeyResponse `json:"items"`
+}
+
+func convertAPIKeyToResponse(key *domain.APIKey) *apiKeyResponse {
+	scopes := make([]string, len(key.Scopes))
+	for i := range key.Scopes {
+		scopes[i] = string(key.Scopes[i])
+	}
+
+	return &apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     scopes,
+		ExpiresAt:  formatOptionalTime(key.ExpiresAt),
+		LastUsedAt: formatOptionalTime(key.LastUsedAt),
+		LastUsedIP: key.LastUsedIP,
+		CreatedAt:  key.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func convertAPIKeysToResponse(keys []*domain.APIKey) *apiKeysResponse {
+	items := make([]*apiKeyResponse, len(keys))
+
+	for i := range keys {
+		items[i] = convertAPIKeyToResponse(keys[i])
+	}
+
+	return &apiKeysResponse{
+		Items: items,
+	}
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	formatted := t.Format(time.RFC3339)
+
+	return &formatted
+}
+
+func (h *Handler) apiKeyCreate(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input apiKeyCreateRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	scopes := make([]domain.Scope, len(input.Scopes))
+	for i := range input.Scopes {
+		scopes[i] = domain.Scope(input.Scopes[i])
+	}
+
+	key, rawKey, err := h.services.APIKey.Create(
+		r.Context(),
+		userID.(string),
+		service.APIKeyCreateInput{
+			Name:      input.Name,
+			Scopes:    scopes,
+			ExpiresAt: input.ExpiresAt,
+		},
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusCreated, apiKeyCreateResponse{
+		apiKeyResponse: convertAPIKeyToResponse(key),
+		Key:            rawKey,
+	})
+}
+
+func (h *Handler) apiKeyGetMany(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	keys, err := h.services.APIKey.GetMany(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertAPIKeysToResponse(keys))
+}
+
+func (h *Handler) apiKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	keyID, err := GetPathVar(r, "key_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.services.APIKey.Revoke(r.Context(), userID.(string), keyID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
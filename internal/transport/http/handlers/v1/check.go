@@ -13,6 +13,10 @@ import (
 
 func (h *Handler) initCheckRoutes(router *mux.Router, publicChain alice.Chain) {
 	router.Handle("/check-message", publicChain.ThenFunc(h.checkMessageInternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-messages", publicChain.ThenFunc(h.checkMessagesInternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-document", publicChain.ThenFunc(h.checkDocumentInternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-jobs", publicChain.ThenFunc(h.checkJobCreateInternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-jobs/{job_id}", publicChain.ThenFunc(h.checkJobGet)).Methods(http.MethodGet)
 }
 
 func (h *Handler) checkMessageInternal(w http.ResponseWriter, r *http.Request) {
@@ -37,8 +41,9 @@ func (h *Handler) checkMessageInternal(w http.ResponseWriter, r *http.Request) {
 		reqContext.GetUserID(ctx),
 		reqContext.GetPlanID(ctx),
 		&service.CheckMessageInput{
-			Message:  input.Message,
-			SaveToDb: input.SaveToDb,
+			Message:      input.Message,
+			SaveToDb:     input.SaveToDb,
+			Segmentation: input.Segmentation,
 		},
 		domain.Internal,
 	)
@@ -47,5 +52,37 @@ func (h *Handler) checkMessageInternal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	SendResponse(w, http.StatusOK, checkMessageResponse(*res))
+	SendResponse(w, http.StatusOK, convertCheckMessageToResponse(res))
+}
+
+func (h *Handler) checkMessagesInternal(w http.ResponseWriter, r *http.Request) {
+	var input checkMessagesRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	res, err := h.services.AI.CheckMessages(
+		ctx,
+		reqContext.GetUserID(ctx),
+		reqContext.GetPlanID(ctx),
+		convertCheckMessagesInput(&input),
+		domain.Internal,
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertCheckMessagesToResponse(res))
 }
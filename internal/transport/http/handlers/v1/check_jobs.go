@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	reqContext "necutya/faker/internal/context"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/service"
+)
+
+type checkJobRequest struct {
+	Messages     []checkMessagesItemRequest `json:"messages" validate:"required,min=1,max=1000,unique=ID,dive"`
+	SaveToDb     bool                       `json:"save_to_db"`
+	Segmentation string                     `json:"segmentation" validate:"omitempty,oneof=sentence paragraph"`
+}
+
+type checkJobResponse struct {
+	ID         string                  `json:"id"`
+	Status     string                  `json:"status"`
+	Progress   float64                 `json:"progress"`
+	Total      int                     `json:"total"`
+	Processed  int                     `json:"processed"`
+	Failed     int                     `json:"failed"`
+	Results    []*checkJobItemResponse `json:"results,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	UpdatedAt  time.Time               `json:"updated_at"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+}
+
+type checkJobItemResponse struct {
+	ID        string                `json:"id"`
+	Result    *checkMessageResponse `json:"result,omitempty"`
+	ErrorCode string                `json:"error_code,omitempty"`
+	Error     string                `json:"error,omitempty"`
+}
+
+func convertCheckJobToResponse(job *domain.CheckJob) *checkJobResponse {
+	var results []*checkJobItemResponse
+
+	if job.Finished() {
+		results = make([]*checkJobItemResponse, len(job.Items))
+
+		for i, item := range job.Items {
+			results[i] = &checkJobItemResponse{
+				ID:        item.ID,
+				ErrorCode: item.ErrorCode,
+				Error:     item.Error,
+			}
+
+			if item.Result != nil {
+				results[i].Result = convertCheckMessageToResponse(&service.CheckMessageOutput{
+					Message:            item.Result.Message,
+					IsGenerated:        item.Result.IsGenerated,
+					GeneratedPercent:   item.Result.GeneratedPercent,
+					Segments:           item.Result.Segments,
+					Detectors:          item.Result.Detectors,
+					Cached:             item.Cached,
+					Language:           item.Result.Language,
+					LanguageConfidence: item.Result.LanguageConfidence,
+				})
+			}
+		}
+	}
+
+	return &checkJobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		Progress:   job.Progress(),
+		Total:      len(job.Items),
+		Processed:  job.Processed,
+		Failed:     job.Failed,
+		Results:    results,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		FinishedAt: job.FinishedAt,
+	}
+}
+
+func (h *Handler) checkJobCreateInternal(w http.ResponseWriter, r *http.Request) {
+	h.checkJobCreate(w, r, domain.Internal)
+}
+
+func (h *Handler) checkJobCreateExternal(w http.ResponseWriter, r *http.Request) {
+	h.checkJobCreate(w, r, domain.External)
+}
+
+func (h *Handler) checkJobCreate(w http.ResponseWriter, r *http.Request, requestType domain.RequestType) {
+	var input checkJobRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	job, err := h.services.CheckJob.Create(
+		ctx,
+		reqContext.GetUserID(ctx),
+		convertCheckMessagesInput((*checkMessagesRequest)(&input)),
+		requestType,
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusAccepted, convertCheckJobToResponse(job))
+}
+
+func (h *Handler) checkJobGet(w http.ResponseWriter, r *http.Request) {
+	jobID, err := GetPathVar(r, "job_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	job, err := h.services.CheckJob.Get(
+		ctx,
+		reqContext.GetUserID(ctx),
+		jobID.(string),
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertCheckJobToResponse(job))
+}
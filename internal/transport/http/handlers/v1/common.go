@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
 	"necutya/faker/pkg/logger"
 
 	"github.com/go-playground/validator/v10"
@@ -28,6 +32,8 @@ const (
 
 	intType    = "int"
 	stringType = "string"
+	boolType   = "bool"
+	dateType   = "date"
 )
 
 // SendHTTPError sends empty HTTP response
@@ -55,6 +61,19 @@ func SendResponse(w http.ResponseWriter, statusCode int, respBody interface{}) {
 	}
 }
 
+// SendFile sends the data as a file to download with the name.
+func SendFile(w http.ResponseWriter, contentType, name string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write(data)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
 // SendHTTPError sends HTTP error with code.
 func SendHTTPError(w http.ResponseWriter, err error) {
 	type HttError struct {
@@ -71,34 +90,93 @@ func SendHTTPError(w http.ResponseWriter, err error) {
 		httpErr.StatusCode = http.StatusNotFound
 		httpErr.Code = "not_found"
 
-	case core.ErrExpiredSession, core.ErrInvalidSession:
+	case core.ErrExpiredSession, core.ErrInvalidSession, core.ErrRefreshTokenReused:
 		httpErr.StatusCode = http.StatusUnauthorized
 		httpErr.Code = "unauthorized"
 
-	case core.ErrUnconfirmedEmail, core.ErrInvalidLoginOrPassword, core.ErrInvalidExternalToken:
+	case core.ErrUnconfirmedEmail, core.ErrInvalidLoginOrPassword, core.ErrInvalidAPIKey, core.ErrExpiredAPIKey:
 		httpErr.StatusCode = http.StatusUnauthorized
 		httpErr.Code = "unauthorized"
 		httpErr.Message = err.Error()
 
+	case core.ErrInsufficientScope, core.ErrInsufficientPermission:
+		httpErr.StatusCode = http.StatusForbidden
+		httpErr.Code = "forbidden"
+		httpErr.Message = err.Error()
+
+	case core.ErrUnknownOAuthProvider, core.ErrPaymentSimulationDisabled:
+		httpErr.StatusCode = http.StatusNotFound
+		httpErr.Code = "not_found"
+		httpErr.Message = err.Error()
+
+	case core.ErrInvalidMFAChallenge, core.ErrInvalidMagicLink, core.ErrOAuthFailed, core.ErrOAuthEmailNotVerified:
+		httpErr.StatusCode = http.StatusUnauthorized
+		httpErr.Code = "unauthorized"
+		httpErr.Message = err.Error()
+
+	case core.ErrTwoFactorRequired:
+		httpErr.StatusCode = http.StatusForbidden
+		httpErr.Code = "two_factor_required"
+		httpErr.Message = err.Error()
+
+	case core.ErrMagicLinkDisabled:
+		httpErr.StatusCode = http.StatusForbidden
+		httpErr.Code = "forbidden"
+		httpErr.Message = err.Error()
+
 	case core.ErrRequestLimit:
 		httpErr.StatusCode = http.StatusForbidden
 		httpErr.Code = "requests limit"
 		httpErr.Message = err.Error()
 
-	case core.ErrAlreadyExist, core.ErrThisPlanAlreadySet:
+	case core.ErrAlreadyExist, core.ErrThisPlanAlreadySet, core.ErrTwoFactorAlreadyEnabled, core.ErrEmailAlreadyConfirmed,
+		core.ErrIdentityAlreadyLinked, core.ErrLastSignInMethod, core.ErrIllegalOrderStatus,
+		core.ErrSubscriptionNotActive, core.ErrRefundDeclined:
 		httpErr.StatusCode = http.StatusConflict
 		httpErr.Code = "conflict"
 		httpErr.Message = err.Error()
 
-	case core.ErrUnknownCallbackType,
-		core.ErrTransactionInvalid,
+	case core.ErrTransactionInvalid,
+		core.ErrInvalidRefundAmount,
 		core.ErrInvalidCode,
 		core.ErrExpiredCode,
-		core.ErrInvalidCurrentPassword:
+		core.ErrInvalidOAuthState,
+		core.ErrInvalidRevertToken,
+		core.ErrUnknownRole,
+		core.ErrRoleNotEditable,
+		core.ErrPermissionNotGrantable,
+		core.ErrKeyRotationUnsupported,
+		core.ErrCodeAttemptsExceeded,
+		core.ErrInvalidCurrentPassword,
+		core.ErrTwoFactorNotEnabled,
+		core.ErrMalformedDocument,
+		core.ErrEmptyDocument,
+		core.ErrInvalidWebhookURL,
+		core.ErrInvoiceNameUnsupported:
 		httpErr.StatusCode = http.StatusBadRequest
 		httpErr.Code = "bad_request"
 		httpErr.Message = err.Error()
 
+	case core.ErrUnsupportedDocument:
+		httpErr.StatusCode = http.StatusUnsupportedMediaType
+		httpErr.Code = "unsupported_media_type"
+		httpErr.Message = err.Error()
+
+	case core.ErrFileTooLarge, core.ErrDocumentTooLong:
+		httpErr.StatusCode = http.StatusRequestEntityTooLarge
+		httpErr.Code = "too_large"
+		httpErr.Message = err.Error()
+
+	case core.ErrUnsupportedLanguage:
+		httpErr.StatusCode = http.StatusBadRequest
+		httpErr.Code = "unsupported_language"
+		httpErr.Message = err.Error()
+
+	case core.ErrAIServiceUnavailable:
+		httpErr.StatusCode = http.StatusServiceUnavailable
+		httpErr.Code = "service_unavailable"
+		httpErr.Message = err.Error()
+
 	default:
 		switch v := err.(type) {
 		case validator.ValidationErrors:
@@ -112,6 +190,16 @@ func SendHTTPError(w http.ResponseWriter, err error) {
 			httpErr.StatusCode = http.StatusBadRequest
 			httpErr.Errors = apiErrors
 			httpErr.Code = "bad_request"
+		case *core.ValidationError:
+			httpErr.StatusCode = http.StatusBadRequest
+			httpErr.Errors = v.Errors
+			httpErr.Code = "bad_request"
+		case *core.LockoutError:
+			retryAfter := int(math.Ceil(v.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			httpErr.StatusCode = http.StatusTooManyRequests
+			httpErr.Code = "too_many_attempts"
+			httpErr.Message = v.Error()
 		default:
 			httpErr.StatusCode = http.StatusInternalServerError
 			httpErr.Code = "internal"
@@ -155,22 +243,89 @@ func GetUserClient(r *http.Request) string {
 	return r.UserAgent()
 }
 
+// GetUserIPAddress returns the address of the client. The forwarded headers are not read here,
+// RealIPMiddleware takes the address from them only for the requests of the trusted proxies.
 func GetUserIPAddress(r *http.Request) string {
-	ipAddress := r.Header.Get("X-Real-Ip")
-	if ipAddress == "" {
-		ipAddress = r.Header.Get("X-Forwarded-For")
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
 
-	if ipAddress == "" {
-		ipAddress = r.RemoteAddr
+	return host
+}
+
+// ParseTrustedProxies parses the addresses and CIDRs of the trusted proxies.
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+
+			bits := net.IPv6len * 8
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), net.IPv4len*8
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+
+		networks = append(networks, network)
 	}
 
-	if ipAddress != "" {
-		return strings.Split(ipAddress, ":")[0]
+	return networks, nil
+}
+
+// forwardedIPAddress returns the client address from X-Forwarded-For of the request of a trusted proxy,
+// or empty string for the other requests and the headers with no address the proxies have appended.
+// X-Real-Ip is not read, a proxy may pass it on from the client as is.
+func forwardedIPAddress(r *http.Request, trustedProxies []*net.IPNet) string {
+	if !isTrustedProxy(GetUserIPAddress(r), trustedProxies) {
+		return ""
 	}
+
+	// every proxy appends the address it got the request from, so the first untrusted
+	// address from the right is the client, the ones before it could be sent by the client
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ipAddress := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(ipAddress) == nil {
+			return ""
+		}
+
+		if i == 0 || !isTrustedProxy(ipAddress, trustedProxies) {
+			return ipAddress
+		}
+	}
+
 	return ""
 }
 
+func isTrustedProxy(ipAddress string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func GetAuthorizationHeader(r *http.Request) string {
 	return r.Header.Get(accessTokenHeader)
 }
@@ -218,12 +373,38 @@ func GetPathVar(r *http.Request, varName string, varType string) (interface{}, e
 	return nil, errors.New("can`t get path variable")
 }
 
+// GetQueryParam returns parsed query parameter or nil if it is not set.
+func GetQueryParam(r *http.Request, name string, varType string) (interface{}, error) {
+	valueStr := r.URL.Query().Get(name)
+	if valueStr == "" {
+		return nil, nil
+	}
+
+	switch varType {
+	case intType:
+		return strconv.Atoi(valueStr)
+
+	case boolType:
+		return strconv.ParseBool(valueStr)
+
+	case dateType:
+		return time.Parse(domain.DateLayout, valueStr)
+
+	default:
+		return valueStr, nil
+	}
+}
+
 func msgForTag(tag, tagParam string) string {
 	switch tag {
 	case "required":
 		return "field is required"
 	case "email":
 		return "invalid email"
+	case "url":
+		return "invalid url"
+	case "invoice_name":
+		return "value must be in latin letters"
 	case "max":
 		return "value is too bigger"
 	case "min":
@@ -232,6 +413,18 @@ func msgForTag(tag, tagParam string) string {
 		return fmt.Sprintf("value must not be equal to value of %s", tagParam)
 	case "eqfield":
 		return fmt.Sprintf("value must be equal to value of %s", tagParam)
+	case "oneof":
+		return fmt.Sprintf("value must be one of: %s", tagParam)
+	case "unique":
+		if tagParam == "" {
+			return "values must be unique"
+		}
+		return fmt.Sprintf("values of %s must be unique", tagParam)
+	case "gt":
+		if tagParam == "" {
+			return "value must be in the future"
+		}
+		return fmt.Sprintf("value must be greater than %s", tagParam)
 	default:
 		return "invalid value"
 	}
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	reqContext "necutya/faker/internal/context"
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/service"
+)
+
+const (
+	maxUploadSize       = 32 << 20
+	maxUploadMemorySize = 8 << 20
+
+	documentFormField     = "file"
+	saveToDbFormField     = "save_to_db"
+	segmentationFormField = "segmentation"
+)
+
+// errRequestBodyTooLarge is the text of the error http.MaxBytesReader fails with,
+// net/http does not export it before Go 1.19.
+const errRequestBodyTooLarge = "http: request body too large"
+
+type documentMetadataResponse struct {
+	Filename       string `json:"filename"`
+	PageCount      int    `json:"page_count"`
+	WordCount      int    `json:"word_count"`
+	CharacterCount int    `json:"character_count"`
+	Encoding       string `json:"encoding"`
+}
+
+type checkDocumentResponse struct {
+	*checkMessageResponse
+	Document documentMetadataResponse `json:"document"`
+}
+
+// parseCheckDocumentRequest reads the multipart form with the `file` and optional `save_to_db` and `segmentation` fields.
+func parseCheckDocumentRequest(w http.ResponseWriter, r *http.Request) (*service.CheckDocumentInput, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	if err := r.ParseMultipartForm(maxUploadMemorySize); err != nil {
+		return nil, convertUploadError(err)
+	}
+
+	file, header, err := r.FormFile(documentFormField)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, &core.ValidationError{Errors: []core.ApiError{
+			{Field: documentFormField, Msg: msgForTag("required", "")},
+		}}
+	}
+	if err != nil {
+		return nil, convertUploadError(err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, convertUploadError(err)
+	}
+
+	input := &service.CheckDocumentInput{
+		Filename:     header.Filename,
+		Content:      content,
+		Segmentation: r.FormValue(segmentationFormField),
+	}
+
+	switch input.Segmentation {
+	case service.NoSegmentation, service.SentenceSegmentation, service.ParagraphSegmentation:
+	default:
+		return nil, &core.ValidationError{Errors: []core.ApiError{
+			{Field: segmentationFormField, Msg: msgForTag("oneof", "sentence paragraph")},
+		}}
+	}
+
+	if saveToDb := r.FormValue(saveToDbFormField); saveToDb != "" {
+		input.SaveToDb, err = strconv.ParseBool(saveToDb)
+		if err != nil {
+			return nil, &core.ValidationError{Errors: []core.ApiError{
+				{Field: saveToDbFormField, Msg: msgForTag("", "")},
+			}}
+		}
+	}
+
+	return input, nil
+}
+
+// convertUploadError tells the uploads over maxUploadSize from the broken multipart forms.
+func convertUploadError(err error) error {
+	if err.Error() == errRequestBodyTooLarge {
+		return core.ErrFileTooLarge
+	}
+
+	return core.ErrMalformedDocument
+}
+
+func (h *Handler) checkDocument(w http.ResponseWriter, r *http.Request, requestType domain.RequestType) {
+	input, err := parseCheckDocumentRequest(w, r)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	res, err := h.services.AI.CheckDocument(
+		ctx,
+		reqContext.GetUserID(ctx),
+		reqContext.GetPlanID(ctx),
+		input,
+		requestType,
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, checkDocumentResponse{
+		checkMessageResponse: convertCheckMessageToResponse(&res.CheckMessageOutput),
+		Document:             documentMetadataResponse(res.Document),
+	})
+}
+
+func (h *Handler) checkDocumentInternal(w http.ResponseWriter, r *http.Request) {
+	h.checkDocument(w, r, domain.Internal)
+}
+
+func (h *Handler) checkDocumentExternal(w http.ResponseWriter, r *http.Request) {
+	h.checkDocument(w, r, domain.External)
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"net/http"
+
+	"necutya/faker/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initEmailChangeRoutes(router *mux.Router, publicChain, privateChain alice.Chain) {
+	router.Handle("/email/revert", publicChain.ThenFunc(h.emailChangeRevert)).Methods(http.MethodPost, http.MethodOptions)
+
+	emailRouter := router.PathPrefix("/users/{user_id}/email").Subrouter()
+
+	emailRouter.Handle("", privateChain.ThenFunc(h.emailChangeRequest)).Methods(http.MethodPost, http.MethodOptions)
+	emailRouter.Handle("/confirm", privateChain.ThenFunc(h.emailChangeConfirm)).Methods(http.MethodPost, http.MethodOptions)
+}
+
+type emailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=64"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type emailChangeConfirmRequest struct {
+	Code string `json:"code" validate:"required,min=8"`
+}
+
+type emailChangeRevertRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+func (h *Handler) emailChangeRequest(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input emailChangeRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	err = h.services.Auth.RequestEmailChange(r.Context(), userID.(string), service.EmailChangeInput(input))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusOK)
+}
+
+// emailChangeConfirm changes the email, all sessions are revoked so the user has to sign in again.
+func (h *Handler) emailChangeConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input emailChangeConfirmRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	user, err := h.services.Auth.ConfirmEmailChange(r.Context(), userID.(string), input.Code, GetUserIPAddress(r))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertUserToUserResponse(user))
+}
+
+func (h *Handler) emailChangeRevert(w http.ResponseWriter, r *http.Request) {
+	var input emailChangeRevertRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	err = h.services.Auth.RevertEmailChange(r.Context(), input.Token)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusOK)
+}
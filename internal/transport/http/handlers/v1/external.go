@@ -6,26 +6,160 @@ import (
 	reqContext "necutya/faker/internal/context"
 	"necutya/faker/internal/domain/domain"
 	"necutya/faker/internal/service"
+	"necutya/faker/pkg/logger"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 )
 
 func (h *Handler) initExternalRoutes(router *mux.Router, externalPrivateChain alice.Chain) {
-	router.Handle("/check-message", externalPrivateChain.ThenFunc(h.checkMessageExternal)).Methods(http.MethodPost, http.MethodOptions)
+	var (
+		checkWriteChain  = externalPrivateChain.Append(h.RequireScope(domain.CheckWriteScope))
+		historyReadChain = externalPrivateChain.Append(h.RequireScope(domain.HistoryReadScope))
+	)
+
+	router.Handle("/check-message", checkWriteChain.ThenFunc(h.checkMessageExternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-messages", checkWriteChain.ThenFunc(h.checkMessagesExternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-document", checkWriteChain.ThenFunc(h.checkDocumentExternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-jobs", checkWriteChain.ThenFunc(h.checkJobCreateExternal)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/check-jobs/{job_id}", checkWriteChain.ThenFunc(h.checkJobGet)).Methods(http.MethodGet)
+
+	router.Handle("/checks", historyReadChain.ThenFunc(h.historyGetMany)).Methods(http.MethodGet)
+	router.Handle("/checks/{check_id}", historyReadChain.ThenFunc(h.historyGetOne)).Methods(http.MethodGet)
 }
 
 type checkMessageRequest struct {
-	Message  string `json:"message" validate:"required,min=3"`
-	SaveToDb bool   `json:"save_to_db"`
+	Message      string `json:"message" validate:"required,min=3"`
+	SaveToDb     bool   `json:"save_to_db"`
+	Segmentation string `json:"segmentation" validate:"omitempty,oneof=sentence paragraph"`
 }
 
 type checkMessageResponse struct {
-	Message          string  `json:"message"`
+	Message          string                    `json:"message"`
+	IsGenerated      bool                      `json:"is_generated"`
+	GeneratedPercent float64                   `json:"generated_percent"`
+	Segments         []*messageSegmentResponse `json:"segments,omitempty"`
+	Detectors        []*detectorScoreResponse  `json:"detectors,omitempty"`
+	Cached           bool                      `json:"cached"`
+
+	Language           string  `json:"language"`
+	LanguageConfidence float64 `json:"language_confidence"`
+}
+
+type detectorScoreResponse struct {
+	Detector         string  `json:"detector"`
+	IsGenerated      bool    `json:"is_generated"`
+	GeneratedPercent float64 `json:"generated_percent"`
+	Error            string  `json:"error,omitempty"`
+}
+
+type messageSegmentResponse struct {
+	Start            int     `json:"start"`
+	End              int     `json:"end"`
+	Text             string  `json:"text"`
 	IsGenerated      bool    `json:"is_generated"`
 	GeneratedPercent float64 `json:"generated_percent"`
 }
 
+func convertCheckMessageToResponse(output *service.CheckMessageOutput) *checkMessageResponse {
+	var segments []*messageSegmentResponse
+
+	if len(output.Segments) > 0 {
+		segments = make([]*messageSegmentResponse, len(output.Segments))
+
+		for i := range output.Segments {
+			segment := messageSegmentResponse(*output.Segments[i])
+			segments[i] = &segment
+		}
+	}
+
+	detectors := make([]*detectorScoreResponse, len(output.Detectors))
+	for i := range output.Detectors {
+		detector := detectorScoreResponse(*output.Detectors[i])
+		detectors[i] = &detector
+	}
+
+	return &checkMessageResponse{
+		Message:          output.Message,
+		IsGenerated:      output.IsGenerated,
+		GeneratedPercent: output.GeneratedPercent,
+		Segments:         segments,
+		Detectors:        detectors,
+		Cached:           output.Cached,
+
+		Language:           output.Language,
+		LanguageConfidence: output.LanguageConfidence,
+	}
+}
+
+type checkMessagesRequest struct {
+	Messages     []checkMessagesItemRequest `json:"messages" validate:"required,min=1,max=100,unique=ID,dive"`
+	SaveToDb     bool                       `json:"save_to_db"`
+	Segmentation string                     `json:"segmentation" validate:"omitempty,oneof=sentence paragraph"`
+}
+
+type checkMessagesItemRequest struct {
+	ID      string `json:"id" validate:"required,max=64"`
+	Message string `json:"message" validate:"required,min=3"`
+}
+
+type checkMessagesResponse struct {
+	Results []*checkMessagesItemResponse `json:"results"`
+}
+
+type checkMessagesItemResponse struct {
+	ID        string                `json:"id"`
+	Result    *checkMessageResponse `json:"result,omitempty"`
+	ErrorCode string                `json:"error_code,omitempty"`
+	Error     string                `json:"error,omitempty"`
+}
+
+func convertCheckMessagesInput(input *checkMessagesRequest) *service.CheckMessagesInput {
+	messages := make([]service.CheckMessagesItemInput, len(input.Messages))
+
+	for i := range input.Messages {
+		messages[i] = service.CheckMessagesItemInput(input.Messages[i])
+	}
+
+	return &service.CheckMessagesInput{
+		Messages:     messages,
+		SaveToDb:     input.SaveToDb,
+		Segmentation: input.Segmentation,
+	}
+}
+
+func convertCheckMessagesToResponse(outputs []*service.CheckMessagesItemOutput) *checkMessagesResponse {
+	results := make([]*checkMessagesItemResponse, len(outputs))
+
+	for i := range outputs {
+		results[i] = &checkMessagesItemResponse{
+			ID: outputs[i].ID,
+		}
+
+		if outputs[i].Err != nil {
+			results[i].ErrorCode, results[i].Error = convertCheckMessagesItemError(outputs[i].Err)
+			continue
+		}
+
+		results[i].Result = convertCheckMessageToResponse(outputs[i].Result)
+	}
+
+	return &checkMessagesResponse{
+		Results: results,
+	}
+}
+
+// convertCheckMessagesItemError returns the code and the message of the failed item,
+// the errors not known to the clients are logged and reported as internal.
+func convertCheckMessagesItemError(err error) (string, string) {
+	code, message := service.CheckItemError(err)
+	if code == service.InternalItemErrorCode {
+		logger.Error(err)
+	}
+
+	return code, message
+}
+
 func (h *Handler) checkMessageExternal(w http.ResponseWriter, r *http.Request) {
 	var input checkMessageRequest
 
@@ -48,8 +182,9 @@ func (h *Handler) checkMessageExternal(w http.ResponseWriter, r *http.Request) {
 		reqContext.GetUserID(ctx),
 		reqContext.GetPlanID(ctx),
 		&service.CheckMessageInput{
-			Message:  input.Message,
-			SaveToDb: input.SaveToDb,
+			Message:      input.Message,
+			SaveToDb:     input.SaveToDb,
+			Segmentation: input.Segmentation,
 		},
 		domain.External,
 	)
@@ -58,5 +193,37 @@ func (h *Handler) checkMessageExternal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	SendResponse(w, http.StatusOK, checkMessageResponse(*res))
+	SendResponse(w, http.StatusOK, convertCheckMessageToResponse(res))
+}
+
+func (h *Handler) checkMessagesExternal(w http.ResponseWriter, r *http.Request) {
+	var input checkMessagesRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	res, err := h.services.AI.CheckMessages(
+		ctx,
+		reqContext.GetUserID(ctx),
+		reqContext.GetPlanID(ctx),
+		convertCheckMessagesInput(&input),
+		domain.External,
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertCheckMessagesToResponse(res))
 }
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"net"
 	"net/http"
 
 	"necutya/faker/internal/service"
@@ -16,16 +17,18 @@ const (
 )
 
 type Handler struct {
-	services *service.Service
-	router   *mux.Router
-	validate *validator.Validate
+	services       *service.Service
+	router         *mux.Router
+	validate       *validator.Validate
+	trustedProxies []*net.IPNet
 }
 
-func NewHandler(services *service.Service, router *mux.Router, validate *validator.Validate) *Handler {
+func NewHandler(services *service.Service, router *mux.Router, validate *validator.Validate, trustedProxies []*net.IPNet) *Handler {
 	return &Handler{
-		services: services,
-		router:   router,
-		validate: validate,
+		services:       services,
+		router:         router,
+		validate:       validate,
+		trustedProxies: trustedProxies,
 	}
 }
 
@@ -36,6 +39,10 @@ func (h *Handler) Init(URLPrefix, externalURLPrefix string, CORSAllowedHost []st
 		externalRouter = router.PathPrefix(externalURLPrefix).Subrouter()
 	)
 
+	router.Use(h.RealIPMiddleware)
+
+	h.initWellKnownRoutes(router)
+
 	h.initInternal(internalRouter, CORSAllowedHost)
 
 	h.initExternal(externalRouter)
@@ -50,8 +57,6 @@ func (h *Handler) initInternal(router *mux.Router, CORSAllowedHost []string) {
 		publicChain   = alice.New()
 		authChain     = publicChain.Append(h.AuthMiddleware)
 		authUserChain = authChain.Append(h.UserMiddleware)
-
-		adminChain = publicChain.Append(h.AuthMiddleware, h.AdminMiddleware)
 	)
 
 	v1InternalRouter.Handle("/ping", publicChain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -60,7 +65,15 @@ func (h *Handler) initInternal(router *mux.Router, CORSAllowedHost []string) {
 
 	h.initUsersRoutes(v1InternalRouter, publicChain, authUserChain)
 	h.initCheckRoutes(v1InternalRouter, authChain)
-	h.initAdminRoutes(v1InternalRouter, adminChain)
+	h.initHistoryRoutes(v1InternalRouter, authUserChain)
+	h.initAPIKeysRoutes(v1InternalRouter, authUserChain)
+	h.initTwoFactorRoutes(v1InternalRouter, authUserChain)
+	h.initSessionsRoutes(v1InternalRouter, authUserChain)
+	h.initEmailChangeRoutes(v1InternalRouter, publicChain, authUserChain)
+	h.initOAuthRoutes(v1InternalRouter, publicChain, authUserChain)
+	h.initSubscriptionRoutes(v1InternalRouter, authUserChain)
+	h.initOrderRoutes(v1InternalRouter, authUserChain)
+	h.initAdminRoutes(v1InternalRouter, authChain)
 	h.initPaymentRoutes(v1InternalRouter, publicChain)
 	h.initPlansRoutes(v1InternalRouter, publicChain)
 	h.initFeedbacksRoutes(v1InternalRouter, publicChain)
@@ -0,0 +1,203 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	reqContext "necutya/faker/internal/context"
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/domain/dto"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initHistoryRoutes(router *mux.Router, privateChain alice.Chain) {
+	checksRouter := router.PathPrefix("/users/{user_id}/checks").Subrouter()
+
+	checksRouter.Handle("", privateChain.ThenFunc(h.historyGetMany)).Methods(http.MethodGet)
+	checksRouter.Handle("", privateChain.ThenFunc(h.historyDeleteAll)).Methods(http.MethodDelete, http.MethodOptions)
+	checksRouter.Handle("/{check_id}", privateChain.ThenFunc(h.historyGetOne)).Methods(http.MethodGet)
+	checksRouter.Handle("/{check_id}", privateChain.ThenFunc(h.historyDelete)).Methods(http.MethodDelete, http.MethodOptions)
+}
+
+type historyItemResponse struct {
+	ID               string  `json:"id"`
+	Text             string  `json:"text"`
+	RequestType      string  `json:"request_type"`
+	IsGenerated      bool    `json:"is_generated"`
+	GeneratedPercent float64 `json:"generated_percent"`
+	ModelVersion     string  `json:"model_version"`
+	Language         string  `json:"language"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+type historyManyResponse struct {
+	Items  []*historyItemResponse `json:"items"`
+	Total  int64                  `json:"total"`
+	Limit  int64                  `json:"limit"`
+	Offset int64                  `json:"offset"`
+}
+
+func convertMessageToHistoryResponse(message *domain.Message) *historyItemResponse {
+	return &historyItemResponse{
+		ID:               message.ID,
+		Text:             message.Text,
+		RequestType:      string(message.RequestType),
+		IsGenerated:      message.IsGenerated,
+		GeneratedPercent: message.GeneratedPercent,
+		ModelVersion:     message.ModelVersion,
+		Language:         message.Language,
+		CreatedAt:        message.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func convertMessagesToHistoryResponse(messages []*domain.Message) []*historyItemResponse {
+	items := make([]*historyItemResponse, len(messages))
+
+	for i := range messages {
+		items[i] = convertMessageToHistoryResponse(messages[i])
+	}
+
+	return items
+}
+
+// parseHistoryFilter reads `limit`, `offset`, `from`, `to`, `is_generated` and `q` query params.
+func parseHistoryFilter(r *http.Request, userID string) (*dto.MessageFilter, error) {
+	filter := &dto.MessageFilter{
+		UserID: userID,
+	}
+
+	limit, err := GetQueryParam(r, "limit", intType)
+	if err != nil {
+		return nil, err
+	}
+	if limit != nil {
+		filter.Limit = int64(limit.(int))
+	}
+
+	offset, err := GetQueryParam(r, "offset", intType)
+	if err != nil {
+		return nil, err
+	}
+	if offset != nil {
+		filter.Offset = int64(offset.(int))
+	}
+
+	from, err := GetQueryParam(r, "from", dateType)
+	if err != nil {
+		return nil, err
+	}
+	if from != nil {
+		fromDate := from.(time.Time)
+		filter.From = &fromDate
+	}
+
+	to, err := GetQueryParam(r, "to", dateType)
+	if err != nil {
+		return nil, err
+	}
+	if to != nil {
+		toDate := domain.DayAfter(to.(time.Time))
+		filter.To = &toDate
+	}
+
+	isGenerated, err := GetQueryParam(r, "is_generated", boolType)
+	if err != nil {
+		return nil, err
+	}
+	if isGenerated != nil {
+		isGeneratedValue := isGenerated.(bool)
+		filter.IsGenerated = &isGeneratedValue
+	}
+
+	search, err := GetQueryParam(r, "q", stringType)
+	if err != nil {
+		return nil, err
+	}
+	if search != nil {
+		filter.Search = search.(string)
+	}
+
+	return filter, nil
+}
+
+// historyGetMany takes the user from the context, so it serves both the user's and the external routes.
+func (h *Handler) historyGetMany(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, err := parseHistoryFilter(r, reqContext.GetUserID(ctx))
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	messages, total, err := h.services.History.GetMany(ctx, *filter)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, historyManyResponse{
+		Items:  convertMessagesToHistoryResponse(messages),
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+func (h *Handler) historyGetOne(w http.ResponseWriter, r *http.Request) {
+	checkID, err := GetPathVar(r, "check_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	message, err := h.services.History.GetOne(ctx, reqContext.GetUserID(ctx), checkID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertMessageToHistoryResponse(message))
+}
+
+func (h *Handler) historyDelete(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	checkID, err := GetPathVar(r, "check_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.services.History.Delete(r.Context(), userID.(string), checkID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
+
+func (h *Handler) historyDeleteAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.services.History.DeleteAll(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
@@ -1,11 +1,28 @@
 package v1
 
 import (
+	"net"
 	"net/http"
 
 	reqContext "necutya/faker/internal/context"
+	core "necutya/faker/internal/domain"
+	"necutya/faker/internal/domain/domain"
+
+	"github.com/justinas/alice"
 )
 
+// RealIPMiddleware - take the address of the client from the forwarded headers if the request came
+// through a trusted proxy, so GetUserIPAddress returns the client and not the proxy.
+func (h *Handler) RealIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ipAddress := forwardedIPAddress(r, h.trustedProxies); ipAddress != "" {
+			r.RemoteAddr = net.JoinHostPort(ipAddress, "0")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // AuthMiddleware - authenticate User by JWT token and add to context his ID and session ID.
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +46,8 @@ func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 		ctx = reqContext.WithPlanID(ctx, tokenInfo.PlanID)
 		ctx = reqContext.WithSessionID(ctx, tokenInfo.SessionID)
 		ctx = reqContext.WithTokenID(ctx, tokenInfo.TokenID)
+		ctx = reqContext.WithRole(ctx, tokenInfo.Role)
+		ctx = reqContext.WithPermissions(ctx, tokenInfo.Permissions)
 
 		SetUserIDHeader(r, tokenInfo.UserID)
 
@@ -54,7 +73,26 @@ func (h *Handler) UserMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
+// RequirePermission - allow the request only if the role of the user has the permission,
+// must go after AuthMiddleware. Permissions are taken from the access token claims, or from the user's
+// role for the tokens issued before they were embedded.
+func (h *Handler) RequirePermission(permission domain.Permission) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, p := range reqContext.GetPermissions(r.Context()) {
+				if p == string(permission) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			SendHTTPError(w, core.ErrInsufficientPermission)
+		})
+	}
+}
+
+// TwoFactorRequirementMiddleware - reject users whose role requires the second factor which is not enabled yet.
+func (h *Handler) TwoFactorRequirementMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := reqContext.GetUserID(r.Context())
 		user, err := h.services.User.GetOne(r.Context(), userID)
@@ -63,8 +101,8 @@ func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		if !user.IsAdmin() {
-			SendEmptyResponse(w, http.StatusUnauthorized)
+		if err = h.services.TwoFactor.CheckRequirement(r.Context(), user); err != nil {
+			SendHTTPError(w, err)
 			return
 		}
 
@@ -72,21 +110,43 @@ func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// ExternalAuthMiddleware - authenticate User by external token.
+// ExternalAuthMiddleware - authenticate User by API key and add to context his ID and key scopes.
 func (h *Handler) ExternalAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var (
-			externalToken = GetExternalAuthorizationHeader(r)
+			apiKey = GetExternalAuthorizationHeader(r)
 		)
 
-		userID, err := h.services.Auth.ValidateExternalToken(r.Context(), externalToken)
+		key, err := h.services.APIKey.Authenticate(r.Context(), apiKey, GetUserIPAddress(r))
 		if err != nil {
 			SendHTTPError(w, err)
 			return
 		}
 
-		ctx := reqContext.WithUserID(r.Context(), userID)
+		scopes := make([]string, len(key.Scopes))
+		for i := range key.Scopes {
+			scopes[i] = string(key.Scopes[i])
+		}
+
+		ctx := reqContext.WithUserID(r.Context(), key.UserID)
+		ctx = reqContext.WithScopes(ctx, scopes)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireScope - allow the request only if the API key has the scope, must go after ExternalAuthMiddleware.
+func (h *Handler) RequireScope(scope domain.Scope) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, s := range reqContext.GetScopes(r.Context()) {
+				if s == string(scope) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			SendHTTPError(w, core.ErrInsufficientScope)
+		})
+	}
+}
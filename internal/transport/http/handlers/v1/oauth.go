@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"net/http"
+
+	"necutya/faker/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initOAuthRoutes(router *mux.Router, publicChain, privateChain alice.Chain) {
+	oauthRouter := router.PathPrefix("/oauth").Subrouter()
+
+	oauthRouter.Handle("/providers", publicChain.ThenFunc(h.oauthGetProviders)).Methods(http.MethodGet)
+	oauthRouter.Handle("/{provider}", publicChain.ThenFunc(h.oauthAuthorize)).Methods(http.MethodGet)
+	oauthRouter.Handle("/{provider}/callback", publicChain.ThenFunc(h.oauthSignIn)).Methods(http.MethodPost, http.MethodOptions)
+
+	identitiesRouter := router.PathPrefix("/users/{user_id}/identities").Subrouter()
+
+	identitiesRouter.Handle("/{provider}", privateChain.ThenFunc(h.oauthLinkAuthorize)).Methods(http.MethodPost, http.MethodOptions)
+	identitiesRouter.Handle("/{provider}", privateChain.ThenFunc(h.oauthUnlink)).Methods(http.MethodDelete)
+	identitiesRouter.Handle("/{provider}/callback", privateChain.ThenFunc(h.oauthLink)).Methods(http.MethodPost, http.MethodOptions)
+}
+
+type oauthProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type oauthAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type oauthCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=256"`
+}
+
+func (h *Handler) oauthGetProviders(w http.ResponseWriter, r *http.Request) {
+	SendResponse(w, http.StatusOK, oauthProvidersResponse{
+		Providers: h.services.OAuth.Providers(),
+	})
+}
+
+func (h *Handler) oauthAuthorize(w http.ResponseWriter, r *http.Request) {
+	provider, err := GetPathVar(r, "provider", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	authorizationURL, err := h.services.OAuth.AuthorizationURL(r.Context(), provider.(string), "")
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, oauthAuthorizeResponse{AuthorizationURL: authorizationURL})
+}
+
+func (h *Handler) oauthSignIn(w http.ResponseWriter, r *http.Request) {
+	provider, err := GetPathVar(r, "provider", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input oauthCallbackRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	output, err := h.services.OAuth.SignIn(r.Context(), service.OAuthCallbackInput{
+		Provider:  provider.(string),
+		State:     input.State,
+		Code:      input.Code,
+		Client:    GetUserClient(r),
+		IPAddress: GetUserIPAddress(r),
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	statusCode, response := convertSignInOutputToResponse(output)
+
+	SendResponse(w, statusCode, response)
+}
+
+func (h *Handler) oauthLinkAuthorize(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	provider, err := GetPathVar(r, "provider", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	authorizationURL, err := h.services.OAuth.AuthorizationURL(r.Context(), provider.(string), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, oauthAuthorizeResponse{AuthorizationURL: authorizationURL})
+}
+
+func (h *Handler) oauthLink(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	provider, err := GetPathVar(r, "provider", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input oauthCallbackRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	user, err := h.services.OAuth.Link(r.Context(), userID.(string), service.OAuthCallbackInput{
+		Provider: provider.(string),
+		State:    input.State,
+		Code:     input.Code,
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusCreated, convertUserToUserResponse(user))
+}
+
+func (h *Handler) oauthUnlink(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	provider, err := GetPathVar(r, "provider", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.services.OAuth.Unlink(r.Context(), userID.(string), provider.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+const contentTypePDF = "application/pdf"
+
+func (h *Handler) initOrderRoutes(router *mux.Router, privateChain alice.Chain) {
+	orderRouter := router.PathPrefix("/users/{user_id}/orders").Subrouter()
+
+	orderRouter.Handle("", privateChain.ThenFunc(h.orderGetMany)).Methods(http.MethodGet)
+	orderRouter.Handle("/{order_id}", privateChain.ThenFunc(h.orderGetOne)).Methods(http.MethodGet)
+	orderRouter.Handle("/{order_id}/invoice", privateChain.ThenFunc(h.orderGetInvoice)).Methods(http.MethodGet)
+}
+
+// orderResponse has the price of the plan in Amount, the other amounts are in cents.
+type orderResponse struct {
+	ID             string `json:"id"`
+	PlanID         string `json:"plan_id"`
+	Status         string `json:"status"`
+	Amount         int    `json:"amount"`
+	Credit         int64  `json:"credit"`
+	Total          int64  `json:"total"`
+	RefundedAmount int64  `json:"refunded_amount"`
+	Currency       string `json:"currency"`
+	Description    string `json:"description"`
+	Renewal        bool   `json:"renewal"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type orderDetailsResponse struct {
+	*orderResponse
+	InvoiceNumber *string                `json:"invoice_number,omitempty"`
+	Transactions  []*transactionResponse `json:"transactions"`
+}
+
+type transactionResponse struct {
+	Status       string `json:"status"`
+	RefundAmount int64  `json:"refund_amount,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type adminOrderRefundRequest struct {
+	// Amount is in cents, the whole amount left to refund is refunded if it is omitted.
+	Amount  int64  `json:"amount" validate:"min=0"`
+	Comment string `json:"comment" validate:"max=255"`
+}
+
+type ordersResponse struct {
+	Orders []*orderResponse `json:"orders"`
+}
+
+func convertOrderToResponse(order *domain.Order) *orderResponse {
+	return &orderResponse{
+		ID:             order.ID,
+		PlanID:         order.PlanID,
+		Status:         order.Status,
+		Amount:         order.Amount,
+		Credit:         order.Credit,
+		Total:          order.Total(),
+		RefundedAmount: order.RefundedAmount(),
+		Currency:       order.Currency,
+		Description:    order.Description,
+		Renewal:        order.SubscriptionID != "",
+		CreatedAt:      order.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func convertOrderToDetailsResponse(order *domain.Order, invoice *domain.Invoice) *orderDetailsResponse {
+	resp := &orderDetailsResponse{
+		orderResponse: convertOrderToResponse(order),
+		Transactions:  make([]*transactionResponse, len(order.Transactions)),
+	}
+
+	if invoice != nil {
+		resp.InvoiceNumber = &invoice.Number
+	}
+
+	for i, transaction := range order.Transactions {
+		resp.Transactions[i] = &transactionResponse{
+			Status:       transaction.Status,
+			RefundAmount: transaction.RefundAmount,
+			CreatedAt:    transaction.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	return resp
+}
+
+func (h *Handler) orderGetMany(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	orders, err := h.services.Order.GetMany(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	resp := ordersResponse{Orders: make([]*orderResponse, len(orders))}
+	for i := range orders {
+		resp.Orders[i] = convertOrderToResponse(orders[i])
+	}
+
+	SendResponse(w, http.StatusOK, resp)
+}
+
+func (h *Handler) orderGetOne(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	orderID, err := GetPathVar(r, "order_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	order, invoice, err := h.services.Order.GetOne(r.Context(), userID.(string), orderID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertOrderToDetailsResponse(order, invoice))
+}
+
+// orderGetInvoice downloads the invoice of the paid order as PDF.
+func (h *Handler) orderGetInvoice(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	orderID, err := GetPathVar(r, "order_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	invoice, data, err := h.services.Order.GetInvoice(r.Context(), userID.(string), orderID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendFile(w, contentTypePDF, invoice.FileName(), data)
+}
+
+// adminOrderRefund refunds the order in full or in part through the payment provider.
+func (h *Handler) adminOrderRefund(w http.ResponseWriter, r *http.Request) {
+	orderID, err := GetPathVar(r, "order_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input adminOrderRefundRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	order, err := h.services.Payments.Refund(r.Context(), orderID.(string), input.Amount, input.Comment)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertOrderToDetailsResponse(order, nil))
+}
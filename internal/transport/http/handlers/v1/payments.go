@@ -1,29 +1,64 @@
 package v1
 
 import (
+	"io/ioutil"
 	"net/http"
 
-	"necutya/faker/pkg/payments/fondy"
-
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 )
 
 func (h *Handler) initPaymentRoutes(router *mux.Router, publicChain alice.Chain) {
 	paymentsRouter := router.PathPrefix("/payments").Subrouter()
-	paymentsRouter.Handle("/subscribe/callback", publicChain.ThenFunc(h.paymentsFondyCallback)).Methods(http.MethodPost, http.MethodOptions)
+	paymentsRouter.Handle("/subscribe/callback", publicChain.ThenFunc(h.paymentsWebhook)).Methods(http.MethodPost, http.MethodOptions)
+	paymentsRouter.Handle("/fake/{order_id}", publicChain.ThenFunc(h.paymentsSimulate)).Methods(http.MethodPost, http.MethodOptions)
+}
+
+type paymentsSimulateRequest struct {
+	Outcome string `json:"outcome" validate:"required,oneof=approve decline expire"`
+}
+
+// paymentsWebhook processes the webhook of the configured provider, the body is verified as it was sent.
+func (h *Handler) paymentsWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err = h.services.Payments.ProcessWebhook(r.Context(), r.Header, body)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusOK)
 }
 
-func (h *Handler) paymentsFondyCallback(w http.ResponseWriter, r *http.Request) {
-	var input fondy.Callback
+// paymentsSimulate finishes the checkout of the order with the outcome, it works only with the fake provider.
+func (h *Handler) paymentsSimulate(w http.ResponseWriter, r *http.Request) {
+	orderID, err := GetPathVar(r, "order_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
 
-	err := UnmarshalRequest(r, &input)
+	var input paymentsSimulateRequest
+
+	err = UnmarshalRequest(r, &input)
 	if err != nil {
 		SendEmptyResponse(w, http.StatusBadRequest)
 		return
 	}
 
-	err = h.services.Payments.ProcessCallback(r.Context(), input)
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	err = h.services.Payments.SimulatePayment(r.Context(), orderID.(string), input.Outcome)
 	if err != nil {
 		SendHTTPError(w, err)
 		return
@@ -25,6 +25,10 @@ type planResponse struct {
 	Duration             int      `json:"duration"`
 	InternalRequestCount int      `json:"internal_request_count"`
 	ExternalRequestCount int      `json:"external_request_count"`
+	MaxFileSize          int      `json:"max_file_size"`
+	MaxDocumentLength    int      `json:"max_document_length"`
+	CountCachedRequests  bool     `json:"count_cached_requests"`
+	SupportedLanguages   []string `json:"supported_languages"`
 }
 
 func convertPlansToPlansManyResponse(plans []*domain.Plan) []*planResponse {
@@ -47,6 +51,10 @@ func convertPlanToPlanResponse(plan *domain.Plan) *planResponse {
 		Duration:             plan.Duration,
 		InternalRequestCount: plan.InternalRequestsCount,
 		ExternalRequestCount: plan.ExternalRequestsCount,
+		MaxFileSize:          plan.MaxFileSize,
+		MaxDocumentLength:    plan.MaxDocumentLength,
+		CountCachedRequests:  plan.CountCachedRequests,
+		SupportedLanguages:   plan.SupportedLanguages,
 	}
 }
 
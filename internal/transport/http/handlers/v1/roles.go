@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"net/http"
+
+	"necutya/faker/internal/domain/domain"
+	"necutya/faker/internal/service"
+)
+
+type adminRoleResponse struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+	Editable    bool     `json:"editable"`
+	UpdatedAt   *string  `json:"updated_at"`
+}
+
+type adminRolesResponse struct {
+	Items []*adminRoleResponse `json:"items"`
+}
+
+type adminUpdateRoleRequest struct {
+	Permissions []string `json:"permissions" validate:"unique,dive,oneof=users:read feedback:resolve plans:write reports:read settings:write orders:refund"`
+}
+
+type adminSetUserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin support billing-manager analyst basic"`
+}
+
+func convertRolePermissionsToResponse(rolePermissions *domain.RolePermissions) *adminRoleResponse {
+	permissions := make([]string, len(rolePermissions.Permissions))
+	for i := range rolePermissions.Permissions {
+		permissions[i] = string(rolePermissions.Permissions[i])
+	}
+
+	var updatedAt *string
+	if !rolePermissions.UpdatedAt.IsZero() {
+		updatedAt = formatOptionalTime(&rolePermissions.UpdatedAt)
+	}
+
+	return &adminRoleResponse{
+		Role:        string(rolePermissions.Role),
+		Permissions: permissions,
+		Editable:    rolePermissions.Role.IsEditable(),
+		UpdatedAt:   updatedAt,
+	}
+}
+
+func (h *Handler) adminGetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.services.Role.GetMany(r.Context())
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	items := make([]*adminRoleResponse, len(roles))
+	for i := range roles {
+		items[i] = convertRolePermissionsToResponse(roles[i])
+	}
+
+	SendResponse(w, http.StatusOK, adminRolesResponse{Items: items})
+}
+
+func (h *Handler) adminUpdateRole(w http.ResponseWriter, r *http.Request) {
+	role, err := GetPathVar(r, "role", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input adminUpdateRoleRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	permissions := make([]domain.Permission, len(input.Permissions))
+	for i := range input.Permissions {
+		permissions[i] = domain.Permission(input.Permissions[i])
+	}
+
+	rolePermissions, err := h.services.Role.Update(r.Context(), domain.Role(role.(string)), permissions)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertRolePermissionsToResponse(rolePermissions))
+}
+
+// adminSetUserRole assigns the role to the user, the user is signed out everywhere.
+func (h *Handler) adminSetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input adminSetUserRoleRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	user, err := h.services.Role.SetUserRole(r.Context(), userID.(string), domain.Role(input.Role))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertUserToUserResponse(user))
+}
+
+type adminUpdatePlanRequest struct {
+	Description          string   `json:"description" validate:"required"`
+	Options              []string `json:"options"`
+	Price                int      `json:"price" validate:"min=0"`
+	Duration             int      `json:"duration" validate:"min=0"`
+	InternalRequestCount int      `json:"internal_request_count" validate:"min=0"`
+	ExternalRequestCount int      `json:"external_request_count" validate:"min=0"`
+	MaxFileSize          int      `json:"max_file_size" validate:"min=0"`
+	MaxDocumentLength    int      `json:"max_document_length" validate:"min=0"`
+	CountCachedRequests  bool     `json:"count_cached_requests"`
+	SupportedLanguages   []string `json:"supported_languages" validate:"unique,dive,len=2"`
+}
+
+func (h *Handler) adminUpdatePlan(w http.ResponseWriter, r *http.Request) {
+	planID, err := GetPathVar(r, "plan_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input adminUpdatePlanRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	plan, err := h.services.Plan.Update(r.Context(), planID.(string), service.PlanUpdateInput{
+		Description:           input.Description,
+		Options:               input.Options,
+		Price:                 input.Price,
+		Duration:              input.Duration,
+		InternalRequestsCount: input.InternalRequestCount,
+		ExternalRequestsCount: input.ExternalRequestCount,
+		MaxFileSize:           input.MaxFileSize,
+		MaxDocumentLength:     input.MaxDocumentLength,
+		CountCachedRequests:   input.CountCachedRequests,
+		SupportedLanguages:    input.SupportedLanguages,
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertPlanToPlanResponse(plan))
+}
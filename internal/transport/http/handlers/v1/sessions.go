@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	reqContext "necutya/faker/internal/context"
+	"necutya/faker/internal/domain/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initSessionsRoutes(router *mux.Router, privateChain alice.Chain) {
+	sessionsRouter := router.PathPrefix("/users/{user_id}/sessions").Subrouter()
+
+	sessionsRouter.Handle("", privateChain.ThenFunc(h.sessionGetMany)).Methods(http.MethodGet)
+	sessionsRouter.Handle("", privateChain.ThenFunc(h.sessionRevokeOthers)).Methods(http.MethodDelete, http.MethodOptions)
+	sessionsRouter.Handle("/{session_id}", privateChain.ThenFunc(h.sessionRevoke)).Methods(http.MethodDelete, http.MethodOptions)
+}
+
+type sessionResponse struct {
+	ID         string `json:"id"`
+	Client     string `json:"client"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"`
+}
+
+type sessionsResponse struct {
+	Items []*sessionResponse `json:"items"`
+}
+
+func convertSessionsToResponse(sessions []*domain.Session, currentSessionID uuid.UUID) *sessionsResponse {
+	items := make([]*sessionResponse, len(sessions))
+
+	for i, session := range sessions {
+		lastUsedAt := session.LastUsedAt
+		if lastUsedAt.IsZero() {
+			lastUsedAt = session.CreatedAt
+		}
+
+		items[i] = &sessionResponse{
+			ID:         session.ID.String(),
+			Client:     session.Client,
+			IPAddress:  session.IpAddress,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastUsedAt: lastUsedAt.Format(time.RFC3339),
+			ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+			Current:    session.ID == currentSessionID,
+		}
+	}
+
+	return &sessionsResponse{
+		Items: items,
+	}
+}
+
+func (h *Handler) sessionGetMany(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	sessions, err := h.services.Auth.GetSessions(ctx, userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertSessionsToResponse(sessions, reqContext.GetSessionID(ctx)))
+}
+
+func (h *Handler) sessionRevoke(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	sessionIDParam, err := GetPathVar(r, "session_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	sessionID, err := uuid.Parse(sessionIDParam.(string))
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.services.Auth.RevokeSession(r.Context(), userID.(string), sessionID)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
+
+// sessionRevokeOthers signs the user out on all devices except the one making the request.
+func (h *Handler) sessionRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	err = h.services.Auth.RevokeOtherSessions(ctx, userID.(string), reqContext.GetSessionID(ctx))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"necutya/faker/internal/domain/domain"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initSubscriptionRoutes(router *mux.Router, privateChain alice.Chain) {
+	subscriptionRouter := router.PathPrefix("/users/{user_id}/subscription").Subrouter()
+
+	subscriptionRouter.Handle("", privateChain.ThenFunc(h.subscriptionGet)).Methods(http.MethodGet)
+	subscriptionRouter.Handle("/cancel", privateChain.ThenFunc(h.subscriptionCancel)).Methods(http.MethodPost, http.MethodOptions)
+	subscriptionRouter.Handle("/resume", privateChain.ThenFunc(h.subscriptionResume)).Methods(http.MethodPost, http.MethodOptions)
+}
+
+type subscriptionResponse struct {
+	ID                 string `json:"id"`
+	PlanID             string `json:"plan_id"`
+	Status             string `json:"status"`
+	CurrentPeriodStart string `json:"current_period_start"`
+	CurrentPeriodEnd   string `json:"current_period_end"`
+	CancelAtPeriodEnd  bool   `json:"cancel_at_period_end"`
+}
+
+func convertSubscriptionToResponse(subscription *domain.Subscription) *subscriptionResponse {
+	return &subscriptionResponse{
+		ID:                 subscription.ID,
+		PlanID:             subscription.PlanID,
+		Status:             subscription.Status,
+		CurrentPeriodStart: subscription.CurrentPeriodStart.Format(time.RFC3339),
+		CurrentPeriodEnd:   subscription.CurrentPeriodEnd.Format(time.RFC3339),
+		CancelAtPeriodEnd:  subscription.CancelAtPeriodEnd,
+	}
+}
+
+func (h *Handler) subscriptionGet(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.services.Subscription.Get(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertSubscriptionToResponse(subscription))
+}
+
+// subscriptionCancel stops the renewal, the plan stays active until the end of the paid period.
+func (h *Handler) subscriptionCancel(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.services.Subscription.Cancel(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertSubscriptionToResponse(subscription))
+}
+
+func (h *Handler) subscriptionResume(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.services.Subscription.Resume(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertSubscriptionToResponse(subscription))
+}
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"net/http"
+
+	"necutya/faker/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+func (h *Handler) initTwoFactorRoutes(router *mux.Router, privateChain alice.Chain) {
+	twoFactorRouter := router.PathPrefix("/users/{user_id}/2fa").Subrouter()
+
+	twoFactorRouter.Handle("", privateChain.ThenFunc(h.twoFactorSetup)).Methods(http.MethodPost, http.MethodOptions)
+	twoFactorRouter.Handle("", privateChain.ThenFunc(h.twoFactorDisable)).Methods(http.MethodDelete)
+	twoFactorRouter.Handle("/confirm", privateChain.ThenFunc(h.twoFactorConfirm)).Methods(http.MethodPost, http.MethodOptions)
+	twoFactorRouter.Handle("/recovery-codes", privateChain.ThenFunc(h.twoFactorRegenerateRecoveryCodes)).Methods(http.MethodPost, http.MethodOptions)
+}
+
+type twoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,min=6,max=16"`
+}
+
+type twoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required,max=72"`
+	Code     string `json:"code" validate:"required,min=6,max=16"`
+}
+
+type twoFactorRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (h *Handler) twoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	output, err := h.services.TwoFactor.Setup(r.Context(), userID.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusCreated, twoFactorSetupResponse(*output))
+}
+
+func (h *Handler) twoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input twoFactorCodeRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	codes, err := h.services.TwoFactor.Confirm(r.Context(), userID.(string), input.Code)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, twoFactorRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) twoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input twoFactorDisableRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	err = h.services.TwoFactor.Disable(r.Context(), userID.(string), service.TwoFactorDisableInput{
+		Password: input.Password,
+		Code:     input.Code,
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusNoContent)
+}
+
+func (h *Handler) twoFactorRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	var input twoFactorCodeRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	codes, err := h.services.TwoFactor.RegenerateRecoveryCodes(r.Context(), userID.(string), input.Code)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusCreated, twoFactorRecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -3,6 +3,7 @@ package v1
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"necutya/faker/internal/context"
 	"necutya/faker/internal/domain/domain"
@@ -21,8 +22,12 @@ func (h *Handler) initUsersRoutes(router *mux.Router, publicChain, privateChain
 
 	router.Handle("/sign-up", publicChain.ThenFunc(h.userSignUp)).Methods(http.MethodPost, http.MethodOptions)
 	router.Handle("/sign-in", publicChain.ThenFunc(h.userSignIn)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/sign-in/2fa", publicChain.ThenFunc(h.userSignInTwoFactor)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/sign-in/magic-link", publicChain.ThenFunc(h.userRequestMagicLink)).Methods(http.MethodPost, http.MethodOptions)
+	router.Handle("/sign-in/magic-link/confirm", publicChain.ThenFunc(h.userSignInMagicLink)).Methods(http.MethodPost, http.MethodOptions)
 	usersRouter.Handle("/{user_id}/refresh", publicChain.ThenFunc(h.userRefresh)).Methods(http.MethodPost, http.MethodOptions)
 
+	usersRouter.Handle("/{user_email}/confirm", publicChain.ThenFunc(h.userResendEmailConfirmation)).Methods(http.MethodGet)
 	usersRouter.Handle("/{user_email}/confirm", publicChain.ThenFunc(h.userConfirmEmail)).Methods(http.MethodPost, http.MethodOptions)
 
 	usersRouter.Handle("/{user_email}/password-reset", publicChain.ThenFunc(h.passwordResetRequest)).Methods(http.MethodGet)
@@ -38,14 +43,15 @@ func (h *Handler) initUsersRoutes(router *mux.Router, publicChain, privateChain
 
 	usersRouter.Handle("/{user_id}/notification/{action:\\b*add|remove\\b*}", privateChain.ThenFunc(h.userUpdateNotification)).Methods(http.MethodPut, http.MethodPatch, http.MethodOptions)
 	usersRouter.Handle("/{user_id}/plan/update", privateChain.ThenFunc(h.userUpdatePlan)).Methods(http.MethodPut, http.MethodPatch, http.MethodOptions)
-	usersRouter.Handle("/{user_id}/external/regenerate", privateChain.ThenFunc(h.userRegenerateExternal)).Methods(http.MethodPut, http.MethodPatch, http.MethodOptions)
+	usersRouter.Handle("/{user_id}/webhook", privateChain.ThenFunc(h.userSetWebhook)).Methods(http.MethodPut, http.MethodPatch, http.MethodOptions)
+	usersRouter.Handle("/{user_id}/webhook", privateChain.ThenFunc(h.userRemoveWebhook)).Methods(http.MethodDelete)
 }
 
 type userSignUpRequest struct {
-	FirstName           string `json:"first_name" validate:"required"`
-	LastName            string `json:"last_name" validate:"required"`
+	FirstName           string `json:"first_name" validate:"required,invoice_name"`
+	LastName            string `json:"last_name" validate:"required,invoice_name"`
 	Email               string `json:"email" validate:"required,email,max=64"`
-	Password            string `json:"password" validate:"required,min=8,max=64"`
+	Password            string `json:"password" validate:"required"`
 	ReceiveNotification bool   `json:"receive_notification"`
 }
 
@@ -92,12 +98,15 @@ func (h *Handler) userSignUp(w http.ResponseWriter, r *http.Request) {
 
 type userSignInRequest struct {
 	Email    string `json:"email" validate:"required,email,max=64"`
-	Password string `json:"password" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 type userSignInResponse struct {
-	Tokens userSignInTokens `json:"tokens"`
-	User   *userResponse    `json:"user"`
+	Tokens *userSignInTokens `json:"tokens,omitempty"`
+	User   *userResponse     `json:"user,omitempty"`
+
+	MFA                    *userSignInMFAResponse `json:"mfa,omitempty"`
+	TwoFactorSetupRequired bool                   `json:"two_factor_setup_required,omitempty"`
 }
 
 type userSignInTokens struct {
@@ -105,6 +114,31 @@ type userSignInTokens struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type userSignInMFAResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// convertSignInOutputToResponse returns 202 when the sign-in has to be finished with the second factor.
+func convertSignInOutputToResponse(output *service.SignInOutput) (int, *userSignInResponse) {
+	if output.MFAChallenge != nil {
+		return http.StatusAccepted, &userSignInResponse{
+			MFA: &userSignInMFAResponse{
+				Token:     output.MFAChallenge.Token,
+				ExpiresAt: output.MFAChallenge.ExpiresAt.Format(time.RFC3339),
+			},
+		}
+	}
+
+	tokens := userSignInTokens(*output.Tokens)
+
+	return http.StatusCreated, &userSignInResponse{
+		Tokens:                 &tokens,
+		User:                   convertUserToUserResponse(output.User),
+		TwoFactorSetupRequired: output.TwoFactorSetupRequired,
+	}
+}
+
 func (h *Handler) userSignIn(w http.ResponseWriter, r *http.Request) {
 	var input userSignInRequest
 
@@ -120,7 +154,7 @@ func (h *Handler) userSignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokens, user, err := h.services.Auth.SignIn(r.Context(), service.UserSignInInput{
+	output, err := h.services.Auth.SignIn(r.Context(), service.UserSignInInput{
 		Email:     input.Email,
 		Password:  input.Password,
 		Client:    GetUserClient(r),
@@ -131,10 +165,110 @@ func (h *Handler) userSignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	SendResponse(w, http.StatusCreated, userSignInResponse{
-		Tokens: userSignInTokens(*tokens),
-		User:   convertUserToUserResponse(user),
+	statusCode, response := convertSignInOutputToResponse(output)
+
+	SendResponse(w, statusCode, response)
+}
+
+type userSignInTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,min=6,max=16"`
+}
+
+func (h *Handler) userSignInTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var input userSignInTwoFactorRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	output, err := h.services.Auth.SignInTwoFactor(r.Context(), service.UserSignInTwoFactorInput{
+		MFAToken: input.MFAToken,
+		Code:     input.Code,
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	statusCode, response := convertSignInOutputToResponse(output)
+
+	SendResponse(w, statusCode, response)
+}
+
+type userMagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email,max=64"`
+}
+
+// userRequestMagicLink answers the same way whether the email is registered or not.
+func (h *Handler) userRequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input userMagicLinkRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	err = h.services.Auth.RequestMagicLink(r.Context(), service.MagicLinkInput{
+		Email:     input.Email,
+		Client:    GetUserClient(r),
+		IPAddress: GetUserIPAddress(r),
+	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusAccepted)
+}
+
+type userSignInMagicLinkRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+func (h *Handler) userSignInMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input userSignInMagicLinkRequest
+
+	err := UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	output, err := h.services.Auth.SignInMagicLink(r.Context(), service.MagicLinkSignInInput{
+		Token:     input.Token,
+		Client:    GetUserClient(r),
+		IPAddress: GetUserIPAddress(r),
 	})
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	statusCode, response := convertSignInOutputToResponse(output)
+
+	SendResponse(w, statusCode, response)
 }
 
 type userConfirmEmailRequest struct {
@@ -161,7 +295,7 @@ func (h *Handler) userConfirmEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.services.Auth.ConfirmUserEmail(r.Context(), email.(string), input.Code)
+	err = h.services.Auth.ConfirmUserEmail(r.Context(), email.(string), input.Code, GetUserIPAddress(r))
 	if err != nil {
 		SendHTTPError(w, err)
 		return
@@ -170,6 +304,22 @@ func (h *Handler) userConfirmEmail(w http.ResponseWriter, r *http.Request) {
 	SendEmptyResponse(w, http.StatusCreated)
 }
 
+func (h *Handler) userResendEmailConfirmation(w http.ResponseWriter, r *http.Request) {
+	email, err := GetPathVar(r, "user_email", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.services.Auth.ResendEmailConfirmation(r.Context(), email.(string))
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendEmptyResponse(w, http.StatusOK)
+}
+
 type userRefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required,min=32"`
 }
@@ -227,7 +377,6 @@ func (h *Handler) userSignOut(w http.ResponseWriter, r *http.Request) {
 	err = h.services.Auth.SignOut(
 		requestContext,
 		userID.(string),
-		context.GetTokenID(requestContext),
 		context.GetSessionID(requestContext),
 	)
 	if err != nil {
@@ -239,21 +388,52 @@ func (h *Handler) userSignOut(w http.ResponseWriter, r *http.Request) {
 }
 
 type userResponse struct {
-	ID            string `json:"id"`
-	FirstName     string `json:"first_name"`
-	LastName      string `json:"last_name"`
-	Email         string `json:"email"`
-	PlanID        string `json:"plan_id"`
-	ExternalToken string `json:"external_token"`
-	Role          string `json:"role"`
+	ID        string `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	PlanID    string `json:"plan_id"`
+	Role      string `json:"role"`
 
 	TodayInternalRequest int `json:"today_internal_request"`
 	TodayExternalRequest int `json:"today_external_request"`
 
 	ReceiveNotification bool `json:"receive_notification"`
+	TwoFactorEnabled    bool `json:"two_factor_enabled"`
+
+	Webhook    *userWebhookResponse    `json:"webhook,omitempty"`
+	Identities []*userIdentityResponse `json:"identities"`
+}
+
+type userIdentityResponse struct {
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+	LinkedAt string `json:"linked_at"`
+}
+
+type userWebhookResponse struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
 }
 
 func convertUserToUserResponse(user *domain.User) *userResponse {
+	var webhook *userWebhookResponse
+	if user.Webhook != nil {
+		webhook = &userWebhookResponse{
+			URL:    user.Webhook.URL,
+			Secret: user.Webhook.Secret,
+		}
+	}
+
+	identities := make([]*userIdentityResponse, len(user.Identities))
+	for i := range user.Identities {
+		identities[i] = &userIdentityResponse{
+			Provider: user.Identities[i].Provider,
+			Email:    user.Identities[i].Email,
+			LinkedAt: user.Identities[i].LinkedAt.Format(time.RFC3339),
+		}
+	}
+
 	return &userResponse{
 		ID:                   user.ID,
 		Email:                user.Email,
@@ -261,10 +441,12 @@ func convertUserToUserResponse(user *domain.User) *userResponse {
 		FirstName:            user.FirstName,
 		PlanID:               user.PlanID,
 		ReceiveNotification:  user.ReceiveNotification,
-		ExternalToken:        user.Credential.ExternalToken,
+		TwoFactorEnabled:     user.TwoFactorEnabled(),
 		Role:                 string(user.Role),
 		TodayInternalRequest: user.TodayInternalRequest,
 		TodayExternalRequest: user.TodayExternalRequest,
+		Webhook:              webhook,
+		Identities:           identities,
 	}
 }
 
@@ -288,8 +470,8 @@ func (h *Handler) userGetOne(w http.ResponseWriter, r *http.Request) {
 }
 
 type userUpdateRequest struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+	FirstName string `json:"first_name" validate:"invoice_name"`
+	LastName  string `json:"last_name" validate:"invoice_name"`
 }
 
 func (h *Handler) userUpdate(w http.ResponseWriter, r *http.Request) {
@@ -415,7 +597,7 @@ func (h *Handler) verifyPasswordReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.services.Auth.VerifyPasswordReset(r.Context(), email.(string), input.Code)
+	err = h.services.Auth.VerifyPasswordReset(r.Context(), email.(string), input.Code, GetUserIPAddress(r))
 	if err != nil {
 		SendHTTPError(w, err)
 		return
@@ -425,8 +607,8 @@ func (h *Handler) verifyPasswordReset(w http.ResponseWriter, r *http.Request) {
 }
 
 type userPasswordReset struct {
-	Password        string `json:"password" validate:"required,min=8,max=64"`
-	PasswordConfirm string `json:"password_confirm" validate:"required,min=8,max=64,eqfield=Password"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"required,eqfield=Password"`
 }
 
 func (h *Handler) passwordReset(w http.ResponseWriter, r *http.Request) {
@@ -464,8 +646,8 @@ func (h *Handler) passwordReset(w http.ResponseWriter, r *http.Request) {
 
 type userUpdatePasswordRequest struct {
 	CurrentPassword    string `json:"current_password" validate:"required"`
-	NewPassword        string `json:"new_password" validate:"required,min=8,max=64,nefield=CurrentPassword"`
-	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,min=8,max=64,eqfield=NewPassword"`
+	NewPassword        string `json:"new_password" validate:"required,nefield=CurrentPassword"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,eqfield=NewPassword"`
 }
 
 func (h *Handler) userUpdatePassword(w http.ResponseWriter, r *http.Request) {
@@ -547,21 +729,59 @@ func (h *Handler) userUpdatePlan(w http.ResponseWriter, r *http.Request) {
 	SendResponse(w, http.StatusCreated, userUpdatePlanResponse{CheckoutLink: checkoutLink})
 }
 
-func (h *Handler) userRegenerateExternal(w http.ResponseWriter, r *http.Request) {
+type userSetWebhookRequest struct {
+	URL string `json:"url" validate:"required,url,max=2048"`
+}
+
+func (h *Handler) userSetWebhook(w http.ResponseWriter, r *http.Request) {
 	userID, err := GetPathVar(r, "user_id", stringType)
 	if err != nil {
 		SendEmptyResponse(w, http.StatusBadRequest)
 		return
 	}
 
-	user, err := h.services.User.RegenerateExternalToken(
+	var input userSetWebhookRequest
+
+	err = UnmarshalRequest(r, &input)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	err = h.validate.Struct(input)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	user, err := h.services.User.SetWebhook(
 		r.Context(),
 		userID.(string),
+		input.URL,
 	)
 	if err != nil {
 		SendHTTPError(w, err)
 		return
 	}
 
-	SendResponse(w, http.StatusCreated, convertUserToUserResponse(user))
+	SendResponse(w, http.StatusOK, convertUserToUserResponse(user))
+}
+
+func (h *Handler) userRemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetPathVar(r, "user_id", stringType)
+	if err != nil {
+		SendEmptyResponse(w, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.services.User.RemoveWebhook(
+		r.Context(),
+		userID.(string),
+	)
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, convertUserToUserResponse(user))
 }
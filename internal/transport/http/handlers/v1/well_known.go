@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"net/http"
+
+	tokenManager "necutya/faker/pkg/token_managers"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) initWellKnownRoutes(router *mux.Router) {
+	router.HandleFunc("/.well-known/jwks.json", h.getJWKS).Methods(http.MethodGet)
+}
+
+type jwksResponse struct {
+	Keys []tokenManager.JSONWebKey `json:"keys"`
+}
+
+// getJWKS publishes the public keys access tokens are signed with, so other services can verify
+// them without the secret.
+func (h *Handler) getJWKS(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.services.SigningKey.JWKS()
+	if err != nil {
+		SendHTTPError(w, err)
+		return
+	}
+
+	SendResponse(w, http.StatusOK, jwksResponse{Keys: keys})
+}
@@ -0,0 +1,103 @@
+package ai_grpc_client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen is returned without calling the service while the circuit breaker is open.
+	ErrCircuitOpen = errors.New("ai service circuit breaker is open")
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker opens after threshold consecutive failures and rejects calls for timeout.
+// Then a single trial call is let through: its success closes the circuit, its failure opens it again.
+type breaker struct {
+	mu sync.Mutex
+
+	threshold int
+	timeout   time.Duration
+
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newBreaker(threshold int, timeout time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return false
+		}
+
+		b.state = breakerHalfOpen
+		b.trial = true
+
+		return true
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+
+		b.trial = true
+
+		return true
+	}
+
+	return true
+}
+
+// done records the result of a call allowed by allow.
+func (b *breaker) done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.trial = false
+	}
+
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a call allowed by allow without recording its result, e.g. when the caller gave up.
+// It only frees the half-open trial, so the next call is tried instead.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.trial = false
+	}
+}
@@ -2,17 +2,77 @@ package ai_grpc_client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"math"
+	"math/rand"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/necutya-diploma/ai-service/gen/go"
 )
 
+const (
+	defaultTimeout          = 10 * time.Second
+	defaultKeepaliveTime    = 30 * time.Second
+	defaultKeepaliveTimeout = 10 * time.Second
+	defaultRetryBaseDelay   = 100 * time.Millisecond
+	defaultBreakerThreshold = 5
+	defaultBreakerTimeout   = 30 * time.Second
+)
+
+var (
+	// ErrUnavailable is returned when the AI service stays unavailable after all retries.
+	ErrUnavailable = errors.New("ai service is unavailable")
+)
+
+type TLSOptions struct {
+	Enabled bool
+	// CAFile is a PEM bundle used to verify the server, system roots are used if empty.
+	CAFile string
+	// CertFile and KeyFile enable mutual TLS when both are set.
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+type Options struct {
+	Addr         string
+	ModelVersion string
+	TLS          TLSOptions
+
+	// Timeout limits a single attempt of a call.
+	Timeout          time.Duration
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+
+	// MaxRetries is a number of additional attempts made when the service answers with Unavailable.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+
+	// BreakerThreshold consecutive failures open the circuit for BreakerTimeout.
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+}
+
 type AiGrpc struct {
-	addr string
+	conn         *grpc.ClientConn
+	client       pb.AIClient
+	breaker      *breaker
+	modelVersion string
+
+	timeout        time.Duration
+	maxRetries     int
+	retryBaseDelay time.Duration
 }
 
 type CheckMessageResponse struct {
@@ -21,32 +81,199 @@ type CheckMessageResponse struct {
 	GeneratedPercent float32
 }
 
-func New(addr string) *AiGrpc {
+// New creates a client with a long-lived connection. The connection is established lazily,
+// use HealthCheck to make sure the service is reachable.
+func New(opts Options) (*AiGrpc, error) {
+	setDefaultOptions(&opts)
+
+	transportCredentials, err := newTransportCredentials(opts.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(
+		opts.Addr,
+		grpc.WithTransportCredentials(transportCredentials),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                opts.KeepaliveTime,
+			Timeout:             opts.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &AiGrpc{
-		addr: addr,
+		conn:           conn,
+		client:         pb.NewAIClient(conn),
+		breaker:        newBreaker(opts.BreakerThreshold, opts.BreakerTimeout),
+		modelVersion:   opts.ModelVersion,
+		timeout:        opts.Timeout,
+		maxRetries:     opts.MaxRetries,
+		retryBaseDelay: opts.RetryBaseDelay,
+	}, nil
+}
+
+func setDefaultOptions(opts *Options) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	if opts.KeepaliveTime <= 0 {
+		opts.KeepaliveTime = defaultKeepaliveTime
+	}
+
+	if opts.KeepaliveTimeout <= 0 {
+		opts.KeepaliveTimeout = defaultKeepaliveTimeout
+	}
+
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = defaultRetryBaseDelay
+	}
+
+	if opts.BreakerThreshold <= 0 {
+		opts.BreakerThreshold = defaultBreakerThreshold
+	}
+
+	if opts.BreakerTimeout <= 0 {
+		opts.BreakerTimeout = defaultBreakerTimeout
 	}
 }
 
-func (ai *AiGrpc) CheckMessage(msg string) (string, bool, float64, error) {
-	conn, err := grpc.Dial(ai.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return "", false, 0.0, err
+func newTransportCredentials(opts TLSOptions) (credentials.TransportCredentials, error) {
+	if !opts.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{
+		ServerName: opts.ServerName,
+		MinVersion: tls.VersionTLS12,
 	}
 
-	defer conn.Close()
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
 
-	c := pb.NewAIClient(conn)
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+	}
+
+	if opts.CertFile != "" && opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+func (ai *AiGrpc) ModelVersion() string {
+	return ai.modelVersion
+}
+
+func (ai *AiGrpc) Close() error {
+	return ai.conn.Close()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+func (ai *AiGrpc) CheckMessage(ctx context.Context, msg string) (string, bool, float64, error) {
+	var r *pb.MessageResponse
 
-	r, err := c.CheckMessage(ctx, &pb.Message{Message: msg})
+	err := ai.call(ctx, func(ctx context.Context) error {
+		var err error
+
+		r, err = ai.client.CheckMessage(ctx, &pb.Message{Message: msg})
+
+		return err
+	})
 	if err != nil {
 		return "", false, 0.0, err
 	}
+
 	return r.Message, r.IsGenerated, roundValueToTwoDecimalPlaces(float64(r.GeneratedPercent)), nil
 }
 
+// Detect lets the client take part in an ensemble of detectors.
+func (ai *AiGrpc) Detect(ctx context.Context, msg string) (bool, float64, error) {
+	_, isGenerated, generatedPercent, err := ai.CheckMessage(ctx, msg)
+
+	return isGenerated, generatedPercent, err
+}
+
+// call runs fn through the circuit breaker and retries it with jittered exponential backoff
+// while the service answers with Unavailable.
+func (ai *AiGrpc) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !ai.breaker.allow() {
+		return ErrCircuitOpen
+	}
+
+	var err error
+
+	for attempt := 0; attempt <= ai.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				ai.breaker.release()
+				return ctx.Err()
+			case <-time.After(ai.backoff(attempt)):
+			}
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, ai.timeout)
+		err = fn(attemptCtx)
+		cancel()
+
+		if status.Code(err) != codes.Unavailable {
+			break
+		}
+	}
+
+	// the caller giving up tells nothing about the service's health
+	if ctx.Err() != nil {
+		ai.breaker.release()
+	} else {
+		ai.breaker.done(isServiceFailure(err))
+	}
+
+	if status.Code(err) == codes.Unavailable {
+		return fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// backoff returns a random delay between zero and RetryBaseDelay * 2^(attempt-1).
+func (ai *AiGrpc) backoff(attempt int) time.Duration {
+	delay := ai.retryBaseDelay << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1)) // nolint: gosec
+}
+
+// isServiceFailure tells whether the error means the service itself is unhealthy,
+// errors caused by the request are not counted by the breaker.
+func isServiceFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+
+	return false
+}
+
 func convertGrpcMessageResponseToCheckMessageResponse(response *pb.MessageResponse) *CheckMessageResponse {
 	return &CheckMessageResponse{
 		Message:          response.Message,
@@ -0,0 +1,101 @@
+package ai_grpc_client
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	healthCheckMethod = "/grpc.health.v1.Health/Check"
+
+	// servingStatus is HealthCheckResponse.SERVING of grpc.health.v1.
+	servingStatus = 1
+)
+
+// HealthCheck calls the standard grpc.health.v1 service. If the AI service does not implement it,
+// the service is considered healthy once the connection is ready.
+func (ai *AiGrpc) HealthCheck(ctx context.Context) error {
+	var resp []byte
+
+	// the request has no fields set, so it is encoded as an empty message
+	err := ai.conn.Invoke(ctx, healthCheckMethod, []byte{}, &resp, grpc.ForceCodec(rawCodec{}), grpc.WaitForReady(true))
+	if status.Code(err) == codes.Unimplemented {
+		if state := ai.conn.GetState(); state != connectivity.Ready {
+			return fmt.Errorf("ai service connection is %s", state)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if servingStatus != parseHealthStatus(resp) {
+		return fmt.Errorf("ai service is not serving")
+	}
+
+	return nil
+}
+
+// parseHealthStatus reads the status field (1, enum) of HealthCheckResponse.
+func parseHealthStatus(b []byte) uint64 {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0
+		}
+		b = b[n:]
+
+		if num == 1 && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0
+			}
+
+			return v
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return 0
+		}
+		b = b[n:]
+	}
+
+	return 0
+}
+
+// rawCodec passes already encoded protobuf messages through, so the health service
+// can be called without its generated code.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec: unexpected type %T", v)
+	}
+
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec: unexpected type %T", v)
+	}
+
+	*b = append((*b)[:0], data...)
+
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,126 @@
+package breached_passwords
+
+import (
+	"bufio"
+	"crypto/sha1" // nolint: gosec
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prefixSize is the number of leading bytes of a SHA-1 hash kept per password. 8 bytes make
+// false positives practically impossible for dumps of a billion hashes while taking 2.5 times
+// less memory than the full hashes.
+const prefixSize = 8
+
+// Set holds the hash prefixes of breached passwords as a sorted slice, a lookup is a binary search.
+type Set struct {
+	prefixes []uint64
+}
+
+// Load reads the file in the HIBP format: a hex SHA-1 hash per line optionally followed
+// by `:<count>`. Hashes seen less than minCount times are skipped.
+func Load(path string, minCount int) (*Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f, minCount)
+}
+
+// Read parses the dump from r, see Load.
+func Read(r io.Reader, minCount int) (*Set, error) {
+	var (
+		prefixes []uint64
+		scanner  = bufio.NewScanner(r)
+		lineNum  = 0
+	)
+
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var (
+			hash  = line
+			count = minCount
+			err   error
+		)
+
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			hash = line[:i]
+
+			if count, err = strconv.Atoi(line[i+1:]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid count: %w", lineNum, err)
+			}
+		}
+
+		if count < minCount {
+			continue
+		}
+
+		if len(hash) < 2*prefixSize {
+			return nil, fmt.Errorf("line %d: hash is too short", lineNum)
+		}
+
+		b, err := hex.DecodeString(hash[:2*prefixSize])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		prefixes = append(prefixes, binary.BigEndian.Uint64(b))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i] < prefixes[j] })
+
+	return &Set{prefixes: dedupe(prefixes)}, nil
+}
+
+// Len returns the number of distinct hash prefixes.
+func (s *Set) Len() int {
+	return len(s.prefixes)
+}
+
+// Contains reports whether the password is in the dump.
+func (s *Set) Contains(password string) bool {
+	hash := sha1.Sum([]byte(password)) // nolint: gosec
+	prefix := binary.BigEndian.Uint64(hash[:prefixSize])
+
+	i := sort.Search(len(s.prefixes), func(i int) bool { return s.prefixes[i] >= prefix })
+
+	return i < len(s.prefixes) && s.prefixes[i] == prefix
+}
+
+func dedupe(sorted []uint64) []uint64 {
+	if len(sorted) == 0 {
+		return sorted
+	}
+
+	n := 1
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] != sorted[n-1] {
+			sorted[n] = sorted[i]
+			n++
+		}
+	}
+
+	// copied to drop the spare capacity left by appending
+	prefixes := make([]uint64, n)
+	copy(prefixes, sorted)
+
+	return prefixes
+}
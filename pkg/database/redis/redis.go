@@ -8,6 +8,8 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+const scanBatchSize = 500
+
 // Client for accessing to Redis.
 type Client struct {
 	cli *redis.Client
@@ -17,6 +19,22 @@ func (r *Client) Incr(ctx context.Context, key string) error {
 	return r.cli.Incr(ctx, key).Err()
 }
 
+// IncrWithTTL - increments the key and sets its ttl when the key is created.
+func (r *Client) IncrWithTTL(ctx context.Context, key string, ttl int64) (int64, error) {
+	res, err := r.cli.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if res == 1 {
+		if err = r.cli.Expire(ctx, key, time.Second*time.Duration(ttl)).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return res, nil
+}
+
 func (r *Client) Exists(ctx context.Context, key string) (bool, error) {
 	res, err := r.cli.Exists(ctx, key).Result()
 	if err != nil {
@@ -75,6 +93,19 @@ func (r *Client) Set(ctx context.Context, key string, value []byte, ttl int64) e
 	return r.cli.Set(ctx, key, value, time.Second*time.Duration(ttl)).Err()
 }
 
+// GetDel - returns the value of the key and deletes it, nil if the key does not exist.
+func (r *Client) GetDel(ctx context.Context, key string) ([]byte, error) {
+	res, err := r.cli.GetDel(ctx, key).Bytes()
+
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 // Del - implementation delete keys from redis.
 func (r *Client) Del(ctx context.Context, keys ...string) error {
 	return r.cli.Del(ctx, keys...).Err()
@@ -100,11 +131,95 @@ func (r *Client) HIncrBy(ctx context.Context, key, field string, incr int64) err
 	return r.cli.HIncrBy(ctx, key, field, incr).Err()
 }
 
+// HIncrByAndGet - finds map(hash) by `key`, increments its `field` and returns the new value.
+func (r *Client) HIncrByAndGet(ctx context.Context, key, field string, incr int64) (int64, error) {
+	return r.cli.HIncrBy(ctx, key, field, incr).Result()
+}
+
 // Expire - adds `exp` - expiration time for the `key`.
 func (r *Client) Expire(ctx context.Context, key string, exp time.Duration) error {
 	return r.cli.Expire(ctx, key, exp).Err()
 }
 
+// LPush - prepends `values` to the list stored at `key`.
+func (r *Client) LPush(ctx context.Context, key string, values ...interface{}) error {
+	return r.cli.LPush(ctx, key, values...).Err()
+}
+
+// BRPopLPush - moves the last element of the list stored at `source` to the head of the list
+// stored at `destination` and returns it, blocks up to `timeout`. Returns empty string if the source stays empty.
+func (r *Client) BRPopLPush(ctx context.Context, timeout time.Duration, source, destination string) (string, error) {
+	res, err := r.cli.BRPopLPush(ctx, source, destination, timeout).Result()
+
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return res, nil
+}
+
+// RPush - appends `values` to the list stored at `key`.
+func (r *Client) RPush(ctx context.Context, key string, values ...interface{}) error {
+	return r.cli.RPush(ctx, key, values...).Err()
+}
+
+// LRange - returns the elements of the list stored at `key` from `start` to `stop` inclusive.
+func (r *Client) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return r.cli.LRange(ctx, key, start, stop).Result()
+}
+
+// LRem - removes up to `count` occurrences of `value` from the list stored at `key`
+// and returns the number of removed elements.
+func (r *Client) LRem(ctx context.Context, key string, count int64, value interface{}) (int64, error) {
+	return r.cli.LRem(ctx, key, count, value).Result()
+}
+
+// SetNX - sets the value with ttl only if the key does not exist, reports whether it was set.
+func (r *Client) SetNX(ctx context.Context, key string, value []byte, ttl int64) (bool, error) {
+	return r.cli.SetNX(ctx, key, value, time.Second*time.Duration(ttl)).Result()
+}
+
+// DelByPattern - deletes all keys matching `pattern` and returns the number of deleted keys.
+// Keys are iterated with SCAN, so it does not block the server on large databases.
+func (r *Client) DelByPattern(ctx context.Context, pattern string) (int64, error) {
+	var (
+		deleted int64
+		keys    []string
+		iter    = r.cli.Scan(ctx, 0, pattern, scanBatchSize).Iterator()
+	)
+
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+
+		if len(keys) == scanBatchSize {
+			n, err := r.cli.Del(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+
+			deleted += n
+			keys = keys[:0]
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+
+	if len(keys) > 0 {
+		n, err := r.cli.Del(ctx, keys...).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += n
+	}
+
+	return deleted, nil
+}
+
 // Ping - pings the redis client connection.
 func (r *Client) Ping(ctx context.Context) error {
 	return r.cli.Ping(ctx).Err()
@@ -0,0 +1,266 @@
+package document_parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	docxContentFile = "word/document.xml"
+	docxAppFile     = "docProps/app.xml"
+	odtContentFile  = "content.xml"
+	odtMetaFile     = "meta.xml"
+
+	wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	odtTextNS     = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odtMetaNS     = "urn:oasis:names:tc:opendocument:xmlns:meta:1.0"
+
+	maxOfficeFileSize = 64 << 20
+	maxODTSpaceCount  = 64
+)
+
+func extractDOCX(data []byte) (string, int, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", 0, ErrMalformedDocument
+	}
+
+	content, err := readZipFile(archive, docxContentFile)
+	if err != nil {
+		return "", 0, err
+	}
+
+	text, err := parseDOCXContent(content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	pages := 0
+	if app, err := readZipFile(archive, docxAppFile); err == nil {
+		pages = parseDOCXPages(app)
+	}
+
+	return text, pages, nil
+}
+
+func parseDOCXContent(content []byte) (string, error) {
+	var (
+		sb      strings.Builder
+		decoder = xml.NewDecoder(bytes.NewReader(content))
+		inText  bool
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", ErrMalformedDocument
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+
+		if sb.Len() > maxTextLength {
+			return "", ErrDocumentTooLarge
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func parseDOCXPages(app []byte) int {
+	var properties struct {
+		Pages int `xml:"Pages"`
+	}
+
+	if err := xml.Unmarshal(app, &properties); err != nil {
+		return 0
+	}
+
+	return properties.Pages
+}
+
+func extractODT(data []byte) (string, int, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", 0, ErrMalformedDocument
+	}
+
+	content, err := readZipFile(archive, odtContentFile)
+	if err != nil {
+		return "", 0, err
+	}
+
+	text, err := parseODTContent(content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	pages := 0
+	if meta, err := readZipFile(archive, odtMetaFile); err == nil {
+		pages = parseODTPages(meta)
+	}
+
+	return text, pages, nil
+}
+
+func parseODTContent(content []byte) (string, error) {
+	var (
+		sb             strings.Builder
+		decoder        = xml.NewDecoder(bytes.NewReader(content))
+		paragraphDepth int
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", ErrMalformedDocument
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odtTextNS {
+				continue
+			}
+
+			switch t.Name.Local {
+			case "p", "h":
+				paragraphDepth++
+			case "s":
+				sb.WriteString(strings.Repeat(" ", odtSpaceCount(t)))
+			case "tab":
+				sb.WriteString("\t")
+			case "line-break":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space != odtTextNS {
+				continue
+			}
+
+			if t.Name.Local == "p" || t.Name.Local == "h" {
+				paragraphDepth--
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if paragraphDepth > 0 {
+				sb.Write(t)
+			}
+		}
+
+		if sb.Len() > maxTextLength {
+			return "", ErrDocumentTooLarge
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// odtSpaceCount returns the number of spaces encoded by <text:s text:c="N"/>,
+// clamped to maxODTSpaceCount as the spaces carry no text.
+func odtSpaceCount(element xml.StartElement) int {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == odtTextNS && attr.Name.Local == "c" {
+			if count, err := strconv.Atoi(attr.Value); err == nil && count > 0 {
+				if count > maxODTSpaceCount {
+					return maxODTSpaceCount
+				}
+
+				return count
+			}
+		}
+	}
+
+	return 1
+}
+
+func parseODTPages(meta []byte) int {
+	decoder := xml.NewDecoder(bytes.NewReader(meta))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Space != odtMetaNS || element.Name.Local != "document-statistic" {
+			continue
+		}
+
+		for _, attr := range element.Attr {
+			if attr.Name.Space == odtMetaNS && attr.Name.Local == "page-count" {
+				pages, _ := strconv.Atoi(attr.Value)
+				return pages
+			}
+		}
+
+		return 0
+	}
+}
+
+func readZipFile(archive *zip.Reader, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+
+		if file.UncompressedSize64 > maxOfficeFileSize {
+			return nil, ErrMalformedDocument
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, ErrMalformedDocument
+		}
+		defer rc.Close()
+
+		content, err := io.ReadAll(io.LimitReader(rc, maxOfficeFileSize))
+		if err != nil {
+			return nil, ErrMalformedDocument
+		}
+
+		return content, nil
+	}
+
+	return nil, ErrMalformedDocument
+}
@@ -0,0 +1,90 @@
+package document_parser
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	PDFFormat      = "pdf"
+	DOCXFormat     = "docx"
+	ODTFormat      = "odt"
+	TextFormat     = "txt"
+	MarkdownFormat = "md"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported document format")
+	ErrEmptyDocument     = errors.New("document contains no extractable text")
+	ErrMalformedDocument = errors.New("malformed document")
+	ErrDocumentTooLarge  = errors.New("document content exceeds the extraction limit")
+)
+
+// maxTextLength limits the text extracted from a document in bytes, the documents
+// that decompress into more are rejected rather than read to the end.
+const maxTextLength = 16 << 20
+
+// DocumentParser extracts plain text from PDF, DOCX, ODT, plain text and Markdown documents.
+type DocumentParser struct{}
+
+func New() *DocumentParser {
+	return &DocumentParser{}
+}
+
+// Extract returns document text, its page count (0 when the format has no pages) and the detected text encoding.
+func (p *DocumentParser) Extract(filename string, data []byte) (string, int, string, error) {
+	var (
+		text     string
+		pages    int
+		encoding string
+		err      error
+	)
+
+	switch detectFormat(filename) {
+	case PDFFormat:
+		text, pages, err = extractPDF(data)
+		encoding = pdfDocEncoding
+	case DOCXFormat:
+		text, pages, err = extractDOCX(data)
+		encoding = utf8Encoding
+	case ODTFormat:
+		text, pages, err = extractODT(data)
+		encoding = utf8Encoding
+	case TextFormat:
+		text, encoding, err = decodeText(data)
+	case MarkdownFormat:
+		text, encoding, err = decodeText(data)
+		text = stripMarkdown(text)
+	default:
+		return "", 0, "", ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", 0, "", ErrEmptyDocument
+	}
+
+	return text, pages, encoding, nil
+}
+
+func detectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return PDFFormat
+	case ".docx":
+		return DOCXFormat
+	case ".odt":
+		return ODTFormat
+	case ".txt", ".text":
+		return TextFormat
+	case ".md", ".markdown":
+		return MarkdownFormat
+	}
+
+	return ""
+}
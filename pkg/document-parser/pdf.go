@@ -0,0 +1,483 @@
+package document_parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	pdfHeader          = "%PDF-"
+	pdfWordSpacing     = -200
+	maxPDFStreamLength = 64 << 20
+	// maxPDFInflatedLength limits the decompressed length of all streams of the document.
+	maxPDFInflatedLength = 256 << 20
+	maxPDFArrayDepth     = 64
+)
+
+var (
+	pdfPageRegexp   = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfStreamRegexp = regexp.MustCompile(`stream\r?\n`)
+
+	pdfStreamEnd   = []byte("endstream")
+	pdfObjKeyword  = []byte("obj")
+	pdfFlateDecode = []byte("/FlateDecode")
+	pdfFilter      = []byte("/Filter")
+
+	utf16BEMarker = []byte{0xFE, 0xFF}
+)
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfArray
+	pdfArrayEnd
+	pdfOther
+)
+
+type pdfToken struct {
+	kind   pdfTokenKind
+	value  string
+	number float64
+	items  []pdfToken
+}
+
+// extractPDF extracts text shown by the text operators of uncompressed and Flate compressed content streams.
+// Fonts with custom CMaps are not decoded, their glyphs come out as is.
+func extractPDF(data []byte) (string, int, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(pdfHeader)) {
+		return "", 0, ErrMalformedDocument
+	}
+
+	var (
+		sb       strings.Builder
+		pages    = len(pdfPageRegexp.FindAllIndex(data, -1))
+		inflated int
+	)
+
+	for _, loc := range pdfStreamRegexp.FindAllIndex(data, -1) {
+		if loc[0] >= 3 && string(data[loc[0]-3:loc[0]]) == "end" {
+			continue
+		}
+
+		end := bytes.Index(data[loc[1]:], pdfStreamEnd)
+		if end < 0 {
+			break
+		}
+
+		dictionary := pdfStreamDictionary(data, loc[0])
+
+		content, ok := decodePDFStream(dictionary, data[loc[1]:loc[1]+end])
+		if !ok {
+			continue
+		}
+
+		if bytes.Contains(dictionary, pdfFlateDecode) {
+			if inflated += len(content); inflated > maxPDFInflatedLength {
+				return "", 0, ErrDocumentTooLarge
+			}
+		}
+
+		text, err := extractPDFContentText(content)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if sb.WriteString(text); sb.Len() > maxTextLength {
+			return "", 0, ErrDocumentTooLarge
+		}
+	}
+
+	return sb.String(), pages, nil
+}
+
+// pdfStreamDictionary returns the object header that precedes the stream keyword.
+func pdfStreamDictionary(data []byte, streamStart int) []byte {
+	objStart := bytes.LastIndex(data[:streamStart], pdfObjKeyword)
+	if objStart < 0 {
+		return data[:streamStart]
+	}
+
+	return data[objStart:streamStart]
+}
+
+func decodePDFStream(dictionary, raw []byte) ([]byte, bool) {
+	if bytes.Contains(dictionary, pdfFlateDecode) {
+		reader, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, false
+		}
+		defer reader.Close()
+
+		content, err := io.ReadAll(io.LimitReader(reader, maxPDFStreamLength))
+		if err != nil && len(content) == 0 {
+			return nil, false
+		}
+
+		return content, true
+	}
+
+	if bytes.Contains(dictionary, pdfFilter) {
+		return nil, false
+	}
+
+	return raw, true
+}
+
+func extractPDFContentText(content []byte) (string, error) {
+	var (
+		sb       strings.Builder
+		operands []pdfToken
+		inText   bool
+		lexer    = &pdfLexer{data: content}
+	)
+
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+
+		if token.kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch token.value {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			sb.WriteString("\n")
+		case "ID":
+			lexer.skipInlineImage()
+		}
+
+		if inText {
+			writePDFTextOperator(&sb, token.value, operands)
+		}
+
+		operands = operands[:0]
+	}
+
+	if lexer.err != nil {
+		return "", lexer.err
+	}
+
+	return sb.String(), nil
+}
+
+func writePDFTextOperator(sb *strings.Builder, operator string, operands []pdfToken) {
+	switch operator {
+	case "Tj":
+		writePDFLastString(sb, operands)
+	case "'", "\"":
+		sb.WriteString("\n")
+		writePDFLastString(sb, operands)
+	case "TJ":
+		if len(operands) == 0 || operands[len(operands)-1].kind != pdfArray {
+			return
+		}
+
+		for _, item := range operands[len(operands)-1].items {
+			switch {
+			case item.kind == pdfString:
+				sb.WriteString(item.value)
+			case item.kind == pdfNumber && item.number < pdfWordSpacing:
+				sb.WriteString(" ")
+			}
+		}
+	case "Td", "TD":
+		if len(operands) >= 2 && operands[1].kind == pdfNumber && operands[1].number != 0 {
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString(" ")
+		}
+	case "T*":
+		sb.WriteString("\n")
+	case "Tm":
+		sb.WriteString(" ")
+	}
+}
+
+func writePDFLastString(sb *strings.Builder, operands []pdfToken) {
+	if len(operands) > 0 && operands[len(operands)-1].kind == pdfString {
+		sb.WriteString(operands[len(operands)-1].value)
+	}
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+	// depth is the nesting level of the array being read, err stops the lexer once it is set.
+	depth int
+	err   error
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	l.skipWhitespace()
+
+	if l.pos >= len(l.data) || l.err != nil {
+		return pdfToken{}, false
+	}
+
+	c := l.data[l.pos]
+
+	switch {
+	case c == '(':
+		l.pos++
+		return pdfToken{kind: pdfString, value: decodePDFString(l.readLiteralString())}, true
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		return pdfToken{kind: pdfOther, value: "<<"}, true
+	case c == '>' && l.peek(1) == '>':
+		l.pos += 2
+		return pdfToken{kind: pdfOther, value: ">>"}, true
+	case c == '<':
+		l.pos++
+		return pdfToken{kind: pdfString, value: decodePDFString(l.readHexString())}, true
+	case c == '[':
+		if l.depth >= maxPDFArrayDepth {
+			l.err = ErrMalformedDocument
+			return pdfToken{}, false
+		}
+
+		l.pos++
+		return pdfToken{kind: pdfArray, items: l.readArray()}, true
+	case c == ']':
+		l.pos++
+		return pdfToken{kind: pdfArrayEnd}, true
+	case c == '/':
+		l.pos++
+		return pdfToken{kind: pdfOther, value: "/" + l.readRegular()}, true
+	case c == '{' || c == '}' || c == ')' || c == '>':
+		l.pos++
+		return pdfToken{kind: pdfOther, value: string(c)}, true
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		value := l.readRegular()
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return pdfToken{kind: pdfOther, value: value}, true
+		}
+
+		return pdfToken{kind: pdfNumber, value: value, number: number}, true
+	}
+
+	value := l.readRegular()
+	if value == "" {
+		l.pos++
+		return pdfToken{kind: pdfOther}, true
+	}
+
+	switch value {
+	case "true", "false", "null":
+		return pdfToken{kind: pdfOther, value: value}, true
+	}
+
+	return pdfToken{kind: pdfOperator, value: value}, true
+}
+
+func (l *pdfLexer) peek(offset int) byte {
+	if l.pos+offset >= len(l.data) {
+		return 0
+	}
+
+	return l.data[l.pos+offset]
+}
+
+func (l *pdfLexer) skipWhitespace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+
+		switch {
+		case isPDFWhitespace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *pdfLexer) readRegular() string {
+	start := l.pos
+
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+
+	return string(l.data[start:l.pos])
+}
+
+func (l *pdfLexer) readArray() []pdfToken {
+	var items []pdfToken
+
+	l.depth++
+	defer func() { l.depth-- }()
+
+	for {
+		token, ok := l.next()
+		if !ok || token.kind == pdfArrayEnd {
+			return items
+		}
+
+		items = append(items, token)
+	}
+}
+
+func (l *pdfLexer) readLiteralString() []byte {
+	var (
+		buf   []byte
+		depth = 1
+	)
+
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return buf
+			}
+		case '\\':
+			buf = l.readEscape(buf)
+			continue
+		}
+
+		buf = append(buf, c)
+	}
+
+	return buf
+}
+
+func (l *pdfLexer) readEscape(buf []byte) []byte {
+	if l.pos >= len(l.data) {
+		return buf
+	}
+
+	c := l.data[l.pos]
+	l.pos++
+
+	switch c {
+	case 'n':
+		return append(buf, '\n')
+	case 'r':
+		return append(buf, '\r')
+	case 't':
+		return append(buf, '\t')
+	case 'b':
+		return append(buf, '\b')
+	case 'f':
+		return append(buf, '\f')
+	case '\r':
+		if l.peek(0) == '\n' {
+			l.pos++
+		}
+
+		return buf
+	case '\n':
+		return buf
+	}
+
+	if c >= '0' && c <= '7' {
+		value := int(c - '0')
+
+		for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+			value = value*8 + int(l.data[l.pos]-'0')
+			l.pos++
+		}
+
+		return append(buf, byte(value))
+	}
+
+	return append(buf, c)
+}
+
+func (l *pdfLexer) readHexString() []byte {
+	start := l.pos
+
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		l.pos++
+	}
+
+	digits := make([]byte, 0, l.pos-start+1)
+	for _, c := range l.data[start:l.pos] {
+		if !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	l.pos++
+
+	decoded := make([]byte, hex.DecodedLen(len(digits)))
+	if _, err := hex.Decode(decoded, digits); err != nil {
+		return nil
+	}
+
+	return decoded
+}
+
+// skipInlineImage skips binary data of an inline image up to the EI operator.
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if isPDFWhitespace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' {
+			l.pos += 3
+			return
+		}
+
+		l.pos++
+	}
+
+	l.pos = len(l.data)
+}
+
+// decodePDFString decodes UTF-16BE strings with a byte order mark and PDFDocEncoding strings as Latin-1.
+func decodePDFString(raw []byte) string {
+	if bytes.HasPrefix(raw, utf16BEMarker) {
+		return decodeUTF16(raw[len(utf16BEMarker):], binary.BigEndian)
+	}
+
+	var sb strings.Builder
+
+	for _, b := range raw {
+		r := rune(b)
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,144 @@
+package document_parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	utf8Encoding        = "utf-8"
+	utf8BOMEncoding     = "utf-8-bom"
+	utf16LEEncoding     = "utf-16le"
+	utf16BEEncoding     = "utf-16be"
+	windows1251Encoding = "windows-1251"
+	latin1Encoding      = "iso-8859-1"
+	pdfDocEncoding      = "pdfdoc"
+
+	windows1251LettersStart = 0xC0
+	cyrillicCapitalA        = 'А'
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+
+	// windows1251Table maps bytes 0x80-0xBF of Windows-1251 to runes, 0xC0-0xFF are А-я in order.
+	windows1251Table = [64]rune{
+		0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+		0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0xFFFD, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+		0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+		0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+		0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+		0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	}
+
+	mdCodeFenceRegexp  = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+	mdImageRegexp      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRegexp       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdRefLinkRegexp    = regexp.MustCompile(`(?m)^\s*\[[^\]]+\]:\s+\S+.*$`)
+	mdHTMLTagRegexp    = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	mdHeadingRegexp    = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s*`)
+	mdBlockquoteRegexp = regexp.MustCompile(`(?m)^\s*>+\s?`)
+	mdListRegexp       = regexp.MustCompile(`(?m)^\s*([-*+]|\d+[.)])\s+`)
+	mdRuleRegexp       = regexp.MustCompile(`(?m)^\s*([-*_]\s*){3,}$`)
+	mdEmphasisRegexp   = regexp.MustCompile(`(\*{1,3}|_{1,3}|~~|` + "`" + `)`)
+	mdTablePipeRegexp  = regexp.MustCompile(`(?m)^\s*\|?(\s*:?-+:?\s*\|)+\s*:?-*:?\s*$`)
+)
+
+// decodeText detects the encoding of plain text and converts it to UTF-8.
+func decodeText(data []byte) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return string(data[len(utf8BOM):]), utf8BOMEncoding, nil
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return decodeUTF16(data[len(utf16LEBOM):], binary.LittleEndian), utf16LEEncoding, nil
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return decodeUTF16(data[len(utf16BEBOM):], binary.BigEndian), utf16BEEncoding, nil
+	case utf8.Valid(data):
+		return string(data), utf8Encoding, nil
+	case looksLikeWindows1251(data):
+		return decodeWindows1251(data), windows1251Encoding, nil
+	}
+
+	return decodeLatin1(data), latin1Encoding, nil
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[i*2:])
+	}
+
+	return string(utf16.Decode(units))
+}
+
+func decodeWindows1251(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+
+	for _, b := range data {
+		switch {
+		case b < utf8.RuneSelf:
+			sb.WriteByte(b)
+		case b >= windows1251LettersStart:
+			sb.WriteRune(cyrillicCapitalA + rune(b-windows1251LettersStart))
+		default:
+			sb.WriteRune(windows1251Table[b-utf8.RuneSelf])
+		}
+	}
+
+	return sb.String()
+}
+
+func decodeLatin1(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+
+	for _, b := range data {
+		sb.WriteRune(rune(b))
+	}
+
+	return sb.String()
+}
+
+// looksLikeWindows1251 reports whether most of the high bytes fall into the Cyrillic letters range.
+func looksLikeWindows1251(data []byte) bool {
+	var high, cyrillic int
+
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			high++
+
+			if b >= windows1251LettersStart {
+				cyrillic++
+			}
+		}
+	}
+
+	return high > 0 && cyrillic*2 >= high
+}
+
+// stripMarkdown removes Markdown markup and leaves readable text.
+func stripMarkdown(text string) string {
+	text = mdCodeFenceRegexp.ReplaceAllString(text, "")
+	text = mdImageRegexp.ReplaceAllString(text, "$1")
+	text = mdLinkRegexp.ReplaceAllString(text, "$1")
+	text = mdRefLinkRegexp.ReplaceAllString(text, "")
+	text = mdHTMLTagRegexp.ReplaceAllString(text, "")
+	text = mdTablePipeRegexp.ReplaceAllString(text, "")
+	text = mdHeadingRegexp.ReplaceAllString(text, "")
+	text = mdBlockquoteRegexp.ReplaceAllString(text, "")
+	text = mdRuleRegexp.ReplaceAllString(text, "")
+	text = mdListRegexp.ReplaceAllString(text, "")
+	text = mdEmphasisRegexp.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "|", " ")
+
+	return text
+}
@@ -0,0 +1,230 @@
+package ensemble
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	WeightedAverageStrategy = "weighted_average"
+	MajorityVoteStrategy    = "majority_vote"
+	FallbackStrategy        = "fallback"
+
+	// DefaultThreshold is the generated percent from which the weighted_average verdict is generated.
+	DefaultThreshold = 50
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown ensemble strategy")
+	ErrNoDetectors     = errors.New("ensemble has no detectors")
+)
+
+// Detector is a single model which estimates whether a text is generated.
+// GeneratedPercent is expected to be in [0, 100].
+type Detector interface {
+	Detect(ctx context.Context, msg string) (isGenerated bool, generatedPercent float64, err error)
+}
+
+type Member struct {
+	Name     string
+	Weight   float64
+	Detector Detector
+}
+
+type Score struct {
+	Detector         string
+	IsGenerated      bool
+	GeneratedPercent float64
+	Err              error
+}
+
+type Result struct {
+	IsGenerated      bool
+	GeneratedPercent float64
+	// Scores holds the verdict of every detector which was called, in the order of members.
+	Scores []*Score
+}
+
+// Ensemble combines verdicts of several detectors with one of the strategies:
+//   - weighted_average: percents are averaged by weights, the text is generated when the average
+//     reaches the threshold, so the verdict always agrees with the percent;
+//   - majority_vote: the verdict of the most detectors wins, a tie is broken by the earlier member;
+//   - fallback: members are called one by one until one of them succeeds.
+//
+// Failed detectors are skipped, the call fails only when all of them fail.
+type Ensemble struct {
+	strategy     string
+	modelVersion string
+	threshold    float64
+	members      []Member
+}
+
+// New creates the ensemble, DefaultThreshold is used when threshold is not positive.
+func New(strategy, modelVersion string, threshold float64, members ...Member) (*Ensemble, error) {
+	switch strategy {
+	case WeightedAverageStrategy, MajorityVoteStrategy, FallbackStrategy:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, strategy)
+	}
+
+	if len(members) == 0 {
+		return nil, ErrNoDetectors
+	}
+
+	for i := range members {
+		if members[i].Weight <= 0 {
+			members[i].Weight = 1
+		}
+	}
+
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &Ensemble{
+		strategy:     strategy,
+		modelVersion: modelVersion,
+		threshold:    threshold,
+		members:      members,
+	}, nil
+}
+
+func (e *Ensemble) ModelVersion() string {
+	return e.modelVersion
+}
+
+// CheckMessage returns the combined verdict. If all detectors fail, the error of the first one is returned.
+func (e *Ensemble) CheckMessage(ctx context.Context, msg string) (*Result, error) {
+	var scores []*Score
+
+	if e.strategy == FallbackStrategy {
+		scores = e.detectSequentially(ctx, msg)
+	} else {
+		scores = e.detectConcurrently(ctx, msg)
+	}
+
+	var (
+		succeeded []int
+		firstErr  error
+	)
+
+	for i, score := range scores {
+		if score.Err != nil {
+			if firstErr == nil {
+				firstErr = score.Err
+			}
+
+			continue
+		}
+
+		succeeded = append(succeeded, i)
+	}
+
+	if len(succeeded) == 0 {
+		return nil, firstErr
+	}
+
+	result := &Result{
+		Scores: scores,
+	}
+
+	switch e.strategy {
+	case WeightedAverageStrategy:
+		result.IsGenerated, result.GeneratedPercent = e.weightedAverage(scores, succeeded)
+	case MajorityVoteStrategy:
+		result.IsGenerated, result.GeneratedPercent = majorityVote(scores, succeeded)
+	case FallbackStrategy:
+		used := scores[succeeded[0]]
+		result.IsGenerated, result.GeneratedPercent = used.IsGenerated, used.GeneratedPercent
+	}
+
+	return result, nil
+}
+
+func (e *Ensemble) detectConcurrently(ctx context.Context, msg string) []*Score {
+	var (
+		wg     sync.WaitGroup
+		scores = make([]*Score, len(e.members))
+	)
+
+	for i := range e.members {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			scores[i] = detect(ctx, e.members[i], msg)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return scores
+}
+
+func (e *Ensemble) detectSequentially(ctx context.Context, msg string) []*Score {
+	scores := make([]*Score, 0, len(e.members))
+
+	for i := range e.members {
+		score := detect(ctx, e.members[i], msg)
+		scores = append(scores, score)
+
+		if score.Err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return scores
+}
+
+func detect(ctx context.Context, member Member, msg string) *Score {
+	score := &Score{
+		Detector: member.Name,
+	}
+
+	score.IsGenerated, score.GeneratedPercent, score.Err = member.Detector.Detect(ctx, msg)
+
+	return score
+}
+
+func (e *Ensemble) weightedAverage(scores []*Score, succeeded []int) (bool, float64) {
+	var weighted, total float64
+
+	for _, i := range succeeded {
+		weight := e.members[i].Weight
+		weighted += scores[i].GeneratedPercent * weight
+		total += weight
+	}
+
+	percent := roundValueToTwoDecimalPlaces(weighted / total)
+
+	return percent >= e.threshold, percent
+}
+
+func majorityVote(scores []*Score, succeeded []int) (bool, float64) {
+	var (
+		votes   int
+		percent float64
+	)
+
+	for _, i := range succeeded {
+		percent += scores[i].GeneratedPercent
+
+		if scores[i].IsGenerated {
+			votes++
+		}
+	}
+
+	isGenerated := votes*2 > len(succeeded)
+	if votes*2 == len(succeeded) {
+		isGenerated = scores[succeeded[0]].IsGenerated
+	}
+
+	return isGenerated, roundValueToTwoDecimalPlaces(percent / float64(len(succeeded)))
+}
+
+func roundValueToTwoDecimalPlaces(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -1,9 +1,9 @@
 package generators
 
 import (
+	"crypto/rand"
 	"encoding/base64"
-	"math/rand"
-	"time"
+	"math/big"
 )
 
 var (
@@ -17,15 +17,20 @@ func NewRandomGenerator() *RandomGenerator {
 	return &RandomGenerator{}
 }
 
-func (rg *RandomGenerator) GenerateNumericCode(length int) string {
-	rand.Seed(time.Now().Unix())
+func (rg *RandomGenerator) GenerateNumericCode(length int) (string, error) {
+	max := big.NewInt(int64(len(numSet)))
 
 	code := make([]rune, length)
 	for i := range code {
-		code[i] = numSet[rand.Intn(len(numSet))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		code[i] = numSet[n.Int64()]
 	}
 
-	return string(code)
+	return string(code), nil
 }
 
 func (rg *RandomGenerator) GenerateString(s int) (string, error) {
@@ -36,10 +41,7 @@ func (rg *RandomGenerator) GenerateString(s int) (string, error) {
 func generateRandomBytes(n int) ([]byte, error) {
 	b := make([]byte, n)
 
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
-
-	if _, err := r.Read(b); err != nil {
+	if _, err := rand.Read(b); err != nil {
 		return nil, err
 	}
 
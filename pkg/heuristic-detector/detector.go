@@ -0,0 +1,159 @@
+package heuristic_detector
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	minWords     = 40
+	minSentences = 3
+
+	// mattrWindow is a window of the moving-average type-token ratio, it makes the ratio independent of text length.
+	mattrWindow = 50
+
+	// Human writing mixes short and long sentences, generated text is more uniform.
+	// Below the pivot the burstiness (coefficient of variation of sentence lengths) points to generated text.
+	burstinessPivot = 0.45
+	burstinessScale = 0.1
+
+	// Generated text tends to reuse the same words, which lowers the lexical diversity.
+	ttrPivot = 0.72
+	ttrScale = 0.04
+
+	burstinessWeight = 0.6
+	ttrWeight        = 0.4
+)
+
+var (
+	ErrNotEnoughText = errors.New("text is too short for heuristic detection")
+)
+
+// Detector is a local detector based on text statistics. It needs no model and is meant to
+// back up the AI service or to add a cheap independent opinion to the ensemble.
+type Detector struct{}
+
+func New() *Detector {
+	return &Detector{}
+}
+
+func (d *Detector) Detect(_ context.Context, msg string) (bool, float64, error) {
+	words, sentenceLengths := tokenize(msg)
+	if len(words) < minWords || len(sentenceLengths) < minSentences {
+		return false, 0, ErrNotEnoughText
+	}
+
+	score := burstinessWeight*sigmoid((burstinessPivot-burstiness(sentenceLengths))/burstinessScale) +
+		ttrWeight*sigmoid((ttrPivot-movingTypeTokenRatio(words))/ttrScale)
+
+	percent := math.Round(score*10000) / 100
+
+	return percent >= 50, percent, nil
+}
+
+// tokenize returns lower-cased words and the number of words in every sentence.
+func tokenize(msg string) ([]string, []int) {
+	var (
+		words           []string
+		sentenceLengths []int
+		current         int
+		word            strings.Builder
+	)
+
+	flushWord := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+			current++
+		}
+	}
+
+	flushSentence := func() {
+		flushWord()
+
+		if current > 0 {
+			sentenceLengths = append(sentenceLengths, current)
+			current = 0
+		}
+	}
+
+	for _, r := range msg {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’':
+			word.WriteRune(unicode.ToLower(r))
+		case r == '.' || r == '!' || r == '?' || r == '…' || r == '\n':
+			flushSentence()
+		default:
+			flushWord()
+		}
+	}
+
+	flushSentence()
+
+	return words, sentenceLengths
+}
+
+// burstiness returns the coefficient of variation of sentence lengths.
+func burstiness(lengths []int) float64 {
+	var sum float64
+	for _, l := range lengths {
+		sum += float64(l)
+	}
+
+	mean := sum / float64(len(lengths))
+
+	var variance float64
+	for _, l := range lengths {
+		variance += (float64(l) - mean) * (float64(l) - mean)
+	}
+
+	variance /= float64(len(lengths))
+
+	return math.Sqrt(variance) / mean
+}
+
+// movingTypeTokenRatio returns the mean share of unique words in every window of mattrWindow words.
+func movingTypeTokenRatio(words []string) float64 {
+	window := mattrWindow
+	if len(words) < window {
+		window = len(words)
+	}
+
+	counts := make(map[string]int, window)
+	unique := 0
+
+	for _, w := range words[:window] {
+		if counts[w] == 0 {
+			unique++
+		}
+		counts[w]++
+	}
+
+	total := float64(unique) / float64(window)
+	windows := 1
+
+	for i := window; i < len(words); i++ {
+		out := words[i-window]
+		counts[out]--
+		if counts[out] == 0 {
+			unique--
+		}
+
+		if counts[words[i]] == 0 {
+			unique++
+		}
+		counts[words[i]]++
+
+		total += float64(unique) / float64(window)
+		windows++
+	}
+
+	return total / float64(windows)
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
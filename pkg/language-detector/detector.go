@@ -0,0 +1,143 @@
+package language_detector
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	Ukrainian    = "uk"
+	English      = "en"
+	Polish       = "pl"
+	Russian      = "ru"
+	Undetermined = "und"
+
+	// distinctiveLetterWeight is how many stopword hits a letter specific to one language is worth.
+	distinctiveLetterWeight = 2
+)
+
+var (
+	englishStopwords = newSet(
+		"the", "and", "of", "to", "a", "in", "is", "that", "it", "for", "was", "on", "with", "as", "are", "be",
+		"this", "have", "not", "by", "at", "from", "they", "you", "we", "he", "she", "but", "or", "an", "which",
+		"has", "were", "their", "will", "would", "there", "what", "been", "can", "if", "more", "when", "also",
+		"so", "about", "into", "than", "these", "our", "its", "my", "i",
+	)
+	polishStopwords = newSet(
+		"i", "w", "z", "na", "się", "nie", "do", "to", "że", "jest", "a", "o", "jak", "ale", "po", "co", "tak",
+		"za", "od", "czy", "przez", "dla", "tylko", "jego", "już", "jej", "są", "być", "może", "był", "była",
+		"oraz", "który", "która", "które", "ich", "ten", "ta", "także", "bardzo", "gdy", "przy", "tym", "mnie",
+		"jestem", "też", "we", "ze",
+	)
+	ukrainianStopwords = newSet(
+		"і", "й", "в", "у", "на", "що", "не", "з", "та", "це", "як", "до", "за", "від", "для", "але", "він",
+		"вона", "воно", "вони", "ми", "ви", "я", "ти", "його", "її", "їх", "був", "була", "було", "були",
+		"бути", "є", "так", "також", "або", "щоб", "коли", "вже", "ще", "може", "дуже", "тому", "який",
+		"яка", "які", "цей", "ця", "ці", "при", "про", "після",
+	)
+	russianStopwords = newSet(
+		"и", "в", "на", "что", "не", "с", "это", "как", "до", "за", "от", "для", "но", "он", "она", "оно",
+		"они", "мы", "вы", "я", "ты", "его", "ее", "её", "их", "был", "была", "было", "были", "быть", "есть",
+		"так", "также", "или", "чтобы", "когда", "уже", "еще", "ещё", "может", "очень", "поэтому", "который",
+		"которая", "которые", "этот", "эта", "эти", "при", "про", "после",
+	)
+
+	polishLetters    = "ąćęłńóśźż"
+	ukrainianLetters = "іїєґ"
+	russianLetters   = "ыэъё"
+)
+
+// Detector guesses the language of a text from the script, stopwords and letters specific to a language.
+// It knows Ukrainian, English, Polish and Russian, which is enough to tell Ukrainian from Russian.
+type Detector struct{}
+
+func New() *Detector {
+	return &Detector{}
+}
+
+// Detect returns the language code and the confidence in [0, 1], Undetermined is returned
+// when the text gives no evidence for any of the known languages.
+func (d *Detector) Detect(text string) (string, float64) {
+	var (
+		latin, cyrillic, letters int
+		scores                   = make(map[string]float64, 4)
+	)
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		for _, r := range word {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+
+			letters++
+
+			switch {
+			case unicode.Is(unicode.Latin, r):
+				latin++
+			case unicode.Is(unicode.Cyrillic, r):
+				cyrillic++
+			}
+
+			switch {
+			case strings.ContainsRune(polishLetters, r):
+				scores[Polish] += distinctiveLetterWeight
+			case strings.ContainsRune(ukrainianLetters, r):
+				scores[Ukrainian] += distinctiveLetterWeight
+			case strings.ContainsRune(russianLetters, r):
+				scores[Russian] += distinctiveLetterWeight
+			}
+		}
+
+		if englishStopwords[word] {
+			scores[English]++
+		}
+		if polishStopwords[word] {
+			scores[Polish]++
+		}
+		if ukrainianStopwords[word] {
+			scores[Ukrainian]++
+		}
+		if russianStopwords[word] {
+			scores[Russian]++
+		}
+	}
+
+	if letters == 0 {
+		return Undetermined, 0
+	}
+
+	candidates := []string{English, Polish}
+	scriptShare := float64(latin) / float64(letters)
+
+	if cyrillic > latin {
+		candidates = []string{Ukrainian, Russian}
+		scriptShare = float64(cyrillic) / float64(letters)
+	}
+
+	best, second := candidates[0], candidates[1]
+	if scores[second] > scores[best] {
+		best, second = second, best
+	}
+
+	if scores[best] == 0 {
+		return Undetermined, 0
+	}
+
+	confidence := scores[best] / (scores[best] + scores[second]) * scriptShare
+
+	return best, math.Round(confidence*100) / 100
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+}
+
+func newSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+
+	return set
+}
@@ -0,0 +1,100 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+var defaultGitHubScopes = []string{"read:user", "user:email"}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// GitHubProvider signs users in with GitHub. GitHub is a plain OAuth2 provider without ID tokens,
+// so the identity is read from its API with the access token.
+type GitHubProvider struct {
+	cfg    Config
+	client *http.Client
+
+	authURL  string
+	tokenURL string
+	apiURL   string
+}
+
+func NewGitHub(cfg Config, timeout time.Duration) *GitHubProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultGitHubScopes
+	}
+
+	return &GitHubProvider{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: timeout},
+		authURL:  githubAuthURL,
+		tokenURL: githubTokenURL,
+		apiURL:   githubAPIURL,
+	}
+}
+
+// AuthCodeURL returns the authorization URL, nonce is not used as there is no ID token.
+func (p *GitHubProvider) AuthCodeURL(_ context.Context, state, codeChallenge, _ string) (string, error) {
+	return authCodeURL(p.authURL, p.cfg, state, codeChallenge, nil)
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code, codeVerifier, _ string) (*Identity, error) {
+	token, err := exchangeCode(ctx, p.client, p.tokenURL, p.cfg, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var user githubUser
+
+	if err = getJSON(ctx, p.client, p.apiURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserInfoFailed, err)
+	}
+
+	var emails []githubEmail
+
+	if err = getJSON(ctx, p.client, p.apiURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserInfoFailed, err)
+	}
+
+	if user.ID == 0 {
+		return nil, fmt.Errorf("%w: no user id", ErrUserInfoFailed)
+	}
+
+	identity := &Identity{
+		Subject: strconv.FormatInt(user.ID, 10),
+	}
+
+	for i := range emails {
+		if emails[i].Primary {
+			identity.Email = emails[i].Email
+			identity.EmailVerified = emails[i].Verified
+		}
+	}
+
+	identity.FirstName, identity.LastName = splitName(user.Name)
+	if identity.FirstName == "" {
+		identity.FirstName = user.Login
+	}
+
+	return identity, nil
+}
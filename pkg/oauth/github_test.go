@@ -0,0 +1,127 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestGitHub returns the provider talking to a local GitHub with the user and the emails.
+func newTestGitHub(t *testing.T, user githubUser, emails []githubEmail) *GitHubProvider {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" || r.FormValue("code_verifier") != "verifier" {
+			writeTestJSON(w, map[string]string{"error": "bad_verification_code"})
+			return
+		}
+
+		writeTestJSON(w, map[string]string{"access_token": "access-token", "token_type": "bearer"})
+	})
+
+	authorized := func(next func(w http.ResponseWriter)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			next(w)
+		}
+	}
+
+	mux.HandleFunc("/user", authorized(func(w http.ResponseWriter) { writeTestJSON(w, user) }))
+	mux.HandleFunc("/user/emails", authorized(func(w http.ResponseWriter) { writeTestJSON(w, emails) }))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	provider := NewGitHub(Config{ClientID: testClientID, RedirectURL: testRedirectURL}, 5*time.Second)
+	provider.authURL = server.URL + "/login/oauth/authorize"
+	provider.tokenURL = server.URL + "/login/oauth/access_token"
+	provider.apiURL = server.URL
+
+	return provider
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestGitHubExchange(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   githubUser
+		emails []githubEmail
+		want   Identity
+	}{
+		{
+			name: "primary verified email",
+			user: githubUser{ID: 42, Login: "jane", Name: "Jane Doe"},
+			emails: []githubEmail{
+				{Email: "jane@users.noreply.github.com", Verified: true},
+				{Email: "jane@example.com", Primary: true, Verified: true},
+			},
+			want: Identity{Subject: "42", Email: "jane@example.com", EmailVerified: true, FirstName: "Jane", LastName: "Doe"},
+		},
+		{
+			// a verified secondary email is not taken instead, the primary one is the account's email
+			name: "primary unverified email",
+			user: githubUser{ID: 42, Login: "jane"},
+			emails: []githubEmail{
+				{Email: "jane@work.example", Verified: true},
+				{Email: "jane@example.com", Primary: true},
+			},
+			want: Identity{Subject: "42", Email: "jane@example.com", FirstName: "jane"},
+		},
+		{
+			name:   "no primary email",
+			user:   githubUser{ID: 42, Login: "jane"},
+			emails: []githubEmail{{Email: "jane@work.example", Verified: true}},
+			want:   Identity{Subject: "42", FirstName: "jane"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestGitHub(t, tt.user, tt.emails)
+
+			identity, err := provider.Exchange(context.Background(), "code", "verifier", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *identity != tt.want {
+				t.Errorf("identity = %+v, want %+v", *identity, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubExchangeFailed(t *testing.T) {
+	provider := newTestGitHub(t, githubUser{ID: 42}, nil)
+
+	_, err := provider.Exchange(context.Background(), "code", "another verifier", "")
+	if !errors.Is(err, ErrExchangeFailed) {
+		t.Fatalf("err = %v, want %v", err, ErrExchangeFailed)
+	}
+}
+
+func TestGitHubExchangeNoUserID(t *testing.T) {
+	provider := newTestGitHub(t, githubUser{Login: "jane"}, nil)
+
+	_, err := provider.Exchange(context.Background(), "code", "verifier", "")
+	if !errors.Is(err, ErrUserInfoFailed) {
+		t.Fatalf("err = %v, want %v", err, ErrUserInfoFailed)
+	}
+}
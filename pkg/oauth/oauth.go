@@ -0,0 +1,182 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	codeVerifierSize      = 32
+	codeChallengeMethod   = "S256"
+	maxResponseBodySize   = 1 << 20
+	contentTypeURLEncoded = "application/x-www-form-urlencoded"
+	contentTypeJSON       = "application/json"
+)
+
+var (
+	ErrDiscoveryFailed = errors.New("oauth: provider discovery failed")
+	ErrExchangeFailed  = errors.New("oauth: authorization code exchange failed")
+	ErrInvalidIDToken  = errors.New("oauth: invalid id token")
+	ErrUserInfoFailed  = errors.New("oauth: can not get user info")
+)
+
+// Config is the client registration at the provider.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity is the provider's account the user signed in with.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// GenerateCodeVerifier returns a random PKCE code verifier, RFC 7636 section 4.1.
+func GenerateCodeVerifier() (string, error) {
+	b := make([]byte, codeVerifierSize)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 challenge of the verifier.
+func CodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func authCodeURL(authURL string, cfg Config, state, codeChallenge string, extra url.Values) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", cfg.RedirectURL)
+	query.Set("scope", strings.Join(cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", codeChallengeMethod)
+
+	for key := range extra {
+		query.Set(key, extra.Get(key))
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func exchangeCode(ctx context.Context, client *http.Client, tokenURL string, cfg Config, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectURL)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", cfg.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentTypeURLEncoded)
+	req.Header.Set("Accept", contentTypeJSON)
+
+	var token tokenResponse
+
+	status, err := doJSON(client, req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrExchangeFailed, err)
+	}
+
+	if status != http.StatusOK || token.Error != "" || token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: status %d %s %s", ErrExchangeFailed, status, token.Error, token.ErrorDescription)
+	}
+
+	return &token, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", contentTypeJSON)
+
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	status, err := doJSON(client, req, v)
+	if err != nil {
+		return err
+	}
+
+	if status != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", status, url)
+	}
+
+	return nil
+}
+
+func doJSON(client *http.Client, req *http.Request, v interface{}) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return 0, err
+	}
+
+	if err = json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, err
+	}
+
+	return resp.StatusCode, nil
+}
+
+// splitName splits the full name into the first name and the rest.
+func splitName(name string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(name), " ", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,251 @@
+// Package oauthtest runs a local OpenID Connect provider for the tests of the sign-in flow.
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	DiscoveryPath     = "/.well-known/openid-configuration"
+	AuthorizationPath = "/authorize"
+	TokenPath         = "/token"
+	JWKSPath          = "/jwks"
+
+	ClientSecret = "client-secret"
+)
+
+type signingKey struct {
+	kid       string
+	key       *rsa.PrivateKey
+	published bool
+}
+
+// authorization is the consent given in Authorize, redeemed once at the token endpoint.
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+// Provider is a provider with an RSA signing key, the codes are issued by Authorize
+// instead of the user's consent in the browser.
+type Provider struct {
+	*httptest.Server
+
+	ClientID string
+
+	mu             sync.Mutex
+	keys           []*signingKey
+	authorizations map[string]*authorization
+	jwksRequests   int
+}
+
+func NewProvider(clientID string) *Provider {
+	p := &Provider{
+		ClientID:       clientID,
+		authorizations: make(map[string]*authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(DiscoveryPath, p.discovery)
+	mux.HandleFunc(TokenPath, p.token)
+	mux.HandleFunc(JWKSPath, p.jwks)
+
+	p.Server = httptest.NewServer(mux)
+	p.AddKey(true)
+
+	return p
+}
+
+// Issuer is the URL of the provider.
+func (p *Provider) Issuer() string {
+	return p.URL
+}
+
+// AddKey creates the key the next ID tokens are signed with and returns its id.
+// An unpublished key is not in the JWKS, so the tokens signed with it can not be verified.
+func (p *Provider) AddKey(publish bool) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kid := fmt.Sprintf("key-%d", len(p.keys)+1)
+	p.keys = append(p.keys, &signingKey{kid: kid, key: key, published: publish})
+
+	return kid
+}
+
+// JWKSRequests returns how many times the key set was fetched.
+func (p *Provider) JWKSRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.jwksRequests
+}
+
+// Authorize plays the user who consents on the authorization page: it checks the authorization URL
+// and returns the code and the state the provider redirects back with. The claims are added to the
+// ID token, a nil claim removes the default one.
+func (p *Provider) Authorize(authURL string, claims jwt.MapClaims) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Scheme+"://"+u.Host != p.URL || u.Path != AuthorizationPath {
+		return "", "", fmt.Errorf("unexpected authorization endpoint %s", authURL)
+	}
+
+	query := u.Query()
+
+	switch {
+	case query.Get("response_type") != "code":
+		return "", "", errors.New("unexpected response type")
+	case query.Get("client_id") != p.ClientID:
+		return "", "", errors.New("unexpected client id")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", "", errors.New("no S256 code challenge")
+	case query.Get("state") == "":
+		return "", "", errors.New("no state")
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":   p.URL,
+		"aud":   p.ClientID,
+		"sub":   "subject",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+
+		idClaims[name] = value
+	}
+
+	code = fmt.Sprintf("code-%d", time.Now().UnixNano())
+
+	p.mu.Lock()
+	p.authorizations[code] = &authorization{
+		clientID:      p.ClientID,
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        idClaims,
+	}
+	p.mu.Unlock()
+
+	return code, query.Get("state"), nil
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.URL,
+		"authorization_endpoint": p.URL + AuthorizationPath,
+		"token_endpoint":         p.URL + TokenPath,
+		"jwks_uri":               p.URL + JWKSPath,
+	})
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.authorizations[r.PostForm.Get("code")]
+	delete(p.authorizations, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code", !ok,
+		r.PostForm.Get("client_id") != auth.clientID,
+		r.PostForm.Get("client_secret") != ClientSecret,
+		r.PostForm.Get("redirect_uri") != auth.redirectURI,
+		base64.RawURLEncoding.EncodeToString(verifierHash[:]) != auth.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := p.sign(auth.claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.jwksRequests++
+
+	keys := make([]map[string]string, 0, len(p.keys))
+
+	for _, key := range p.keys {
+		if !key.published {
+			continue
+		}
+
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": key.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.key.E)).Bytes()),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// sign signs the ID token with the latest key.
+func (p *Provider) sign(claims jwt.MapClaims) (string, error) {
+	p.mu.Lock()
+	key := p.keys[len(p.keys)-1]
+	p.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.kid
+
+	return token.SignedString(key.key)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
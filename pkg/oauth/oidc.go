@@ -0,0 +1,230 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	GoogleIssuer = "https://accounts.google.com"
+)
+
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider signs users in with an OpenID Connect provider, its endpoints are read from
+// the discovery document and the ID token is verified against the provider's JWKS.
+type OIDCProvider struct {
+	cfg          Config
+	discoveryURL string
+	client       *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *keySet
+}
+
+// NewOIDC creates a provider of the issuer, discoveryURL may be either the issuer
+// or the full URL of its discovery document.
+func NewOIDC(discoveryURL string, cfg Config, timeout time.Duration) *OIDCProvider {
+	if !strings.HasSuffix(discoveryURL, discoveryPath) {
+		discoveryURL = strings.TrimRight(discoveryURL, "/") + discoveryPath
+	}
+
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultOIDCScopes
+	}
+
+	return &OIDCProvider{
+		cfg:          cfg,
+		discoveryURL: discoveryURL,
+		client:       &http.Client{Timeout: timeout},
+	}
+}
+
+func NewGoogle(cfg Config, timeout time.Duration) *OIDCProvider {
+	return NewOIDC(GoogleIssuer, cfg, timeout)
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, codeChallenge, nonce string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return authCodeURL(discovery.AuthorizationEndpoint, p.cfg, state, codeChallenge, url.Values{"nonce": {nonce}})
+}
+
+// Exchange redeems the code and returns the identity from the verified ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := exchangeCode(ctx, p.client, discovery.TokenEndpoint, p.cfg, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id token", ErrInvalidIDToken)
+	}
+
+	claims, err := p.verifyIDToken(ctx, discovery, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:       stringClaim(claims, "sub"),
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		FirstName:     stringClaim(claims, "given_name"),
+		LastName:      stringClaim(claims, "family_name"),
+	}
+
+	if identity.FirstName == "" && identity.LastName == "" {
+		identity.FirstName, identity.LastName = splitName(stringClaim(claims, "name"))
+	}
+
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	return identity, nil
+}
+
+// verifyIDToken checks the signature, the expiration, the issuer, the audience and the nonce,
+// OpenID Connect Core section 3.1.3.7.
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *discoveryDocument, idToken, nonce string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return p.keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidIDToken
+	}
+
+	if stringClaim(claims, "iss") != discovery.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+
+	audience := audienceClaim(claims)
+	if !contains(audience, p.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+
+	if azp := stringClaim(claims, "azp"); len(audience) > 1 && azp != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	}
+
+	if _, ok = claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: no expiration", ErrInvalidIDToken)
+	}
+
+	if stringClaim(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: unexpected nonce", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// discover fetches the discovery document once, failures are retried on the next call.
+func (p *OIDCProvider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery discoveryDocument
+
+	if err := getJSON(ctx, p.client, p.discoveryURL, "", &discovery); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiscoveryFailed, err)
+	}
+
+	if discovery.Issuer == "" || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", ErrDiscoveryFailed)
+	}
+
+	p.discovery = &discovery
+	p.keys = newKeySet(discovery.JWKSURI, p.client)
+
+	return p.discovery, nil
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+
+	return value
+}
+
+// boolClaim accepts both booleans and strings, some providers send "true".
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
+
+// audienceClaim returns aud which is either a string or an array of strings.
+func audienceClaim(claims jwt.MapClaims) []string {
+	switch value := claims["aud"].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		audience := make([]string, 0, len(value))
+		for i := range value {
+			if s, ok := value[i].(string); ok {
+				audience = append(audience, s)
+			}
+		}
+
+		return audience
+	default:
+		return nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+
+	return false
+}
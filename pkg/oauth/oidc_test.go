@@ -0,0 +1,253 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"necutya/faker/pkg/oauth/oauthtest"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	testClientID    = "client-id"
+	testRedirectURL = "https://app.example/oauth/callback"
+	testNonce       = "nonce"
+)
+
+func newTestOIDC(t *testing.T) (*oauthtest.Provider, *OIDCProvider) {
+	t.Helper()
+
+	stub := oauthtest.NewProvider(testClientID)
+	t.Cleanup(stub.Close)
+
+	provider := NewOIDC(stub.Issuer(), Config{
+		ClientID:     testClientID,
+		ClientSecret: oauthtest.ClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, 5*time.Second)
+
+	return stub, provider
+}
+
+// signIn runs the flow with the claims added to the ID token.
+func signIn(t *testing.T, stub *oauthtest.Provider, provider *OIDCProvider, claims jwt.MapClaims) (*Identity, error) {
+	t.Helper()
+
+	verifier, err := GenerateCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", CodeChallenge(verifier), testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := stub.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider.Exchange(context.Background(), code, verifier, testNonce)
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	stub, provider := newTestOIDC(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", CodeChallenge("verifier"), testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := u.Scheme + "://" + u.Host + u.Path; got != stub.URL+oauthtest.AuthorizationPath {
+		t.Errorf("authorization endpoint = %s, want the discovered one", got)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 testNonce,
+		"code_challenge":        CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+
+	for name, value := range want {
+		if got := u.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestOIDCDiscoveryFailed(t *testing.T) {
+	stub, provider := newTestOIDC(t)
+	stub.Close()
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", CodeChallenge("verifier"), testNonce)
+	if !errors.Is(err, ErrDiscoveryFailed) {
+		t.Fatalf("err = %v, want %v", err, ErrDiscoveryFailed)
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	stub, provider := newTestOIDC(t)
+
+	identity, err := signIn(t, stub, provider, jwt.MapClaims{
+		"email":          "jane@example.com",
+		"email_verified": "true",
+		"name":           "Jane van Doe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Identity{
+		Subject:       "subject",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		FirstName:     "Jane",
+		LastName:      "van Doe",
+	}
+
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestOIDCExchangeCodeVerifier(t *testing.T) {
+	stub, provider := newTestOIDC(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", CodeChallenge("verifier"), testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := stub.Authorize(authURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = provider.Exchange(context.Background(), code, "another verifier", testNonce)
+	if !errors.Is(err, ErrExchangeFailed) {
+		t.Fatalf("err = %v, want %v", err, ErrExchangeFailed)
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{name: "valid"},
+		{name: "audience in array", claims: jwt.MapClaims{"aud": []string{testClientID}}},
+		{
+			name:   "several audiences with azp",
+			claims: jwt.MapClaims{"aud": []string{"another-client", testClientID}, "azp": testClientID},
+		},
+		{name: "another issuer", claims: jwt.MapClaims{"iss": "https://issuer.example"}, wantErr: true},
+		{name: "another audience", claims: jwt.MapClaims{"aud": "another-client"}, wantErr: true},
+		{name: "no audience", claims: jwt.MapClaims{"aud": nil}, wantErr: true},
+		{
+			name:    "several audiences without azp",
+			claims:  jwt.MapClaims{"aud": []string{"another-client", testClientID}},
+			wantErr: true,
+		},
+		{
+			name:    "several audiences with another azp",
+			claims:  jwt.MapClaims{"aud": []string{"another-client", testClientID}, "azp": "another-client"},
+			wantErr: true,
+		},
+		{name: "another nonce", claims: jwt.MapClaims{"nonce": "another nonce"}, wantErr: true},
+		{name: "no nonce", claims: jwt.MapClaims{"nonce": nil}, wantErr: true},
+		{name: "expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}, wantErr: true},
+		{name: "no expiration", claims: jwt.MapClaims{"exp": nil}, wantErr: true},
+		{name: "no subject", claims: jwt.MapClaims{"sub": nil}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			stub, provider := newTestOIDC(t)
+
+			_, err := signIn(t, stub, provider, tt.claims)
+			if !tt.wantErr && err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestOIDCUnknownKey(t *testing.T) {
+	stub, provider := newTestOIDC(t)
+
+	if _, err := signIn(t, stub, provider, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stub.AddKey(false)
+
+	provider.keys.fetchedAt = time.Now().Add(-keysRefreshInterval)
+
+	if _, err := signIn(t, stub, provider, nil); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidIDToken)
+	}
+
+	if got := stub.JWKSRequests(); got != 2 {
+		t.Errorf("key set fetched %d times, want 2", got)
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	stub, provider := newTestOIDC(t)
+
+	if _, err := signIn(t, stub, provider, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stub.AddKey(true)
+
+	// the key set was fetched just now, so the new key is not looked up yet
+	if _, err := signIn(t, stub, provider, nil); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidIDToken)
+	}
+
+	if got := stub.JWKSRequests(); got != 1 {
+		t.Errorf("key set fetched %d times within the refresh interval, want 1", got)
+	}
+
+	provider.keys.fetchedAt = time.Now().Add(-keysRefreshInterval)
+
+	if _, err := signIn(t, stub, provider, nil); err != nil {
+		t.Fatalf("err = %v after the refresh interval, want nil", err)
+	}
+
+	if got := stub.JWKSRequests(); got != 2 {
+		t.Errorf("key set fetched %d times, want 2", got)
+	}
+
+	// the known key is taken from the cache
+	if _, err := signIn(t, stub, provider, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stub.JWKSRequests(); got != 2 {
+		t.Errorf("key set fetched %d times for a cached key, want 2", got)
+	}
+}
@@ -0,0 +1,312 @@
+package fake
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"necutya/faker/pkg/payments"
+)
+
+const (
+	providerName = "fake"
+
+	ApproveOutcome = "approve"
+	DeclineOutcome = "decline"
+	ExpireOutcome  = "expire"
+
+	signatureHeader      = "X-Fake-Signature"
+	recurringTokenPrefix = "fake_"
+	secretLength         = 32
+)
+
+var ErrUnknownOutcome = errors.New("unknown payment outcome")
+
+type Config struct {
+	// CheckoutURL is the page of the checkout, %s is replaced with the order id.
+	CheckoutURL string
+	Currency    string
+	// Secret signs the simulated webhooks, a random one is used if it is empty.
+	Secret string
+	// RecurringOutcome is the outcome of every recurring charge, approve by default.
+	RecurringOutcome string
+}
+
+// event is the webhook of the fake provider.
+type event struct {
+	PaymentID      string `json:"payment_id"`
+	OrderID        string `json:"order_id"`
+	ProductID      string `json:"product_id"`
+	Status         string `json:"status"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	RecurringToken string `json:"recurring_token,omitempty"`
+	RefundedAmount int64  `json:"refunded_amount"`
+}
+
+func (e *event) toPayment(raw []byte) *payments.Payment {
+	return &payments.Payment{
+		Provider:       providerName,
+		PaymentID:      e.PaymentID,
+		OrderID:        e.OrderID,
+		ProductID:      e.ProductID,
+		Status:         payments.Status(e.Status),
+		Amount:         e.Amount,
+		Currency:       e.Currency,
+		RecurringToken: e.RecurringToken,
+		RefundedAmount: e.RefundedAmount,
+		Raw:            string(raw),
+	}
+}
+
+type checkout struct {
+	request payments.CheckoutRequest
+	// event is the last state of the payment, nil until the outcome is simulated
+	event *event
+}
+
+// PaymentsManager simulates a payment provider in memory, the outcome of a checkout
+// is chosen with Simulate which returns the signed webhook the provider would send.
+type PaymentsManager struct {
+	cfg    Config
+	secret []byte
+
+	mu            sync.Mutex
+	checkouts     map[string]*checkout
+	refunds       map[string]*payments.Refund
+	nextPaymentID int64
+}
+
+func NewPaymentManager(cfg Config) (*PaymentsManager, error) {
+	secret := []byte(cfg.Secret)
+
+	if len(secret) == 0 {
+		secret = make([]byte, secretLength)
+
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.RecurringOutcome == "" {
+		cfg.RecurringOutcome = ApproveOutcome
+	}
+
+	return &PaymentsManager{
+		cfg:       cfg,
+		secret:    secret,
+		checkouts: make(map[string]*checkout),
+		refunds:   make(map[string]*payments.Refund),
+	}, nil
+}
+
+func (pm *PaymentsManager) Name() string {
+	return providerName
+}
+
+func (pm *PaymentsManager) Currency() string {
+	return pm.cfg.Currency
+}
+
+func (pm *PaymentsManager) CreateCheckout(_ context.Context, req payments.CheckoutRequest) (string, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.checkouts[req.OrderID] = &checkout{request: req}
+
+	return fmt.Sprintf(pm.cfg.CheckoutURL, req.OrderID), nil
+}
+
+// Simulate finishes the checkout of the order with the outcome and returns the header
+// and the body of the webhook reporting it.
+func (pm *PaymentsManager) Simulate(orderID, outcome string) (http.Header, []byte, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	c, ok := pm.checkouts[orderID]
+	if !ok {
+		return nil, nil, payments.ErrPaymentUnknown
+	}
+
+	status, err := outcomeStatus(outcome)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e := pm.newEvent(c.request.OrderID, c.request.ProductID, c.request.Amount, status)
+
+	if c.request.Recurring && status == payments.ApprovedStatus {
+		e.RecurringToken = recurringTokenPrefix + e.PaymentID
+	}
+
+	c.event = e
+
+	return pm.signedEvent(e)
+}
+
+func (pm *PaymentsManager) ChargeRecurring(_ context.Context, req payments.RecurringRequest) (*payments.Payment, error) {
+	if !strings.HasPrefix(req.RecurringToken, recurringTokenPrefix) {
+		return nil, errors.New("fake: malformed recurring token")
+	}
+
+	status, err := outcomeStatus(pm.cfg.RecurringOutcome)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	e := pm.newEvent(req.OrderID, req.ProductID, req.Amount, status)
+
+	pm.checkouts[req.OrderID] = &checkout{
+		request: payments.CheckoutRequest{
+			OrderID:       req.OrderID,
+			Description:   req.Description,
+			CustomerEmail: req.CustomerEmail,
+			ProductID:     req.ProductID,
+			Amount:        req.Amount,
+		},
+		event: e,
+	}
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.toPayment(raw), nil
+}
+
+func (pm *PaymentsManager) VerifyWebhook(_ context.Context, header http.Header, body []byte) (*payments.Payment, error) {
+	signature, err := hex.DecodeString(header.Get(signatureHeader))
+	if err != nil || !hmac.Equal(signature, pm.sign(body)) {
+		return nil, payments.ErrInvalidSignature
+	}
+
+	var e event
+
+	if err = json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+
+	return e.toPayment(body), nil
+}
+
+// Refund refunds the approved payment at once.
+func (pm *PaymentsManager) Refund(_ context.Context, req payments.RefundRequest) (*payments.Refund, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if refund, ok := pm.refunds[req.RefundID]; ok && req.RefundID != "" {
+		return refund, nil
+	}
+
+	c, ok := pm.checkouts[req.OrderID]
+	if !ok || c.event == nil {
+		return nil, payments.ErrPaymentUnknown
+	}
+
+	if c.event.Status != string(payments.ApprovedStatus) || c.event.RefundedAmount+req.Amount > c.event.Amount {
+		return &payments.Refund{Status: payments.DeclinedStatus, Amount: req.Amount}, nil
+	}
+
+	c.event.RefundedAmount += req.Amount
+
+	pm.nextPaymentID++
+
+	refund := &payments.Refund{
+		ID:     "refund_" + strconv.FormatInt(pm.nextPaymentID, 10),
+		Status: payments.ApprovedStatus,
+		Amount: req.Amount,
+	}
+
+	if req.RefundID != "" {
+		pm.refunds[req.RefundID] = refund
+	}
+
+	return refund, nil
+}
+
+func (pm *PaymentsManager) GetPaymentStatus(_ context.Context, orderID string) (*payments.Payment, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	c, ok := pm.checkouts[orderID]
+	if !ok {
+		return nil, payments.ErrPaymentUnknown
+	}
+
+	if c.event == nil {
+		return &payments.Payment{
+			Provider:  providerName,
+			OrderID:   c.request.OrderID,
+			ProductID: c.request.ProductID,
+			Status:    payments.PendingStatus,
+			Amount:    c.request.Amount,
+			Currency:  pm.cfg.Currency,
+		}, nil
+	}
+
+	raw, err := json.Marshal(c.event)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.event.toPayment(raw), nil
+}
+
+// newEvent must be called with the lock held.
+func (pm *PaymentsManager) newEvent(orderID, productID string, amount int64, status payments.Status) *event {
+	pm.nextPaymentID++
+
+	return &event{
+		PaymentID: strconv.FormatInt(pm.nextPaymentID, 10),
+		OrderID:   orderID,
+		ProductID: productID,
+		Status:    string(status),
+		Amount:    amount,
+		Currency:  pm.cfg.Currency,
+	}
+}
+
+func (pm *PaymentsManager) signedEvent(e *event) (http.Header, []byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set(signatureHeader, hex.EncodeToString(pm.sign(body)))
+
+	return header, body, nil
+}
+
+func (pm *PaymentsManager) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, pm.secret)
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
+
+func outcomeStatus(outcome string) (payments.Status, error) {
+	switch outcome {
+	case ApproveOutcome:
+		return payments.ApprovedStatus, nil
+	case DeclineOutcome:
+		return payments.DeclinedStatus, nil
+	case ExpireOutcome:
+		return payments.ExpiredStatus, nil
+	default:
+		return "", ErrUnknownOutcome
+	}
+}
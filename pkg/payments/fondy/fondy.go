@@ -2,10 +2,15 @@ package fondy
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"strings"
+
+	"necutya/faker/pkg/payments"
 )
 
 type PaymentsManager struct {
@@ -33,22 +38,31 @@ func NewPaymentManager(
 	}
 }
 
-func (pm *PaymentsManager) GenerateSubscriptionCheckout(
-	orderID, orderDesc, senderEmail, productID string,
-	amount int64,
-) (string, error) {
+func (pm *PaymentsManager) Name() string {
+	return providerName
+}
+
+func (pm *PaymentsManager) Currency() string {
+	return pm.currency
+}
+
+func (pm *PaymentsManager) CreateCheckout(ctx context.Context, req payments.CheckoutRequest) (string, error) {
 	checkoutReq := checkoutRequest{
-		OrderID:           orderID,
+		OrderID:           req.OrderID,
 		MerchantID:        pm.merchantID,
-		OrderDesc:         orderDesc,
-		ProductID:         productID,
-		Amount:            amount,
+		OrderDesc:         req.Description,
+		ProductID:         req.ProductID,
+		Amount:            req.Amount,
 		Currency:          pm.currency,
-		SenderEmail:       senderEmail,
+		SenderEmail:       req.CustomerEmail,
 		ServerCallbackURL: pm.callbackUrl,
 		ResponseURL:       pm.responseURL,
 	}
 
+	if req.Recurring {
+		checkoutReq.RequiredRectoken = yesState
+	}
+
 	checkoutReq.setSignature(pm.merchantPassword)
 
 	apiRequest := apiCheckoutRequest{
@@ -60,24 +74,13 @@ func (pm *PaymentsManager) GenerateSubscriptionCheckout(
 		return "", err
 	}
 
-	resp, err := http.Post(
-		checkoutUrl,
-		contentTypeApplicationJson,
-		bytes.NewBuffer(requestBody),
-	)
+	body, err := pm.post(ctx, checkoutUrl, requestBody)
 	if err != nil {
 		return "", err
 	}
 
-	defer resp.Body.Close()
-
 	apiResponse := apiCheckoutResponse{}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
 	err = json.Unmarshal(body, &apiResponse)
 	if err != nil {
 		return "", err
@@ -93,11 +96,215 @@ func (pm *PaymentsManager) GenerateSubscriptionCheckout(
 	return "", ErrInvalidResponseStatus
 }
 
-func (pm *PaymentsManager) ValidateCallback(input interface{}) error {
-	_, ok := input.(Callback)
-	if !ok {
-		return errors.New("invalid callback data")
+// ChargeRecurring charges the card saved at the checkout by its rectoken.
+func (pm *PaymentsManager) ChargeRecurring(ctx context.Context, req payments.RecurringRequest) (*payments.Payment, error) {
+	recurringReq := recurringRequest{
+		OrderID:           req.OrderID,
+		MerchantID:        pm.merchantID,
+		OrderDesc:         req.Description,
+		Amount:            req.Amount,
+		Currency:          pm.currency,
+		Rectoken:          req.RecurringToken,
+		SenderEmail:       req.CustomerEmail,
+		ProductID:         req.ProductID,
+		ServerCallbackURL: pm.callbackUrl,
+	}
+
+	recurringReq.setSignature(pm.merchantPassword)
+
+	requestBody, err := json.Marshal(apiRecurringRequest{
+		Request: &recurringReq,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pm.call(ctx, recurringUrl, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return pm.decodeCallback(response)
+}
+
+// VerifyWebhook checks the callback sent to the callback URL, its signature is generated
+// by the same rules as the one of the checkout.
+func (pm *PaymentsManager) VerifyWebhook(_ context.Context, _ http.Header, body []byte) (*payments.Payment, error) {
+	return pm.decodeCallback(body)
+}
+
+// Refund reverses the amount of the approved order, the amount may be less than the paid one.
+func (pm *PaymentsManager) Refund(ctx context.Context, req payments.RefundRequest) (*payments.Refund, error) {
+	reverseReq := reverseRequest{
+		OrderID:    req.OrderID,
+		MerchantID: pm.merchantID,
+		Amount:     req.Amount,
+		Currency:   pm.currency,
+		Comment:    req.Comment,
+	}
+
+	reverseReq.setSignature(pm.merchantPassword)
+
+	requestBody, err := json.Marshal(apiReverseRequest{
+		Request: &reverseReq,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pm.call(ctx, reverseUrl, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pm.verifySignature(response); err != nil {
+		return nil, err
+	}
+
+	var reverseResp reverseResponse
+
+	if err = json.Unmarshal(response, &reverseResp); err != nil {
+		return nil, err
+	}
+
+	amount, err := parseAmount(reverseResp.ReversalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &payments.Refund{
+		ID:     reverseResp.TransactionID,
+		Amount: amount,
+	}
+
+	switch reverseResp.ReverseStatus {
+	case "approved":
+		refund.Status = payments.ApprovedStatus
+	case "declined":
+		refund.Status = payments.DeclinedStatus
+	default:
+		refund.Status = payments.PendingStatus
+	}
+
+	return refund, nil
+}
+
+func (pm *PaymentsManager) GetPaymentStatus(ctx context.Context, orderID string) (*payments.Payment, error) {
+	statusReq := statusRequest{
+		OrderID:    orderID,
+		MerchantID: pm.merchantID,
+	}
+
+	statusReq.setSignature(pm.merchantPassword)
+
+	requestBody, err := json.Marshal(apiStatusRequest{
+		Request: &statusReq,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pm.call(ctx, statusUrl, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return pm.decodeCallback(response)
+}
+
+func (pm *PaymentsManager) decodeCallback(data []byte) (*payments.Payment, error) {
+	var callback Callback
+
+	if err := json.Unmarshal(data, &callback); err != nil {
+		return nil, err
+	}
+
+	if err := pm.validateCallback(callback); err != nil {
+		return nil, err
+	}
+
+	return callback.toPayment(data)
+}
+
+// validateCallback checks that the callback is addressed to the merchant
+// and its signature is generated by the same rules as the one of the checkout.
+func (pm *PaymentsManager) validateCallback(callback Callback) error {
+	if int64(callback.MerchantId) != pm.merchantID {
+		return payments.ErrInvalidMerchant
+	}
+
+	params, err := callback.signatureParams()
+	if err != nil {
+		return err
+	}
+
+	return pm.checkSignature(params, callback.Signature)
+}
+
+// verifySignature checks the signature of the API response.
+func (pm *PaymentsManager) verifySignature(response []byte) error {
+	params, err := decodeParams(response)
+	if err != nil {
+		return err
+	}
+
+	signature, _ := params["signature"].(string)
+
+	return pm.checkSignature(signedParams(params), signature)
+}
+
+func (pm *PaymentsManager) checkSignature(params map[string]interface{}, signature string) error {
+	expected := generateSignature(params, pm.merchantPassword)
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(signature))) != 1 {
+		return payments.ErrInvalidSignature
 	}
 
 	return nil
 }
+
+// call posts the request to the API and returns the response once its status is checked.
+func (pm *PaymentsManager) call(ctx context.Context, url string, requestBody []byte) (json.RawMessage, error) {
+	body, err := pm.post(ctx, url, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	apiResp := apiResponse{}
+
+	err = json.Unmarshal(body, &apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	errorResp := errorResponse{}
+
+	err = json.Unmarshal(apiResp.Response, &errorResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if errorResp.ResponseStatus == failureRespStatus {
+		return nil, errors.New(errorResp.ErrorMessage)
+	}
+
+	return apiResp.Response, nil
+}
+
+func (pm *PaymentsManager) post(ctx context.Context, url string, requestBody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentTypeApplicationJson)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
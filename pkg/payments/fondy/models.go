@@ -1,19 +1,28 @@
 package fondy
 
 import (
+	"bytes"
 	"crypto/sha1"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 
+	"necutya/faker/pkg/payments"
+
 	"github.com/fatih/structs"
 )
 
 const (
 	contentTypeApplicationJson = "application/json"
 	checkoutUrl                = "https://pay.fondy.eu/api/checkout/url/"
+	recurringUrl               = "https://pay.fondy.eu/api/recurring"
+	reverseUrl                 = "https://pay.fondy.eu/api/reverse/order_id"
+	statusUrl                  = "https://pay.fondy.eu/api/status/order_id"
+
+	providerName = "fondy"
 
 	signatureDelimiter = "|"
 	successRespStatus  = "success"
@@ -31,6 +40,12 @@ var (
 	ErrInvalidResponseStatus = errors.New("invalid response status")
 )
 
+// unsignedParams are not included in the signature of the callbacks and the responses.
+var unsignedParams = map[string]bool{
+	"signature":                 true,
+	"response_signature_string": true,
+}
+
 type apiCheckoutRequest struct {
 	Request *checkoutRequest `json:"request"`
 }
@@ -45,6 +60,7 @@ type checkoutRequest struct {
 	SenderEmail       string `json:"sender_email"`
 	Subscription      string `json:"subscription,omitempty"`
 	ProductID         string `json:"product_id"`
+	RequiredRectoken  string `json:"required_rectoken,omitempty"`
 	ServerCallbackURL string `json:"server_callback_url"`
 	ResponseURL       string `json:"response_url"`
 }
@@ -54,6 +70,82 @@ func (cr *checkoutRequest) setSignature(password string) {
 	cr.Signature = generateSignature(params, password)
 }
 
+type apiRecurringRequest struct {
+	Request *recurringRequest `json:"request"`
+}
+
+// recurringRequest charges the card saved at the checkout by its rectoken.
+type recurringRequest struct {
+	OrderID           string `json:"order_id"`
+	MerchantID        int64  `json:"merchant_id"`
+	OrderDesc         string `json:"order_desc"`
+	Signature         string `json:"signature"`
+	Amount            int64  `json:"amount"`
+	Currency          string `json:"currency"`
+	Rectoken          string `json:"rectoken"`
+	SenderEmail       string `json:"sender_email"`
+	ProductID         string `json:"product_id"`
+	ServerCallbackURL string `json:"server_callback_url"`
+}
+
+func (rr *recurringRequest) setSignature(password string) {
+	params := structs.Map(rr)
+	rr.Signature = generateSignature(params, password)
+}
+
+type apiReverseRequest struct {
+	Request *reverseRequest `json:"request"`
+}
+
+// reverseRequest returns the amount of the approved order to the card.
+type reverseRequest struct {
+	OrderID    string `json:"order_id"`
+	MerchantID int64  `json:"merchant_id"`
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	Comment    string `json:"comment,omitempty"`
+	Signature  string `json:"signature"`
+}
+
+func (rr *reverseRequest) setSignature(password string) {
+	params := structs.Map(rr)
+	rr.Signature = generateSignature(params, password)
+}
+
+type reverseResponse struct {
+	OrderID        string `json:"order_id"`
+	ReverseStatus  string `json:"reverse_status"` // created; processing; declined; approved;
+	ReversalAmount string `json:"reversal_amount"`
+	TransactionID  string `json:"transaction_id"`
+	Signature      string `json:"signature"`
+}
+
+type apiStatusRequest struct {
+	Request *statusRequest `json:"request"`
+}
+
+type statusRequest struct {
+	OrderID    string `json:"order_id"`
+	MerchantID int64  `json:"merchant_id"`
+	Signature  string `json:"signature"`
+}
+
+func (sr *statusRequest) setSignature(password string) {
+	params := structs.Map(sr)
+	sr.Signature = generateSignature(params, password)
+}
+
+// apiResponse wraps the responses of the API, they are decoded once the status is checked.
+type apiResponse struct {
+	Response json.RawMessage `json:"response"`
+}
+
+type errorResponse struct {
+	ResponseStatus string `json:"response_status"`
+	ErrorMessage   string `json:"error_message"`
+	ErrorCode      int64  `json:"error_code"`
+}
+
 type apiCheckoutResponse struct {
 	Response *checkoutResponse `json:"response"`
 }
@@ -103,14 +195,140 @@ type Callback struct {
 	ProductId               string      `json:"product_id"`
 	AdditionalInfo          string      `json:"additional_info"`
 	ResponseSignatureString string      `json:"response_signature_string"`
+
+	// params are the raw values of the callback as they were signed by Fondy
+	params map[string]interface{}
+}
+
+func (c *Callback) UnmarshalJSON(data []byte) error {
+	type callback Callback
+
+	if err := json.Unmarshal(data, (*callback)(c)); err != nil {
+		return err
+	}
+
+	params, err := decodeParams(data)
+	if err != nil {
+		return err
+	}
+
+	c.params = params
+
+	return nil
+}
+
+// signatureParams returns the params of the callback the signature is generated from.
+func (c Callback) signatureParams() (map[string]interface{}, error) {
+	params := c.params
+
+	if params == nil {
+		type callback Callback
+
+		data, err := json.Marshal(callback(c))
+		if err != nil {
+			return nil, err
+		}
+
+		if params, err = decodeParams(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return signedParams(params), nil
+}
+
+// signedParams returns the params the signature is generated from as strings.
+func signedParams(params map[string]interface{}) map[string]interface{} {
+	signed := make(map[string]interface{}, len(params))
+
+	for k, v := range params {
+		if unsignedParams[k] {
+			continue
+		}
+
+		switch value := v.(type) {
+		case string:
+			signed[k] = value
+		case json.Number:
+			signed[k] = value.String()
+		case bool:
+			signed[k] = strconv.FormatBool(value)
+		}
+	}
+
+	return signed
+}
+
+// decodeParams decodes the JSON object keeping the numbers as they were sent.
+func decodeParams(data []byte) (map[string]interface{}, error) {
+	var params map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
 }
 
 func (c Callback) Success() bool {
 	return c.ResponseStatus == "success"
 }
 
-func (c Callback) PaymentApproved() bool {
-	return c.OrderStatus == "approved"
+func (c Callback) paymentStatus() payments.Status {
+	if !c.Success() {
+		return payments.DeclinedStatus
+	}
+
+	switch c.OrderStatus {
+	case "approved":
+		return payments.ApprovedStatus
+	case "declined":
+		return payments.DeclinedStatus
+	case "expired":
+		return payments.ExpiredStatus
+	case "reversed":
+		return payments.ReversedStatus
+	default:
+		return payments.PendingStatus
+	}
+}
+
+// toPayment converts the callback, raw is the payload the callback is decoded from.
+func (c Callback) toPayment(raw []byte) (*payments.Payment, error) {
+	amount, err := parseAmount(c.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	refundedAmount, err := parseAmount(c.ReversalAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &payments.Payment{
+		Provider:       providerName,
+		PaymentID:      strconv.Itoa(c.PaymentId),
+		OrderID:        c.OrderId,
+		ProductID:      c.ProductId,
+		Status:         c.paymentStatus(),
+		Amount:         amount,
+		Currency:       c.Currency,
+		RecurringToken: c.Rectoken,
+		RefundedAmount: refundedAmount,
+		Raw:            string(raw),
+	}, nil
+}
+
+// parseAmount parses the amount in cents, which is sent as a string.
+func parseAmount(amount string) (int64, error) {
+	if amount == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(amount, 10, 64)
 }
 
 func generateSignature(params map[string]interface{}, password string) string {
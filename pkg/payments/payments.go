@@ -0,0 +1,76 @@
+package payments
+
+import "errors"
+
+// Status is the state of the payment reported by the provider.
+type Status string
+
+const (
+	PendingStatus  Status = "pending"
+	ApprovedStatus Status = "approved"
+	DeclinedStatus Status = "declined"
+	ExpiredStatus  Status = "expired"
+	ReversedStatus Status = "reversed"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidMerchant  = errors.New("webhook is addressed to another merchant")
+	// ErrIgnoredEvent is returned for the webhooks which are not about payments, they are acknowledged as is.
+	ErrIgnoredEvent   = errors.New("webhook event is ignored")
+	ErrPaymentUnknown = errors.New("payment is unknown to the provider")
+)
+
+// CheckoutRequest describes the payment of the order, amounts are in cents.
+type CheckoutRequest struct {
+	OrderID       string
+	Description   string
+	CustomerEmail string
+	ProductID     string
+	Amount        int64
+	// Recurring asks the provider to save the card so the following periods are charged without the customer.
+	Recurring bool
+}
+
+// RecurringRequest charges the card saved at the checkout by its token.
+type RecurringRequest struct {
+	OrderID        string
+	Description    string
+	CustomerEmail  string
+	ProductID      string
+	RecurringToken string
+	Amount         int64
+}
+
+type RefundRequest struct {
+	OrderID   string
+	PaymentID string
+	// RefundID identifies the refund, the providers which support it make the repeated request only once.
+	RefundID string
+	Amount   int64
+	Comment  string
+}
+
+// Payment is the state of the payment of the order as reported by the provider,
+// either in a verified webhook or in an API response.
+type Payment struct {
+	Provider  string
+	PaymentID string
+	OrderID   string
+	ProductID string
+	Status    Status
+	Amount    int64
+	Currency  string
+	// RecurringToken is set once the provider has saved the card.
+	RecurringToken string
+	// RefundedAmount is the total refunded and reversed amount of the payment.
+	RefundedAmount int64
+	// Raw is the payload of the provider, it is stored with the transaction.
+	Raw string
+}
+
+type Refund struct {
+	ID     string
+	Status Status
+	Amount int64
+}
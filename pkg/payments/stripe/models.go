@@ -0,0 +1,138 @@
+package stripe
+
+import (
+	"encoding/json"
+	"strings"
+
+	"necutya/faker/pkg/payments"
+)
+
+const (
+	apiURL = "https://api.stripe.com/v1"
+
+	providerName = "stripe"
+
+	signatureHeader = "Stripe-Signature"
+	// webhookTolerance is how old the signed webhook can be, in seconds
+	webhookTolerance = 5 * 60
+
+	orderIDMetadata   = "order_id"
+	productIDMetadata = "product_id"
+
+	// recurringTokenDelimiter joins the customer and the payment method into the recurring token
+	recurringTokenDelimiter = ":"
+)
+
+type apiError struct {
+	Error struct {
+		Type          string         `json:"type"`
+		Code          string         `json:"code"`
+		Message       string         `json:"message"`
+		PaymentIntent *paymentIntent `json:"payment_intent"`
+	} `json:"error"`
+}
+
+type checkoutSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+type event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object paymentIntent `json:"object"`
+	} `json:"data"`
+}
+
+type paymentIntent struct {
+	ID       string `json:"id"`
+	Object   string `json:"object"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// Status is one of requires_payment_method, requires_confirmation, requires_action,
+	// processing, requires_capture, canceled, succeeded
+	Status           string            `json:"status"`
+	Customer         string            `json:"customer"`
+	PaymentMethod    string            `json:"payment_method"`
+	SetupFutureUsage string            `json:"setup_future_usage"`
+	Created          int64             `json:"created"`
+	Metadata         map[string]string `json:"metadata"`
+	LatestCharge     *charge           `json:"latest_charge"`
+	LastPaymentError *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+func (pi *paymentIntent) paymentStatus() payments.Status {
+	switch pi.Status {
+	case "succeeded":
+		return payments.ApprovedStatus
+	case "canceled":
+		return payments.ExpiredStatus
+	case "requires_payment_method":
+		if pi.LastPaymentError != nil {
+			return payments.DeclinedStatus
+		}
+	}
+
+	return payments.PendingStatus
+}
+
+// toPayment converts the payment intent, raw is the payload it is decoded from.
+func (pi *paymentIntent) toPayment(raw []byte) *payments.Payment {
+	var recurringToken string
+	if pi.SetupFutureUsage != "" && pi.Customer != "" && pi.PaymentMethod != "" {
+		recurringToken = pi.Customer + recurringTokenDelimiter + pi.PaymentMethod
+	}
+
+	return &payments.Payment{
+		Provider:       providerName,
+		PaymentID:      pi.ID,
+		OrderID:        pi.Metadata[orderIDMetadata],
+		ProductID:      pi.Metadata[productIDMetadata],
+		Status:         pi.paymentStatus(),
+		Amount:         pi.Amount,
+		Currency:       strings.ToUpper(pi.Currency),
+		RecurringToken: recurringToken,
+		RefundedAmount: pi.LatestCharge.amountRefunded(),
+		Raw:            string(raw),
+	}
+}
+
+// charge is the latest charge of the payment intent, it is expanded in the API responses
+// which ask for it and is only the id otherwise.
+type charge struct {
+	ID             string `json:"id"`
+	AmountRefunded int64  `json:"amount_refunded"`
+}
+
+func (c *charge) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.ID)
+	}
+
+	type expandedCharge charge
+
+	return json.Unmarshal(data, (*expandedCharge)(c))
+}
+
+func (c *charge) amountRefunded() int64 {
+	if c == nil {
+		return 0
+	}
+
+	return c.AmountRefunded
+}
+
+type searchResult struct {
+	Data []*paymentIntent `json:"data"`
+}
+
+type refund struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+	// Status is one of pending, requires_action, succeeded, failed, canceled
+	Status string `json:"status"`
+}
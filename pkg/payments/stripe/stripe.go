@@ -0,0 +1,336 @@
+package stripe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"necutya/faker/pkg/payments"
+)
+
+type Config struct {
+	SecretKey     string
+	WebhookSecret string
+	Currency      string
+	SuccessURL    string
+	CancelURL     string
+}
+
+// PaymentsManager takes payments with Stripe Checkout, the payments are reported
+// by the payment_intent webhooks signed with the webhook secret.
+type PaymentsManager struct {
+	cfg    Config
+	client *http.Client
+	apiURL string
+}
+
+func NewPaymentManager(cfg Config, timeout time.Duration) *PaymentsManager {
+	return &PaymentsManager{
+		cfg:    cfg,
+		client: &http.Client{Timeout: timeout},
+		apiURL: apiURL,
+	}
+}
+
+func (pm *PaymentsManager) Name() string {
+	return providerName
+}
+
+func (pm *PaymentsManager) Currency() string {
+	return pm.cfg.Currency
+}
+
+func (pm *PaymentsManager) CreateCheckout(ctx context.Context, req payments.CheckoutRequest) (string, error) {
+	form := url.Values{
+		"mode":                                {"payment"},
+		"success_url":                         {pm.cfg.SuccessURL},
+		"cancel_url":                          {pm.cfg.CancelURL},
+		"client_reference_id":                 {req.OrderID},
+		"customer_email":                      {req.CustomerEmail},
+		"metadata[" + orderIDMetadata + "]":   {req.OrderID},
+		"metadata[" + productIDMetadata + "]": {req.ProductID},
+
+		"line_items[0][quantity]":                                  {"1"},
+		"line_items[0][price_data][currency]":                      {strings.ToLower(pm.cfg.Currency)},
+		"line_items[0][price_data][unit_amount]":                   {strconv.FormatInt(req.Amount, 10)},
+		"line_items[0][price_data][product_data][name]":            {req.Description},
+		"payment_intent_data[metadata][" + orderIDMetadata + "]":   {req.OrderID},
+		"payment_intent_data[metadata][" + productIDMetadata + "]": {req.ProductID},
+	}
+
+	if req.Recurring {
+		form.Set("customer_creation", "always")
+		form.Set("payment_intent_data[setup_future_usage]", "off_session")
+	}
+
+	var session checkoutSession
+
+	if err := pm.call(ctx, http.MethodPost, "/checkout/sessions", form, "", &session); err != nil {
+		return "", err
+	}
+
+	return session.URL, nil
+}
+
+// ChargeRecurring confirms a payment with the payment method saved at the checkout,
+// the customer is not present so a declined card is reported as the declined payment.
+func (pm *PaymentsManager) ChargeRecurring(ctx context.Context, req payments.RecurringRequest) (*payments.Payment, error) {
+	parts := strings.SplitN(req.RecurringToken, recurringTokenDelimiter, 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("stripe: malformed recurring token")
+	}
+
+	form := url.Values{
+		"amount":                              {strconv.FormatInt(req.Amount, 10)},
+		"currency":                            {strings.ToLower(pm.cfg.Currency)},
+		"customer":                            {parts[0]},
+		"payment_method":                      {parts[1]},
+		"off_session":                         {"true"},
+		"confirm":                             {"true"},
+		"description":                         {req.Description},
+		"receipt_email":                       {req.CustomerEmail},
+		"metadata[" + orderIDMetadata + "]":   {req.OrderID},
+		"metadata[" + productIDMetadata + "]": {req.ProductID},
+	}
+
+	var intent paymentIntent
+
+	// the order is charged once however many times the charge is repeated
+	body, err := pm.do(ctx, http.MethodPost, "/payment_intents", form, req.OrderID)
+	if err != nil {
+		apiErr, ok := err.(*requestError)
+		if !ok || apiErr.body.Error.PaymentIntent == nil {
+			return nil, err
+		}
+
+		return apiErr.body.Error.PaymentIntent.toPayment(apiErr.raw), nil
+	}
+
+	if err = json.Unmarshal(body, &intent); err != nil {
+		return nil, err
+	}
+
+	return intent.toPayment(body), nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header, the events which are not about
+// payment intents are ignored.
+func (pm *PaymentsManager) VerifyWebhook(_ context.Context, header http.Header, body []byte) (*payments.Payment, error) {
+	if err := pm.verifySignature(header.Get(signatureHeader), body, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var e event
+
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(e.Type, "payment_intent.") || e.Data.Object.Object != "payment_intent" {
+		return nil, payments.ErrIgnoredEvent
+	}
+
+	return e.Data.Object.toPayment(body), nil
+}
+
+// Refund refunds the payment intent, the amount may be less than the paid one.
+func (pm *PaymentsManager) Refund(ctx context.Context, req payments.RefundRequest) (*payments.Refund, error) {
+	paymentID := req.PaymentID
+
+	if paymentID == "" {
+		payment, err := pm.GetPaymentStatus(ctx, req.OrderID)
+		if err != nil {
+			return nil, err
+		}
+
+		paymentID = payment.PaymentID
+	}
+
+	form := url.Values{
+		"payment_intent":                    {paymentID},
+		"amount":                            {strconv.FormatInt(req.Amount, 10)},
+		"metadata[" + orderIDMetadata + "]": {req.OrderID},
+	}
+
+	if req.Comment != "" {
+		form.Set("metadata[comment]", req.Comment)
+	}
+
+	var r refund
+
+	if err := pm.call(ctx, http.MethodPost, "/refunds", form, req.RefundID, &r); err != nil {
+		return nil, err
+	}
+
+	result := &payments.Refund{
+		ID:     r.ID,
+		Amount: r.Amount,
+	}
+
+	switch r.Status {
+	case "succeeded":
+		result.Status = payments.ApprovedStatus
+	case "failed", "canceled":
+		result.Status = payments.DeclinedStatus
+	default:
+		result.Status = payments.PendingStatus
+	}
+
+	return result, nil
+}
+
+// GetPaymentStatus returns the latest payment intent of the order, its charge is expanded for the refunded amount.
+func (pm *PaymentsManager) GetPaymentStatus(ctx context.Context, orderID string) (*payments.Payment, error) {
+	query := url.Values{
+		"query":    {fmt.Sprintf("metadata['%s']:'%s'", orderIDMetadata, orderID)},
+		"expand[]": {"data.latest_charge"},
+	}
+
+	body, err := pm.do(ctx, http.MethodGet, "/payment_intents/search?"+query.Encode(), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var result searchResult
+
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, payments.ErrPaymentUnknown
+	}
+
+	sort.Slice(result.Data, func(i, j int) bool {
+		return result.Data[i].Created > result.Data[j].Created
+	})
+
+	raw, err := json.Marshal(result.Data[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Data[0].toPayment(raw), nil
+}
+
+// verifySignature checks the header of the form t=timestamp,v1=signature,...
+// the signature is HMAC-SHA256 of "timestamp.body" with the webhook secret.
+func (pm *PaymentsManager) verifySignature(header string, body []byte, now time.Time) error {
+	var (
+		timestamp  string
+		signatures []string
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return payments.ErrInvalidSignature
+	}
+
+	if age := now.Unix() - signedAt; age > webhookTolerance || age < -webhookTolerance {
+		return payments.ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(pm.cfg.WebhookSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		decoded, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+
+	return payments.ErrInvalidSignature
+}
+
+// requestError is returned for the responses with an error status, body is the decoded error.
+type requestError struct {
+	status int
+	body   apiError
+	raw    []byte
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("stripe: %d %s", e.status, e.body.Error.Message)
+}
+
+func (pm *PaymentsManager) call(ctx context.Context, method, path string, form url.Values, idempotencyKey string, dst interface{}) error {
+	body, err := pm.do(ctx, method, path, form, idempotencyKey)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, dst)
+}
+
+// do sends the request, a request with the idempotency key is made only once by Stripe
+// and its repeats get the response of the first one.
+func (pm *PaymentsManager) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string) ([]byte, error) {
+	var reqBody io.Reader
+	if form != nil {
+		reqBody = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, pm.apiURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(pm.cfg.SecretKey, "")
+
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := pm.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		reqErr := &requestError{status: resp.StatusCode, raw: body}
+		_ = json.Unmarshal(body, &reqErr.body)
+
+		return nil, reqErr
+	}
+
+	return body, nil
+}
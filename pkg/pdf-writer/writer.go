@@ -0,0 +1,310 @@
+package pdf_writer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Font is one of the standard Type 1 fonts, they are built into every reader so nothing is embedded.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = map[Font]string{
+	Helvetica:     "Helvetica",
+	HelveticaBold: "Helvetica-Bold",
+}
+
+// ErrUnsupportedText is returned for the text with the characters out of WinAnsiEncoding,
+// the standard fonts have no glyphs for them.
+var ErrUnsupportedText = errors.New("text has characters the standard fonts can not show")
+
+// Document is a minimal PDF 1.4 writer for text documents such as invoices.
+// The text is encoded in WinAnsiEncoding, the document with the characters out of it is not written.
+type Document struct {
+	title     string
+	createdAt time.Time
+	pages     []*Page
+}
+
+func New(title string, createdAt time.Time) *Document {
+	return &Document{
+		title:     title,
+		createdAt: createdAt,
+	}
+}
+
+func (d *Document) AddPage() *Page {
+	page := &Page{}
+	d.pages = append(d.pages, page)
+
+	return page
+}
+
+// Page is drawn with the coordinates in points from the top left corner.
+type Page struct {
+	content bytes.Buffer
+	// err is the first text that can not be encoded, it is returned when the document is written.
+	err error
+}
+
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	if !Encodable(text) && p.err == nil {
+		p.err = fmt.Errorf("%w: %q", ErrUnsupportedText, text)
+	}
+
+	fmt.Fprintf(
+		&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		font+1, formatNumber(size), formatNumber(x), formatNumber(PageHeight-y), escapeText(text),
+	)
+}
+
+// TextRight draws the text so that it ends at x.
+func (p *Page) TextRight(x, y float64, font Font, size float64, text string) {
+	p.Text(x-TextWidth(font, size, text), y, font, size, text)
+}
+
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(
+		&p.content, "%s w %s %s m %s %s l S\n",
+		formatNumber(width), formatNumber(x1), formatNumber(PageHeight-y1), formatNumber(x2), formatNumber(PageHeight-y2),
+	)
+}
+
+// FillRect fills the rectangle with the gray level from 0 (black) to 1 (white), y is its top edge.
+func (p *Page) FillRect(x, y, width, height, gray float64) {
+	fmt.Fprintf(
+		&p.content, "%s g %s %s %s %s re f 0 g\n",
+		formatNumber(gray), formatNumber(x), formatNumber(PageHeight-y-height), formatNumber(width), formatNumber(height),
+	)
+}
+
+// TextWidth returns the width of the text in points.
+func TextWidth(font Font, size float64, text string) float64 {
+	widths := helveticaWidths
+	if font == HelveticaBold {
+		widths = helveticaBoldWidths
+	}
+
+	var units int
+
+	for _, r := range text {
+		if r >= firstWidthChar && int(r-firstWidthChar) < len(widths) {
+			units += widths[r-firstWidthChar]
+		} else {
+			units += defaultWidth
+		}
+	}
+
+	return float64(units) * size / 1000
+}
+
+// Bytes renders the document, it has at least one page.
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the document, the objects are the catalog, the page tree, the fonts,
+// the info and the page with its content stream for each page.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pages := d.pages
+	if len(pages) == 0 {
+		pages = []*Page{{}}
+	}
+
+	if !Encodable(d.title) {
+		return 0, fmt.Errorf("%w: %q", ErrUnsupportedText, d.title)
+	}
+
+	for _, page := range pages {
+		if page.err != nil {
+			return 0, page.err
+		}
+	}
+
+	const (
+		catalogObject = 1
+		pagesObject   = 2
+		fontsObject   = 3
+	)
+
+	infoObject := fontsObject + len(fontNames)
+	firstPageObject := infoObject + 1
+
+	pw := &pdfWriter{}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	pw.object(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+2*i)
+	}
+
+	pw.object(pagesObject, fmt.Sprintf(
+		"<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages),
+	))
+
+	fonts := make([]string, len(fontNames))
+
+	for font := Helvetica; int(font) < len(fontNames); font++ {
+		pw.object(fontsObject+int(font), fmt.Sprintf(
+			"<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[font],
+		))
+
+		fonts[font] = fmt.Sprintf("/F%d %d 0 R", font+1, fontsObject+int(font))
+	}
+
+	pw.object(infoObject, fmt.Sprintf(
+		"<< /Title (%s) /Producer (CheckIT) /CreationDate (D:%s) >>",
+		escapeText(d.title), d.createdAt.UTC().Format("20060102150405Z"),
+	))
+
+	for i, page := range pages {
+		pageObject := firstPageObject + 2*i
+
+		pw.object(pageObject, fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pagesObject, formatNumber(PageWidth), formatNumber(PageHeight), strings.Join(fonts, " "), pageObject+1,
+		))
+
+		pw.stream(pageObject+1, page.content.Bytes())
+	}
+
+	pw.trailer(catalogObject, infoObject)
+
+	if pw.err != nil {
+		return 0, pw.err
+	}
+
+	n, err := w.Write(pw.buf.Bytes())
+
+	return int64(n), err
+}
+
+// pdfWriter keeps the offsets of the objects for the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+	err     error
+}
+
+func (pw *pdfWriter) printf(format string, args ...interface{}) {
+	if pw.err != nil {
+		return
+	}
+
+	_, pw.err = fmt.Fprintf(&pw.buf, format, args...)
+}
+
+func (pw *pdfWriter) begin(id int) {
+	for len(pw.offsets) < id {
+		pw.offsets = append(pw.offsets, 0)
+	}
+
+	pw.offsets[id-1] = pw.buf.Len()
+	pw.printf("%d 0 obj\n", id)
+}
+
+func (pw *pdfWriter) object(id int, dict string) {
+	pw.begin(id)
+	pw.printf("%s\nendobj\n", dict)
+}
+
+func (pw *pdfWriter) stream(id int, data []byte) {
+	pw.begin(id)
+	pw.printf("<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(data), data)
+}
+
+func (pw *pdfWriter) trailer(root, info int) {
+	xref := pw.buf.Len()
+
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+
+	for _, offset := range pw.offsets {
+		pw.printf("%010d 00000 n \n", offset)
+	}
+
+	pw.printf(
+		"trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(pw.offsets)+1, root, info, xref,
+	)
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
+
+// Encodable reports whether the text can be written with the standard fonts.
+func Encodable(text string) bool {
+	for _, r := range text {
+		if _, ok := winAnsiByte(r); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// escapeText encodes the text in WinAnsiEncoding and escapes it for a literal string,
+// the text is checked with Encodable before.
+func escapeText(text string) string {
+	var b strings.Builder
+
+	for _, r := range text {
+		c, _ := winAnsiByte(r)
+
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < ' ' || c > '~' {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// winAnsiSpecials are the characters of WinAnsiEncoding which differ from Latin-1.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+func winAnsiByte(r rune) (byte, bool) {
+	switch {
+	case r == '\t':
+		return ' ', true
+	case r >= ' ' && r <= '~', r >= 0xa0 && r <= 0xff:
+		return byte(r), true
+	}
+
+	c, ok := winAnsiSpecials[r]
+
+	return c, ok
+}
@@ -2,6 +2,7 @@ package token_manager
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -13,55 +14,145 @@ var (
 	ErrInvalidSignMethod = errors.New("unexpected signing method")
 	ErrInvalidClaims     = errors.New("invalid token claims")
 	ErrTokenExpired      = errors.New("token is expired")
+	ErrNoSigningKey      = errors.New("no signing key")
+	ErrUnknownKey        = errors.New("unknown signing key")
 )
 
+// JWTManager signs access tokens either with the HS256 sign key or, for asymmetric algorithms,
+// with the active key of the key ring. Tokens of any key of the ring are accepted, so a rotated key
+// keeps verifying the tokens it has signed. With an asymmetric algorithm HS256 tokens are accepted
+// only until hs256Until, the migration window for the tokens signed before the switch.
 type JWTManager struct {
-	signKey string
+	algorithm  string
+	signKey    string
+	issuer     string
+	audience   string
+	hs256Until time.Time
+
+	mu        sync.RWMutex
+	activeKey *Key
+	keys      map[string]*Key
 }
+
 type Claims struct {
-	SessionID string
-	TokenID   string
-	UserID    string
-	PlanID    string
+	SessionID   string
+	TokenID     string
+	UserID      string
+	PlanID      string
+	Role        string   `json:",omitempty"`
+	Permissions []string `json:",omitempty"`
 
 	jwt.StandardClaims
 }
 
-func NewJWT(signKey string) (JWTManager, error) {
-	if signKey == "" {
-		return JWTManager{}, errors.New("empty signing key")
+func NewJWT(algorithm, signKey, issuer, audience string, hs256Until time.Time) (*JWTManager, error) {
+	if algorithm == "" {
+		algorithm = AlgorithmHS256
+	}
+
+	if algorithm != AlgorithmHS256 && !IsAsymmetric(algorithm) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	if algorithm == AlgorithmHS256 && signKey == "" {
+		return nil, errors.New("empty signing key")
 	}
 
-	return JWTManager{signKey: signKey}, nil
+	return &JWTManager{
+		algorithm:  algorithm,
+		signKey:    signKey,
+		issuer:     issuer,
+		audience:   audience,
+		hs256Until: hs256Until,
+		keys:       map[string]*Key{},
+	}, nil
+}
+
+// Algorithm returns the algorithm new tokens are signed with.
+func (tm *JWTManager) Algorithm() string {
+	return tm.algorithm
 }
 
-func (tm JWTManager) GenerateAccessToken(userID, planID, sessionID, tokenID string, ttl int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+// SetKeys replaces the key ring, active signs new tokens and must be one of keys.
+func (tm *JWTManager) SetKeys(active *Key, keys []*Key) {
+	keyRing := make(map[string]*Key, len(keys))
+	for i := range keys {
+		keyRing[keys[i].ID] = keys[i]
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.activeKey = active
+	tm.keys = keyRing
+}
+
+// JWKS returns public keys of the key ring.
+func (tm *JWTManager) JWKS() ([]JSONWebKey, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	jwks := make([]JSONWebKey, 0, len(tm.keys))
+
+	for _, key := range tm.keys {
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks, nil
+}
+
+func (tm *JWTManager) GenerateAccessToken(
+	userID, planID, sessionID, tokenID, role string,
+	permissions []string,
+	ttl int64,
+) (string, error) {
+	now := time.Now()
+
+	claims := Claims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Second * time.Duration(ttl)).Unix(),
+			Issuer:    tm.issuer,
+			Audience:  tm.audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(time.Second * time.Duration(ttl)).Unix(),
 		},
-		UserID:    userID,
-		SessionID: sessionID,
-		TokenID:   tokenID,
-		PlanID:    planID,
-	})
+		UserID:      userID,
+		SessionID:   sessionID,
+		TokenID:     tokenID,
+		PlanID:      planID,
+		Role:        role,
+		Permissions: permissions,
+	}
 
-	return token.SignedString([]byte(tm.signKey))
+	if !IsAsymmetric(tm.algorithm) {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tm.signKey))
+	}
+
+	tm.mu.RLock()
+	activeKey := tm.activeKey
+	tm.mu.RUnlock()
+
+	if activeKey == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(activeKey.signingMethod(), claims)
+	token.Header["kid"] = activeKey.ID
+
+	return token.SignedString(activeKey.PrivateKey)
 }
 
-func (tm JWTManager) GenerateRefreshToken() (string, error) {
+func (tm *JWTManager) GenerateRefreshToken() (string, error) {
 	return generateRandomString(refreshTokenLength)
 }
 
-func (tm JWTManager) Parse(accessToken string) (map[string]interface{}, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidSignMethod
-		}
-
-		return []byte(tm.signKey), nil
-	})
-
+func (tm *JWTManager) Parse(accessToken string) (map[string]interface{}, error) {
+	token, err := jwt.ParseWithClaims(accessToken, &Claims{}, tm.verificationKey)
 	if err != nil {
 		return nil, err
 	}
@@ -71,12 +162,57 @@ func (tm JWTManager) Parse(accessToken string) (map[string]interface{}, error) {
 		return nil, ErrInvalidClaims
 	}
 
+	if tm.issuer != "" && !claims.VerifyIssuer(tm.issuer, true) {
+		return nil, ErrInvalidClaims
+	}
+
+	if tm.audience != "" && !claims.VerifyAudience(tm.audience, true) {
+		return nil, ErrInvalidClaims
+	}
+
 	claimsMap := map[string]interface{}{
-		"session_id": claims.SessionID,
-		"user_id":    claims.UserID,
-		"token_id":   claims.TokenID,
-		"plan_id":    claims.PlanID,
+		"session_id":  claims.SessionID,
+		"user_id":     claims.UserID,
+		"token_id":    claims.TokenID,
+		"plan_id":     claims.PlanID,
+		"role":        claims.Role,
+		"permissions": claims.Permissions,
 	}
 
 	return claimsMap, nil
 }
+
+func (tm *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if tm.signKey == "" {
+			return nil, ErrInvalidSignMethod
+		}
+
+		if tm.algorithm != AlgorithmHS256 && !time.Now().Before(tm.hs256Until) {
+			return nil, ErrInvalidSignMethod
+		}
+
+		return []byte(tm.signKey), nil
+
+	case *jwt.SigningMethodRSA, *SigningMethodEdDSA:
+		kid, _ := token.Header["kid"].(string)
+
+		tm.mu.RLock()
+		key, ok := tm.keys[kid]
+		tm.mu.RUnlock()
+
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+
+		if key.signingMethod().Alg() != token.Method.Alg() {
+			return nil, ErrInvalidSignMethod
+		}
+
+		return key.PrivateKey.Public(), nil
+
+	default:
+		return nil, ErrInvalidSignMethod
+	}
+}
@@ -0,0 +1,70 @@
+package token_manager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrEmptyEncryptionKey = errors.New("empty keys encryption key")
+	ErrInvalidCiphertext  = errors.New("invalid encrypted private key")
+)
+
+// KeyCipher encrypts the PEM encoded private keys before they are stored with AES-256-GCM,
+// the AES key is derived from the secret with SHA-256.
+type KeyCipher struct {
+	aead cipher.AEAD
+}
+
+func NewKeyCipher(secret string) (*KeyCipher, error) {
+	if secret == "" {
+		return nil, ErrEmptyEncryptionKey
+	}
+
+	key := sha256.Sum256([]byte(secret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyCipher{aead: aead}, nil
+}
+
+// Encrypt returns the nonce followed by the sealed private key, base64 encoded.
+func (c *KeyCipher) Encrypt(privateKeyPEM string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(privateKeyPEM), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *KeyCipher) Decrypt(encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	privateKeyPEM, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(privateKeyPEM), nil
+}
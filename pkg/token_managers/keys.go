@@ -0,0 +1,162 @@
+package token_manager
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	rsaKeySize = 2048
+
+	pemPrivateKeyType = "PRIVATE KEY"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidPrivateKey    = errors.New("invalid private key")
+)
+
+// Key is an asymmetric key identified by ID, the kid header of the tokens it signs.
+type Key struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+}
+
+// JSONWebKey is the public part of a key in the RFC 7517 format.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// IsAsymmetric reports whether the algorithm signs with a key pair.
+func IsAsymmetric(algorithm string) bool {
+	return algorithm == AlgorithmRS256 || algorithm == AlgorithmEdDSA
+}
+
+// GenerateKey creates a new key pair for the algorithm.
+func GenerateKey(id, algorithm string) (*Key, error) {
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch algorithm {
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:         id,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// MarshalPrivateKey encodes the private key as a PKCS #8 PEM block.
+func MarshalPrivateKey(key *Key) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: pemPrivateKeyType, Bytes: der})), nil
+}
+
+// ParseKey decodes the PKCS #8 PEM encoded private key and checks it matches the algorithm.
+func ParseKey(id, algorithm, privateKeyPEM string) (*Key, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil || block.Type != pemPrivateKeyType {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var privateKey crypto.Signer
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if algorithm != AlgorithmRS256 {
+			return nil, ErrInvalidPrivateKey
+		}
+
+		privateKey = key
+	case ed25519.PrivateKey:
+		if algorithm != AlgorithmEdDSA {
+			return nil, ErrInvalidPrivateKey
+		}
+
+		privateKey = key
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return &Key{
+		ID:         id,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return SigningMethodEd25519
+	}
+
+	return jwt.SigningMethodRS256
+}
+
+// JWK returns the public key in the JWK format.
+func (k *Key) JWK() (JSONWebKey, error) {
+	jwk := JSONWebKey{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Algorithm,
+	}
+
+	switch publicKey := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JSONWebKey{}, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, publicKey)
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	contentTypeApplicationJson = "application/json"
+
+	EventHeader     = "X-CheckIT-Event"
+	TimestampHeader = "X-CheckIT-Timestamp"
+	SignatureHeader = "X-CheckIT-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	ErrDeliveryFailed   = errors.New("webhook delivery failed")
+	ErrInsecureURL      = errors.New("webhook url must be an absolute https url")
+	ErrForbiddenAddress = errors.New("webhook host resolves to an address which is not public")
+)
+
+// Sender delivers signed webhooks and retries failed deliveries with exponential backoff and jitter.
+type Sender struct {
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+}
+
+func New(timeout time.Duration, maxAttempts int, baseDelay time.Duration) *Sender {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: controlAddress,
+	}
+
+	// the proxy is not used as the dialer checks only the address it connects to
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			// redirects are not followed, 3xx responses are failed deliveries
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+	}
+}
+
+// ValidateURL accepts absolute https URLs whose host is not an internal IP address,
+// the host names are checked when they are resolved for the delivery.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return ErrInsecureURL
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublicIP(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// controlAddress refuses the connections to internal addresses, it runs after the host is
+// resolved so the names that resolve or rebind to such addresses are refused too.
+func controlAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// reservedNetworks are the global unicast ranges which are not reachable on the internet,
+// they may still route to the hosts of the provider or its network.
+var reservedNetworks = parseNetworks(
+	"0.0.0.0/8",      // this network
+	"100.64.0.0/10",  // carrier-grade NAT
+	"192.0.0.0/24",   // IETF protocol assignments
+	"198.18.0.0/15",  // benchmarking
+	"240.0.0.0/4",    // reserved
+	"64:ff9b::/96",   // NAT64, embeds IPv4 addresses
+	"64:ff9b:1::/48", // local NAT64
+	"fec0::/10",      // deprecated site-local
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks[i] = network
+	}
+
+	return networks
+}
+
+// isPublicIP accepts only global unicast addresses outside of the private and reserved ranges.
+// IPv4-mapped IPv6 addresses are checked as the IPv4 addresses they are mapped to.
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Send posts payload to url. The signature is HMAC-SHA256 of "<timestamp>.<payload>" keyed with secret.
+func (s *Sender) Send(ctx context.Context, url, secret, event string, payload []byte) error {
+	if err := ValidateURL(url); err != nil {
+		return err
+	}
+
+	var err error
+
+	for attempt := 0; attempt < s.maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.backoff(attempt)):
+			}
+		}
+
+		var retry bool
+
+		retry, err = s.send(ctx, url, secret, event, payload)
+		if err == nil || !retry {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrDeliveryFailed, err)
+}
+
+func (s *Sender) send(ctx context.Context, url, secret, event string, payload []byte) (bool, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", contentTypeApplicationJson)
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, signaturePrefix+Sign(secret, timestamp, payload))
+
+	resp, err := s.client.Do(req)
+	if errors.Is(err, ErrForbiddenAddress) {
+		return false, ErrForbiddenAddress
+	}
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return false, nil
+	}
+
+	err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError, err
+}
+
+// backoff returns baseDelay * 2^(attempt-1) plus up to 50% of random jitter.
+func (s *Sender) backoff(attempt int) time.Duration {
+	delay := s.baseDelay << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1)) // nolint: gosec
+}
+
+// Sign returns hex encoded HMAC-SHA256 of "<timestamp>.<payload>".
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestControlAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{name: "public IPv4", address: "93.184.216.34:443"},
+		{name: "public IPv6", address: "[2606:2800:220:1:248:1893:25c8:1946]:443"},
+		{name: "IPv4-mapped public", address: "[::ffff:93.184.216.34]:443"},
+		{name: "loopback", address: "127.0.0.1:443", wantErr: true},
+		{name: "IPv6 loopback", address: "[::1]:443", wantErr: true},
+		{name: "private 10/8", address: "10.0.0.1:443", wantErr: true},
+		{name: "private 172.16/12", address: "172.16.5.4:443", wantErr: true},
+		{name: "private 192.168/16", address: "192.168.1.1:443", wantErr: true},
+		{name: "IPv6 unique local", address: "[fd00::1]:443", wantErr: true},
+		{name: "link-local", address: "169.254.169.254:443", wantErr: true},
+		{name: "IPv6 link-local", address: "[fe80::1]:443", wantErr: true},
+		{name: "unspecified", address: "0.0.0.0:443", wantErr: true},
+		{name: "IPv6 unspecified", address: "[::]:443", wantErr: true},
+		{name: "this network", address: "0.1.2.3:443", wantErr: true},
+		{name: "carrier-grade NAT", address: "100.64.0.1:443", wantErr: true},
+		{name: "carrier-grade NAT upper bound", address: "100.127.255.254:443", wantErr: true},
+		{name: "reserved", address: "240.0.0.1:443", wantErr: true},
+		{name: "broadcast", address: "255.255.255.255:443", wantErr: true},
+		{name: "multicast", address: "224.0.0.1:443", wantErr: true},
+		{name: "IPv6 multicast", address: "[ff02::1]:443", wantErr: true},
+		{name: "IPv4-mapped loopback", address: "[::ffff:127.0.0.1]:443", wantErr: true},
+		{name: "IPv4-mapped private", address: "[::ffff:10.0.0.1]:443", wantErr: true},
+		{name: "IPv4-mapped link-local", address: "[::ffff:169.254.169.254]:443", wantErr: true},
+		{name: "NAT64", address: "[64:ff9b::a00:1]:443", wantErr: true},
+		{name: "host name", address: "example.com:443", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			err := controlAddress("tcp", tt.address, nil)
+			if !tt.wantErr && err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrForbiddenAddress) {
+				t.Fatalf("err = %v, want %v", err, ErrForbiddenAddress)
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "public host name", url: "https://hooks.example.com/faker"},
+		{name: "public address", url: "https://93.184.216.34/faker"},
+		{name: "http", url: "http://hooks.example.com/faker", wantErr: ErrInsecureURL},
+		{name: "relative", url: "/faker", wantErr: ErrInsecureURL},
+		{name: "loopback", url: "https://127.0.0.1/faker", wantErr: ErrForbiddenAddress},
+		{name: "carrier-grade NAT", url: "https://100.100.100.100/faker", wantErr: ErrForbiddenAddress},
+		{name: "IPv4-mapped private", url: "https://[::ffff:192.168.0.1]/faker", wantErr: ErrForbiddenAddress},
+		{name: "multicast", url: "https://[ff05::1]/faker", wantErr: ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateURL(tt.url); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsPublicIPMappedAddress(t *testing.T) {
+	// the 16 byte form of an IPv4 address is checked as the IPv4 address
+	if isPublicIP(net.ParseIP("100.64.0.1").To16()) {
+		t.Fatal("IPv4-mapped carrier-grade NAT address is public")
+	}
+}